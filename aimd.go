@@ -0,0 +1,144 @@
+package apns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AIMD limits how many requests Client2 has in flight, finding the limit
+// on its own: it grows by Increase per round of successful requests and
+// shrinks by the factor Decrease when APNS pushes back, by failing a
+// request, answering 429 or 5xx, or answering slower than SlowAfter. This
+// keeps throughput near what a deployment's connection and APNS allow
+// without tuning the limit by hand.
+type AIMD struct {
+	// Min and Max bound the limit, which starts at Min.
+	Min int
+	Max int
+
+	// Increase is added to the limit after a limit's worth of successful
+	// requests; 1 if zero. Decrease multiplies it on pushback; 0.5 if
+	// zero.
+	Increase float64
+	Decrease float64
+
+	// SlowAfter, if positive, takes requests slower than it as pushback.
+	SlowAfter time.Duration
+
+	mu           sync.Mutex
+	limit        float64
+	inFlight     int
+	lastDecrease time.Time
+	changed      chan struct{}
+}
+
+// NewAIMD returns an AIMD keeping between min and max requests in flight.
+func NewAIMD(min, max int) *AIMD {
+	return &AIMD{Min: min, Max: max}
+}
+
+// Limit returns how many requests may currently be in flight.
+func (a *AIMD) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.init()
+	return int(a.limit)
+}
+
+func (a *AIMD) init() {
+	if a.changed == nil {
+		a.changed = make(chan struct{})
+	}
+	if a.limit == 0 {
+		a.limit = float64(a.Min)
+		if a.limit < 1 {
+			a.limit = 1
+		}
+	}
+}
+
+// acquire waits until a request may be sent, or ctx is done.
+func (a *AIMD) acquire(ctx context.Context) error {
+	for {
+		a.mu.Lock()
+		a.init()
+		if a.inFlight < int(a.limit) {
+			a.inFlight++
+			a.mu.Unlock()
+			return nil
+		}
+		changed := a.changed
+		a.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// release ends a request sent at start and adjusts the limit by how it
+// went. Pushback only shrinks the limit once per SlowAfter, or second, so
+// a burst of failures from one overloaded moment doesn't collapse it.
+func (a *AIMD) release(start time.Time, r Response, err error) {
+	now := time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.inFlight--
+	close(a.changed)
+	a.changed = make(chan struct{})
+
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if a.pushback(now.Sub(start), r, err) {
+		cooldown := a.SlowAfter
+		if cooldown <= 0 {
+			cooldown = time.Second
+		}
+		if now.Sub(a.lastDecrease) < cooldown {
+			return
+		}
+		a.lastDecrease = now
+
+		decrease := a.Decrease
+		if decrease <= 0 || decrease >= 1 {
+			decrease = 0.5
+		}
+		a.limit *= decrease
+	} else {
+		increase := a.Increase
+		if increase <= 0 {
+			increase = 1
+		}
+		a.limit += increase / a.limit
+	}
+
+	if min := float64(a.Min); a.limit < min {
+		a.limit = min
+	}
+	if a.limit < 1 {
+		a.limit = 1
+	}
+	if max := float64(a.Max); a.Max > 0 && a.limit > max {
+		a.limit = max
+	}
+}
+
+func (a *AIMD) pushback(latency time.Duration, r Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	if r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= 500 {
+		return true
+	}
+	return a.SlowAfter > 0 && latency > a.SlowAfter
+}
@@ -0,0 +1,135 @@
+package apns_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("AIMD", func() {
+	var s *mockHTTP2Server
+
+	BeforeEach(func() {
+		s = newMockHTTP2Server()
+	})
+
+	AfterEach(func() {
+		s.Close()
+	})
+
+	send := func(c *apns.Client2) (apns.Response, error) {
+		n := apns.NewNotification()
+		n.DeviceToken = "abcd"
+		n.Payload.APS.Alert.Body = "hi"
+		return c.SendSync(context.Background(), n)
+	}
+
+	It("should start at Min and grow with successes up to Max", func() {
+		c := newTestClient2(s)
+		c.Concurrency = apns.NewAIMD(2, 3)
+		Expect(c.Concurrency.Limit()).To(Equal(2))
+
+		for i := 0; i < 10; i++ {
+			_, err := send(c)
+			Expect(err).To(BeNil())
+		}
+		Expect(c.Concurrency.Limit()).To(Equal(3))
+	})
+
+	It("should shrink on throttling, once per cooldown", func() {
+		c := newTestClient2(s)
+		c.Concurrency = &apns.AIMD{Min: 1, Max: 8, SlowAfter: 50 * time.Millisecond}
+		c.TokenCooldown = 0 // every send goes to the same token
+		for i := 0; i < 100; i++ {
+			send(c)
+		}
+		Expect(c.Concurrency.Limit()).To(Equal(8))
+
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"reason":"TooManyRequests"}`))
+		}
+
+		send(c)
+		send(c)
+		Expect(c.Concurrency.Limit()).To(Equal(4))
+
+		time.Sleep(60 * time.Millisecond)
+		send(c)
+		Expect(c.Concurrency.Limit()).To(Equal(2))
+	})
+
+	It("should hold sends over the limit until one finishes", func() {
+		release := make(chan struct{})
+		var once sync.Once
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			once.Do(func() { <-release })
+		}
+
+		c := newTestClient2(s)
+		c.Concurrency = apns.NewAIMD(1, 1)
+
+		first := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			send(c)
+			close(first)
+		}()
+		Eventually(func() int {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return len(s.requests)
+		}).Should(Equal(1))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		n := apns.NewNotification()
+		n.DeviceToken = "abcd"
+		n.Payload.APS.Alert.Body = "hi"
+		_, err := c.SendSync(ctx, n)
+		Expect(err).To(Equal(context.DeadlineExceeded))
+
+		close(release)
+		<-first
+		_, err = send(c)
+		Expect(err).To(BeNil())
+	})
+
+	It("should not hold PriorityLane pushes back", func() {
+		release := make(chan struct{})
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("apns-push-type") != string(apns.PushTypeVoIP) {
+				<-release
+			}
+		}
+		defer close(release)
+
+		c := newTestClient2(s)
+		c.Concurrency = apns.NewAIMD(1, 1)
+		c.PriorityLane = []apns.PushType{apns.PushTypeVoIP}
+
+		go func() {
+			defer GinkgoRecover()
+			send(c)
+		}()
+		Eventually(func() int {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return len(s.requests)
+		}).Should(Equal(1))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		n := apns.NewNotification()
+		n.DeviceToken = "abcd"
+		n.PushType = apns.PushTypeVoIP
+		n.Payload.APS.Alert.Body = "hi"
+		_, err := c.SendSync(ctx, n)
+		Expect(err).To(BeNil())
+	})
+})
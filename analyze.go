@@ -0,0 +1,156 @@
+package apns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PayloadReport says where the bytes of a notification's payload go, for
+// working out why it exceeds its limit. It is returned by AnalyzePayload.
+type PayloadReport struct {
+	// Size is the payload as sent, Limit the largest APNS accepts for the
+	// push type and Over how far Size exceeds it, or 0.
+	Size  int
+	Limit int
+	Over  int
+
+	// Fields lists the payload's fields, largest first.
+	Fields []FieldUsage
+
+	// Suggestions lists ways to save bytes, biggest saving first.
+	Suggestions []Suggestion
+}
+
+// FieldUsage is the size of one payload field: its key, value and the
+// punctuation separating it from the others. Path names the field by its
+// keys, such as "aps.alert.loc-args" or a custom key at the top level.
+type FieldUsage struct {
+	Path string
+	Size int
+}
+
+// Suggestion is a way to make a payload smaller. Path is the field it
+// applies to, or empty if it applies to the whole payload.
+type Suggestion struct {
+	Path    string
+	Savings int
+	Advice  string
+}
+
+// htmlEscapes are what encoding/json writes for <, > and &, six bytes for
+// one.
+var htmlEscapes = [][]byte{[]byte(`\u003c`), []byte(`\u003e`), []byte(`\u0026`)}
+
+// AnalyzePayload reports which fields of n's payload use its byte budget
+// and how it could be made smaller, such as by shortening custom keys.
+// Field sizes are as encoded, so they add up to roughly the payload size;
+// the braces and keys of the aps and alert dictionaries aren't counted.
+func AnalyzePayload(n Notification) (PayloadReport, error) {
+	j, err := n.payloadBytes()
+	if err != nil {
+		return PayloadReport{}, err
+	}
+
+	r := PayloadReport{Size: len(j), Limit: PayloadLimit(n.PushType)}
+	if r.Size > r.Limit {
+		r.Over = r.Size - r.Limit
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, j); err != nil {
+		return PayloadReport{}, err
+	}
+	if saved := len(j) - compact.Len(); saved > 0 {
+		r.suggest("", saved, "remove the whitespace from the precomputed payload")
+	}
+
+	escapes := 0
+	for _, e := range htmlEscapes {
+		escapes += bytes.Count(compact.Bytes(), e)
+	}
+	if escapes > 0 {
+		r.suggest("", escapes*5, "precompute the payload with an encoder that doesn't escape <, > and &")
+	}
+
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(compact.Bytes(), &top); err != nil {
+		return PayloadReport{}, err
+	}
+	r.addFields("", top)
+
+	sort.Slice(r.Fields, func(a, b int) bool {
+		if r.Fields[a].Size != r.Fields[b].Size {
+			return r.Fields[a].Size > r.Fields[b].Size
+		}
+		return r.Fields[a].Path < r.Fields[b].Path
+	})
+
+	if r.Over > 0 {
+		r.suggestTruncation()
+	}
+	sort.Slice(r.Suggestions, func(a, b int) bool {
+		if r.Suggestions[a].Savings != r.Suggestions[b].Savings {
+			return r.Suggestions[a].Savings > r.Suggestions[b].Savings
+		}
+		return r.Suggestions[a].Path < r.Suggestions[b].Path
+	})
+
+	return r, nil
+}
+
+// addFields adds the fields of the dictionary at prefix, descending into
+// aps and its alert. Custom values are counted whole.
+func (r *PayloadReport) addFields(prefix string, dict map[string]json.RawMessage) {
+	for k, v := range dict {
+		key, _ := json.Marshal(k)
+		path := prefix + k
+
+		if path == "aps" || path == "aps.alert" {
+			var sub map[string]json.RawMessage
+			if json.Unmarshal(v, &sub) == nil {
+				r.addFields(path+".", sub)
+				continue
+			}
+		}
+
+		r.Fields = append(r.Fields, FieldUsage{Path: path, Size: len(key) + 1 + len(v) + 1})
+
+		if prefix == "" && path != "mdm" && len(k) > 2 {
+			r.suggest(path, len(k)-1, "shorten the custom key to one character")
+		}
+	}
+}
+
+// suggestTruncation suggests cutting the largest text field by as much as
+// the payload is over its limit.
+func (r *PayloadReport) suggestTruncation() {
+	for _, f := range r.Fields {
+		if !truncatable(f.Path) {
+			continue
+		}
+
+		savings := r.Over
+		if savings > f.Size {
+			savings = f.Size
+		}
+		r.suggest(f.Path, savings, fmt.Sprintf("truncate it by %d bytes", r.Over))
+		return
+	}
+}
+
+// truncatable reports whether the field at path holds text that can be
+// cut: alert text or a custom value.
+func truncatable(path string) bool {
+	switch path {
+	case "aps.alert", "aps.alert.title", "aps.alert.subtitle", "aps.alert.body", "aps.alert.loc-args":
+		return true
+	}
+	return !strings.Contains(path, ".") && path != "aps" && path != "mdm"
+}
+
+func (r *PayloadReport) suggest(path string, savings int, advice string) {
+	r.Suggestions = append(r.Suggestions, Suggestion{Path: path, Savings: savings, Advice: advice})
+}
@@ -0,0 +1,57 @@
+package apns_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("AnalyzePayload", func() {
+	It("should report fields largest first", func() {
+		n := apns.NewNotification()
+		n.Payload.APS.Alert.Title = "Hi"
+		n.Payload.APS.Alert.LocArgs = []string{"Ann", "Bob"}
+		n.Payload.SetCustomValue("k", 1)
+
+		r, err := apns.AnalyzePayload(n)
+		Expect(err).To(BeNil())
+		Expect(r.Over).To(Equal(0))
+		Expect(r.Limit).To(Equal(apns.MaxPayloadSize))
+		Expect(r.Fields).To(Equal([]apns.FieldUsage{
+			{Path: "aps.alert.loc-args", Size: len(`"loc-args":["Ann","Bob"],`)},
+			{Path: "aps.alert.title", Size: len(`"title":"Hi",`)},
+			{Path: "k", Size: len(`"k":1,`)},
+		}))
+		Expect(r.Suggestions).To(BeEmpty())
+	})
+
+	It("should suggest shorter keys and truncation when over the limit", func() {
+		n := apns.NewNotification()
+		n.Payload.APS.Alert.Body = strings.Repeat("x", apns.MaxPayloadSize)
+		n.Payload.SetCustomValue("conversation", "abc")
+
+		r, err := apns.AnalyzePayload(n)
+		Expect(err).To(BeNil())
+		Expect(r.Over).To(Equal(r.Size - apns.MaxPayloadSize))
+		Expect(r.Fields[0].Path).To(Equal("aps.alert"))
+
+		Expect(r.Suggestions).To(Equal([]apns.Suggestion{
+			{Path: "aps.alert", Savings: r.Over, Advice: fmt.Sprintf("truncate it by %d bytes", r.Over)},
+			{Path: "conversation", Savings: 11, Advice: "shorten the custom key to one character"},
+		}))
+	})
+
+	It("should suggest compacting precomputed payloads", func() {
+		n := apns.NewNotification().WithPrecomputedPayload([]byte(`{ "aps": { "alert": "a < b" } }`))
+
+		r, err := apns.AnalyzePayload(n)
+		Expect(err).To(BeNil())
+		Expect(r.Fields).To(Equal([]apns.FieldUsage{{Path: "aps.alert", Size: len(`"alert":"a < b",`)}}))
+		Expect(r.Suggestions).To(Equal([]apns.Suggestion{
+			{Path: "", Savings: 6, Advice: "remove the whitespace from the precomputed payload"},
+		}))
+	})
+})
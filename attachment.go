@@ -0,0 +1,57 @@
+package apns
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// AttachmentURLKey is the custom payload key SetAttachmentURL stores the
+// media URL under for the app's Notification Service Extension to download.
+const AttachmentURLKey = "attachment-url"
+
+// MaxAttachmentURLSize caps attachment URLs so they leave most of the
+// payload for the alert itself.
+const MaxAttachmentURLSize = 1024
+
+// AttachmentError is returned for attachment URLs a Notification Service
+// Extension couldn't or shouldn't download.
+type AttachmentError struct {
+	URL    string
+	Reason string
+}
+
+func (e *AttachmentError) Error() string {
+	return fmt.Sprintf("attachment url %q: %s", e.URL, e.Reason)
+}
+
+// SetAttachmentURL sets mutable-content and stores rawurl under
+// AttachmentURLKey, the convention rich notifications use to hand media to
+// a Notification Service Extension. Only absolute https URLs are accepted,
+// since App Transport Security blocks plain http by default.
+func (p *Payload) SetAttachmentURL(rawurl string) error {
+	if len(rawurl) > MaxAttachmentURLSize {
+		return &AttachmentError{URL: rawurl, Reason: fmt.Sprintf("%d bytes, max %d", len(rawurl), MaxAttachmentURLSize)}
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return &AttachmentError{URL: rawurl, Reason: err.Error()}
+	}
+	if u.Scheme != "https" {
+		return &AttachmentError{URL: rawurl, Reason: "scheme must be https"}
+	}
+	if u.Host == "" {
+		return &AttachmentError{URL: rawurl, Reason: "missing host"}
+	}
+
+	p.APS.MutableContent = 1
+	p.customValues[AttachmentURLKey] = rawurl
+
+	return nil
+}
+
+// AttachmentURL returns the URL stored by SetAttachmentURL, if any.
+func (p *Payload) AttachmentURL() (string, bool) {
+	u, ok := p.customValues[AttachmentURLKey].(string)
+	return u, ok
+}
@@ -0,0 +1,55 @@
+package apns_test
+
+import (
+	"encoding/json"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Attachments", func() {
+	Describe("Payload#SetAttachmentURL", func() {
+		It("should set mutable-content and the url", func() {
+			p := apns.NewPayload()
+			p.APS.Alert.Body = "New photo"
+
+			Expect(p.SetAttachmentURL("https://example.com/a.jpg")).To(BeNil())
+
+			b, _ := json.Marshal(p)
+			Expect(b).To(MatchJSON(`{"aps":{"alert":"New photo","mutable-content":1},"attachment-url":"https://example.com/a.jpg"}`))
+
+			u, ok := p.AttachmentURL()
+			Expect(ok).To(BeTrue())
+			Expect(u).To(Equal("https://example.com/a.jpg"))
+		})
+
+		It("should survive a JSON round trip", func() {
+			p := apns.NewPayload()
+			p.SetAttachmentURL("https://example.com/a.jpg")
+
+			b, _ := json.Marshal(p)
+			q := apns.NewPayload()
+			Expect(json.Unmarshal(b, q)).To(BeNil())
+
+			u, _ := q.AttachmentURL()
+			Expect(u).To(Equal("https://example.com/a.jpg"))
+			Expect(q.APS.MutableContent).To(Equal(1))
+		})
+
+		It("should reject plain http", func() {
+			p := apns.NewPayload()
+			Expect(p.SetAttachmentURL("http://example.com/a.jpg")).To(MatchError(ContainSubstring("scheme must be https")))
+
+			_, ok := p.AttachmentURL()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should reject overly long urls", func() {
+			p := apns.NewPayload()
+			err := p.SetAttachmentURL("https://example.com/" + strings.Repeat("a", apns.MaxAttachmentURLSize))
+			Expect(err).To(BeAssignableToTypeOf(&apns.AttachmentError{}))
+		})
+	})
+})
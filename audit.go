@@ -0,0 +1,158 @@
+package apns
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// ConnectReason classifies the outcome of a connection attempt.
+type ConnectReason string
+
+const (
+	ConnectOK               ConnectReason = "ok"
+	ConnectDNSFailure       ConnectReason = "dns-failure"
+	ConnectTCPRefused       ConnectReason = "tcp-refused"
+	ConnectTCPTimeout       ConnectReason = "tcp-timeout"
+	ConnectTCPFailure       ConnectReason = "tcp-failure"
+	ConnectTLSAlert         ConnectReason = "tls-alert"
+	ConnectCertExpired      ConnectReason = "cert-expired"
+	ConnectCertInvalid      ConnectReason = "cert-invalid"
+	ConnectHandshakeTimeout ConnectReason = "handshake-timeout"
+	ConnectOther            ConnectReason = "other"
+)
+
+// DefaultConnectAuditSize is how many attempts a Conn's audit keeps.
+const DefaultConnectAuditSize = 32
+
+// ConnectAttempt is one entry in a ConnectAudit.
+type ConnectAttempt struct {
+	ConnectTiming
+	Reason ConnectReason
+}
+
+// MarshalJSON renders the error as a string, since most error types have
+// no useful JSON form.
+func (a ConnectAttempt) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Start  string        `json:"start"`
+		DNS    string        `json:"dns"`
+		TCP    string        `json:"tcp"`
+		TLS    string        `json:"tls"`
+		Reason ConnectReason `json:"reason"`
+		Err    string        `json:"error,omitempty"`
+	}{
+		Start:  a.Start.Format("2006-01-02T15:04:05.000Z07:00"),
+		DNS:    a.DNS.String(),
+		TCP:    a.TCP.String(),
+		TLS:    a.TLS.String(),
+		Reason: a.Reason,
+	}
+	if a.Err != nil {
+		v.Err = a.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// ClassifyConnectError returns the reason a connection attempt failed
+// with err, or ConnectOK for a nil error.
+func ClassifyConnectError(err error) ConnectReason {
+	if err == nil {
+		return ConnectOK
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ConnectDNSFailure
+	}
+
+	var certErr x509.CertificateInvalidError
+	if errors.As(err, &certErr) {
+		if certErr.Reason == x509.Expired {
+			return ConnectCertExpired
+		}
+		return ConnectCertInvalid
+	}
+	var authErr x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	if errors.As(err, &authErr) || errors.As(err, &hostErr) {
+		return ConnectCertInvalid
+	}
+
+	// Alerts sent by the gateway, e.g. when it rejects our certificate.
+	if msg := err.Error(); strings.Contains(msg, "remote error: tls:") {
+		if strings.Contains(msg, "expired certificate") {
+			return ConnectCertExpired
+		}
+		return ConnectTLSAlert
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		switch {
+		case errors.Is(err, syscall.ECONNREFUSED):
+			return ConnectTCPRefused
+		case opErr.Timeout():
+			return ConnectTCPTimeout
+		}
+		return ConnectTCPFailure
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ConnectHandshakeTimeout
+	}
+
+	return ConnectOther
+}
+
+// ConnectAudit keeps the most recent connection attempts of a Conn in a
+// ring buffer. It is an http.Handler serving them as JSON, so it can be
+// mounted on a debug endpoint.
+type ConnectAudit struct {
+	mu       sync.Mutex
+	attempts []ConnectAttempt
+	next     int
+	full     bool
+}
+
+func NewConnectAudit(size int) *ConnectAudit {
+	return &ConnectAudit{attempts: make([]ConnectAttempt, size)}
+}
+
+// Record adds an attempt, evicting the oldest one if the audit is full.
+func (a *ConnectAudit) Record(t ConnectTiming) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.attempts) == 0 {
+		return
+	}
+
+	a.attempts[a.next] = ConnectAttempt{ConnectTiming: t, Reason: ClassifyConnectError(t.Err)}
+	a.next = (a.next + 1) % len(a.attempts)
+	if a.next == 0 {
+		a.full = true
+	}
+}
+
+// Attempts returns the recorded attempts, oldest first.
+func (a *ConnectAudit) Attempts() []ConnectAttempt {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.full {
+		return append([]ConnectAttempt(nil), a.attempts[:a.next]...)
+	}
+	return append(append([]ConnectAttempt(nil), a.attempts[a.next:]...), a.attempts[:a.next]...)
+}
+
+func (a *ConnectAudit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.Attempts())
+}
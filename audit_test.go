@@ -0,0 +1,97 @@
+package apns_test
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("ConnectAudit", func() {
+	DescribeTable(".ClassifyConnectError",
+		func(err error, want apns.ConnectReason) {
+			Expect(apns.ClassifyConnectError(err)).To(Equal(want))
+		},
+		Entry("success", nil, apns.ConnectOK),
+		Entry("dns", &net.DNSError{Err: "no such host", Name: "gateway.push.apple.com"}, apns.ConnectDNSFailure),
+		Entry("refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, apns.ConnectTCPRefused),
+		Entry("expired", x509.CertificateInvalidError{Reason: x509.Expired}, apns.ConnectCertExpired),
+		Entry("unknown authority", x509.UnknownAuthorityError{}, apns.ConnectCertInvalid),
+		Entry("alert", errors.New("remote error: tls: bad certificate"), apns.ConnectTLSAlert),
+		Entry("expired alert", errors.New("remote error: tls: expired certificate"), apns.ConnectCertExpired),
+		Entry("other", errors.New("boom"), apns.ConnectOther),
+	)
+
+	It("should keep only the most recent attempts", func() {
+		a := apns.NewConnectAudit(2)
+		for i := 0; i < 3; i++ {
+			a.Record(apns.ConnectTiming{Start: time.Unix(int64(i), 0)})
+		}
+
+		attempts := a.Attempts()
+		Expect(attempts).To(HaveLen(2))
+		Expect(attempts[0].Start).To(Equal(time.Unix(1, 0)))
+		Expect(attempts[1].Start).To(Equal(time.Unix(2, 0)))
+	})
+
+	It("should record every Connect", func() {
+		conn, _ := apns.NewConn("127.0.0.1:1", DummyCert, DummyKey)
+		conn.Connect()
+
+		attempts := conn.Audit.Attempts()
+		Expect(attempts).To(HaveLen(1))
+		Expect(attempts[0].Reason).To(Equal(apns.ConnectTCPRefused))
+	})
+
+	It("should record a handshake that times out", func() {
+		// Accepts connections and then says nothing.
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		defer l.Close()
+		go func() {
+			var held []net.Conn
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					for _, c := range held {
+						c.Close()
+					}
+					return
+				}
+				held = append(held, c)
+			}
+		}()
+
+		conn, _ := apns.NewConn(l.Addr().String(), DummyCert, DummyKey)
+		conn.HandshakeTimeout = 50 * time.Millisecond
+		err := conn.Connect()
+		Expect(err).NotTo(BeNil())
+		Expect(apns.ClassifyConnectError(err)).To(Equal(apns.ConnectHandshakeTimeout))
+
+		attempts := conn.Audit.Attempts()
+		Expect(attempts).To(HaveLen(1))
+		Expect(attempts[0].Reason).To(Equal(apns.ConnectHandshakeTimeout))
+		Expect(attempts[0].TLS).To(BeNumerically("<", time.Second))
+	})
+
+	It("should serve attempts as JSON", func() {
+		a := apns.NewConnectAudit(1)
+		a.Record(apns.ConnectTiming{Err: errors.New("boom")})
+
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, httptest.NewRequest("GET", "/debug/apns/connects", nil))
+
+		var attempts []map[string]interface{}
+		Expect(json.Unmarshal(w.Body.Bytes(), &attempts)).To(BeNil())
+		Expect(attempts[0]["reason"]).To(Equal("other"))
+		Expect(attempts[0]["error"]).To(Equal("boom"))
+	})
+})
@@ -0,0 +1,194 @@
+package apns
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditRecord is one line of an AuditLog. It holds everything needed to
+// rebuild and resend the notification.
+type AuditRecord struct {
+	Time        time.Time         `json:"time"`
+	Disposition string            `json:"disposition"`
+	Error       string            `json:"error,omitempty"`
+	ConnID      uint64            `json:"conn_id,omitempty"`
+	ContentHash string            `json:"content_hash,omitempty"`
+	ID          string            `json:"id,omitempty"`
+	DeviceToken string            `json:"device_token"`
+	Identifier  uint32            `json:"identifier,omitempty"`
+	Expiration  *time.Time        `json:"expiration,omitempty"`
+	Priority    int               `json:"priority,omitempty"`
+	PushType    PushType          `json:"push_type,omitempty"`
+	CollapseID  string            `json:"collapse_id,omitempty"`
+	Topic       string            `json:"topic,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Payload     json.RawMessage   `json:"payload"`
+
+	// ExpireImmediately is set for notifications sent with
+	// ExpirationImmediate.
+	ExpireImmediately bool `json:"expire_immediately,omitempty"`
+}
+
+// NewAuditRecord builds the audit record for a result.
+func NewAuditRecord(r Result) (AuditRecord, error) {
+	n := r.Notification
+
+	payload, err := n.payloadBytes()
+	if err != nil {
+		return AuditRecord{}, err
+	}
+
+	a := AuditRecord{
+		Time:        r.Time,
+		Disposition: r.Disposition.String(),
+		ConnID:      r.ConnID,
+		ContentHash: r.ContentHash,
+		ID:          n.ID,
+		DeviceToken: n.DeviceToken,
+		Identifier:  n.Identifier,
+		Expiration:  n.Expiration,
+		Priority:    n.Priority,
+		PushType:    n.PushType,
+		CollapseID:  n.CollapseID,
+		Topic:       n.Topic,
+		Metadata:    n.Metadata,
+		Payload:     payload,
+	}
+	if r.Err != nil {
+		a.Error = r.Err.Error()
+	}
+	a.ExpireImmediately = n.expirationPolicy() == ExpirationImmediate
+
+	return a, nil
+}
+
+// Notification rebuilds the notification the record describes. The
+// identifier is left for the client to assign again.
+func (a AuditRecord) Notification() (Notification, error) {
+	p := NewPayload()
+	if len(a.Payload) > 0 {
+		if err := json.Unmarshal(a.Payload, p); err != nil {
+			return Notification{}, err
+		}
+	}
+
+	n := Notification{
+		ID:          a.ID,
+		DeviceToken: a.DeviceToken,
+		Expiration:  a.Expiration,
+		Priority:    a.Priority,
+		PushType:    a.PushType,
+		CollapseID:  a.CollapseID,
+		Topic:       a.Topic,
+		Metadata:    a.Metadata,
+		Payload:     p,
+	}
+	if a.ExpireImmediately {
+		n = n.ExpireImmediately()
+	}
+	return n, nil
+}
+
+// AuditLog writes Results as JSON lines, one AuditRecord per line. Feed
+// it from a Subscription:
+//
+//	sub := client.Subscribe(1000, apns.DropOldest)
+//	log := apns.NewAuditLog(f)
+//	go func() {
+//		for r := range sub.Results {
+//			log.Write(r)
+//		}
+//	}()
+type AuditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{enc: json.NewEncoder(w)}
+}
+
+func (l *AuditLog) Write(r Result) error {
+	a, err := NewAuditRecord(r)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.enc.Encode(a)
+}
+
+// ReadAuditLog calls fn with every record in an audit log, stopping at the
+// first error.
+func ReadAuditLog(r io.Reader, fn func(AuditRecord) error) error {
+	d := json.NewDecoder(r)
+	for {
+		var a AuditRecord
+		if err := d.Decode(&a); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+}
+
+// ForgetAuditLog removes every record for token from the audit log at
+// path, for honoring privacy deletion requests. It returns how many
+// records were removed. A missing file has none.
+func ForgetAuditLog(path, token string) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var kept []AuditRecord
+	forgotten := 0
+	err = ReadAuditLog(f, func(a AuditRecord) error {
+		if strings.EqualFold(a.DeviceToken, token) {
+			forgotten++
+		} else {
+			kept = append(kept, a)
+		}
+		return nil
+	})
+	f.Close()
+	if err != nil || forgotten == 0 {
+		return 0, err
+	}
+
+	// Write the rest alongside and swap it in, so a failure leaves the
+	// original intact.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	enc := json.NewEncoder(tmp)
+	for _, a := range kept {
+		if err := enc.Encode(a); err != nil {
+			tmp.Close()
+			return 0, err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return forgotten, nil
+}
@@ -0,0 +1,63 @@
+package apns_test
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("AuditLog", func() {
+	It("should round trip notifications through JSON lines", func() {
+		n := apns.NewNotification()
+		n.ID = "n1"
+		n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+		n.Priority = apns.PriorityImmediate
+		n.PushType = apns.PushTypeAlert
+		n.Topic = "com.example.app"
+		n.Payload.APS.Alert.Body = "hi"
+		n.Payload.SetCustomValue("link", "app://x")
+
+		var buf bytes.Buffer
+		l := apns.NewAuditLog(&buf)
+		Expect(l.Write(apns.Result{Notification: n, Disposition: apns.Delivered, Time: time.Unix(1, 0)})).To(BeNil())
+		Expect(l.Write(apns.Result{Notification: n, Disposition: apns.FailedRetryable, Err: errors.New("Shutdown"), Time: time.Unix(2, 0)})).To(BeNil())
+
+		var records []apns.AuditRecord
+		err := apns.ReadAuditLog(&buf, func(a apns.AuditRecord) error {
+			records = append(records, a)
+			return nil
+		})
+		Expect(err).To(BeNil())
+		Expect(records).To(HaveLen(2))
+		Expect(records[0].Disposition).To(Equal("delivered"))
+		Expect(records[1].Disposition).To(Equal("failed-retryable"))
+		Expect(records[1].Error).To(Equal("Shutdown"))
+
+		m, err := records[1].Notification()
+		Expect(err).To(BeNil())
+		Expect(m.ID).To(Equal("n1"))
+		Expect(m.PushType).To(Equal(apns.PushTypeAlert))
+		Expect(m.Topic).To(Equal("com.example.app"))
+
+		want, _ := n.ToBinary()
+		got, _ := m.ToBinary()
+		Expect(got).To(Equal(want))
+	})
+
+	It("should keep ExpirationImmediate", func() {
+		n := apns.NewNotification().ExpireImmediately()
+		n.DeviceToken = "abcd"
+
+		a, err := apns.NewAuditRecord(apns.Result{Notification: n})
+		Expect(err).To(BeNil())
+		Expect(a.ExpireImmediately).To(BeTrue())
+
+		m, err := a.Notification()
+		Expect(err).To(BeNil())
+		Expect(m.ExpirationPolicy).To(Equal(apns.ExpirationImmediate))
+	})
+})
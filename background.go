@@ -0,0 +1,17 @@
+package apns
+
+// NewBackgroundNotification returns a background push for the app with the
+// given bundle ID: content-available set to 1, the background push type
+// and PriorityPowerConserve. Apple silently throttles background pushes
+// sent at other priorities or with keys that only apply to alerts, so
+// ValidatePushType rejects those, should they be added; custom keys are
+// fine.
+func NewBackgroundNotification(token, bundleID string) Notification {
+	n := NewNotification()
+	n.DeviceToken = token
+	n.PushType = PushTypeBackground
+	n.Topic = bundleID
+	n.Priority = PriorityPowerConserve
+	n.Payload.APS.ContentAvailable = 1
+	return n
+}
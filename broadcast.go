@@ -0,0 +1,209 @@
+package apns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ChannelProductionHost and ChannelDevelopmentHost are the endpoints of
+	// Apple's broadcast channel management API.
+	ChannelProductionHost  = "https://api-manage-broadcast.push.apple.com:2196"
+	ChannelDevelopmentHost = "https://api-manage-broadcast.sandbox.push.apple.com:2195"
+)
+
+// MessageStoragePolicy says whether APNS keeps the latest broadcast on a
+// channel for devices that are offline when it's sent.
+type MessageStoragePolicy int
+
+const (
+	NoMessageStored         MessageStoragePolicy = 0
+	MostRecentMessageStored MessageStoragePolicy = 1
+)
+
+// Channel describes a broadcast channel.
+type Channel struct {
+	ID                   string
+	MessageStoragePolicy MessageStoragePolicy
+	PushType             string
+}
+
+// ChannelError is returned when the channel management API rejects a
+// request. Like ReasonError, it wraps the ErrReason value for its
+// reason.
+type ChannelError struct {
+	StatusCode int
+	Reason     string
+}
+
+func (e *ChannelError) Error() string {
+	return fmt.Sprintf("apns: channel request failed with %d %s", e.StatusCode, e.Reason)
+}
+
+func (e *ChannelError) Unwrap() error {
+	return reasonErrors[e.Reason]
+}
+
+// ChannelManager creates, reads and deletes the broadcast channels an app
+// uses to update Live Activities on many devices with one push. Requests
+// go through Client, with its credentials.
+type ChannelManager struct {
+	// Host is ChannelProductionHost or ChannelDevelopmentHost.
+	Host string
+
+	BundleID string
+	Client   *Client2
+}
+
+func NewChannelManager(host, bundleID string, c *Client2) *ChannelManager {
+	return &ChannelManager{Host: host, BundleID: bundleID, Client: c}
+}
+
+type channelBody struct {
+	MessageStoragePolicy MessageStoragePolicy `json:"message-storage-policy"`
+	PushType             string               `json:"push-type"`
+}
+
+// Create creates a Live Activity channel and returns its ID.
+func (m *ChannelManager) Create(ctx context.Context, policy MessageStoragePolicy) (string, error) {
+	body, err := json.Marshal(channelBody{MessageStoragePolicy: policy, PushType: "LiveActivity"})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := m.do(ctx, "POST", "/channels", "", body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	return resp.Header.Get("apns-channel-id"), nil
+}
+
+// Get returns the channel with the given ID.
+func (m *ChannelManager) Get(ctx context.Context, id string) (Channel, error) {
+	resp, err := m.do(ctx, "GET", "/channels", id, nil)
+	if err != nil {
+		return Channel{}, err
+	}
+	defer resp.Body.Close()
+
+	var b channelBody
+	if err := json.NewDecoder(resp.Body).Decode(&b); err != nil {
+		return Channel{}, err
+	}
+
+	return Channel{ID: id, MessageStoragePolicy: b.MessageStoragePolicy, PushType: b.PushType}, nil
+}
+
+// List returns the IDs of all the app's channels.
+func (m *ChannelManager) List(ctx context.Context) ([]string, error) {
+	resp, err := m.do(ctx, "GET", "/all-channels", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var b struct {
+		Channels []string `json:"channels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&b); err != nil {
+		return nil, err
+	}
+
+	return b.Channels, nil
+}
+
+// Delete deletes the channel with the given ID.
+func (m *ChannelManager) Delete(ctx context.Context, id string) error {
+	resp, err := m.do(ctx, "DELETE", "/channels", id, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	return nil
+}
+
+// do makes a request to the app's path under the management API. Answers
+// other than 2xx are returned as a *ChannelError.
+func (m *ChannelManager) do(ctx context.Context, method, path, id string, body []byte) (*http.Response, error) {
+	url := strings.TrimSuffix(m.Host, "/") + "/1/apps/" + m.BundleID + path
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if id != "" {
+		req.Header.Set("apns-channel-id", id)
+	}
+	if err := m.Client.authorize(req.Header); err != nil {
+		return nil, err
+	}
+
+	resp, err := m.Client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+
+		r, err := readResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+		m.Client.checkReason(resp, r.Reason, strings.TrimPrefix(req.Header.Get("authorization"), "bearer "))
+		return nil, &ChannelError{StatusCode: r.StatusCode, Reason: r.Reason}
+	}
+
+	return resp, nil
+}
+
+// SendBroadcast sends n to every device subscribed to the broadcast
+// channel with the given ID, rather than to n.DeviceToken, which is
+// ignored. Broadcasts are Live Activity pushes, so a missing PushType is
+// taken to be PushTypeLiveActivity. The app's bundle ID is taken from
+// n.Topic or DefaultTopic.
+func (c *Client2) SendBroadcast(ctx context.Context, channelID string, n Notification) (Response, error) {
+	if n.PushType == "" {
+		n.PushType = PushTypeLiveActivity
+	}
+	if n.Topic == "" {
+		n.Topic = c.DefaultTopic
+	}
+
+	if err := n.ValidateLimits(); err != nil {
+		return Response{}, err
+	}
+	if err := n.ValidatePushType(); err != nil {
+		return Response{}, err
+	}
+
+	body, err := n.payloadBytes()
+	if err != nil {
+		return Response{}, err
+	}
+
+	header := http.Header{}
+	setHeaders(header, n)
+	header.Set("apns-channel-id", channelID)
+	if err := c.authorize(header); err != nil {
+		return Response{}, err
+	}
+
+	bundleID := strings.TrimSuffix(n.Topic, TopicSuffix(PushTypeLiveActivity))
+	return c.send(ctx, c.HTTPClient, "/4/broadcasts/apps/"+bundleID, header, body)
+}
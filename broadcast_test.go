@@ -0,0 +1,123 @@
+package apns_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Broadcast", func() {
+	var s *mockHTTP2Server
+
+	BeforeEach(func() {
+		s = newMockHTTP2Server()
+	})
+
+	AfterEach(func() {
+		s.Close()
+	})
+
+	Describe("ChannelManager", func() {
+		var m *apns.ChannelManager
+
+		BeforeEach(func() {
+			m = apns.NewChannelManager(s.URL, "com.example.app", newTestClient2(s))
+		})
+
+		It("should create channels", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("apns-channel-id", "dHN0LXNyY2gtY2hubA==")
+				w.WriteHeader(http.StatusCreated)
+			}
+
+			id, err := m.Create(context.Background(), apns.MostRecentMessageStored)
+			Expect(err).To(BeNil())
+			Expect(id).To(Equal("dHN0LXNyY2gtY2hubA=="))
+
+			req := s.requests[0]
+			Expect(req.Method).To(Equal("POST"))
+			Expect(req.URL.Path).To(Equal("/1/apps/com.example.app/channels"))
+			Expect(s.bodies[0]).To(MatchJSON(`{"message-storage-policy":1,"push-type":"LiveActivity"}`))
+		})
+
+		It("should read channels", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"message-storage-policy":0,"push-type":"LiveActivity"}`))
+			}
+
+			ch, err := m.Get(context.Background(), "abc")
+			Expect(err).To(BeNil())
+			Expect(ch).To(Equal(apns.Channel{ID: "abc", MessageStoragePolicy: apns.NoMessageStored, PushType: "LiveActivity"}))
+			Expect(s.requests[0].Header.Get("apns-channel-id")).To(Equal("abc"))
+		})
+
+		It("should list channels", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string][]string{"channels": {"a", "b"}})
+			}
+
+			ids, err := m.List(context.Background())
+			Expect(err).To(BeNil())
+			Expect(ids).To(Equal([]string{"a", "b"}))
+			Expect(s.requests[0].URL.Path).To(Equal("/1/apps/com.example.app/all-channels"))
+		})
+
+		It("should delete channels", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}
+
+			Expect(m.Delete(context.Background(), "abc")).To(BeNil())
+			Expect(s.requests[0].Method).To(Equal("DELETE"))
+			Expect(s.requests[0].Header.Get("apns-channel-id")).To(Equal("abc"))
+		})
+
+		It("should return rejections as a ChannelError", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"reason":"ChannelNotRegistered"}`))
+			}
+
+			err := m.Delete(context.Background(), "abc")
+
+			var ce *apns.ChannelError
+			Expect(errors.As(err, &ce)).To(BeTrue())
+			Expect(ce.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(ce.Reason).To(Equal("ChannelNotRegistered"))
+		})
+
+		It("should wrap reasons shared with the push API", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"reason":"InvalidProviderToken"}`))
+			}
+
+			err := m.Delete(context.Background(), "abc")
+			Expect(errors.Is(err, apns.ErrReasonInvalidProviderToken)).To(BeTrue())
+		})
+	})
+
+	Describe("#SendBroadcast", func() {
+		It("should post a Live Activity push to the channel", func() {
+			c := newTestClient2(s)
+			c.DefaultTopic = "com.example.app.push-type.liveactivity"
+
+			n := apns.NewNotification()
+			n.Payload.APS.ContentState = json.RawMessage(`{"score":1}`)
+
+			r, err := c.SendBroadcast(context.Background(), "abc", n)
+			Expect(err).To(BeNil())
+			Expect(r.OK()).To(BeTrue())
+
+			req := s.requests[0]
+			Expect(req.URL.Path).To(Equal("/4/broadcasts/apps/com.example.app"))
+			Expect(req.Header.Get("apns-channel-id")).To(Equal("abc"))
+			Expect(req.Header.Get("apns-push-type")).To(Equal("liveactivity"))
+		})
+	})
+})
@@ -2,9 +2,16 @@ package apns
 
 import (
 	"container/list"
+	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -27,35 +34,181 @@ func (b *buffer) Add(v interface{}) *list.Element {
 	return e
 }
 
+// DefaultBufferSize is the default Client.BufferSize.
+const DefaultBufferSize = 50
+
+// ErrClosed is returned by Client methods called after Close.
+var ErrClosed = errors.New("apns: client closed")
+
 type Client struct {
 	Conn         *Conn
 	FailedNotifs chan NotificationResult
+	Events       chan Event
 	Sent         int
 	Failed       int
+	Skipped      int
 	Len          int
 	Verbose      bool
 
-	notifs chan Notification
-	id     uint32
+	// Schemas, if set, validates every notification against the schema
+	// declared in its metadata before it is written.
+	Schemas *SchemaRegistry
+
+	// Suppressions, if set, is consulted before every send. Suppressed
+	// notifications fail locally with ErrSuppressed.
+	Suppressions *SuppressionList
+
+	// Correlations, if set, records every written notification with an ID
+	// so app-side receipts can be joined back to it with Correlate.
+	Correlations CorrelationStore
+
+	// DedupeWindow, if non-zero, fails notifications with ErrDuplicate when
+	// the same content went to the same token within the window.
+	// Notifications resent after a reconnect are exempt.
+	DedupeWindow time.Duration
+
+	// SuccessSampleRate is the fraction of delivered notifications that are
+	// logged and published as Results, between 0 and 1. Failures are always
+	// reported. Sampled Results carry the rate so counts can be scaled back
+	// up.
+	SuccessSampleRate float64
+
+	// CutoverAfter is how many handshakes in a row the primary certificate
+	// must fail before the client switches to a verified secondary one.
+	CutoverAfter int
+
+	// CollapseIDFunc, if set, derives the collapse ID of notifications that
+	// don't have one, for example from a thread ID in their metadata.
+	CollapseIDFunc func(n Notification) string
+
+	// DefaultTopic is the topic of notifications sent without one. The
+	// binary protocol doesn't send topics, but results, audit records and
+	// mirrored copies carry it.
+	DefaultTopic string
+
+	// MaxInFlight caps how many notifications may be written within
+	// InFlightWindow on a connection before dispatch pauses. Since APNS
+	// only reports failures, everything written inside the window could
+	// still be requeued by a late error frame. Zero means no cap.
+	MaxInFlight    int
+	InFlightWindow time.Duration
+
+	// QueueSize is how many notifications Send accepts ahead of the
+	// connection before it blocks. Zero means DefaultQueueSize. It is read
+	// when the client starts; see NewClientWithConfig.
+	QueueSize int
+
+	// BufferSize is how many written notifications are kept for resending
+	// after an error. It is read when the client starts; see
+	// NewClientWithConfig.
+	BufferSize int
+
+	// GroupWeights sets how many notifications each send group may send
+	// per turn of the send queue; groups not listed, including the unnamed
+	// one, get 1. Set it before sending. See InGroup.
+	GroupWeights map[string]int
+
+	// Strict fails notifications that Notification.Lint finds problems
+	// with, which is meant for CI and staging. Otherwise the problems are
+	// only reported as EventLintWarning.
+	Strict bool
+
+	// SoftFail keeps a client that can't connect, say for lack of real
+	// credentials in development or CI, from holding up the application:
+	// while connecting fails, notifications are counted in Skipped and
+	// reported as Skipped instead of waiting for a connection. Each outage
+	// is logged once, and with Verbose every skipped notification is too.
+	SoftFail bool
+
+	// RecoveryFile, if set, is where Close appends the notifications it
+	// discards, those still queued or waiting to be resent, so the next
+	// run can send them with LoadRecovery instead of losing them.
+	RecoveryFile string
+
+	// CompressRecovery gzips what Close appends to RecoveryFile.
+	// LoadRecovery reads the file either way.
+	CompressRecovery bool
+
+	mu          sync.RWMutex
+	beforeSends []func(*Notification) error
+	afterSends  []func(Notification)
+	subs        []*Subscription
+	lock        Locker
+	mirror      *Mirror
+	recovery    recovery
+
+	// remoteAddr is Conn.RemoteAddr as of the last connection attempt,
+	// for logs and results produced off the connection loop, which is the
+	// only goroutine that may touch Conn.
+	remoteAddr string
+
+	secondary    *tls.Certificate
+	secondaryOK  bool
+	secondaryErr error
+
+	recent     eventLog
+	gauges     *queueGauges
+	errLatency *errorLatencies
+	rate       *sendRate
+
+	intake      chan Notification
+	notifs      chan Notification
+	forgets     chan forgetRequest
+	queuePurges chan forgetRequest
+	cancels     chan cancelRequest
+	relock      chan struct{}
+	id          uint32
+
+	closeOnce  sync.Once
+	closed     chan struct{}
+	done       chan struct{}
+	intakeDone chan struct{}
 }
 
 func newClientWithConn(gw string, conn Conn, verbose bool) *Client {
 	c := &Client{
-		Conn:         &conn,
-		FailedNotifs: make(chan NotificationResult),
-		Sent:         0,
-		Failed:       0,
-		Len:          0,
-		Verbose:      verbose,
-		id:           uint32(1),
-		notifs:       make(chan Notification),
+		Conn:              &conn,
+		FailedNotifs:      make(chan NotificationResult),
+		Events:            make(chan Event, DefaultEventsBuffer),
+		Sent:              0,
+		Failed:            0,
+		Len:               0,
+		Verbose:           verbose,
+		InFlightWindow:    DefaultInFlightWindow,
+		QueueSize:         DefaultQueueSize,
+		BufferSize:        DefaultBufferSize,
+		CutoverAfter:      DefaultCutoverAfter,
+		SuccessSampleRate: 1,
+		id:                uint32(1),
+		intake:            make(chan Notification),
+		notifs:            make(chan Notification),
+		forgets:           make(chan forgetRequest),
+		queuePurges:       make(chan forgetRequest),
+		cancels:           make(chan cancelRequest),
+		relock:            make(chan struct{}, 1),
+		gauges:            &queueGauges{},
+		errLatency:        &errorLatencies{},
+		rate:              &sendRate{},
+		closed:            make(chan struct{}),
+		done:              make(chan struct{}),
+		intakeDone:        make(chan struct{}),
 	}
 
-	go c.runLoop()
-
 	return c
 }
 
+// start launches the client's goroutines. The settings they keep for their
+// lifetime, QueueSize and BufferSize, are read here and only here.
+func (c *Client) start() {
+	queueSize := c.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	go c.intakeLoop(queueSize)
+	go c.runLoop(c.BufferSize)
+}
+
 func NewClientWithCert(gw string, cert tls.Certificate, args ...bool) *Client {
 	verbose := false
 	for _, v := range args {
@@ -63,7 +216,23 @@ func NewClientWithCert(gw string, cert tls.Certificate, args ...bool) *Client {
 		break
 	}
 	conn := NewConnWithCert(gw, cert)
-	return newClientWithConn(gw, conn, verbose)
+	c := newClientWithConn(gw, conn, verbose)
+	c.start()
+	return c
+}
+
+// NewClientWithConfig creates a Client like NewClientWithCert, but calls
+// configure on it before it starts, so settings its goroutines depend on,
+// such as QueueSize, BufferSize and Conn.LocalAddrs, can be set without
+// racing them. If configure returns an error the client is never started
+// and the error is returned.
+func NewClientWithConfig(gw string, cert tls.Certificate, configure func(c *Client) error) (*Client, error) {
+	c := newClientWithConn(gw, NewConnWithCert(gw, cert), false)
+	if err := configure(c); err != nil {
+		return nil, err
+	}
+	c.start()
+	return c, nil
 }
 
 func NewClient(gw string, cert string, key string, args ...bool) (*Client, error) {
@@ -76,7 +245,9 @@ func NewClient(gw string, cert string, key string, args ...bool) (*Client, error
 	if err != nil {
 		return nil, err
 	}
-	return newClientWithConn(gw, conn, verbose), nil
+	c := newClientWithConn(gw, conn, verbose)
+	c.start()
+	return c, nil
 }
 
 func NewClientWithFiles(gw string, certFile string, keyFile string, args ...bool) (*Client, error) {
@@ -89,47 +260,223 @@ func NewClientWithFiles(gw string, certFile string, keyFile string, args ...bool
 	if err != nil {
 		return nil, err
 	}
-	return newClientWithConn(gw, conn, verbose), nil
+	c := newClientWithConn(gw, conn, verbose)
+	c.start()
+	return c, nil
+}
+
+// logPrefix tags log lines with the connection they concern.
+func (c *Client) logPrefix() string {
+	return fmt.Sprintf("[conn %d %s]", c.Conn.ID, c.lastRemoteAddr())
+}
+
+// lastRemoteAddr returns the remote address recorded by the connection
+// loop. Unlike c.Conn.RemoteAddr, it is safe to call from any goroutine.
+func (c *Client) lastRemoteAddr() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.remoteAddr
 }
 
 func (c *Client) logln(v ...interface{}) {
 	if c.Verbose {
-		log.Println(v...)
+		log.Println(append([]interface{}{c.logPrefix()}, v...)...)
 	}
 }
 
 func (c *Client) logf(s string, v ...interface{}) {
 	if c.Verbose {
-		log.Printf(s, v...)
+		log.Printf(c.logPrefix()+" "+s, v...)
+	}
+}
+
+// Send queues n for delivery. It blocks while the queue is full and
+// returns ErrClosed once the client has been closed.
+func (c *Client) Send(n Notification, opts ...SendOption) error {
+	for _, opt := range opts {
+		opt(&n)
+	}
+
+	return c.send(n, nil)
+}
+
+// SendContext is Send, except it gives up waiting for room in a full queue
+// once ctx is done, returning ctx.Err().
+func (c *Client) SendContext(ctx context.Context, n Notification, opts ...SendOption) error {
+	for _, opt := range opts {
+		opt(&n)
+	}
+
+	if err := c.send(n, ctx.Done()); err != nil {
+		if err == errStopped {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// send queues n, giving up with errStopped if stop is closed while the
+// queue is full.
+func (c *Client) send(n Notification, stop <-chan struct{}) error {
+	select {
+	case <-c.closed:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case c.intake <- n:
+		c.logln("Added notification to push queue.")
+		c.Len++
+		return nil
+	case <-c.closed:
+		return ErrClosed
+	case <-stop:
+		return errStopped
 	}
 }
 
-func (c *Client) Send(n Notification) {
-	c.logln("Added notification to push queue.")
-	c.Len++
-	c.notifs <- n
+// Close stops the client and closes its connection to APNS. Notifications
+// that are still queued or waiting to be resent are discarded, and every
+// Subscription is unsubscribed. FailedNotifs and Events are left open since
+// failures already being reported may still arrive on them.
+//
+// Close waits for the connection loop to stop, then writes the discarded
+// notifications to RecoveryFile if set, returning any error doing so. It is
+// safe to call more than once and from several goroutines.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	<-c.done
+	<-c.intakeDone
+
+	c.mu.RLock()
+	subs := append([]*Subscription(nil), c.subs...)
+	c.mu.RUnlock()
+
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+	c.SetMirror(nil)
+
+	return c.writeRecovery()
 }
 
-func (c *Client) reportFailedPush(v interface{}, err *Error) {
+// OnBeforeSend registers fn to run just before each notification is
+// written. fn may modify the notification; returning an error cancels the
+// send and reports the notification on FailedNotifs instead. Hooks run in
+// the order they were registered. Hooks registered after Close never run.
+func (c *Client) OnBeforeSend(fn func(*Notification) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.beforeSends = append(c.beforeSends, fn)
+}
+
+// runBeforeSends runs the before-send hooks on n. They run without the
+// lock held, so they may register hooks or subscribe themselves.
+func (c *Client) runBeforeSends(n *Notification) error {
+	c.mu.RLock()
+	hooks := append(([]func(*Notification) error)(nil), c.beforeSends...)
+	c.mu.RUnlock()
+
+	for _, fn := range hooks {
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OnAfterSend registers fn to run after each notification is successfully
+// written to APNS. fn runs on the client's connection loop, so it must not
+// block. Hooks registered after Close never run.
+func (c *Client) OnAfterSend(fn func(Notification)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.afterSends = append(c.afterSends, fn)
+}
+
+func (c *Client) runAfterSends(n Notification) {
+	c.mu.RLock()
+	hooks := append(([]func(Notification))(nil), c.afterSends...)
+	c.mu.RUnlock()
+
+	for _, fn := range hooks {
+		fn(n)
+	}
+}
+
+// reportFailedPush reports v as failed with err on the connection with the
+// given ID and remote address. Only runLoop may touch c.Conn, so it passes
+// them in rather than this reading them on another goroutine.
+func (c *Client) reportFailedPush(v interface{}, err *Error, connID uint64, remoteAddr string) {
 	failedNotif, ok := v.(Notification)
 	if !ok || v == nil {
 		return
 	}
 
+	r := NotificationResult{Notif: failedNotif, Err: *err, ConnID: connID, RemoteAddr: remoteAddr}
+	c.publishResult(r.Result())
+	failedNotif.callback.fire(r.Result())
+
 	select {
-	case c.FailedNotifs <- NotificationResult{Notif: failedNotif, Err: *err}:
+	case c.FailedNotifs <- r:
 	default:
 	}
 }
 
-func (c *Client) requeue(cursor *list.Element) {
-	// If `cursor` is not nil, this means there are notifications that
-	// need to be delivered (or redelivered)
-	for ; cursor != nil; cursor = cursor.Next() {
+// reportLocalFailure fails n with err without it ever reaching APNs. The
+// resulting Error has no Command or Status since it didn't come from an
+// error frame, and wraps err so errors.As can get at it.
+func (c *Client) reportLocalFailure(n Notification, err error) {
+	c.reportLocal(&Error{Identifier: n.Identifier, ErrStr: err.Error(), Err: err, kind: localRejected}, n)
+}
+
+// reportLocalStatus fails n for one of the client's own reasons, such as
+// localSuppressed.
+func (c *Client) reportLocalStatus(n Notification, kind localKind) {
+	e := localError(n, kind)
+	c.reportLocal(&e, n)
+}
+
+func (c *Client) reportLocal(e *Error, n Notification) {
+	c.Failed++
+	go c.reportFailedPush(n, e, c.Conn.ID, c.lastRemoteAddr())
+}
+
+// skip reports n as Skipped because connecting failed, in SoftFail mode.
+func (c *Client) skip(n Notification) {
+	c.Skipped++
+	c.logf("Skipped notification to %s: can't connect.", n.DeviceToken)
+
+	c.report(skippedResult(n))
+}
+
+// requeue moves the notifications from cursor onwards out of the buffer so
+// they can be delivered (or redelivered) ahead of new ones.
+func (c *Client) requeue(buffer *buffer, cursor *list.Element) []Notification {
+	var retry []Notification
+
+	for cursor != nil {
+		next := cursor.Next()
 		if n, ok := cursor.Value.(Notification); ok {
-			go func() { c.notifs <- n }()
+			retry = append(retry, n)
 		}
+		buffer.Remove(cursor)
+		cursor = next
 	}
+
+	if len(retry) > 0 {
+		c.emit(Event{Type: EventRequeue, Message: fmt.Sprintf("requeued %d notifications", len(retry)), Count: len(retry)})
+	}
+
+	return retry
 }
 
 func (c *Client) handleError(err *Error, buffer *buffer) *list.Element {
@@ -141,7 +488,10 @@ func (c *Client) handleError(err *Error, buffer *buffer) *list.Element {
 
 		// If the notification, move cursor after the trouble notification
 		if n.Identifier == err.Identifier {
-			go c.reportFailedPush(cursor.Value, err)
+			if !n.writtenAt.IsZero() {
+				c.errLatency.observe(time.Since(n.writtenAt))
+			}
+			go c.reportFailedPush(cursor.Value, err, c.Conn.ID, c.Conn.RemoteAddr())
 
 			next := cursor.Next()
 
@@ -152,46 +502,256 @@ func (c *Client) handleError(err *Error, buffer *buffer) *list.Element {
 		cursor = cursor.Prev()
 	}
 
+	// The failed notification isn't in the buffer, usually because it fell
+	// out of it. APNS drops everything written after a failure, so resend
+	// the buffered notifications that came after it; the ones before it
+	// got through.
+	cursor = buffer.Front()
+	for cursor != nil {
+		if n, ok := cursor.Value.(Notification); ok && after(n.Identifier, err.Identifier) {
+			break
+		}
+		cursor = cursor.Next()
+	}
+
+	resend := 0
+	for e := cursor; e != nil; e = e.Next() {
+		resend++
+	}
+	c.emit(Event{
+		Type:    EventBufferUnderrun,
+		Message: fmt.Sprintf("identifier %d not among %d buffered notifications, resending the %d after it; consider a larger BufferSize", err.Identifier, buffer.Len(), resend),
+	})
+
 	return cursor
 }
 
-func (c *Client) runLoop() {
-	sent := newBuffer(50)
+// after reports whether identifier a was assigned after b, allowing for
+// identifiers wrapping around.
+func after(a, b uint32) bool {
+	return int32(a-b) > 0
+}
+
+func (c *Client) runLoop(bufferSize int) {
+	sent := newBuffer(bufferSize)
 	cursor := sent.Front()
+	var retry []Notification
+	window := writeWindow{}
+	var locations locationRates
+	dedupe := dedupeWindow{}
+	var frames frameEncoder
+
+	// forget serves Forget for the state runLoop owns. It's served while
+	// waiting to connect too, so Forget doesn't wait for a connection.
+	forget := func(req forgetRequest) {
+		dedupe.forget(req.token)
+		locations.forget(req.token)
+		cursor, retry = c.purge(req, sent, cursor, retry)
+	}
+
+	var held Locker
+	var lost <-chan struct{}
+	skipping := false
+	handshakeFailures := 0
+
+	// Connecting gives up when the client is closed, so Close never waits
+	// on a gateway that stopped answering.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	defer close(c.done)
+	defer func() {
+		for _, n := range retry {
+			c.discard(n)
+		}
+		for e := cursor; e != nil; e = e.Next() {
+			if n, ok := e.Value.(Notification); ok {
+				c.discard(n)
+			}
+		}
+	}()
+	defer func() {
+		if held != nil {
+			held.Unlock()
+		}
+	}()
+	defer c.Conn.Close()
 
 	// APNS connection
 	for {
-		err := c.Conn.Connect()
+		// Whatever follows the cursor will be resent, so it can't be
+		// reported as delivered yet.
+		for e := cursor; e != nil; e = e.Next() {
+			if n, ok := e.Value.(Notification); ok {
+				n.callback.hold()
+			}
+		}
+
+		select {
+		case <-c.closed:
+			return
+		default:
+		}
+
+		select {
+		case <-c.relock:
+		default:
+		}
+
+		if lock := c.currentLock(); lock != held {
+			if held != nil {
+				held.Unlock()
+				held, lost = nil, nil
+			}
+			if lock != nil {
+				c.logln("Waiting for connection lock...")
+				if err := c.acquireLock(lock, forget); err != nil {
+					c.logln("Error acquiring connection lock:", err.Error())
+					retryLock := time.After(1 * time.Second)
+				wait:
+					for {
+						select {
+						case <-c.closed:
+							return
+						case <-retryLock:
+							break wait
+						case req := <-c.forgets:
+							forget(req)
+						}
+					}
+					continue
+				}
+				held, lost = lock, lockLost(lock)
+			}
+		}
+
+		err := c.Conn.ConnectContext(ctx)
+		c.mu.Lock()
+		c.remoteAddr = c.Conn.RemoteAddr()
+		c.mu.Unlock()
+		if err != nil && isHandshakeFailure(ClassifyConnectError(err)) {
+			handshakeFailures++
+			if handshakeFailures >= c.CutoverAfter && c.cutover(handshakeFailures) {
+				handshakeFailures = 0
+				continue
+			}
+		} else {
+			handshakeFailures = 0
+		}
 		if err != nil {
+			// Skip whatever comes up while waiting if the client mustn't
+			// hold things up.
+			var skips <-chan Notification
+			if c.SoftFail {
+				if !skipping {
+					log.Printf("apns: can't connect, skipping notifications until connected: %v", err)
+					skipping = true
+				}
+				for _, n := range retry {
+					c.skip(n)
+				}
+				retry = nil
+				skips = c.notifs
+			}
+
 			// TODO Probably want to exponentially backoff...
-			time.Sleep(1 * time.Second)
+			wait := time.After(1 * time.Second)
+			for waiting := true; waiting; {
+				select {
+				case <-c.closed:
+					return
+				case <-wait:
+					waiting = false
+				case n := <-skips:
+					c.skip(n)
+				case req := <-c.forgets:
+					forget(req)
+				}
+			}
 			continue
 		}
 
+		skipping = false
+
 		// Start reading errors from APNS
 		errs := readErrs(c.Conn)
-
-		c.requeue(cursor)
+		window.reset()
+
+		retry = append(c.requeue(sent, cursor), retry...)
+		cursor = nil
+
+		// loseLock disconnects after the connection lock was lost, so it's
+		// acquired again before reconnecting.
+		loseLock := func() error {
+			c.logln("Connection lock lost, reconnecting.")
+			c.emit(Event{Type: EventLockLost, Message: "connection lock lost; disconnecting until it's held again"})
+			c.Conn.Close()
+			held.Unlock()
+			held, lost = nil, nil
+			return io.EOF
+		}
 
 		// Connection open, listen for notifs and errors
 		for {
+			atomic.StoreInt64(&c.gauges.buffered, int64(sent.Len()))
+			atomic.StoreInt64(&c.gauges.retrying, int64(len(retry)))
+
 			var err error
 			var n Notification
+			fresh := false
 
 			// Check for notifications or errors. There is a chance we'll send notifications
 			// if we already have an error since `select` will "pseudorandomly" choose a
 			// ready channels. It turns out to be fine because the connection will already
 			// be closed and it'll requeue. We could check before we get to this select
 			// block, but it doesn't seem worth the extra code and complexity.
-			c.logln("Waiting for channel input...")
-			select {
-			case err = <-errs:
-				break
-			case n = <-c.notifs:
-				notificationPayloadBytes, _ := n.Payload.MarshalJSON()
-				notificationPayload := string(notificationPayloadBytes)
-				c.logf("Incoming notification to %v: %v\n", n.DeviceToken, notificationPayload)
-				break
+			if len(retry) > 0 {
+				// Requeued notifications go out before any new ones.
+				select {
+				case err = <-errs:
+				case <-c.closed:
+					return
+				case <-lost:
+					err = loseLock()
+				default:
+					n, retry = retry[0], retry[1:]
+					if c.dropUnsendable(n, time.Now()) {
+						continue
+					}
+				}
+			} else {
+				c.logln("Waiting for channel input...")
+				select {
+				case err = <-errs:
+					break
+				case <-c.closed:
+					return
+				case <-c.relock:
+					c.logln("Connection lock changed, reconnecting.")
+					c.Conn.Close()
+					err = io.EOF
+				case <-lost:
+					err = loseLock()
+				case req := <-c.forgets:
+					forget(req)
+					continue
+				case n = <-c.notifs:
+					if c.dropUnsendable(n, time.Now()) {
+						continue
+					}
+					fresh = true
+					notificationPayloadBytes, _ := json.Marshal(n.Payload)
+					notificationPayload := string(notificationPayloadBytes)
+					c.logf("Incoming notification to %v: %v\n", n.DeviceToken, notificationPayload)
+					break
+				}
 			}
 
 			// Check if there is an error we understand.
@@ -201,12 +761,12 @@ func (c *Client) runLoop() {
 					// The notification is malformed in some way, and resending it won't help.
 					c.Sent--
 					c.Failed++
-					continue
-				} else {
-					// Find the notification that failed, move the cursor right after it.
-					cursor = c.handleError(nErr, sent)
-					break
 				}
+
+				// APNS closes the connection after an error frame. Find the
+				// notification that failed, move the cursor right after it.
+				cursor = c.handleError(nErr, sent)
+				break
 			}
 
 			if err != nil {
@@ -214,29 +774,113 @@ func (c *Client) runLoop() {
 				break
 			}
 
-			// Add to list
-			cursor = sent.Add(n)
+			if c.Suppressions != nil {
+				suppressed, err := c.Suppressions.Suppressed(n)
+				if err != nil {
+					c.logln("Error checking suppression list:", err.Error())
+					c.reportLocalFailure(n, err)
+					continue
+				}
+				if suppressed {
+					c.logln("Notification suppressed.")
+					c.reportLocalStatus(n, localSuppressed)
+					continue
+				}
+			}
+
+			if err := c.runBeforeSends(&n); err != nil {
+				c.logln("Notification cancelled before send:", err.Error())
+				c.reportLocalFailure(n, err)
+				continue
+			}
+
+			if n.CollapseID == "" && c.CollapseIDFunc != nil {
+				n.CollapseID = c.CollapseIDFunc(n)
+			}
+			if n.Topic == "" {
+				n.Topic = c.DefaultTopic
+			}
+
+			if c.Schemas != nil {
+				if err := c.Schemas.Validate(n); err != nil {
+					c.logln("Notification failed schema validation:", err.Error())
+					c.reportLocalFailure(n, err)
+					continue
+				}
+			}
+
+			if err := n.ValidateLimits(); err != nil {
+				c.logln("Notification exceeds APNS limits:", err.Error())
+				c.reportLocalFailure(n, err)
+				continue
+			}
+
+			if err := n.ValidatePushType(); err != nil {
+				c.logln("Notification breaks push type rules:", err.Error())
+				c.reportLocalFailure(n, err)
+				continue
+			}
+
+			lint := &LintError{}
+			if err := n.lintPayload(lint); err == nil && lint.err() != nil {
+				if c.Strict {
+					c.logln("Notification failed strict checks:", lint.Error())
+					c.reportLocalFailure(n, lint)
+					continue
+				}
+				c.emit(Event{Type: EventLintWarning, Message: lint.Error()})
+			}
+
+			// Resends were counted the first time round.
+			if fresh && n.PushType == PushTypeLocation && !locations.allow(n.DeviceToken, time.Now()) {
+				err := &LimitError{Limit: LimitLocationRate, Size: MaxLocationPushesPerHour + 1, Max: MaxLocationPushesPerHour}
+				c.logln("Notification throttled:", err.Error())
+				c.reportLocalFailure(n, err)
+				continue
+			}
+
+			n.contentHash = ""
+			hash, err := n.ContentHash()
+			if err != nil {
+				c.logln("Error hashing notification payload:", err.Error())
+				c.reportLocalFailure(n, err)
+				continue
+			}
+			n.contentHash = hash
+
+			if fresh && c.DedupeWindow > 0 && dedupe.duplicate(dedupeKey(n.DeviceToken, hash), time.Now(), c.DedupeWindow) {
+				c.logln("Duplicate notification dropped. Content hash:", hash)
+				c.reportLocalStatus(n, localDuplicate)
+				continue
+			}
 
 			// Set identifier if not specified
 			if n.Identifier == 0 {
 				n.Identifier = c.id
 				c.id++
-			} else if c.id < n.Identifier {
+			} else if c.id <= n.Identifier {
 				c.id = n.Identifier + 1
 			}
 
+			// Add to list
+			cursor = sent.Add(n)
+
 			// Build binary representation of notification.
-			b, err := n.ToBinary()
+			encodeStart := time.Now()
+			b, err := frames.encode(n)
+			c.gauges.addEncode(time.Since(encodeStart))
 			if err != nil {
 				// Building the binary failed in some way, so skip it.
-				c.Failed++
 				cursor = cursor.Next()
 				c.logln("Error building binary for notification:", err.Error())
+				c.reportLocalFailure(n, err)
 				continue
 			}
 
 			// Write the notification binary to the APNS connection.
+			window.wait(c.MaxInFlight, c.InFlightWindow)
 			_, err = c.Conn.Write(b)
+			window.add(time.Now())
 
 			if err == io.EOF {
 				c.logln("Received EOF trying to write notification.")
@@ -248,8 +892,25 @@ func (c *Client) runLoop() {
 				break
 			}
 
-			c.logln("Successfully pushed notification!")
 			c.Sent++
+			n.writtenAt = time.Now()
+			c.rate.observe(n.writtenAt)
+			cursor.Value = n
+			if c.DedupeWindow > 0 {
+				dedupe.add(dedupeKey(n.DeviceToken, n.contentHash), time.Now())
+			}
+			if rate := c.SuccessSampleRate; rate >= 1 || rand.Float64() < rate {
+				c.logln("Successfully pushed notification! Content hash:", n.contentHash)
+				c.publishResult(Result{Notification: n, Disposition: Delivered, ConnID: c.Conn.ID, RemoteAddr: c.Conn.RemoteAddr(), Time: time.Now(), ContentHash: n.contentHash, SampleRate: rate})
+			}
+			if n.callback != nil {
+				n.callback.deliverAfter(c.InFlightWindow, Result{Notification: n, Disposition: Delivered, ConnID: c.Conn.ID, RemoteAddr: c.Conn.RemoteAddr(), Time: time.Now(), ContentHash: n.contentHash})
+			}
+			c.recordCorrelation(n)
+			c.runAfterSends(n)
+			if m := c.currentMirror(); m != nil {
+				m.offer(n)
+			}
 			cursor = cursor.Next()
 		}
 	}
@@ -259,14 +920,17 @@ func readErrs(c *Conn) chan error {
 	errs := make(chan error)
 
 	go func() {
-		p := make([]byte, 6, 6)
-		_, err := c.Read(p)
+		p := make([]byte, errorFrameLength)
+		n, err := io.ReadFull(c, p)
+		if err == io.ErrUnexpectedEOF {
+			err = &FrameError{Frame: "error", Len: n, Reason: "truncated"}
+		}
 		if err != nil {
 			errs <- err
 			return
 		}
 
-		e := NewError(p)
+		e, _ := ParseError(p)
 		errs <- &e
 	}()
 
@@ -0,0 +1,486 @@
+package apns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ProductionHost and DevelopmentHost are the endpoints of Apple's
+	// HTTP/2 provider API.
+	ProductionHost  = "https://api.push.apple.com"
+	DevelopmentHost = "https://api.sandbox.push.apple.com"
+)
+
+// DefaultHTTP2Timeout bounds a single request made by Client2.
+const DefaultHTTP2Timeout = 30 * time.Second
+
+// Defaults for Client2.SetHealthCheck, which NewClient2 applies from Go
+// 1.24 on.
+const (
+	DefaultHTTP2PingInterval = time.Minute
+	DefaultHTTP2PingTimeout  = 15 * time.Second
+)
+
+// Response is APNS's answer to a single request made by Client2.
+type Response struct {
+	// DeviceToken is the token the push was sent to.
+	DeviceToken string
+
+	// StatusCode is the HTTP status; 200 means APNS accepted the push.
+	StatusCode int
+
+	// Reason explains why APNS rejected the push, e.g. "BadDeviceToken".
+	// It's empty on success.
+	Reason string
+
+	// ID is the apns-id APNS assigned to the push, or echoed back if the
+	// request set one. Apple's delivery logs are keyed by it.
+	ID string
+
+	// UniqueID is the apns-unique-id APNS returns in the development
+	// environment, for looking the push up in the Push Notifications
+	// Console. It's empty in production.
+	UniqueID string
+
+	// Timestamp is set on 410 Unregistered answers to when APNS last
+	// confirmed the token was no longer valid. Only prune the token if
+	// the device hasn't registered it again since.
+	Timestamp time.Time
+
+	// Hedged is true if the answer came from a hedged duplicate of the
+	// request rather than the original.
+	Hedged bool
+
+	// RetryAfter is how long APNS asked to wait before trying again, if
+	// it sent a Retry-After header.
+	RetryAfter time.Duration
+}
+
+// OK reports whether APNS accepted the push.
+func (r Response) OK() bool {
+	return r.StatusCode == http.StatusOK
+}
+
+// Unregistered reports whether APNS rejected the push because the token
+// is no longer active for the topic.
+func (r Response) Unregistered() bool {
+	return r.StatusCode == http.StatusGone
+}
+
+// Client2 sends notifications through Apple's HTTP/2 provider API, which
+// replaces the binary gateway protocol used by Client. Unlike Client, every
+// send waits for APNS's answer, so there's no resend buffer or
+// FailedNotifs channel. Both can be used side by side while migrating.
+type Client2 struct {
+	// Host is ProductionHost or DevelopmentHost.
+	Host string
+
+	// HTTPClient makes the requests. NewClient2 sets up one that presents
+	// the certificate and speaks HTTP/2.
+	HTTPClient *http.Client
+
+	// Tokens, if set, authenticates requests with provider tokens instead
+	// of a certificate.
+	Tokens *TokenProvider
+
+	// DefaultTopic is sent as apns-topic for notifications without a
+	// Topic, usually the app's bundle ID. Certificates imply their topic,
+	// but token authentication needs it.
+	DefaultTopic string
+
+	// CollapseIDFunc, if set, derives the collapse ID of notifications that
+	// don't have one, as Client.CollapseIDFunc does.
+	CollapseIDFunc func(n Notification) string
+
+	// HedgeAfter, if positive, enables hedging for HedgePushTypes: when
+	// APNS hasn't answered a request within HedgeAfter, a duplicate is
+	// sent on a second connection with the same apns-id and collapse ID,
+	// and whichever answers first wins. The device may get the push twice,
+	// so this is only meant for pushes where latency matters most.
+	HedgeAfter time.Duration
+
+	// HedgePushTypes are the push types hedged. DefaultHedgePushTypes if
+	// nil.
+	HedgePushTypes []PushType
+
+	// PriorityLane lists push types, such as VoIP, that get a connection
+	// of their own, so bulk traffic filling the shared connection's
+	// streams can't hold them up.
+	PriorityLane []PushType
+
+	// Strict fails sends that Notification.Lint finds problems with, or
+	// that have no topic when using token authentication. Otherwise the
+	// problems are passed to Warn, or logged if Warn is nil and Verbose
+	// is set.
+	Strict  bool
+	Warn    func(n Notification, err error)
+	Verbose bool
+
+	// Concurrency, if set, caps how many SendSync calls have a request in
+	// flight at once, adapting the cap to how APNS copes. Calls over the
+	// cap wait their turn, except for PriorityLane push types, which
+	// bypass it so bulk traffic can't hold them up here either.
+	Concurrency *AIMD
+
+	// PoolSize, if above 1, spreads requests over that many connections,
+	// sending each on the one with the fewest in flight. APNS allows many
+	// streams per connection, but a few connections carry more than one.
+	// MaxStreams, if positive, caps the requests in flight on each; sends
+	// over the cap wait for a stream to free up. PriorityLane push types
+	// keep their own connection. Set them before sending.
+	PoolSize   int
+	MaxStreams int
+
+	// TokenCooldown is how long sends to a device token are held back
+	// after APNS answers it with 429 TooManyRequests, unless APNS says
+	// otherwise with Retry-After. Zero disables cooldowns; NewClient2
+	// sets DefaultTokenCooldown.
+	TokenCooldown time.Duration
+
+	cooldowns   tokenCooldowns
+	mu          sync.Mutex
+	hedgeClient *http.Client
+	laneClient  *http.Client
+	pool        []*poolMember
+	poolChanged chan struct{}
+}
+
+// NewClient2 creates a Client2 that authenticates with cert.
+func NewClient2(host string, cert tls.Certificate) *Client2 {
+	transport := newTransport2(&tls.Config{Certificates: []tls.Certificate{cert}})
+
+	return &Client2{
+		Host:          host,
+		HTTPClient:    &http.Client{Transport: transport, Timeout: DefaultHTTP2Timeout},
+		TokenCooldown: DefaultTokenCooldown,
+	}
+}
+
+// NewClient2WithToken creates a Client2 that authenticates with provider
+// tokens from tokens, pushing to topic.
+func NewClient2WithToken(host string, tokens *TokenProvider, topic string) *Client2 {
+	transport := newTransport2(&tls.Config{})
+
+	return &Client2{
+		Host:          host,
+		HTTPClient:    &http.Client{Transport: transport, Timeout: DefaultHTTP2Timeout},
+		Tokens:        tokens,
+		DefaultTopic:  topic,
+		TokenCooldown: DefaultTokenCooldown,
+	}
+}
+
+// newTransport2 returns the transport NewClient2 sets up: HTTP/2 with
+// long-lived connections, health-checked with pings where the Go version
+// supports it.
+func newTransport2(conf *tls.Config) *http.Transport {
+	t := &http.Transport{
+		TLSClientConfig:   conf,
+		ForceAttemptHTTP2: true,
+		IdleConnTimeout:   time.Hour,
+	}
+	setPings(t, DefaultHTTP2PingInterval, DefaultHTTP2PingTimeout)
+	return t
+}
+
+// SetHealthCheck makes the client send an HTTP/2 PING on connections that
+// haven't received anything for interval, and drop those that don't answer
+// within timeout, so a dead connection is replaced before the next send
+// waits on it rather than during. A zero interval turns pings off. It
+// fails if HTTPClient doesn't use an *http.Transport, or before Go 1.24.
+func (c *Client2) SetHealthCheck(interval, timeout time.Duration) error {
+	t, ok := c.HTTPClient.Transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("apns: can't health-check a %T", c.HTTPClient.Transport)
+	}
+	return setPings(t, interval, timeout)
+}
+
+// BindLocalAddr binds the client's connections to the local address ip,
+// as Conn.LocalAddrs does for Client. Only gateway addresses of ip's
+// family are dialed. It fails if HTTPClient doesn't use an
+// *http.Transport.
+func (c *Client2) BindLocalAddr(ip net.IP) error {
+	t, ok := c.HTTPClient.Transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("apns: can't bind a %T to a local address", c.HTTPClient.Transport)
+	}
+
+	d := &net.Dialer{LocalAddr: &net.TCPAddr{IP: ip}}
+	t.DialContext = d.DialContext
+	return nil
+}
+
+// NewClient2WithFiles creates a Client2 from certificate and key in the
+// specified files.
+func NewClient2WithFiles(host string, certFile string, keyFile string) (*Client2, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient2(host, cert), nil
+}
+
+// SendSync sends n and waits for APNS's answer. The error is only set if
+// the notification couldn't be built or the request failed; a push APNS
+// rejected still returns a nil error, with the status and reason in the
+// Response. Sends to a device token cooling down after 429
+// TooManyRequests wait for the cooldown to end, or fail with a
+// *CooldownError if ctx ends first.
+func (c *Client2) SendSync(ctx context.Context, n Notification) (Response, error) {
+	if n.CollapseID == "" && c.CollapseIDFunc != nil {
+		n.CollapseID = c.CollapseIDFunc(n)
+	}
+
+	if err := n.ValidateLimits(); err != nil {
+		return Response{}, err
+	}
+	if err := n.ValidatePushType(); err != nil {
+		return Response{}, err
+	}
+
+	body, err := n.payloadBytes()
+	if err != nil {
+		return Response{}, err
+	}
+
+	if n.Topic == "" {
+		n.Topic = c.DefaultTopic
+	}
+
+	if err := c.lint(n); err != nil {
+		if c.Strict {
+			return Response{}, err
+		}
+		c.warn(n, err)
+	}
+
+	if err := c.waitCooldown(ctx, n.DeviceToken); err != nil {
+		return Response{}, err
+	}
+
+	header := http.Header{}
+	setHeaders(header, n)
+	if err := c.authorize(header); err != nil {
+		return Response{}, err
+	}
+
+	client := c.HTTPClient
+	var member *poolMember
+	limiter := c.Concurrency
+	switch {
+	case hasPushType(c.PriorityLane, n.PushType):
+		limiter = nil
+		client = c.dedicatedClient(&c.laneClient)
+	case c.pooled():
+		if member, err = c.acquirePool(ctx); err != nil {
+			return Response{}, err
+		}
+		client = member.client
+	}
+
+	if limiter != nil {
+		if err := limiter.acquire(ctx); err != nil {
+			if member != nil {
+				c.releasePool(member)
+			}
+			return Response{}, err
+		}
+	}
+	start := time.Now()
+
+	var r Response
+	path := "/3/device/" + n.DeviceToken
+	if c.hedges(n) {
+		r, err = c.sendHedged(ctx, client, path, header, body)
+	} else {
+		r, err = c.send(ctx, client, path, header, body)
+	}
+	if err == nil && c.refreshExpiredToken(r, header) {
+		r, err = c.send(ctx, client, path, header, body)
+	}
+	r.DeviceToken = n.DeviceToken
+	if err == nil {
+		c.observeCooldown(r)
+	}
+
+	if member != nil {
+		c.releasePool(member)
+	}
+	if limiter != nil {
+		limiter.release(start, r, err)
+	}
+
+	return r, err
+}
+
+func (c *Client2) warn(n Notification, err error) {
+	if c.Warn != nil {
+		c.Warn(n, err)
+		return
+	}
+	if !c.Verbose {
+		return
+	}
+	log.Printf("apns: warning for notification to %s: %v", n.DeviceToken, err)
+}
+
+// authorize sets the provider token on h when using token authentication.
+func (c *Client2) authorize(h http.Header) error {
+	if c.Tokens == nil {
+		return nil
+	}
+
+	token, err := c.Tokens.Token()
+	if err != nil {
+		return err
+	}
+	h.Set("authorization", "bearer "+token)
+	return nil
+}
+
+// dedicatedClient returns *hc, first setting it to a copy of HTTPClient
+// with its own transport, and so its own connections.
+func (c *Client2) dedicatedClient(hc **http.Client) *http.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if *hc == nil {
+		client := *c.HTTPClient
+		if t, ok := client.Transport.(*http.Transport); ok {
+			client.Transport = t.Clone()
+		}
+		*hc = &client
+	}
+	return *hc
+}
+
+func hasPushType(types []PushType, t PushType) bool {
+	for _, u := range types {
+		if u == t {
+			return true
+		}
+	}
+	return false
+}
+
+// send makes a single request posting a push to path.
+func (c *Client2) send(ctx context.Context, client *http.Client, path string, header http.Header, body []byte) (Response, error) {
+	url := strings.TrimSuffix(c.Host, "/") + path
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, err
+	}
+	req = req.WithContext(ctx)
+	req.Header = header.Clone()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	r, err := readResponse(resp)
+	if err == nil {
+		c.checkReason(resp, r.Reason, strings.TrimPrefix(header.Get("authorization"), "bearer "))
+	}
+
+	return r, err
+}
+
+// checkReason reacts to APNS rejecting the provider token the request was
+// sent with, token, with reason. An expired token is handled like an
+// invalid one: it's usually old enough to replace, or was signed by a
+// clock running behind Apple's.
+func (c *Client2) checkReason(resp *http.Response, reason, token string) {
+	if c.Tokens == nil || (reason != "InvalidProviderToken" && reason != "ExpiredProviderToken") {
+		return
+	}
+
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		c.Tokens.Guard.InvalidProviderToken(token, date)
+	} else {
+		c.Tokens.Guard.invalidate(token)
+	}
+}
+
+// refreshExpiredToken sets a new provider token on header after APNS
+// rejected the one it had as expired, reporting whether it did so the
+// request can be retried once. The TokenGuard decides whether a new token
+// may be signed yet, which keeps refreshes within Apple's limits.
+func (c *Client2) refreshExpiredToken(r Response, header http.Header) bool {
+	if c.Tokens == nil || r.Reason != "ExpiredProviderToken" {
+		return false
+	}
+
+	old := header.Get("authorization")
+	if err := c.authorize(header); err != nil {
+		return false
+	}
+	return header.Get("authorization") != old
+}
+
+// setHeaders sets the request headers describing n.
+func setHeaders(h http.Header, n Notification) {
+	h.Set("Content-Type", "application/json")
+
+	switch n.expirationPolicy() {
+	case ExpirationImmediate:
+		h.Set("apns-expiration", "0")
+	case ExpirationAt:
+		h.Set("apns-expiration", strconv.FormatInt(n.expiry(), 10))
+	}
+	h.Set("apns-priority", strconv.Itoa(n.EffectivePriority()))
+	if t := n.InferPushType(); t != "" {
+		h.Set("apns-push-type", string(t))
+	}
+	if n.CollapseID != "" {
+		h.Set("apns-collapse-id", n.CollapseID)
+	}
+	if n.Topic != "" {
+		h.Set("apns-topic", n.Topic)
+	}
+}
+
+func readResponse(resp *http.Response) (Response, error) {
+	r := Response{
+		StatusCode: resp.StatusCode,
+		ID:         resp.Header.Get("apns-id"),
+		UniqueID:   resp.Header.Get("apns-unique-id"),
+		RetryAfter: retryAfter(resp.Header),
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return r, nil
+	}
+
+	var body struct {
+		Reason    string `json:"reason"`
+		Timestamp int64  `json:"timestamp"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && err != io.EOF {
+		return r, err
+	}
+	r.Reason = body.Reason
+	if body.Timestamp != 0 {
+		// APNS reports milliseconds since the epoch.
+		r.Timestamp = time.Unix(0, body.Timestamp*int64(time.Millisecond))
+	}
+
+	return r, nil
+}
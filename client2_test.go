@@ -0,0 +1,416 @@
+package apns_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+// mockHTTP2Server records the requests it gets and answers each with
+// handler, or 200 if handler is nil.
+type mockHTTP2Server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	handler  http.HandlerFunc
+}
+
+func newMockHTTP2Server() *mockHTTP2Server {
+	m := &mockHTTP2Server{}
+	m.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		m.mu.Lock()
+		m.requests = append(m.requests, r)
+		m.bodies = append(m.bodies, string(b))
+		m.mu.Unlock()
+		if m.handler != nil {
+			m.handler(w, r)
+		}
+	}))
+	m.EnableHTTP2 = true
+	m.StartTLS()
+	return m
+}
+
+// newTestClient2 returns a Client2 for s that trusts its certificate.
+func newTestClient2(s *mockHTTP2Server) *apns.Client2 {
+	cert, _ := tls.X509KeyPair([]byte(DummyCert), []byte(DummyKey))
+	c := apns.NewClient2(s.URL, cert)
+	c.HTTPClient.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify = true
+	return c
+}
+
+// blackholeProxy forwards connections to a server until blackhole is
+// called, after which the connections open at the time silently drop
+// everything, like sockets behind a NAT mapping that expired.
+type blackholeProxy struct {
+	net.Listener
+	mu   sync.Mutex
+	dead []*int32
+}
+
+func newBlackholeProxy(target string) *blackholeProxy {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	p := &blackholeProxy{Listener: l}
+
+	go func() {
+		for {
+			in, err := l.Accept()
+			if err != nil {
+				return
+			}
+			out, err := net.Dial("tcp", target)
+			if err != nil {
+				in.Close()
+				continue
+			}
+
+			dead := new(int32)
+			p.mu.Lock()
+			p.dead = append(p.dead, dead)
+			p.mu.Unlock()
+
+			pipe := func(dst, src net.Conn) {
+				buf := make([]byte, 32*1024)
+				for {
+					n, err := src.Read(buf)
+					if err != nil {
+						dst.Close()
+						return
+					}
+					if atomic.LoadInt32(dead) == 0 {
+						dst.Write(buf[:n])
+					}
+				}
+			}
+			go pipe(out, in)
+			go pipe(in, out)
+		}
+	}()
+	return p
+}
+
+func (p *blackholeProxy) blackhole() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, dead := range p.dead {
+		atomic.StoreInt32(dead, 1)
+	}
+}
+
+var _ = Describe("Client2", func() {
+	var s *mockHTTP2Server
+
+	BeforeEach(func() {
+		s = newMockHTTP2Server()
+	})
+
+	AfterEach(func() {
+		s.Close()
+	})
+
+	Describe("#SendSync", func() {
+		It("should post the payload over HTTP/2", func() {
+			c := newTestClient2(s)
+
+			exp := time.Unix(1500000000, 0)
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			n.Payload.APS.Alert.Body = "hi"
+			n.Expiration = &exp
+			n.Priority = 5
+			n.CollapseID = "score"
+
+			r, err := c.SendSync(context.Background(), n)
+			Expect(err).To(BeNil())
+			Expect(r.OK()).To(BeTrue())
+
+			Expect(s.requests).To(HaveLen(1))
+			req := s.requests[0]
+			Expect(req.ProtoMajor).To(Equal(2))
+			Expect(req.Method).To(Equal("POST"))
+			Expect(req.URL.Path).To(Equal("/3/device/abcd"))
+			Expect(req.Header.Get("apns-expiration")).To(Equal("1500000000"))
+			Expect(req.Header.Get("apns-priority")).To(Equal("5"))
+			Expect(req.Header.Get("apns-collapse-id")).To(Equal("score"))
+			Expect(req.Header.Get("apns-push-type")).To(Equal("alert"))
+			Expect(s.bodies[0]).To(MatchJSON(`{"aps":{"alert":"hi"}}`))
+		})
+
+		It("should leave unset headers out", func() {
+			c := newTestClient2(s)
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			c.SendSync(context.Background(), n)
+
+			Expect(s.requests[0].Header).NotTo(HaveKey("Apns-Expiration"))
+			Expect(s.requests[0].Header.Get("apns-priority")).To(Equal("10"))
+			Expect(s.requests[0].Header).NotTo(HaveKey("Apns-Push-Type"))
+		})
+
+		It("should send an explicit expiration of 0", func() {
+			c := newTestClient2(s)
+
+			n := apns.NewNotification().ExpireImmediately()
+			n.DeviceToken = "abcd"
+			c.SendSync(context.Background(), n)
+
+			Expect(s.requests[0].Header.Get("apns-expiration")).To(Equal("0"))
+		})
+
+		It("should return the status and reason of rejected pushes", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+			}
+			c := newTestClient2(s)
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			r, err := c.SendSync(context.Background(), n)
+
+			Expect(err).To(BeNil())
+			Expect(r.OK()).To(BeFalse())
+			Expect(r.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(r.Reason).To(Equal("BadDeviceToken"))
+		})
+
+		It("should return when APNS found a token unregistered", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusGone)
+				w.Write([]byte(`{"reason":"Unregistered","timestamp":1500000000123}`))
+			}
+			c := newTestClient2(s)
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			r, err := c.SendSync(context.Background(), n)
+
+			Expect(err).To(BeNil())
+			Expect(r.Unregistered()).To(BeTrue())
+			Expect(r.DeviceToken).To(Equal("abcd"))
+			Expect(r.Timestamp.Equal(time.Unix(1500000000, 123*int64(time.Millisecond)))).To(BeTrue())
+		})
+
+		It("should return the IDs APNS assigned", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("apns-id", "EC1BF194-B3B2-424A-89A9-5A918A6E7B6F")
+				w.Header().Set("apns-unique-id", "a8f2b4c5-6d7e-8f90-a1b2-c3d4e5f60718")
+			}
+			c := newTestClient2(s)
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			r, err := c.SendSync(context.Background(), n)
+
+			Expect(err).To(BeNil())
+			Expect(r.ID).To(Equal("EC1BF194-B3B2-424A-89A9-5A918A6E7B6F"))
+			Expect(r.UniqueID).To(Equal("a8f2b4c5-6d7e-8f90-a1b2-c3d4e5f60718"))
+		})
+
+		It("should derive missing collapse IDs", func() {
+			c := newTestClient2(s)
+			c.CollapseIDFunc = func(n apns.Notification) string {
+				return "thread-" + n.Metadata["thread"]
+			}
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			n.Metadata = map[string]string{"thread": "42"}
+			c.SendSync(context.Background(), n)
+
+			n.CollapseID = "explicit"
+			c.SendSync(context.Background(), n)
+
+			Expect(s.requests[0].Header.Get("apns-collapse-id")).To(Equal("thread-42"))
+			Expect(s.requests[1].Header.Get("apns-collapse-id")).To(Equal("explicit"))
+		})
+
+		It("should not send notifications over the limits", func() {
+			c := newTestClient2(s)
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			n.CollapseID = string(make([]byte, apns.MaxCollapseIDSize+1))
+
+			_, err := c.SendSync(context.Background(), n)
+			Expect(err).To(BeAssignableToTypeOf(&apns.LimitError{}))
+			Expect(s.requests).To(BeEmpty())
+		})
+
+		It("should fail if the request does", func() {
+			c := newTestClient2(s)
+			s.Close()
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			_, err := c.SendSync(context.Background(), n)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("#BindLocalAddr", func() {
+		It("should dial from the local address", func() {
+			c := newTestClient2(s)
+			Expect(c.BindLocalAddr(net.ParseIP("127.0.0.1"))).To(BeNil())
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			_, err := c.SendSync(context.Background(), n)
+			Expect(err).To(BeNil())
+			Expect(s.requests[0].RemoteAddr).To(HavePrefix("127.0.0.1:"))
+
+			c = newTestClient2(s)
+			Expect(c.BindLocalAddr(net.ParseIP("::1"))).To(BeNil())
+			_, err = c.SendSync(context.Background(), n)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("#SetHealthCheck", func() {
+		It("should fail for other transports", func() {
+			c := newTestClient2(s)
+			c.HTTPClient.Transport = nil
+			Expect(c.SetHealthCheck(time.Second, time.Second)).NotTo(BeNil())
+		})
+	})
+
+	Describe("#PriorityLane", func() {
+		send := func(c *apns.Client2, t apns.PushType) {
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			n.PushType = t
+			n.Payload.APS.Alert.Body = "hi"
+			_, err := c.SendSync(context.Background(), n)
+			Expect(err).To(BeNil())
+		}
+
+		It("should send lane push types on their own connection", func() {
+			c := newTestClient2(s)
+			c.PriorityLane = []apns.PushType{apns.PushTypeVoIP}
+
+			send(c, apns.PushTypeAlert)
+			send(c, apns.PushTypeVoIP)
+			send(c, apns.PushTypeAlert)
+
+			Expect(s.requests[1].RemoteAddr).NotTo(Equal(s.requests[0].RemoteAddr))
+			Expect(s.requests[2].RemoteAddr).To(Equal(s.requests[0].RemoteAddr))
+		})
+
+		It("should share the connection without a lane", func() {
+			c := newTestClient2(s)
+
+			send(c, apns.PushTypeAlert)
+			send(c, apns.PushTypeVoIP)
+
+			Expect(s.requests[1].RemoteAddr).To(Equal(s.requests[0].RemoteAddr))
+		})
+	})
+
+	Describe("with token authentication", func() {
+		var p *apns.TokenProvider
+
+		BeforeEach(func() {
+			_, p8 := newP8()
+			p, _ = apns.NewTokenProvider("KEY123", "TEAM456", p8)
+		})
+
+		newTokenClient := func() *apns.Client2 {
+			c := apns.NewClient2WithToken(s.URL, p, "com.example.app")
+			c.HTTPClient = s.Client()
+			return c
+		}
+
+		It("should send the token and topic", func() {
+			c := newTokenClient()
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			_, err := c.SendSync(context.Background(), n)
+			Expect(err).To(BeNil())
+
+			token, _ := p.Token()
+			Expect(s.requests[0].Header.Get("authorization")).To(Equal("bearer " + token))
+			Expect(s.requests[0].Header.Get("apns-topic")).To(Equal("com.example.app"))
+		})
+
+		It("should send a notification's own topic in place of the default", func() {
+			c := newTokenClient()
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			n.Topic = "com.example.app.voip"
+			n.PushType = apns.PushTypeVoIP
+			c.SendSync(context.Background(), n)
+
+			Expect(s.requests[0].Header.Get("apns-topic")).To(Equal("com.example.app.voip"))
+		})
+
+		It("should correct for clock skew when the token is rejected", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"reason":"InvalidProviderToken"}`))
+			}
+			c := newTokenClient()
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			r, _ := c.SendSync(context.Background(), n)
+
+			Expect(r.Reason).To(Equal("InvalidProviderToken"))
+			Expect(p.Guard.Skew()).To(BeNumerically("~", -time.Hour, 2*time.Second))
+		})
+
+		It("should retry with a new token when the old one expired", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				s.mu.Lock()
+				first := len(s.requests) == 1
+				s.mu.Unlock()
+				if first {
+					w.Header().Set("Date", time.Now().Add(2*time.Hour).UTC().Format(http.TimeFormat))
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte(`{"reason":"ExpiredProviderToken"}`))
+				}
+			}
+			c := newTokenClient()
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			r, err := c.SendSync(context.Background(), n)
+			Expect(err).To(BeNil())
+			Expect(r.OK()).To(BeTrue())
+
+			Expect(s.requests).To(HaveLen(2))
+			Expect(s.requests[1].Header.Get("authorization")).NotTo(Equal(s.requests[0].Header.Get("authorization")))
+		})
+
+		It("should not refresh a token too young to replace", func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"reason":"ExpiredProviderToken"}`))
+			}
+			c := newTokenClient()
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			r, _ := c.SendSync(context.Background(), n)
+
+			Expect(r.Reason).To(Equal("ExpiredProviderToken"))
+			Expect(s.requests).To(HaveLen(1))
+		})
+	})
+})
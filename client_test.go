@@ -2,12 +2,17 @@ package apns_test
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/binary"
+	"errors"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/timehop/apns"
 	"io/ioutil"
+	"log"
+	"net"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -381,4 +386,430 @@ var _ = Describe("Client", func() {
 			})
 		})
 	})
+
+	Describe("#OnBeforeSend", func() {
+		Context("hook returns an error", func() {
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+				},
+			}
+
+			It("should report the notification as failed", func(d Done) {
+				mockDone := make(chan interface{})
+				withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+					c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+					c.Conn.Conf.InsecureSkipVerify = true
+
+					c.OnBeforeSend(func(n *apns.Notification) error {
+						return errors.New("user opted out")
+					})
+
+					go func() {
+						f := <-c.FailedNotifs
+
+						Expect(f.Notif.ID).To(Equal("opted_out"))
+						Expect(f.Err.Error()).To(Equal("user opted out"))
+						Expect(f.ConnID).To(Equal(c.Conn.ID))
+
+						close(mockDone)
+						close(d)
+					}()
+
+					n := apns.NewNotification()
+					n.ID = "opted_out"
+					Expect(c.Send(n)).To(BeNil())
+				})
+			})
+		})
+
+		Context("hook uses the client", func() {
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+				},
+			}
+
+			It("should not deadlock", func(d Done) {
+				mockDone := make(chan interface{})
+				withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+					c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+					c.Conn.Conf.InsecureSkipVerify = true
+					sub := c.Subscribe(1, apns.DropNewest)
+
+					c.OnBeforeSend(func(n *apns.Notification) error {
+						c.OnBeforeSend(func(*apns.Notification) error { return nil })
+						c.Subscribe(1, apns.DropNewest).Unsubscribe()
+						return errors.New("user opted out")
+					})
+
+					c.Send(apns.NewNotification())
+					Expect((<-sub.Results).Err).To(MatchError("user opted out"))
+
+					close(mockDone)
+					close(d)
+				})
+			})
+		})
+	})
+
+	Describe("#MaxInFlight", func() {
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		It("should pause dispatch once the window is full", func(d Done) {
+			mockDone := make(chan interface{})
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				c.MaxInFlight = 1
+				c.InFlightWindow = 50 * time.Millisecond
+
+				written := make(chan time.Time, 3)
+				c.OnAfterSend(func(n apns.Notification) { written <- time.Now() })
+
+				for i := 0; i < 3; i++ {
+					n := apns.NewNotification()
+					n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+					c.Send(n)
+				}
+
+				first := <-written
+				<-written
+				last := <-written
+				Expect(last.Sub(first)).To(BeNumerically(">=", 100*time.Millisecond))
+
+				close(mockDone)
+				close(d)
+			})
+		})
+	})
+
+	Describe("buffer underrun", func() {
+		tok := "9999999999999999999999999999999999999999999999999999999999999999"
+
+		n1 := apns.Notification{Identifier: 1, DeviceToken: tok}
+		n1b, _ := n1.ToBinary()
+		n2 := apns.Notification{Identifier: 2, DeviceToken: tok}
+		n2b, _ := n2.ToBinary()
+
+		errPayload := bytes.NewBuffer([]byte{})
+		binary.Write(errPayload, binary.BigEndian, uint8(8))
+		binary.Write(errPayload, binary.BigEndian, uint8(1))
+		binary.Write(errPayload, binary.BigEndian, uint32(1))
+
+		It("should emit an event and resend the buffer after the failed notification", func(d Done) {
+			mockDone := make(chan interface{})
+
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: make([]byte, len(n1b))},
+					serverAction{action: readAction, data: make([]byte, len(n2b))},
+					serverAction{action: writeAction, data: errPayload.Bytes()},
+					serverAction{action: closeAction, data: []byte{}},
+				},
+				[]serverAction{
+					serverAction{action: readAction, data: make([]byte, len(n2b)), cb: func(a serverAction) {
+						Expect(a.data).To(Equal(n2b))
+						close(mockDone)
+					}},
+				},
+			}
+
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				cert, _ := tls.X509KeyPair([]byte(DummyCert), []byte(DummyKey))
+				c, _ := apns.NewClientWithConfig(s.Address(), cert, func(c *apns.Client) error {
+					c.Conn.Conf.InsecureSkipVerify = true
+					c.BufferSize = 1
+					return nil
+				})
+
+				c.Send(n1)
+				c.Send(n2)
+
+				e := <-c.Events
+				Expect(e.Type).To(Equal(apns.EventBufferUnderrun))
+
+				e = <-c.Events
+				Expect(e.Type).To(Equal(apns.EventRequeue))
+				Expect(e.Count).To(Equal(1))
+			})
+
+			close(d)
+		})
+
+		It("should not resend notifications sent before the failed one", func(d Done) {
+			mockDone := make(chan interface{})
+
+			n3 := apns.Notification{Identifier: 3, DeviceToken: tok}
+			n3b, _ := n3.ToBinary()
+
+			// The failed notification, 5, was never buffered.
+			unknown := bytes.NewBuffer([]byte{})
+			binary.Write(unknown, binary.BigEndian, uint8(8))
+			binary.Write(unknown, binary.BigEndian, uint8(1))
+			binary.Write(unknown, binary.BigEndian, uint32(5))
+
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: make([]byte, len(n1b))},
+					serverAction{action: readAction, data: make([]byte, len(n2b))},
+					serverAction{action: writeAction, data: unknown.Bytes()},
+					serverAction{action: closeAction, data: []byte{}},
+				},
+				[]serverAction{
+					serverAction{action: readAction, data: make([]byte, len(n3b)), cb: func(a serverAction) {
+						Expect(a.data).To(Equal(n3b))
+						close(mockDone)
+					}},
+				},
+			}
+
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				defer c.Close()
+
+				c.Send(n1)
+				c.Send(n2)
+
+				e := <-c.Events
+				Expect(e.Type).To(Equal(apns.EventBufferUnderrun))
+
+				c.Send(n3)
+				<-mockDone
+			})
+
+			close(d)
+		})
+	})
+
+	Describe("#CollapseIDFunc", func() {
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		It("should fill in missing collapse IDs", func(d Done) {
+			mockDone := make(chan interface{})
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				c.CollapseIDFunc = func(n apns.Notification) string {
+					return "thread-" + n.Metadata["thread"]
+				}
+
+				written := make(chan apns.Notification, 2)
+				c.OnAfterSend(func(n apns.Notification) { written <- n })
+
+				n := apns.NewNotification()
+				n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+				n.Metadata = map[string]string{"thread": "42"}
+				c.Send(n)
+
+				n.CollapseID = "explicit"
+				c.Send(n)
+
+				Expect((<-written).CollapseID).To(Equal("thread-42"))
+				Expect((<-written).CollapseID).To(Equal("explicit"))
+
+				close(mockDone)
+				close(d)
+			})
+		})
+	})
+
+	Describe(".NewClientWithConfig", func() {
+		It("should not start a client it fails to configure", func() {
+			c, err := apns.NewClientWithConfig("127.0.0.1:1", tls.Certificate{}, func(c *apns.Client) error {
+				c.BufferSize = 0
+				return c.Validate()
+			})
+			Expect(c).To(BeNil())
+
+			var ce *apns.ConfigError
+			Expect(errors.As(err, &ce)).To(BeTrue())
+		})
+	})
+
+	Describe("#QueueSize", func() {
+		tok := "9999999999999999999999999999999999999999999999999999999999999999"
+
+		It("should accept sends while the gateway is unreachable", func(d Done) {
+			c, _ := apns.NewClientWithConfig("127.0.0.1:1", tls.Certificate{}, func(c *apns.Client) error {
+				c.QueueSize = 2
+				return nil
+			})
+
+			c.Send(apns.Notification{DeviceToken: tok})
+			c.Send(apns.Notification{DeviceToken: tok})
+
+			close(d)
+		})
+
+		It("should use the default queue size for zero", func(d Done) {
+			c, _ := apns.NewClientWithConfig("127.0.0.1:1", tls.Certificate{}, func(c *apns.Client) error {
+				c.QueueSize = 0
+				return c.Validate()
+			})
+			defer c.Close()
+
+			for i := 0; i < 3; i++ {
+				Expect(c.Send(apns.Notification{DeviceToken: tok})).To(BeNil())
+			}
+
+			close(d)
+		})
+
+		It("should block once the queue is full", func() {
+			c, _ := apns.NewClientWithConfig("127.0.0.1:1", tls.Certificate{}, func(c *apns.Client) error {
+				c.QueueSize = 1
+				return nil
+			})
+
+			sent := make(chan struct{}, 3)
+			go func() {
+				for i := 0; i < 3; i++ {
+					c.Send(apns.Notification{DeviceToken: tok})
+					sent <- struct{}{}
+				}
+			}()
+
+			Eventually(sent).Should(HaveLen(1))
+			Consistently(sent, 100*time.Millisecond).Should(HaveLen(1))
+		})
+	})
+
+	Describe("#SoftFail", func() {
+		tok := "9999999999999999999999999999999999999999999999999999999999999999"
+
+		It("should skip notifications while it can't connect", func() {
+			c, _ := apns.NewClientWithConfig("127.0.0.1:1", tls.Certificate{}, func(c *apns.Client) error {
+				c.SoftFail = true
+				c.QueueSize = 1
+				return nil
+			})
+			defer c.Close()
+
+			results := make(chan apns.Result, 5)
+			for i := 0; i < 5; i++ {
+				c.Send(apns.Notification{DeviceToken: tok}, apns.OnResult(func(r apns.Result) { results <- r }))
+			}
+
+			for i := 0; i < 5; i++ {
+				r := <-results
+				Expect(r.Disposition).To(Equal(apns.Skipped))
+				Expect(r.Err.(*apns.Error).Code()).To(Equal(apns.CodeSkipped))
+			}
+			Expect(c.Skipped).To(Equal(5))
+		})
+
+		It("should log the outage once, without device tokens", func() {
+			var logged bytes.Buffer
+			log.SetOutput(&logged)
+			defer log.SetOutput(os.Stderr)
+
+			c, _ := apns.NewClientWithConfig("127.0.0.1:1", tls.Certificate{}, func(c *apns.Client) error {
+				c.SoftFail = true
+				return nil
+			})
+
+			results := make(chan apns.Result, 3)
+			for i := 0; i < 3; i++ {
+				c.Send(apns.Notification{DeviceToken: tok}, apns.OnResult(func(r apns.Result) { results <- r }))
+			}
+			for i := 0; i < 3; i++ {
+				<-results
+			}
+			c.Close()
+
+			Expect(strings.Count(logged.String(), "skipping notifications")).To(Equal(1))
+			Expect(logged.String()).NotTo(ContainSubstring(tok))
+		})
+	})
+
+	Describe("#Close", func() {
+		It("should be safe to call repeatedly and concurrently", func(d Done) {
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+
+			errs := make(chan error, 2)
+			go func() { errs <- c.Close() }()
+			go func() { errs <- c.Close() }()
+
+			Expect(<-errs).To(BeNil())
+			Expect(<-errs).To(BeNil())
+			Expect(c.Close()).To(BeNil())
+
+			close(d)
+		})
+
+		It("should make Send return ErrClosed", func(d Done) {
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			c.Close()
+
+			Expect(c.Send(apns.NewNotification())).To(Equal(apns.ErrClosed))
+
+			_, err := c.Forget("9999999999999999999999999999999999999999999999999999999999999999")
+			Expect(err).To(Equal(apns.ErrClosed))
+
+			close(d)
+		})
+
+		It("should unblock a Send waiting on a full queue", func(d Done) {
+			c, _ := apns.NewClientWithConfig("127.0.0.1:1", tls.Certificate{}, func(c *apns.Client) error {
+				c.QueueSize = 1
+				return nil
+			})
+			c.Send(apns.NewNotification())
+
+			errs := make(chan error)
+			go func() { errs <- c.Send(apns.NewNotification()) }()
+
+			c.Close()
+			Expect(<-errs).To(Equal(apns.ErrClosed))
+
+			close(d)
+		})
+
+		It("should close subscriptions", func(d Done) {
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			sub := c.Subscribe(1, apns.DropNewest)
+			c.Close()
+
+			_, ok := <-sub.Results
+			Expect(ok).To(BeFalse())
+
+			_, ok = <-c.Subscribe(1, apns.DropNewest).Results
+			Expect(ok).To(BeFalse())
+
+			close(d)
+		})
+
+		It("should not wait on a gateway that never finishes the handshake", func(d Done) {
+			// Accepts connections and then says nothing.
+			l, _ := net.Listen("tcp", "127.0.0.1:0")
+			defer l.Close()
+			var held []net.Conn
+			go func() {
+				for {
+					conn, err := l.Accept()
+					if err != nil {
+						return
+					}
+					held = append(held, conn)
+				}
+			}()
+
+			c, _ := apns.NewClient(l.Addr().String(), DummyCert, DummyKey)
+			c.Send(apns.Notification{DeviceToken: "9999999999999999999999999999999999999999999999999999999999999999"})
+			time.Sleep(50 * time.Millisecond)
+
+			Expect(c.Close()).To(BeNil())
+			close(d)
+		}, 2)
+	})
 })
@@ -0,0 +1,168 @@
+// Command apns-replay resends notifications recorded in an audit log, for
+// recovering from outages downstream of APNS.
+//
+// It reads the JSON-lines log written by apns.AuditLog from a file (or
+// standard input), keeps the records matching the filters and sends them
+// again to the HTTP/2 provider API:
+//
+//	apns-replay -cert apns.crt -key apns.key -since 2016-01-02T15:00:00Z \
+//		-status failed-retryable -topic com.example.app audit.log
+//
+// Use -dry-run to list the matching records without sending anything.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/timehop/apns"
+)
+
+type filter struct {
+	since, until time.Time
+	statuses     map[string]bool
+	topics       map[string]bool
+}
+
+func (f filter) match(a apns.AuditRecord) bool {
+	if !f.since.IsZero() && a.Time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !a.Time.Before(f.until) {
+		return false
+	}
+	if len(f.statuses) > 0 && !f.statuses[a.Disposition] {
+		return false
+	}
+	if len(f.topics) > 0 && !f.topics[a.Topic] {
+		return false
+	}
+	return true
+}
+
+func parseTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		log.Fatalf("Invalid time %q: %v", s, err)
+	}
+	return t
+}
+
+func set(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			m[v] = true
+		}
+	}
+	return m
+}
+
+func main() {
+	cert := flag.String("cert", "apns.crt", "certificate file")
+	key := flag.String("key", "apns.key", "key file")
+	sandbox := flag.Bool("sandbox", false, "use the sandbox environment")
+	since := flag.String("since", "", "only replay records at or after this RFC 3339 time")
+	until := flag.String("until", "", "only replay records before this RFC 3339 time")
+	status := flag.String("status", "", "comma-separated dispositions to replay, e.g. failed-retryable")
+	topic := flag.String("topic", "", "comma-separated topics to replay")
+	dryRun := flag.Bool("dry-run", false, "list matching records without sending them")
+	wait := flag.Duration("wait", 30*time.Second, "how long to wait for results after sending")
+	workers := flag.Int("workers", apns.DefaultHTTP2ShimWorkers, "how many requests to have in flight")
+	flag.Parse()
+
+	f := filter{
+		since:    parseTime(*since),
+		until:    parseTime(*until),
+		statuses: set(*status),
+		topics:   set(*topic),
+	}
+
+	var in io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatal("Could not open audit log: ", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var ns []apns.Notification
+	err := apns.ReadAuditLog(in, func(a apns.AuditRecord) error {
+		if !f.match(a) {
+			return nil
+		}
+
+		n, err := a.Notification()
+		if err != nil {
+			return fmt.Errorf("record for %s at %s: %v", a.DeviceToken, a.Time.Format(time.RFC3339), err)
+		}
+
+		if *dryRun {
+			fmt.Printf("%s %s %s %s\n", a.Time.Format(time.RFC3339), a.Disposition, a.DeviceToken, a.Payload)
+		}
+		ns = append(ns, n)
+		return nil
+	})
+	if err != nil {
+		log.Fatal("Could not read audit log: ", err)
+	}
+
+	if *dryRun || len(ns) == 0 {
+		fmt.Printf("%d notifications matched.\n", len(ns))
+		return
+	}
+
+	env := apns.Production
+	if *sandbox {
+		env = apns.Sandbox
+	}
+
+	c2, err := apns.NewClient2WithFiles(env.Host(), *cert, *key)
+	if err != nil {
+		log.Fatal("Could not create client: ", err)
+	}
+	if err := c2.Validate(); err != nil {
+		log.Fatal("Invalid client: ", err)
+	}
+	c := apns.NewHTTP2ShimWithWorkers(c2, *workers, len(ns))
+
+	results := make(chan apns.Result, len(ns))
+	for _, n := range ns {
+		err := c.Send(n, apns.OnResult(func(r apns.Result) {
+			results <- r
+		}))
+		if err != nil {
+			log.Fatal("Could not send: ", err)
+		}
+	}
+
+	delivered, failed := 0, 0
+	timeout := time.After(*wait)
+results:
+	for delivered+failed < len(ns) {
+		select {
+		case r := <-results:
+			if r.Disposition == apns.Delivered {
+				delivered++
+			} else {
+				failed++
+				fmt.Printf("failed %s: %v\n", r.Notification.DeviceToken, r.Err)
+			}
+		case <-timeout:
+			break results
+		}
+	}
+
+	c.Close()
+	fmt.Printf("%d notifications replayed, %d failed, %d unconfirmed.\n", delivered, failed, len(ns)-delivered-failed)
+}
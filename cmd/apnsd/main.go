@@ -0,0 +1,322 @@
+// Command apnsd is a reference push daemon built on the apns package. It
+// accepts notifications over HTTP and sends them to the HTTP/2 provider
+// API through a Client2:
+//
+//	apnsd -config apnsd.json
+//
+// The configuration file is JSON:
+//
+//	{
+//		"cert": "apns.crt",
+//		"key": "apns.key",
+//		"sandbox": false,
+//		"listen": "localhost:8080",
+//		"default_topic": "com.example.app",
+//		"queue_size": 10000,
+//		"workers": 8,
+//		"spool_dir": "apnsd.spool",
+//		"compress_spool": true,
+//		"reload_interval": "1m",
+//		"audit_log": "apnsd.audit"
+//	}
+//
+// Notifications are posted to /push as audit records, the format written
+// by apns.AuditLog, one per request or several as JSON lines:
+//
+//	curl -d '{"device_token":"...","payload":{"aps":{"alert":"hi"}}}' localhost:8080/push
+//
+// Accepted notifications are written to an apns.Spool in spool_dir before
+// the request is answered, and stay there until they have a result, so
+// none are lost if the daemon is stopped or crashes. The next run sends
+// them again. With compress_spool, spool segments are gzipped once full,
+// keeping the backlog built up during a long outage small.
+//
+// The certificate and key files are checked for changes every
+// reload_interval, and on SIGHUP. When they change the client is replaced
+// with one using the new credentials; notifications the old client hadn't
+// sent are sent through the new one.
+//
+// Result counts, along with how many notifications are spooled and
+// queued, are published with expvar at /debug/vars.
+//
+// On SIGINT or SIGTERM the daemon stops accepting pushes and closes the
+// client, which makes it straightforward to run as a service: apnsd.service
+// is a systemd unit for Linux, apnsd.plist a launchd job for macOS, and
+// apnsd.xml configures WinSW to run it as a Windows service, stopping it
+// with Ctrl+C.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/timehop/apns"
+)
+
+type config struct {
+	Cert           string   `json:"cert"`
+	Key            string   `json:"key"`
+	Sandbox        bool     `json:"sandbox"`
+	Listen         string   `json:"listen"`
+	DefaultTopic   string   `json:"default_topic"`
+	QueueSize      int      `json:"queue_size"`
+	Workers        int      `json:"workers"`
+	SpoolDir       string   `json:"spool_dir"`
+	CompressSpool  bool     `json:"compress_spool"`
+	ReloadInterval duration `json:"reload_interval"`
+	AuditLog       string   `json:"audit_log"`
+	Verbose        bool     `json:"verbose"`
+}
+
+// duration is a time.Duration read from a string such as "1m".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	*d = duration(v)
+	return err
+}
+
+func loadConfig(path string) (config, error) {
+	cfg := config{
+		Cert:           "apns.crt",
+		Key:            "apns.key",
+		Listen:         "localhost:8080",
+		QueueSize:      apns.DefaultQueueSize,
+		Workers:        apns.DefaultHTTP2ShimWorkers,
+		SpoolDir:       "apnsd.spool",
+		ReloadInterval: duration(time.Minute),
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+
+	d := json.NewDecoder(f)
+	d.DisallowUnknownFields()
+	err = d.Decode(&cfg)
+	return cfg, err
+}
+
+// newClient creates the Client2 and validates it before starting the
+// workers sending through it.
+func newClient(cfg config) (*apns.HTTP2Shim, error) {
+	env := apns.Production
+	if cfg.Sandbox {
+		env = apns.Sandbox
+	}
+
+	c, err := apns.NewClient2WithFiles(env.Host(), cfg.Cert, cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	c.DefaultTopic = cfg.DefaultTopic
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.QueueSize < 0 {
+		return nil, errors.New("queue_size is negative")
+	}
+	return apns.NewHTTP2ShimWithWorkers(c, cfg.Workers, cfg.QueueSize), nil
+}
+
+// daemon sends spooled notifications and keeps track of their results.
+type daemon struct {
+	client  *supervisor
+	spool   *apns.Spool
+	audit   *apns.AuditLog
+	results *expvar.Map
+	verbose bool
+}
+
+// send queues n, spooled as seq.
+func (d *daemon) send(n apns.Notification, seq uint64) error {
+	return d.client.send(n, apns.OnResult(func(r apns.Result) {
+		d.record(r, seq)
+	}))
+}
+
+// record handles the result of spooled notification seq. Notifications
+// discarded by replacing the client are sent again, and ones discarded by
+// shutting it down are left spooled for the next run. Other results are
+// counted and audited, and take the notification off the spool.
+func (d *daemon) record(r apns.Result, seq uint64) {
+	if errors.Is(r.Err, apns.ErrClosed) {
+		go func() {
+			if err := d.send(r.Notification, seq); err != nil && err != apns.ErrClosed {
+				log.Println("Could not resend notification:", err)
+			}
+		}()
+		return
+	}
+
+	d.results.Add(r.Disposition.String(), 1)
+
+	if d.audit != nil {
+		if err := d.audit.Write(r); err != nil {
+			log.Println("Could not write audit log:", err)
+		}
+	}
+	if err := d.spool.Ack(seq); err != nil && err != apns.ErrClosed {
+		log.Println("Could not update spool:", err)
+	}
+
+	if d.verbose && r.Err != nil {
+		log.Printf("Notification to %s failed: %v", r.Notification.DeviceToken, r.Err)
+	}
+}
+
+// resend sends what the previous run left spooled. Sending waits on the
+// queue, so it runs alongside new pushes.
+func (d *daemon) resend(pending []apns.SpoolEntry) {
+	if len(pending) == 0 {
+		return
+	}
+	log.Printf("Resending %d spooled notifications.", len(pending))
+
+	for _, e := range pending {
+		if err := d.send(e.Notification, e.Seq); err != nil {
+			return
+		}
+	}
+}
+
+func pushHandler(d *daemon) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "POST notifications as audit records", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var ns []apns.Notification
+		err := apns.ReadAuditLog(r.Body, func(a apns.AuditRecord) error {
+			n, err := a.Notification()
+			if err != nil {
+				return err
+			}
+			ns = append(ns, n)
+			return nil
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Spool the whole request before answering, or none of it.
+		seqs := make([]uint64, 0, len(ns))
+		for _, n := range ns {
+			var seq uint64
+			if seq, err = d.spool.Add(n); err != nil {
+				break
+			}
+			seqs = append(seqs, seq)
+		}
+		if err == nil {
+			err = d.spool.Sync()
+		}
+		if err != nil {
+			for _, seq := range seqs {
+				d.spool.Ack(seq)
+			}
+			http.Error(w, "could not spool notifications: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		// Once spooled they are sent by the next run if not by this one.
+		for i, n := range ns {
+			if err := d.send(n, seqs[i]); err != nil {
+				break
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "accepted %d\n", len(ns))
+	}
+}
+
+func main() {
+	path := flag.String("config", "apnsd.json", "configuration file")
+	flag.Parse()
+
+	cfg, err := loadConfig(*path)
+	if err != nil {
+		log.Fatal("Could not load configuration: ", err)
+	}
+
+	spool, pending, err := apns.OpenSpool(cfg.SpoolDir)
+	if err != nil {
+		log.Fatal("Could not open spool: ", err)
+	}
+	defer spool.Close()
+	spool.Compress = cfg.CompressSpool
+
+	c, err := newSupervisor(cfg)
+	if err != nil {
+		log.Fatal("Could not create client: ", err)
+	}
+
+	d := &daemon{client: c, spool: spool, results: expvar.NewMap("results"), verbose: cfg.Verbose}
+	if cfg.AuditLog != "" {
+		f, err := os.OpenFile(cfg.AuditLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			log.Fatal("Could not open audit log: ", err)
+		}
+		defer f.Close()
+		d.audit = apns.NewAuditLog(f)
+	}
+	expvar.Publish("spooled", expvar.Func(func() interface{} { return spool.Len() }))
+	expvar.Publish("queued", expvar.Func(func() interface{} { return c.len() }))
+	expvar.Publish("reloads", &c.reloads)
+
+	mux := http.NewServeMux()
+	mux.Handle("/push", pushHandler(d))
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	l, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		log.Fatal("Could not listen: ", err)
+	}
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Could not serve: ", err)
+		}
+	}()
+	log.Println("Listening on", cfg.Listen)
+
+	go d.resend(pending)
+
+	reload := make(chan os.Signal, 1)
+	if len(reloadSignals) > 0 {
+		signal.Notify(reload, reloadSignals...)
+	}
+	stopWatching := make(chan struct{})
+	go c.watch(time.Duration(cfg.ReloadInterval), reload, stopWatching)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, stopSignals...)
+	log.Println("Shutting down on", <-sig)
+	close(stopWatching)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
+
+	c.close()
+}
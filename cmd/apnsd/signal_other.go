@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// stopSignals shut the daemon down. systemd and launchd stop services with
+// SIGTERM.
+var stopSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+
+// reloadSignals make the daemon reload its credentials.
+var reloadSignals = []os.Signal{syscall.SIGHUP}
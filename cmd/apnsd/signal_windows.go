@@ -0,0 +1,11 @@
+package main
+
+import "os"
+
+// stopSignals shut the daemon down. Service wrappers such as WinSW stop it
+// with a Ctrl+C event, which arrives as os.Interrupt.
+var stopSignals = []os.Signal{os.Interrupt}
+
+// reloadSignals is empty as Windows has no SIGHUP. Credentials are still
+// reloaded when their files change.
+var reloadSignals []os.Signal
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/timehop/apns"
+)
+
+// supervisor owns the shim notifications are sent through, and replaces it
+// when the certificate or key file changes so credentials can be rotated
+// without restarting the daemon.
+type supervisor struct {
+	cfg     config
+	reloads expvar.Int
+
+	mu      sync.RWMutex
+	shim    *apns.HTTP2Shim
+	stamp   string
+	stopped bool
+}
+
+func newSupervisor(cfg config) (*supervisor, error) {
+	stamp, err := credentialsStamp(cfg)
+	if err != nil {
+		return nil, err
+	}
+	shim, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &supervisor{cfg: cfg, shim: shim, stamp: stamp}, nil
+}
+
+// credentialsStamp identifies the version of the certificate and key
+// files on disk by their modification times.
+func credentialsStamp(cfg config) (string, error) {
+	cert, err := os.Stat(cfg.Cert)
+	if err != nil {
+		return "", err
+	}
+	key, err := os.Stat(cfg.Key)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(cert.ModTime().UnixNano(), key.ModTime().UnixNano()), nil
+}
+
+// send queues n on the current shim. If the shim is replaced while n waits
+// for room, n goes to its replacement instead. It returns ErrClosed once
+// the supervisor is closed.
+func (s *supervisor) send(n apns.Notification, opts ...apns.SendOption) error {
+	for {
+		s.mu.RLock()
+		shim, stopped := s.shim, s.stopped
+		s.mu.RUnlock()
+
+		if stopped {
+			return apns.ErrClosed
+		}
+		if err := shim.Send(n, opts...); err != apns.ErrClosed {
+			return err
+		}
+	}
+}
+
+// len returns how many notifications the current shim has queued.
+func (s *supervisor) len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.shim.Len()
+}
+
+// reload replaces the shim with one using the credentials on disk, unless
+// they haven't changed since the current one was created. The current
+// shim is kept if the new one can't be created.
+func (s *supervisor) reload(force bool) error {
+	stamp, err := credentialsStamp(s.cfg)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	unchanged := stamp == s.stamp
+	s.mu.RUnlock()
+	if unchanged && !force {
+		return nil
+	}
+
+	shim, err := newClient(s.cfg)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return shim.Close()
+	}
+	old := s.shim
+	s.shim, s.stamp = shim, stamp
+	s.mu.Unlock()
+
+	s.reloads.Add(1)
+	log.Println("Reloaded credentials.")
+	return old.Close()
+}
+
+// watch reloads the credentials every interval if they changed, and
+// whenever reload receives, until stop is closed. A zero interval only
+// reloads on demand.
+func (s *supervisor) watch(interval time.Duration, reload <-chan os.Signal, stop <-chan struct{}) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		force := false
+		select {
+		case <-stop:
+			return
+		case <-tick:
+		case <-reload:
+			force = true
+		}
+
+		if err := s.reload(force); err != nil {
+			log.Println("Could not reload credentials:", err)
+		}
+	}
+}
+
+// close stops sending and closes the shim. Notifications it hadn't sent
+// are reported with ErrClosed.
+func (s *supervisor) close() error {
+	s.mu.Lock()
+	s.stopped = true
+	shim := s.shim
+	s.mu.Unlock()
+
+	return shim.Close()
+}
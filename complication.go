@@ -0,0 +1,14 @@
+package apns
+
+// NewComplicationNotification returns a push refreshing the complications
+// of the watch app with the given bundle ID, with the .complication topic
+// suffix. token is the complication push token PushKit gives the watch
+// app. Put the data the complication shows in custom payload values; the
+// watch app gets the payload and reloads its timeline.
+func NewComplicationNotification(token, bundleID string) Notification {
+	n := NewNotification()
+	n.DeviceToken = token
+	n.PushType = PushTypeComplication
+	n.Topic = bundleID + TopicSuffix(PushTypeComplication)
+	return n
+}
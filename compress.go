@@ -0,0 +1,27 @@
+package apns
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// gunzipped returns r decompressed if it holds gzip data, and as it is
+// otherwise, reporting which it was. Concatenated gzip streams, as left by
+// appending to a compressed file, are read as one.
+func gunzipped(r io.Reader) (io.Reader, bool, error) {
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(len(gzipMagic)); err != nil || !bytes.Equal(b, gzipMagic) {
+		return br, false, nil
+	}
+
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, true, err
+	}
+	return zr, true, nil
+}
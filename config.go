@@ -0,0 +1,187 @@
+package apns
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ConfigProblem is one misconfiguration found by Validate.
+type ConfigProblem struct {
+	// Field names the option at fault, such as "BufferSize".
+	Field string
+
+	// Problem says what's wrong and Hint how to fix it.
+	Problem string
+	Hint    string
+}
+
+func (p ConfigProblem) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", p.Field, p.Problem, p.Hint)
+}
+
+// ConfigError is returned by Validate. It lists every problem found, so
+// they can all be fixed in one go.
+type ConfigError struct {
+	Problems []ConfigProblem
+}
+
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+
+	return fmt.Sprintf("apns: %d configuration problem(s): %s", len(e.Problems), strings.Join(msgs, "; "))
+}
+
+func (e *ConfigError) add(field, problem, hint string) {
+	e.Problems = append(e.Problems, ConfigProblem{Field: field, Problem: problem, Hint: hint})
+}
+
+// err returns e, or nil if no problems were found.
+func (e *ConfigError) err() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Certificate environments, as told by the certificate's common name.
+const (
+	certSandbox    = "sandbox"
+	certProduction = "production"
+)
+
+// certEnvironment returns the only environment cert can push to, or an
+// empty string for universal certificates and ones it doesn't recognize.
+func certEnvironment(cert tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return ""
+	}
+
+	cn := leaf.Subject.CommonName
+	switch {
+	case strings.HasPrefix(cn, "Apple Development") && strings.Contains(cn, "Push Services"):
+		return certSandbox
+	case strings.HasPrefix(cn, "Apple Production") && strings.Contains(cn, "Push Services"):
+		return certProduction
+	}
+	return ""
+}
+
+// checkCertEnvironment adds a problem if cert can't push to env.
+func (e *ConfigError) checkCertEnvironment(field string, cert tls.Certificate, env string) {
+	if got := certEnvironment(cert); got != "" && got != env {
+		e.add(field, fmt.Sprintf("a %s-only certificate is used with the %s environment", got, env),
+			fmt.Sprintf("connect to the %s environment, or use a universal or %s certificate", got, env))
+	}
+}
+
+// Validate cross-checks the client's options and returns a *ConfigError
+// listing everything that would make it misbehave, such as a resend
+// buffer too small for MaxInFlight or a certificate for the wrong
+// environment. Call it after setting the options and before sending.
+func (c *Client) Validate() error {
+	e := &ConfigError{}
+
+	if c.Conn.gateway != "" {
+		e.checkGateway("Conn", c.Conn.gateway)
+	}
+	if c.Conn.Conf == nil || len(c.Conn.Conf.Certificates) == 0 {
+		e.add("Conn.Conf", "no client certificate is configured", "create the client with NewClient or NewClientWithCert")
+	} else {
+		env := certProduction
+		if strings.HasPrefix(c.Conn.gateway, "gateway.sandbox.") {
+			env = certSandbox
+		}
+		e.checkCertEnvironment("Conn.Conf", c.Conn.Conf.Certificates[0], env)
+	}
+
+	if c.QueueSize < 0 {
+		e.add("QueueSize", "is negative", fmt.Sprintf("set it to 0 for DefaultQueueSize (%d), or more", DefaultQueueSize))
+	}
+	if c.BufferSize < 1 {
+		e.add("BufferSize", "leaves no room to keep written notifications for resending",
+			fmt.Sprintf("set it to at least 1, e.g. DefaultBufferSize (%d)", DefaultBufferSize))
+	}
+	if c.MaxInFlight < 0 {
+		e.add("MaxInFlight", "is negative", "set it to 0 for no cap")
+	}
+	if c.MaxInFlight > 0 && c.MaxInFlight > c.BufferSize {
+		e.add("MaxInFlight", fmt.Sprintf("%d notifications may be in flight but BufferSize only keeps %d, so a late error can lose some", c.MaxInFlight, c.BufferSize),
+			"raise BufferSize to at least MaxInFlight")
+	}
+	if c.MaxInFlight > 0 && c.InFlightWindow <= 0 {
+		e.add("InFlightWindow", "must be positive when MaxInFlight is set", "set it, e.g. to DefaultInFlightWindow")
+	}
+	if c.SuccessSampleRate < 0 || c.SuccessSampleRate > 1 {
+		e.add("SuccessSampleRate", fmt.Sprintf("%v is not between 0 and 1", c.SuccessSampleRate), "use 1 to report every delivery")
+	}
+	if c.DedupeWindow < 0 {
+		e.add("DedupeWindow", "is negative", "set it to 0 to disable deduplication")
+	}
+	if c.CutoverAfter < 0 {
+		e.add("CutoverAfter", "is negative", fmt.Sprintf("use DefaultCutoverAfter (%d)", DefaultCutoverAfter))
+	}
+
+	return e.err()
+}
+
+// Validate cross-checks the client's options and returns a *ConfigError
+// listing everything that would make requests fail, such as having no
+// credentials or both kinds of credentials.
+func (c *Client2) Validate() error {
+	e := &ConfigError{}
+
+	if c.Host == "" {
+		e.add("Host", "is empty", "use ProductionHost or DevelopmentHost")
+	} else {
+		e.checkHost("Host", c.Host)
+	}
+
+	var certs []tls.Certificate
+	if c.HTTPClient == nil {
+		e.add("HTTPClient", "is nil", "create the client with NewClient2 or NewClient2WithToken")
+	} else if t, ok := c.HTTPClient.Transport.(*http.Transport); ok && t.TLSClientConfig != nil {
+		certs = t.TLSClientConfig.Certificates
+	}
+
+	switch {
+	case c.Tokens != nil && len(certs) > 0:
+		e.add("Tokens", "both a provider token and a client certificate are configured",
+			"authenticate with one of them: unset Tokens or drop the certificate from the transport")
+	case c.Tokens == nil && len(certs) == 0 && c.HTTPClient != nil:
+		e.add("Tokens", "no provider token or client certificate is configured",
+			"create the client with NewClient2 or NewClient2WithToken")
+	}
+
+	if len(certs) > 0 {
+		env := certProduction
+		if strings.TrimSuffix(c.Host, "/") == DevelopmentHost {
+			env = certSandbox
+		}
+		e.checkCertEnvironment("HTTPClient", certs[0], env)
+	}
+
+	if c.HedgeAfter < 0 {
+		e.add("HedgeAfter", "is negative", "set it to 0 to disable hedging")
+	}
+	if c.PoolSize < 0 {
+		e.add("PoolSize", "is negative", "set it to 0 or 1 for a single connection")
+	}
+	if c.MaxStreams < 0 {
+		e.add("MaxStreams", "is negative", "set it to 0 for no cap")
+	}
+	if a := c.Concurrency; a != nil && a.Max > 0 && a.Min > a.Max {
+		e.add("Concurrency", fmt.Sprintf("Min %d is above Max %d", a.Min, a.Max), "lower Min or raise Max")
+	}
+
+	return e.err()
+}
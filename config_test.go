@@ -0,0 +1,120 @@
+package apns_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+// newPushCert returns a self-signed certificate with the common name cn.
+func newPushCert(cn string) tls.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func configProblems(err error) []string {
+	var ce *apns.ConfigError
+	Expect(errors.As(err, &ce)).To(BeTrue())
+
+	var fields []string
+	for _, p := range ce.Problems {
+		fields = append(fields, p.Field)
+	}
+	return fields
+}
+
+var _ = Describe("Config", func() {
+	Describe("Client#Validate", func() {
+		It("should accept the defaults", func() {
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			defer c.Close()
+
+			Expect(c.Validate()).To(BeNil())
+		})
+
+		It("should report every problem at once", func() {
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			defer c.Close()
+
+			c.MaxInFlight = c.BufferSize + 1
+			c.SuccessSampleRate = 2
+
+			err := c.Validate()
+			Expect(configProblems(err)).To(ConsistOf("MaxInFlight", "SuccessSampleRate"))
+			Expect(err.Error()).To(ContainSubstring("raise BufferSize"))
+		})
+
+		It("should catch a certificate for the wrong environment", func() {
+			c := apns.NewClientWithCert(apns.SandboxGateway, newPushCert("Apple Production IOS Push Services: com.example.app"))
+			defer c.Close()
+
+			Expect(configProblems(c.Validate())).To(ConsistOf("Conn.Conf"))
+		})
+
+		It("should accept universal certificates in either environment", func() {
+			c := apns.NewClientWithCert(apns.SandboxGateway, newPushCert("Apple Push Services: com.example.app"))
+			defer c.Close()
+
+			Expect(c.Validate()).To(BeNil())
+		})
+
+		It("should catch an HTTP/2 host used as a gateway", func() {
+			c := apns.NewClientWithCert(apns.ProductionHost, newPushCert("Apple Push Services: com.example.app"))
+			defer c.Close()
+
+			err := c.Validate()
+			Expect(configProblems(err)).To(ConsistOf("Conn"))
+			Expect(err.Error()).To(ContainSubstring("GatewayProduction"))
+		})
+	})
+
+	Describe("Client2#Validate", func() {
+		It("should accept a certificate client", func() {
+			c := apns.NewClient2(apns.DevelopmentHost, newPushCert("Apple Development IOS Push Services: com.example.app"))
+			Expect(c.Validate()).To(BeNil())
+		})
+
+		It("should catch a certificate for the wrong environment", func() {
+			c := apns.NewClient2(apns.ProductionHost, newPushCert("Apple Development IOS Push Services: com.example.app"))
+			Expect(configProblems(c.Validate())).To(ConsistOf("HTTPClient"))
+		})
+
+		It("should catch token and certificate authentication both being set", func() {
+			_, p8 := newP8()
+			p, _ := apns.NewTokenProvider("KEY123", "TEAM456", p8)
+
+			c := apns.NewClient2(apns.ProductionHost, newPushCert("Apple Push Services: com.example.app"))
+			c.Tokens = p
+
+			Expect(configProblems(c.Validate())).To(ConsistOf("Tokens"))
+		})
+
+		It("should catch a gateway used as the host", func() {
+			c := apns.NewClient2(apns.GatewayProduction, newPushCert("Apple Push Services: com.example.app"))
+			Expect(configProblems(c.Validate())).To(ConsistOf("Host"))
+		})
+
+		It("should catch missing credentials", func() {
+			c := apns.NewClient2WithToken(apns.ProductionHost, nil, "com.example.app")
+			Expect(configProblems(c.Validate())).To(ConsistOf("Tokens"))
+		})
+	})
+})
@@ -0,0 +1,95 @@
+package apns_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+// Golden frames for the binary provider protocol (command 2). Each fixture
+// is: command, frame length, then token, payload, identifier, expiry and
+// priority items, each as item ID, big-endian length and big-endian value.
+var _ = Describe("Binary protocol conformance", func() {
+	tok := strings.Repeat("9", 64)
+
+	table.DescribeTable("#ToBinary",
+		func(build func() apns.Notification, golden string) {
+			b, err := build().ToBinary()
+			Expect(err).To(BeNil())
+			Expect(hex.EncodeToString(b)).To(Equal(golden))
+		},
+		table.Entry("empty aps, no expiry, immediate priority", func() apns.Notification {
+			n := apns.NewNotification()
+			n.DeviceToken = tok
+			n.Identifier = 123123
+			n.Priority = apns.PriorityImmediate
+			return n
+		}, "02"+"00000042"+
+			"01"+"0020"+strings.Repeat("99", 32)+
+			"02"+"000a"+"7b22617073223a7b7d7d"+
+			"03"+"0004"+"0001e0f3"+
+			"04"+"0004"+"00000000"+
+			"05"+"0001"+"0a"),
+
+		table.Entry("alert, zero badge, expiry, power-conserving priority", func() apns.Notification {
+			exp := time.Unix(1404102833, 0)
+
+			n := apns.NewNotification()
+			n.DeviceToken = strings.Repeat("00", 31) + "FF"
+			n.Identifier = 1
+			n.Expiration = &exp
+			n.Priority = apns.PriorityPowerConserve
+			n.Payload.APS.Alert.Body = "hi"
+			n.Payload.APS.Badge.Set(0)
+			return n
+		}, "02"+"00000058"+
+			"01"+"0020"+strings.Repeat("00", 31)+"ff"+
+			"02"+"0020"+hex.EncodeToString([]byte(`{"aps":{"alert":"hi","badge":0}}`))+
+			"03"+"0004"+"00000001"+
+			"04"+"0004"+"53b0e8b1"+
+			"05"+"0001"+"05"),
+
+		table.Entry("custom keys, max identifier, default background priority", func() apns.Notification {
+			n := apns.NewNotification()
+			n.DeviceToken = tok
+			n.Identifier = 4294967295
+			n.Payload.APS.ContentAvailable = 1
+			n.Payload.SetCustomValue("link", "app://x")
+			return n
+		}, "02"+"00000068"+
+			"01"+"0020"+strings.Repeat("99", 32)+
+			"02"+"0030"+hex.EncodeToString([]byte(`{"aps":{"content-available":1},"link":"app://x"}`))+
+			"03"+"0004"+"ffffffff"+
+			"04"+"0004"+"00000000"+
+			"05"+"0001"+"05"),
+	)
+
+	table.DescribeTable("#ToBinary rejects",
+		func(token string, msg string) {
+			n := apns.NewNotification()
+			n.DeviceToken = token
+
+			_, err := n.ToBinary()
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring(msg))
+		},
+		table.Entry("non-hex tokens", "zz", "convert token to hex error"),
+		table.Entry("empty tokens", "", "device token is 0 bytes"),
+		table.Entry("short tokens", strings.Repeat("ab", 16), "device token is 16 bytes"),
+		table.Entry("long tokens", strings.Repeat("ab", 64), "device token is 64 bytes"),
+	)
+
+	It("rejects payloads too large for a frame item", func() {
+		n := apns.NewNotification()
+		n.DeviceToken = tok
+		n.Payload.SetCustomValue("blob", strings.Repeat("x", 70000))
+
+		_, err := n.ToBinary()
+		Expect(err).NotTo(BeNil())
+	})
+})
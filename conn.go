@@ -1,9 +1,14 @@
 package apns
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"io"
 	"net"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -14,12 +19,63 @@ const (
 	SandboxFeedbackGateway    = "feedback.sandbox.push.apple.com:2196"
 )
 
+// Defaults for Conn.DialTimeout and Conn.HandshakeTimeout.
+const (
+	DefaultDialTimeout      = 10 * time.Second
+	DefaultHandshakeTimeout = 10 * time.Second
+)
+
+var lastConnID uint64
+
 // Conn is a wrapper for the actual TLS connections made to Apple
 type Conn struct {
 	NetConn net.Conn
 	Conf    *tls.Config
 
+	// ID identifies this Conn in logs and results. It is unique within the
+	// process and stays the same across reconnects.
+	ID uint64
+
+	// IPPreference and FallbackDelay control dual-stack dialing. Addresses
+	// of the preferred family are tried first, and each attempt gets
+	// FallbackDelay (DefaultFallbackDelay if zero) before the next address
+	// is tried alongside it.
+	IPPreference  IPPreference
+	FallbackDelay time.Duration
+
+	// Resolver, if set, resolves the gateway instead of net.DefaultResolver.
+	Resolver Resolver
+
+	// DialTimeout bounds resolving the gateway and opening the TCP
+	// connection, and HandshakeTimeout the TLS handshake that follows.
+	// DefaultDialTimeout and DefaultHandshakeTimeout are used if zero.
+	DialTimeout      time.Duration
+	HandshakeTimeout time.Duration
+
+	// LocalAddrs, if set, binds connections to these local addresses, so
+	// multi-homed hosts egress through the ones allowed to reach Apple.
+	// Gateway addresses of a family without a local address are skipped.
+	LocalAddrs []net.IP
+
+	// LastConnect holds the timing of the most recent connection attempt.
+	// OnConnect, if set, is called with the same value after every attempt,
+	// successful or not, so it can be exported as metrics.
+	LastConnect ConnectTiming
+	OnConnect   func(ConnectTiming)
+
+	// Audit records recent connection attempts along with why they failed.
+	Audit *ConnectAudit
+
+	// Gateways lists alternate gateway addresses, such as regional TLS
+	// egress proxies, tried in order when connecting to the primary gateway
+	// fails. GatewayFunc, if set, is called on every connect and its result
+	// replaces both, so the list can change without a new client. The TLS
+	// ServerName is not changed when failing over.
+	Gateways    []string
+	GatewayFunc func() []string
+
 	gateway   string
+	current   string
 	connected bool
 }
 
@@ -30,7 +86,12 @@ func NewConnWithCert(gw string, cert tls.Certificate) Conn {
 		ServerName:   gatewayParts[0],
 	}
 
-	return Conn{gateway: gw, Conf: &conf}
+	return Conn{
+		gateway: gw,
+		Conf:    &conf,
+		ID:      atomic.AddUint64(&lastConnID, 1),
+		Audit:   NewConnectAudit(DefaultConnectAuditSize),
+	}
 }
 
 // NewConnWithFiles creates a new Conn from certificate and key in the specified files
@@ -53,28 +114,131 @@ func NewConnWithFiles(gw string, certFile string, keyFile string) (Conn, error)
 	return NewConnWithCert(gw, cert), nil
 }
 
+// ConnectTiming breaks down how long each phase of a connection attempt
+// took. Phases that weren't reached are zero.
+type ConnectTiming struct {
+	Start time.Time
+	DNS   time.Duration
+	TCP   time.Duration
+	TLS   time.Duration
+	Err   error
+}
+
+// Total is the duration of the whole attempt.
+func (t ConnectTiming) Total() time.Duration {
+	return t.DNS + t.TCP + t.TLS
+}
+
 // Connect actually creates the TLS connection
 func (c *Conn) Connect() error {
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect, giving up as soon as ctx is done.
+func (c *Conn) ConnectContext(ctx context.Context) error {
+	t := ConnectTiming{Start: time.Now()}
+	err := c.connect(ctx, &t)
+
+	t.Err = err
+	c.LastConnect = t
+	if c.Audit != nil {
+		c.Audit.Record(t)
+	}
+	if c.OnConnect != nil {
+		c.OnConnect(t)
+	}
+
+	return err
+}
+
+// gateways returns the addresses to try, in order.
+func (c *Conn) gateways() []string {
+	if c.GatewayFunc != nil {
+		return c.GatewayFunc()
+	}
+	return append([]string{c.gateway}, c.Gateways...)
+}
+
+// Gateway returns the address of the gateway currently connected to, or an
+// empty string if not connected.
+func (c *Conn) Gateway() string {
+	if c.NetConn == nil {
+		return ""
+	}
+	return c.current
+}
+
+func (c *Conn) connect(ctx context.Context, t *ConnectTiming) error {
 	// Make sure the existing connection is closed
 	if c.NetConn != nil {
 		c.NetConn.Close()
+		c.NetConn = nil
+	}
+
+	gws := c.gateways()
+	if len(gws) == 0 {
+		return errors.New("apns: no gateway to connect to")
 	}
 
-	conn, err := net.Dial("tcp", c.gateway)
+	// If every gateway fails, the first one's error is returned, so t
+	// ends up with the timings of that attempt.
+	var firstErr error
+	var first ConnectTiming
+	for _, gw := range gws {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		*t = ConnectTiming{Start: time.Now()}
+		err := c.connectTo(ctx, gw, t)
+		if err == nil {
+			c.current = gw
+			return nil
+		}
+		if firstErr == nil {
+			firstErr, first = err, *t
+		}
+	}
+
+	*t = first
+	return firstErr
+}
+
+func (c *Conn) connectTo(ctx context.Context, gw string, t *ConnectTiming) error {
+	conn, err := c.dial(ctx, gw, t)
 	if err != nil {
 		return err
 	}
 
+	timeout := c.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = DefaultHandshakeTimeout
+	}
+
+	start := time.Now()
 	tlsConn := tls.Client(conn, c.Conf)
-	err = tlsConn.Handshake()
+	conn.SetDeadline(start.Add(timeout))
+	err = tlsConn.HandshakeContext(ctx)
+	t.TLS = time.Since(start)
 	if err != nil {
+		conn.Close()
 		return err
 	}
+	conn.SetDeadline(time.Time{})
 
 	c.NetConn = tlsConn
 	return nil
 }
 
+// RemoteAddr returns the resolved address of the Apple endpoint currently
+// connected to, or an empty string if not connected.
+func (c *Conn) RemoteAddr() string {
+	if c.NetConn == nil {
+		return ""
+	}
+	return c.NetConn.RemoteAddr().String()
+}
+
 func (c *Conn) Close() error {
 	if c.NetConn != nil {
 		return c.NetConn.Close()
@@ -89,7 +253,25 @@ func (c *Conn) Read(p []byte) (int, error) {
 	return i, err
 }
 
-// Write writes data from the connection
+// Write writes a whole frame to the connection, retrying short writes. A
+// frame that is only partly written would corrupt the stream, so if a write
+// fails midway the connection is closed before the error is returned.
 func (c *Conn) Write(p []byte) (int, error) {
-	return c.NetConn.Write(p)
+	written := 0
+	for written < len(p) {
+		n, err := c.NetConn.Write(p[written:])
+		written += n
+
+		if err == nil && n == 0 {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			if written > 0 {
+				c.NetConn.Close()
+			}
+			return written, err
+		}
+	}
+
+	return written, nil
 }
@@ -117,6 +117,30 @@ func (m mockTLSNetConn) SetWriteDeadline(t time.Time) error {
 	return nil
 }
 
+// Mock TLS connection that accepts at most max bytes per write and fails
+// once limit bytes have been written
+type shortWriteNetConn struct {
+	mockTLSNetConn
+	max    int
+	limit  int
+	closed *bool
+}
+
+func (t shortWriteNetConn) Write(p []byte) (int, error) {
+	if t.bb.Len() >= t.limit {
+		return 0, io.ErrClosedPipe
+	}
+	if len(p) > t.max {
+		p = p[:t.max]
+	}
+	return t.bb.Write(p)
+}
+
+func (t shortWriteNetConn) Close() error {
+	*t.closed = true
+	return nil
+}
+
 type serverAction struct {
 	action string
 	data   []byte
@@ -133,6 +157,10 @@ type mockTLSServer struct {
 	Port                   int
 	Server                 net.Listener
 	ConnectionActionGroups [][]serverAction
+
+	// conns keeps accepted connections referenced, so the garbage
+	// collector doesn't close them once their actions are done.
+	conns []net.Conn
 }
 
 func (m *mockTLSServer) portStr() string {
@@ -170,6 +198,7 @@ func (m *mockTLSServer) start() {
 					log.Fatal(err)
 				}
 			}
+			m.conns = append(m.conns, conn)
 			// Handle the connection in a new goroutine.
 			// The loop then returns to accepting, so that
 			// multiple connections may be served concurrently.
@@ -323,6 +352,144 @@ var _ = Describe("Conn", func() {
 				})
 			})
 
+			Context("preferring a family the server doesn't listen on", func() {
+				It("should fall back to the other family", func(d Done) {
+					withMockServer(as, func(s *mockTLSServer) {
+						conn, _ := apns.NewConn(s.Address(), DummyCert, DummyKey)
+						conn.Conf.InsecureSkipVerify = true
+						conn.IPPreference = apns.PreferIPv6
+
+						err := conn.Connect()
+						Expect(err).To(BeNil())
+
+						close(d)
+					})
+				})
+			})
+
+			Context("with local addresses", func() {
+				It("should bind to the address of the gateway's family", func(d Done) {
+					withMockServer(as, func(s *mockTLSServer) {
+						conn, _ := apns.NewConn(s.Address(), DummyCert, DummyKey)
+						conn.Conf.InsecureSkipVerify = true
+						conn.LocalAddrs = []net.IP{net.ParseIP("::1"), net.ParseIP("127.0.0.1")}
+
+						Expect(conn.Connect()).To(BeNil())
+						host, _, _ := net.SplitHostPort(conn.NetConn.LocalAddr().String())
+						Expect(host).To(Equal("127.0.0.1"))
+
+						close(d)
+					})
+				})
+
+				It("should fail without an address of the gateway's family", func() {
+					conn, _ := apns.NewConn("127.0.0.1:2195", DummyCert, DummyKey)
+					conn.LocalAddrs = []net.IP{net.ParseIP("::1")}
+
+					Expect(apns.ClassifyConnectError(conn.Connect())).To(Equal(apns.ConnectTCPFailure))
+				})
+			})
+
+			Context("with a static resolver", func() {
+				It("should dial the pinned address", func(d Done) {
+					withMockServer(as, func(s *mockTLSServer) {
+						_, port, _ := net.SplitHostPort(s.Address())
+						conn, _ := apns.NewConn(net.JoinHostPort("gateway.invalid", port), DummyCert, DummyKey)
+						conn.Conf.InsecureSkipVerify = true
+						conn.Resolver = apns.StaticResolver{
+							"gateway.invalid": []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}},
+						}
+
+						Expect(conn.Connect()).To(BeNil())
+						Expect(conn.RemoteAddr()).To(Equal(net.JoinHostPort("127.0.0.1", port)))
+
+						close(d)
+					})
+				})
+
+				It("should fail for unknown hosts", func() {
+					conn, _ := apns.NewConn("gateway.invalid:2195", DummyCert, DummyKey)
+					conn.Resolver = apns.StaticResolver{}
+
+					Expect(apns.ClassifyConnectError(conn.Connect())).To(Equal(apns.ConnectDNSFailure))
+				})
+			})
+
+			Context("with fallback gateways", func() {
+				It("should fail over to the next gateway", func(d Done) {
+					withMockServer(as, func(s *mockTLSServer) {
+						conn, _ := apns.NewConn("127.0.0.1:1", DummyCert, DummyKey)
+						conn.Conf.InsecureSkipVerify = true
+						conn.Gateways = []string{s.Address()}
+
+						Expect(conn.Gateway()).To(BeEmpty())
+						Expect(conn.Connect()).To(BeNil())
+						Expect(conn.Gateway()).To(Equal(s.Address()))
+
+						close(d)
+					})
+				})
+
+				It("should use the gateway func instead", func(d Done) {
+					withMockServer(as, func(s *mockTLSServer) {
+						conn, _ := apns.NewConn("127.0.0.1:1", DummyCert, DummyKey)
+						conn.Conf.InsecureSkipVerify = true
+
+						calls := 0
+						conn.GatewayFunc = func() []string {
+							calls++
+							return []string{s.Address()}
+						}
+
+						Expect(conn.Connect()).To(BeNil())
+						Expect(conn.Gateway()).To(Equal(s.Address()))
+						Expect(calls).To(Equal(1))
+
+						close(d)
+					})
+				})
+
+				It("should return the first error if every gateway fails", func() {
+					conn, _ := apns.NewConn("gateway.invalid:2195", DummyCert, DummyKey)
+					conn.Resolver = apns.StaticResolver{}
+					conn.Gateways = []string{"127.0.0.1:1"}
+
+					Expect(apns.ClassifyConnectError(conn.Connect())).To(Equal(apns.ConnectDNSFailure))
+				})
+
+				It("should record the timings of the attempt whose error is returned", func() {
+					conn, _ := apns.NewConn("gateway.invalid:2195", DummyCert, DummyKey)
+					conn.Resolver = apns.StaticResolver{
+						"fallback.test": {{IP: net.IPv4(127, 0, 0, 1)}},
+					}
+					conn.Gateways = []string{"fallback.test:1"}
+
+					conn.Connect()
+					Expect(apns.ClassifyConnectError(conn.LastConnect.Err)).To(Equal(apns.ConnectDNSFailure))
+					Expect(conn.LastConnect.TCP).To(BeZero())
+				})
+			})
+
+			Context("timing", func() {
+				It("should record every phase", func(d Done) {
+					withMockServer(as, func(s *mockTLSServer) {
+						conn, _ := apns.NewConn(s.Address(), DummyCert, DummyKey)
+						conn.Conf.InsecureSkipVerify = true
+
+						var reported apns.ConnectTiming
+						conn.OnConnect = func(t apns.ConnectTiming) { reported = t }
+
+						Expect(conn.Connect()).To(BeNil())
+						Expect(conn.LastConnect.Err).To(BeNil())
+						Expect(conn.LastConnect.TCP).To(BeNumerically(">", 0))
+						Expect(conn.LastConnect.TLS).To(BeNumerically(">", 0))
+						Expect(reported).To(Equal(conn.LastConnect))
+
+						close(d)
+					})
+				})
+			})
+
 			Context("with existing connection", func() {
 				It("should not return an error", func(d Done) {
 					as = [][]serverAction{
@@ -373,6 +540,37 @@ var _ = Describe("Conn", func() {
 			conn.Write([]byte("world!"))
 			Expect(rwc.bb.String()).To(Equal("world!"))
 		})
+
+		Context("short writes", func() {
+			It("should keep writing until the whole frame is out", func() {
+				closed := false
+				sw := shortWriteNetConn{mockTLSNetConn{bb: bytes.NewBuffer([]byte{})}, 2, 100, &closed}
+
+				conn, _ := apns.NewConn(apns.ProductionGateway, DummyCert, DummyKey)
+				conn.NetConn = sw
+
+				n, err := conn.Write([]byte("world!"))
+				Expect(err).To(BeNil())
+				Expect(n).To(Equal(6))
+				Expect(sw.bb.String()).To(Equal("world!"))
+				Expect(closed).To(BeFalse())
+			})
+		})
+
+		Context("failure midway through a frame", func() {
+			It("should close the connection", func() {
+				closed := false
+				sw := shortWriteNetConn{mockTLSNetConn{bb: bytes.NewBuffer([]byte{})}, 2, 4, &closed}
+
+				conn, _ := apns.NewConn(apns.ProductionGateway, DummyCert, DummyKey)
+				conn.NetConn = sw
+
+				n, err := conn.Write([]byte("world!"))
+				Expect(err).To(Equal(io.ErrClosedPipe))
+				Expect(n).To(Equal(4))
+				Expect(closed).To(BeTrue())
+			})
+		})
 	})
 
 	Describe("#Close", func() {
@@ -408,4 +606,29 @@ var _ = Describe("Conn", func() {
 			})
 		})
 	})
+
+	Describe("#ID", func() {
+		It("should be unique per connection", func() {
+			a, _ := apns.NewConn(apns.ProductionGateway, DummyCert, DummyKey)
+			b, _ := apns.NewConn(apns.ProductionGateway, DummyCert, DummyKey)
+			Expect(a.ID).NotTo(Equal(b.ID))
+		})
+	})
+
+	Describe("#RemoteAddr", func() {
+		Context("without connection", func() {
+			It("should be empty", func() {
+				c, _ := apns.NewConn(apns.ProductionGateway, DummyCert, DummyKey)
+				Expect(c.RemoteAddr()).To(Equal(""))
+			})
+		})
+
+		Context("with connection", func() {
+			It("should be the peer's address", func() {
+				c, _ := apns.NewConn(apns.ProductionGateway, DummyCert, DummyKey)
+				c.NetConn = mockTLSNetConn{bb: bytes.NewBuffer([]byte{})}
+				Expect(c.RemoteAddr()).To(Equal("localhost:56789"))
+			})
+		})
+	})
 })
@@ -0,0 +1,120 @@
+package apns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultTokenCooldown is the default Client2.TokenCooldown.
+const DefaultTokenCooldown = time.Minute
+
+// CooldownError is returned by SendSync for a device token that APNS
+// answered with 429 TooManyRequests, when the context ends before the
+// token's cooldown does. It wraps ErrReasonTooManyRequests.
+type CooldownError struct {
+	DeviceToken string
+	Until       time.Time
+}
+
+func (e *CooldownError) Error() string {
+	return fmt.Sprintf("apns: device token %s is cooling down until %s", e.DeviceToken, e.Until.Format(time.RFC3339))
+}
+
+func (e *CooldownError) Unwrap() error {
+	return ErrReasonTooManyRequests
+}
+
+// tokenCooldowns tracks the device tokens APNS has asked Client2 to stop
+// sending to for a while.
+type tokenCooldowns struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func (t *tokenCooldowns) get(token string, now time.Time) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until, ok := t.until[token]
+	if ok && !now.Before(until) {
+		delete(t.until, token)
+		return time.Time{}
+	}
+	return until
+}
+
+func (t *tokenCooldowns) set(token string, until, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.until == nil {
+		t.until = map[string]time.Time{}
+	}
+	// Tokens that are never sent to again would otherwise stay forever.
+	if len(t.until) >= 1000 {
+		for k, u := range t.until {
+			if !now.Before(u) {
+				delete(t.until, k)
+			}
+		}
+	}
+	t.until[token] = until
+}
+
+// Cooldown returns when sends to token resume after APNS answered it with
+// 429 TooManyRequests, or the zero time if they aren't held back.
+func (c *Client2) Cooldown(token string) time.Time {
+	return c.cooldowns.get(token, time.Now())
+}
+
+// waitCooldown holds a send to token back until its cooldown is over. If
+// ctx would end first it returns a *CooldownError straight away rather
+// than waiting for nothing.
+func (c *Client2) waitCooldown(ctx context.Context, token string) error {
+	until := c.Cooldown(token)
+	if until.IsZero() {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(until) {
+		return &CooldownError{DeviceToken: token, Until: until}
+	}
+
+	t := time.NewTimer(time.Until(until))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return &CooldownError{DeviceToken: token, Until: until}
+	}
+}
+
+// observeCooldown starts a cooldown for r's device token if APNS answered
+// 429 TooManyRequests, for as long as its Retry-After asks or
+// TokenCooldown otherwise.
+func (c *Client2) observeCooldown(r Response) {
+	if c.TokenCooldown <= 0 || r.StatusCode != http.StatusTooManyRequests || r.Reason != "TooManyRequests" {
+		return
+	}
+
+	now := time.Now()
+	d := c.TokenCooldown
+	if r.RetryAfter > 0 {
+		d = r.RetryAfter
+	}
+	c.cooldowns.set(r.DeviceToken, now.Add(d), now)
+}
+
+// retryAfter parses a Retry-After header given in seconds.
+func retryAfter(h http.Header) time.Duration {
+	secs, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
@@ -0,0 +1,100 @@
+package apns_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Token cooldown", func() {
+	throttled := "1111111111111111111111111111111111111111111111111111111111111111"
+	other := "2222222222222222222222222222222222222222222222222222222222222222"
+
+	var s *mockHTTP2Server
+
+	BeforeEach(func() {
+		s = newMockHTTP2Server()
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, throttled) {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"reason":"TooManyRequests"}`))
+			}
+		}
+	})
+
+	AfterEach(func() {
+		s.Close()
+	})
+
+	send := func(c *apns.Client2, ctx context.Context, token string) (apns.Response, error) {
+		n := apns.NewNotification()
+		n.DeviceToken = token
+		return c.SendSync(ctx, n)
+	}
+
+	requests := func() int {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.requests)
+	}
+
+	It("should hold back a token APNS throttled, for as long as it asks", func() {
+		c := newTestClient2(s)
+		Expect(c.TokenCooldown).To(Equal(apns.DefaultTokenCooldown))
+
+		r, err := send(c, context.Background(), throttled)
+		Expect(err).To(BeNil())
+		Expect(r.StatusCode).To(Equal(http.StatusTooManyRequests))
+		Expect(r.RetryAfter).To(Equal(time.Second))
+		Expect(c.Cooldown(throttled)).To(BeTemporally("~", time.Now().Add(time.Second), 100*time.Millisecond))
+
+		// Other tokens aren't affected.
+		_, err = send(c, context.Background(), other)
+		Expect(err).To(BeNil())
+		Expect(c.Cooldown(other).IsZero()).To(BeTrue())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err = send(c, ctx, throttled)
+		var ce *apns.CooldownError
+		Expect(errors.As(err, &ce)).To(BeTrue())
+		Expect(ce.DeviceToken).To(Equal(throttled))
+		Expect(errors.Is(err, apns.ErrReasonTooManyRequests)).To(BeTrue())
+		Expect(requests()).To(Equal(2))
+
+		start := time.Now()
+		send(c, context.Background(), throttled)
+		Expect(time.Since(start)).To(BeNumerically(">", 500*time.Millisecond))
+		Expect(requests()).To(Equal(3))
+	})
+
+	It("should fall back to TokenCooldown without Retry-After", func() {
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"reason":"TooManyRequests"}`))
+		}
+
+		c := newTestClient2(s)
+		c.TokenCooldown = 5 * time.Minute
+		send(c, context.Background(), throttled)
+		Expect(c.Cooldown(throttled)).To(BeTemporally("~", time.Now().Add(5*time.Minute), time.Second))
+	})
+
+	It("should be off with a zero TokenCooldown", func() {
+		c := newTestClient2(s)
+		c.TokenCooldown = 0
+
+		send(c, context.Background(), throttled)
+		Expect(c.Cooldown(throttled).IsZero()).To(BeTrue())
+		_, err := send(c, context.Background(), throttled)
+		Expect(err).To(BeNil())
+		Expect(requests()).To(Equal(2))
+	})
+})
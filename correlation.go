@@ -0,0 +1,139 @@
+package apns
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SendRecord is what the client remembers about a sent notification so that
+// receipts reported by the app can be joined back to it.
+type SendRecord struct {
+	ID          string
+	DeviceToken string
+	Identifier  uint32
+	Metadata    map[string]string
+	ContentHash string
+	SentAt      time.Time
+}
+
+// CorrelationStore keeps SendRecords keyed by the notification's ID, the
+// correlation ID the app reports back. Implementations must be safe for
+// concurrent use.
+type CorrelationStore interface {
+	Put(key string, r SendRecord) error
+	Get(key string) (SendRecord, bool, error)
+}
+
+// CorrelationForgetter is implemented by CorrelationStores that can drop
+// every record for a device token, so Client.Forget can purge them.
+type CorrelationForgetter interface {
+	ForgetToken(token string) (int, error)
+}
+
+// MemoryCorrelationStore keeps the most recent Size records in memory,
+// evicting the oldest first.
+type MemoryCorrelationStore struct {
+	Size int
+
+	mu    sync.Mutex
+	order *list.List
+	recs  map[string]*list.Element
+}
+
+type correlationEntry struct {
+	key string
+	rec SendRecord
+}
+
+func NewMemoryCorrelationStore(size int) *MemoryCorrelationStore {
+	return &MemoryCorrelationStore{
+		Size:  size,
+		order: list.New(),
+		recs:  map[string]*list.Element{},
+	}
+}
+
+func (m *MemoryCorrelationStore) Put(key string, r SendRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.recs[key]; ok {
+		m.order.Remove(e)
+	}
+	m.recs[key] = m.order.PushBack(correlationEntry{key, r})
+
+	for m.order.Len() > m.Size {
+		e := m.order.Front()
+		m.order.Remove(e)
+		delete(m.recs, e.Value.(correlationEntry).key)
+	}
+
+	return nil
+}
+
+func (m *MemoryCorrelationStore) Get(key string) (SendRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.recs[key]
+	if !ok {
+		return SendRecord{}, false, nil
+	}
+	return e.Value.(correlationEntry).rec, true, nil
+}
+
+// ForgetToken drops every record for token.
+func (m *MemoryCorrelationStore) ForgetToken(token string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	forgotten := 0
+	for e := m.order.Front(); e != nil; {
+		next := e.Next()
+		if entry := e.Value.(correlationEntry); strings.EqualFold(entry.rec.DeviceToken, token) {
+			m.order.Remove(e)
+			delete(m.recs, entry.key)
+			forgotten++
+		}
+		e = next
+	}
+	return forgotten, nil
+}
+
+// correlationKey is the key a notification is recorded under: the ID the
+// caller gave it. The binary protocol identifier won't do, as it wraps
+// around and is reused across connections.
+func correlationKey(n Notification) string {
+	return n.ID
+}
+
+// recordCorrelation records n if it has an ID to be found by.
+func (c *Client) recordCorrelation(n Notification) {
+	if c.Correlations == nil || n.ID == "" {
+		return
+	}
+
+	err := c.Correlations.Put(correlationKey(n), SendRecord{
+		ID:          n.ID,
+		DeviceToken: n.DeviceToken,
+		Identifier:  n.Identifier,
+		Metadata:    n.Metadata,
+		ContentHash: n.contentHash,
+		SentAt:      time.Now(),
+	})
+	if err != nil {
+		c.logln("Error recording correlation:", err.Error())
+	}
+}
+
+// Correlate looks up the send record for a notification ID reported back by
+// the app. ok is false if the record is unknown or has been evicted. It keeps
+// working after Close.
+func (c *Client) Correlate(id string) (r SendRecord, ok bool, err error) {
+	if c.Correlations == nil {
+		return SendRecord{}, false, nil
+	}
+	return c.Correlations.Get(id)
+}
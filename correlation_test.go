@@ -0,0 +1,62 @@
+package apns_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Correlation", func() {
+	Describe("MemoryCorrelationStore", func() {
+		It("should evict the oldest records", func() {
+			m := apns.NewMemoryCorrelationStore(2)
+			m.Put("1", apns.SendRecord{ID: "one"})
+			m.Put("2", apns.SendRecord{ID: "two"})
+			m.Put("3", apns.SendRecord{ID: "three"})
+
+			_, ok, _ := m.Get("1")
+			Expect(ok).To(BeFalse())
+
+			r, ok, err := m.Get("3")
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeTrue())
+			Expect(r.ID).To(Equal("three"))
+		})
+	})
+
+	Describe("Client#Correlate", func() {
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		It("should find written notifications by ID", func(d Done) {
+			mockDone := make(chan interface{})
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				c.Correlations = apns.NewMemoryCorrelationStore(10)
+
+				n := apns.NewNotification()
+				n.ID = "user_id:timestamp"
+				n.Identifier = 77
+				n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+				n.Metadata = map[string]string{"campaign": "spring"}
+				c.Send(n)
+
+				Eventually(func() bool {
+					_, ok, _ := c.Correlate("user_id:timestamp")
+					return ok
+				}).Should(BeTrue())
+
+				r, _, _ := c.Correlate("user_id:timestamp")
+				Expect(r.Identifier).To(Equal(uint32(77)))
+				Expect(r.Metadata["campaign"]).To(Equal("spring"))
+
+				close(mockDone)
+				close(d)
+			})
+		})
+	})
+})
@@ -0,0 +1,66 @@
+package apns_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Cron", func() {
+	Describe(".ParseCron", func() {
+		Context("wrong number of fields", func() {
+			It("should error out", func() {
+				_, err := apns.ParseCron("* * * *")
+				Expect(err).NotTo(BeNil())
+			})
+		})
+
+		Context("out of range value", func() {
+			It("should error out", func() {
+				_, err := apns.ParseCron("60 * * * *")
+				Expect(err).NotTo(BeNil())
+			})
+		})
+	})
+
+	Describe("#Next", func() {
+		from := time.Date(2015, time.March, 14, 9, 26, 53, 0, time.UTC)
+
+		next := func(expr string) time.Time {
+			s, err := apns.ParseCron(expr)
+			Expect(err).To(BeNil())
+			return s.Next(from)
+		}
+
+		It("should advance to the next minute for *", func() {
+			Expect(next("* * * * *")).To(Equal(time.Date(2015, time.March, 14, 9, 27, 0, 0, time.UTC)))
+		})
+
+		It("should handle a daily digest", func() {
+			Expect(next("0 8 * * *")).To(Equal(time.Date(2015, time.March, 15, 8, 0, 0, 0, time.UTC)))
+		})
+
+		It("should handle steps", func() {
+			Expect(next("*/15 * * * *")).To(Equal(time.Date(2015, time.March, 14, 9, 30, 0, 0, time.UTC)))
+		})
+
+		It("should handle weekdays", func() {
+			// March 14, 2015 was a Saturday.
+			Expect(next("0 9 * * 1-5")).To(Equal(time.Date(2015, time.March, 16, 9, 0, 0, 0, time.UTC)))
+		})
+
+		It("should treat 7 as Sunday", func() {
+			Expect(next("0 0 * * 7")).To(Equal(time.Date(2015, time.March, 15, 0, 0, 0, 0, time.UTC)))
+		})
+
+		It("should match either restricted day field", func() {
+			Expect(next("0 0 1 * 0")).To(Equal(time.Date(2015, time.March, 15, 0, 0, 0, 0, time.UTC)))
+		})
+
+		It("should roll over months and years", func() {
+			Expect(next("0 0 1 1 *")).To(Equal(time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)))
+		})
+	})
+})
@@ -0,0 +1,40 @@
+//go:build go1.18
+// +build go1.18
+
+package apns
+
+import "encoding/json"
+
+// SetCustomObject sets key to v, marshaled right away so values that can't
+// be encoded are reported here rather than when the notification is sent.
+// Later changes to v don't affect the payload.
+func SetCustomObject[T any](p *Payload, key string, v T) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return p.SetCustomValue(key, json.RawMessage(b))
+}
+
+// GetCustomObject decodes the custom value under key into a T. ok is false
+// if the payload has no such key.
+func GetCustomObject[T any](p *Payload, key string) (v T, ok bool, err error) {
+	cv, ok := p.customValues[key]
+	if !ok {
+		return v, false, nil
+	}
+	if t, isT := cv.(T); isT {
+		return t, true, nil
+	}
+
+	b, isRaw := cv.(json.RawMessage)
+	if !isRaw {
+		if b, err = json.Marshal(cv); err != nil {
+			return v, true, err
+		}
+	}
+
+	err = json.Unmarshal(b, &v)
+	return v, true, err
+}
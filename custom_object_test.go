@@ -0,0 +1,87 @@
+//go:build go1.18
+// +build go1.18
+
+package apns_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+type score struct {
+	Home int `json:"home"`
+	Away int `json:"away"`
+}
+
+var _ = Describe("Custom objects", func() {
+	Describe("SetCustomObject", func() {
+		It("should marshal the object into the payload", func() {
+			p := apns.NewPayload()
+			Expect(apns.SetCustomObject(p, "score", score{Home: 2, Away: 1})).To(BeNil())
+
+			b, err := json.Marshal(p)
+			Expect(err).To(BeNil())
+			Expect(b).To(MatchJSON(`{"aps":{},"score":{"home":2,"away":1}}`))
+		})
+
+		It("should reject objects that can't be marshaled", func() {
+			p := apns.NewPayload()
+			Expect(apns.SetCustomObject(p, "f", func() {})).NotTo(BeNil())
+		})
+
+		It("should reject the aps key", func() {
+			p := apns.NewPayload()
+			Expect(apns.SetCustomObject(p, "aps", score{})).To(Equal(apns.ErrCustomAPS))
+		})
+	})
+
+	Describe("GetCustomObject", func() {
+		It("should read back what was set", func() {
+			p := apns.NewPayload()
+			apns.SetCustomObject(p, "score", score{Home: 2, Away: 1})
+
+			s, ok, err := apns.GetCustomObject[score](p, "score")
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeTrue())
+			Expect(s).To(Equal(score{Home: 2, Away: 1}))
+		})
+
+		It("should decode values of unmarshaled payloads", func() {
+			p := apns.NewPayload()
+			json.Unmarshal([]byte(`{"aps":{},"score":{"home":3,"away":0}}`), p)
+
+			s, ok, err := apns.GetCustomObject[score](p, "score")
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeTrue())
+			Expect(s).To(Equal(score{Home: 3}))
+		})
+
+		It("should return values set with SetCustomValue as is", func() {
+			p := apns.NewPayload()
+			p.SetCustomValue("link", "app://x")
+
+			s, ok, err := apns.GetCustomObject[string](p, "link")
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeTrue())
+			Expect(s).To(Equal("app://x"))
+		})
+
+		It("should report missing keys", func() {
+			_, ok, err := apns.GetCustomObject[score](apns.NewPayload(), "score")
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should fail for values of another shape", func() {
+			p := apns.NewPayload()
+			p.SetCustomValue("score", "2-1")
+
+			_, ok, err := apns.GetCustomObject[score](p, "score")
+			Expect(ok).To(BeTrue())
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,97 @@
+package apns
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// DefaultCutoverAfter is the default Client.CutoverAfter.
+const DefaultCutoverAfter = 3
+
+// SetSecondaryCertificate configures a standby certificate for blue/green
+// credential rotation. It is verified in the background with a handshake
+// of its own; once verified, the client switches to it if the primary
+// fails CutoverAfter handshakes in a row (for example because it was
+// revoked mid-rotation) and emits EventCutover.
+func (c *Client) SetSecondaryCertificate(cert tls.Certificate) {
+	c.mu.Lock()
+	conf := c.Conn.Conf.Clone()
+	c.secondary = &cert
+	c.secondaryErr = nil
+	c.secondaryOK = false
+	c.mu.Unlock()
+
+	conf.Certificates = []tls.Certificate{cert}
+	go c.verifySecondary(&cert, conf)
+}
+
+// SecondaryStatus reports whether the secondary certificate passed
+// verification, and the error if it failed.
+func (c *Client) SecondaryStatus() (configured, verified bool, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.secondary != nil, c.secondaryOK, c.secondaryErr
+}
+
+func (c *Client) verifySecondary(cert *tls.Certificate, conf *tls.Config) {
+	v := Conn{
+		gateway:       c.Conn.gateway,
+		Conf:          conf,
+		IPPreference:  c.Conn.IPPreference,
+		FallbackDelay: c.Conn.FallbackDelay,
+		Resolver:      c.Conn.Resolver,
+
+		DialTimeout:      c.Conn.DialTimeout,
+		HandshakeTimeout: c.Conn.HandshakeTimeout,
+		Gateways:         c.Conn.Gateways,
+		GatewayFunc:      c.Conn.GatewayFunc,
+	}
+	err := v.Connect()
+	v.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Ignore results for a certificate that has since been replaced.
+	if c.secondary != cert {
+		return
+	}
+	c.secondaryOK, c.secondaryErr = err == nil, err
+
+	if err != nil {
+		c.logln("Secondary certificate failed verification:", err.Error())
+	} else {
+		c.logln("Secondary certificate verified.")
+	}
+}
+
+// isHandshakeFailure reports whether reason points at the credentials
+// rather than the network.
+func isHandshakeFailure(reason ConnectReason) bool {
+	switch reason {
+	case ConnectTLSAlert, ConnectCertExpired, ConnectCertInvalid:
+		return true
+	}
+	return false
+}
+
+// cutover switches the connection to the verified secondary certificate,
+// if there is one. It must only be called from the connection loop.
+func (c *Client) cutover(failures int) bool {
+	c.mu.Lock()
+	cert, ok := c.secondary, c.secondaryOK
+	if cert == nil || !ok {
+		c.mu.Unlock()
+		return false
+	}
+	c.secondary, c.secondaryOK, c.secondaryErr = nil, false, nil
+
+	conf := c.Conn.Conf.Clone()
+	conf.Certificates = []tls.Certificate{*cert}
+	c.Conn.Conf = conf
+	c.mu.Unlock()
+
+	c.emit(Event{Type: EventCutover, Message: fmt.Sprintf("primary certificate failed %d handshakes, switched to secondary", failures)})
+	return true
+}
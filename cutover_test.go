@@ -0,0 +1,57 @@
+package apns_test
+
+import (
+	"crypto/tls"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Cutover", func() {
+	read := []serverAction{serverAction{action: readAction, data: []byte{}}}
+	as := [][]serverAction{read, read, read, read, read, read}
+
+	It("should switch to a verified secondary when the primary is rejected", func(d Done) {
+		mockDone := make(chan interface{})
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			// The mock server requires a client certificate, so an empty
+			// primary fails every handshake.
+			c := apns.NewClientWithCert(s.Address(), tls.Certificate{})
+			c.Conn.Conf.InsecureSkipVerify = true
+			c.Conn.Conf.MaxVersion = tls.VersionTLS12
+			c.CutoverAfter = 1
+
+			cert, _ := tls.X509KeyPair([]byte(DummyCert), []byte(DummyKey))
+			c.SetSecondaryCertificate(cert)
+
+			for e := range c.Events {
+				if e.Type == apns.EventCutover {
+					break
+				}
+			}
+
+			configured, _, _ := c.SecondaryStatus()
+			Expect(configured).To(BeFalse())
+			Expect(c.Conn.Conf.Certificates[0].Certificate).To(Equal(cert.Certificate))
+
+			close(mockDone)
+			close(d)
+		})
+	}, 5)
+
+	It("should not switch to a secondary that failed verification", func() {
+		c := apns.NewClientWithCert("127.0.0.1:1", tls.Certificate{})
+
+		cert, _ := tls.X509KeyPair([]byte(DummyCert), []byte(DummyKey))
+		c.SetSecondaryCertificate(cert)
+
+		Eventually(func() error {
+			_, _, err := c.SecondaryStatus()
+			return err
+		}).ShouldNot(BeNil())
+
+		_, verified, _ := c.SecondaryStatus()
+		Expect(verified).To(BeFalse())
+	})
+})
@@ -0,0 +1,237 @@
+package apns
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// recentEventsSize is how many events DumpDiagnostics includes.
+const recentEventsSize = 50
+
+// eventLog keeps the most recent events for diagnostics, independently of
+// whoever reads Client.Events.
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (l *eventLog) add(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, e)
+	if len(l.events) > recentEventsSize {
+		l.events = l.events[len(l.events)-recentEventsSize:]
+	}
+}
+
+func (l *eventLog) snapshot() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]Event(nil), l.events...)
+}
+
+// queueGauges are updated by the client's loops so diagnostics can read
+// queue depths without stopping them. It is allocated separately to keep
+// the counters 64-bit aligned for atomic access.
+type queueGauges struct {
+	queued   int64
+	buffered int64
+	retrying int64
+
+	// encodes counts frames built and encodeNanos the total time spent
+	// building them; lastEncodeNanos is the time of the latest one.
+	encodes         int64
+	encodeNanos     int64
+	lastEncodeNanos int64
+}
+
+func (g *queueGauges) addEncode(d time.Duration) {
+	atomic.AddInt64(&g.encodes, 1)
+	atomic.AddInt64(&g.encodeNanos, int64(d))
+	atomic.StoreInt64(&g.lastEncodeNanos, int64(d))
+}
+
+// meanEncode returns the average time spent building a frame.
+func (g *queueGauges) meanEncode() time.Duration {
+	n := atomic.LoadInt64(&g.encodes)
+	if n == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&g.encodeNanos) / n)
+}
+
+type certSummary struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"sha256"`
+}
+
+type diagnosticEvent struct {
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	ConnID  uint64    `json:"conn_id"`
+	Message string    `json:"message"`
+	Count   int       `json:"count,omitempty"`
+}
+
+type diagnostics struct {
+	Time   time.Time `json:"time"`
+	Config struct {
+		Gateway            string        `json:"gateway"`
+		Gateways           []string      `json:"fallback_gateways,omitempty"`
+		GatewayFunc        bool          `json:"gateway_func"`
+		ConnID             uint64        `json:"conn_id"`
+		Certificates       []certSummary `json:"certificates"`
+		InsecureSkipVerify bool          `json:"insecure_skip_verify"`
+		IPPreference       IPPreference  `json:"ip_preference"`
+		FallbackDelay      string        `json:"fallback_delay"`
+		CustomResolver     bool          `json:"custom_resolver"`
+		QueueSize          int           `json:"queue_size"`
+		BufferSize         int           `json:"buffer_size"`
+		MaxInFlight        int           `json:"max_in_flight"`
+		InFlightWindow     string        `json:"in_flight_window"`
+		DedupeWindow       string        `json:"dedupe_window"`
+		CutoverAfter       int           `json:"cutover_after"`
+		Secondary          bool          `json:"secondary_configured"`
+		SecondaryVerified  bool          `json:"secondary_verified"`
+		Lock               string        `json:"lock,omitempty"`
+		Schemas            bool          `json:"schemas"`
+		Suppressions       bool          `json:"suppressions"`
+		Correlations       bool          `json:"correlations"`
+		BeforeSendHooks    int           `json:"before_send_hooks"`
+		AfterSendHooks     int           `json:"after_send_hooks"`
+		Subscriptions      int           `json:"subscriptions"`
+		SoftFail           bool          `json:"soft_fail"`
+	} `json:"config"`
+	Stats struct {
+		Sent     int   `json:"sent"`
+		Failed   int   `json:"failed"`
+		Skipped  int   `json:"skipped"`
+		Len      int   `json:"len"`
+		Queued   int64 `json:"queued"`
+		Buffered int64 `json:"buffered"`
+		Retrying int64 `json:"retrying"`
+		Closed   bool  `json:"closed"`
+
+		// Encodes is how many frames were built, and EncodeMean and
+		// EncodeLast how long building one took on average and last time,
+		// payload encoding included.
+		Encodes    int64  `json:"encodes"`
+		EncodeMean string `json:"encode_mean"`
+		EncodeLast string `json:"encode_last"`
+
+		ErrorLatency ErrorLatency `json:"error_latency"`
+
+		// SendRate is writes per second over the last minute, and
+		// DrainTime how long the backlog will take at that rate; empty if
+		// it can't be estimated.
+		SendRate  float64 `json:"send_rate"`
+		DrainTime string  `json:"drain_time,omitempty"`
+	} `json:"stats"`
+	Connects []ConnectAttempt  `json:"connects"`
+	Events   []diagnosticEvent `json:"events"`
+}
+
+func summarizeCert(der []byte) certSummary {
+	sum := sha256.Sum256(der)
+	s := certSummary{Fingerprint: hex.EncodeToString(sum[:])}
+
+	if cert, err := x509.ParseCertificate(der); err == nil {
+		s.Subject = cert.Subject.String()
+		s.Issuer = cert.Issuer.String()
+		s.NotAfter = cert.NotAfter
+	}
+	return s
+}
+
+// DumpDiagnostics writes a JSON snapshot of the client for support tickets
+// and incident reviews: its configuration, counters, queue depths, recent
+// connection attempts and recent events. Certificates are summarized by
+// subject, expiry and fingerprint; private keys and payloads are never
+// included. It is safe to call at any time, including after Close.
+func (c *Client) DumpDiagnostics(w io.Writer) error {
+	var d diagnostics
+	d.Time = time.Now()
+
+	c.mu.RLock()
+	cfg := &d.Config
+	cfg.Gateway = c.Conn.gateway
+	cfg.Gateways = c.Conn.Gateways
+	cfg.GatewayFunc = c.Conn.GatewayFunc != nil
+	cfg.ConnID = c.Conn.ID
+	if conf := c.Conn.Conf; conf != nil {
+		for _, cert := range conf.Certificates {
+			if len(cert.Certificate) > 0 {
+				cfg.Certificates = append(cfg.Certificates, summarizeCert(cert.Certificate[0]))
+			}
+		}
+		cfg.InsecureSkipVerify = conf.InsecureSkipVerify
+	}
+	cfg.Secondary = c.secondary != nil
+	cfg.SecondaryVerified = c.secondaryOK
+	if c.lock != nil {
+		cfg.Lock = fmt.Sprintf("%T", c.lock)
+	}
+	cfg.BeforeSendHooks = len(c.beforeSends)
+	cfg.AfterSendHooks = len(c.afterSends)
+	cfg.Subscriptions = len(c.subs)
+	c.mu.RUnlock()
+
+	cfg.IPPreference = c.Conn.IPPreference
+	cfg.FallbackDelay = c.Conn.FallbackDelay.String()
+	cfg.CustomResolver = c.Conn.Resolver != nil
+	cfg.QueueSize = c.QueueSize
+	cfg.BufferSize = c.BufferSize
+	cfg.MaxInFlight = c.MaxInFlight
+	cfg.InFlightWindow = c.InFlightWindow.String()
+	cfg.DedupeWindow = c.DedupeWindow.String()
+	cfg.CutoverAfter = c.CutoverAfter
+	cfg.Schemas = c.Schemas != nil
+	cfg.Suppressions = c.Suppressions != nil
+	cfg.Correlations = c.Correlations != nil
+	cfg.SoftFail = c.SoftFail
+
+	d.Stats.Sent = c.Sent
+	d.Stats.Failed = c.Failed
+	d.Stats.Skipped = c.Skipped
+	d.Stats.Len = c.Len
+	d.Stats.Queued = atomic.LoadInt64(&c.gauges.queued)
+	d.Stats.Buffered = atomic.LoadInt64(&c.gauges.buffered)
+	d.Stats.Retrying = atomic.LoadInt64(&c.gauges.retrying)
+	d.Stats.Encodes = atomic.LoadInt64(&c.gauges.encodes)
+	d.Stats.EncodeMean = c.gauges.meanEncode().String()
+	d.Stats.EncodeLast = time.Duration(atomic.LoadInt64(&c.gauges.lastEncodeNanos)).String()
+	d.Stats.ErrorLatency = c.ErrorLatency()
+	d.Stats.SendRate = c.SendRate()
+	if drain, ok := c.EstimatedDrainTime(); ok {
+		d.Stats.DrainTime = drain.String()
+	}
+	select {
+	case <-c.closed:
+		d.Stats.Closed = true
+	default:
+	}
+
+	if c.Conn.Audit != nil {
+		d.Connects = c.Conn.Audit.Attempts()
+	}
+
+	d.Events = []diagnosticEvent{}
+	for _, e := range c.recent.snapshot() {
+		d.Events = append(d.Events, diagnosticEvent{Type: e.Type, Time: e.Time, ConnID: e.ConnID, Message: e.Message, Count: e.Count})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
@@ -0,0 +1,64 @@
+package apns_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Diagnostics", func() {
+	Describe("Client#DumpDiagnostics", func() {
+		It("should write a JSON bundle without secrets", func() {
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			c.QueueSize = 10
+			c.Send(apns.NewNotification())
+			Eventually(c.Conn.Audit.Attempts).ShouldNot(BeEmpty())
+			Eventually(func() string {
+				var buf bytes.Buffer
+				c.DumpDiagnostics(&buf)
+				return buf.String()
+			}).Should(ContainSubstring(`"queued": 1`))
+
+			var buf bytes.Buffer
+			Expect(c.DumpDiagnostics(&buf)).To(BeNil())
+
+			Expect(buf.String()).NotTo(ContainSubstring("PRIVATE KEY"))
+			Expect(buf.String()).NotTo(ContainSubstring(DummyKey[40:80]))
+
+			var d struct {
+				Config struct {
+					Gateway      string `json:"gateway"`
+					QueueSize    int    `json:"queue_size"`
+					Certificates []struct {
+						Fingerprint string `json:"sha256"`
+					} `json:"certificates"`
+				} `json:"config"`
+				Stats struct {
+					Len     int  `json:"len"`
+					Queued  int  `json:"queued"`
+					Closed  bool `json:"closed"`
+					Encodes int  `json:"encodes"`
+				} `json:"stats"`
+				Connects []struct {
+					Reason string `json:"reason"`
+				} `json:"connects"`
+			}
+			Expect(json.Unmarshal(buf.Bytes(), &d)).To(BeNil())
+
+			Expect(d.Config.Gateway).To(Equal("127.0.0.1:1"))
+			Expect(d.Config.QueueSize).To(Equal(10))
+			Expect(d.Config.Certificates).To(HaveLen(1))
+			Expect(d.Config.Certificates[0].Fingerprint).To(HaveLen(64))
+			Expect(d.Stats.Len).To(Equal(1))
+			Expect(d.Stats.Queued).To(Equal(1))
+			Expect(d.Stats.Closed).To(BeFalse())
+			Expect(d.Stats.Encodes).To(Equal(0))
+			Expect(d.Connects[0].Reason).To(Equal("tcp-refused"))
+
+			c.Close()
+		})
+	})
+})
@@ -0,0 +1,244 @@
+package apns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// IPPreference selects which address family is tried first when a gateway
+// resolves to both IPv4 and IPv6 addresses.
+type IPPreference int
+
+const (
+	PreferResolverOrder IPPreference = iota
+	PreferIPv4
+	PreferIPv6
+)
+
+// Resolver resolves gateway host names. *net.Resolver implements it, so
+// internal DNS or DNS-over-HTTPS can be plugged in, as can StaticResolver
+// for pinning addresses behind strict egress rules.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// StaticResolver resolves host names from a fixed table.
+type StaticResolver map[string][]net.IPAddr
+
+func (r StaticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if ips, ok := r[host]; ok && len(ips) > 0 {
+		return ips, nil
+	}
+	return nil, &net.DNSError{Err: "no static address", Name: host, IsNotFound: true}
+}
+
+// DefaultFallbackDelay is how long a connection attempt gets before the next
+// address is tried in parallel, as recommended by RFC 8305.
+const DefaultFallbackDelay = 250 * time.Millisecond
+
+// orderAddrs interleaves IPv4 and IPv6 addresses, starting with the
+// preferred family, so a broken route for one family only costs a single
+// fallback delay.
+func orderAddrs(ips []net.IPAddr, pref IPPreference) []net.IPAddr {
+	var v4, v6 []net.IPAddr
+	for _, ip := range ips {
+		if ip.IP.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+
+	first, second := v6, v4
+	switch pref {
+	case PreferIPv4:
+		first, second = v4, v6
+	case PreferResolverOrder:
+		if len(ips) > 0 && ips[0].IP.To4() != nil {
+			first, second = v4, v6
+		}
+	}
+
+	ordered := make([]net.IPAddr, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			ordered = append(ordered, first[i])
+		}
+		if i < len(second) {
+			ordered = append(ordered, second[i])
+		}
+	}
+	return ordered
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialParallel starts a connection attempt to each address in turn, giving
+// each one delay to succeed before starting the next alongside it. The
+// first successful connection wins and the rest are closed.
+func dialParallel(ctx context.Context, addrs []net.IPAddr, port string, delay time.Duration, dial func(ctx context.Context, addr string) (net.Conn, error)) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	results := make(chan dialResult, len(addrs))
+
+	next, pending := 0, 0
+	start := func() {
+		addr := net.JoinHostPort(addrs[next].String(), port)
+		next++
+		pending++
+		go func() {
+			conn, err := dial(ctx, addr)
+			results <- dialResult{conn, err}
+		}()
+	}
+
+	start()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var firstErr error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				cancel()
+				go func(n int) {
+					for ; n > 0; n-- {
+						if r := <-results; r.conn != nil {
+							r.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, nil
+			}
+
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(addrs) {
+				start()
+				timer.Reset(delay)
+			}
+		case <-timer.C:
+			if next < len(addrs) {
+				start()
+				timer.Reset(delay)
+			}
+		}
+	}
+
+	cancel()
+	return nil, firstErr
+}
+
+// dial opens the TCP connection to gw, racing IPv4 and IPv6
+// addresses according to the Conn's preference, and records how long
+// resolution and connecting took in t. Both together get DialTimeout.
+func (c *Conn) dial(ctx context.Context, gw string, t *ConnectTiming) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(gw)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.DialTimeout
+	if timeout <= 0 {
+		timeout = DefaultDialTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var resolver Resolver = net.DefaultResolver
+	if c.Resolver != nil {
+		resolver = c.Resolver
+	}
+
+	start := time.Now()
+	ips, err := resolver.LookupIPAddr(ctx, host)
+	t.DNS = time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+
+	delay := c.FallbackDelay
+	if delay == 0 {
+		delay = DefaultFallbackDelay
+	}
+
+	if len(c.LocalAddrs) > 0 {
+		ips = bindable(ips, c.LocalAddrs)
+		if len(ips) == 0 {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errNoLocalAddr}
+		}
+	}
+
+	start = time.Now()
+	conn, err := dialParallel(ctx, orderAddrs(ips, c.IPPreference), port, delay, func(ctx context.Context, addr string) (net.Conn, error) {
+		d := net.Dialer{Timeout: timeout}
+		if local := localAddrFor(addr, c.LocalAddrs); local != nil {
+			d.LocalAddr = &net.TCPAddr{IP: local}
+		}
+		return d.DialContext(ctx, "tcp", addr)
+	})
+	t.TCP = time.Since(start)
+
+	return conn, err
+}
+
+var errNoLocalAddr = errors.New("no local address of the gateway's address family")
+
+// bindable returns the addresses in ips of a family locals has an address
+// of.
+func bindable(ips []net.IPAddr, locals []net.IP) []net.IPAddr {
+	var ok []net.IPAddr
+	for _, ip := range ips {
+		if localFor(ip.IP, locals) != nil {
+			ok = append(ok, ip)
+		}
+	}
+	return ok
+}
+
+// localAddrFor returns the first of locals of the same family as the host
+// of addr, or nil.
+func localAddrFor(addr string, locals []net.IP) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	return localFor(net.ParseIP(host), locals)
+}
+
+func localFor(ip net.IP, locals []net.IP) net.IP {
+	for _, l := range locals {
+		if (l.To4() != nil) == (ip.To4() != nil) {
+			return l
+		}
+	}
+	return nil
+}
+
+// InterfaceAddrs returns the IP addresses of the named network interface,
+// for setting Conn.LocalAddrs from an interface name.
+func InterfaceAddrs(name string) ([]net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok {
+			ips = append(ips, n.IP)
+		}
+	}
+	return ips, nil
+}
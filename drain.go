@@ -0,0 +1,87 @@
+package apns
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateWindow is how far back SendRate looks.
+const rateWindow = time.Minute
+
+// sendRate counts writes per second over the last rateWindow.
+type sendRate struct {
+	mu      sync.Mutex
+	first   time.Time
+	seconds [60]int64
+	counts  [60]int64
+}
+
+func (r *sendRate) observe(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.first.IsZero() {
+		r.first = t
+	}
+	s := t.Unix()
+	i := s % int64(len(r.seconds))
+	if r.seconds[i] != s {
+		r.seconds[i] = s
+		r.counts[i] = 0
+	}
+	r.counts[i]++
+}
+
+// perSecond returns the average rate since the first write, or over the
+// window once it's older. Less than a second of history counts as one, so
+// a short burst doesn't look like a sustained rate.
+func (r *sendRate) perSecond(now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.first.IsZero() {
+		return 0
+	}
+
+	var total int64
+	s := now.Unix()
+	for i, sec := range r.seconds {
+		if sec > s-int64(len(r.seconds)) && sec <= s {
+			total += r.counts[i]
+		}
+	}
+
+	elapsed := now.Sub(r.first)
+	if elapsed > rateWindow {
+		elapsed = rateWindow
+	}
+	if elapsed < time.Second {
+		elapsed = time.Second
+	}
+	return float64(total) / elapsed.Seconds()
+}
+
+// SendRate returns how many notifications per second the client has
+// written over the last minute.
+func (c *Client) SendRate() float64 {
+	return c.rate.perSecond(time.Now())
+}
+
+// EstimatedDrainTime returns how long the client will take to send what
+// is queued or waiting to be resent at the current SendRate, so
+// orchestration can add senders when a backlog won't be through before a
+// deadline. ok is false if there is a backlog but nothing has been sent
+// in the last minute to estimate from.
+func (c *Client) EstimatedDrainTime() (d time.Duration, ok bool) {
+	backlog := atomic.LoadInt64(&c.gauges.queued) + atomic.LoadInt64(&c.gauges.retrying)
+	if backlog == 0 {
+		return 0, true
+	}
+
+	rate := c.SendRate()
+	if rate == 0 {
+		return 0, false
+	}
+	return time.Duration(float64(backlog) / rate * float64(time.Second)), true
+}
@@ -0,0 +1,68 @@
+package apns_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Drain time", func() {
+	tok := "9999999999999999999999999999999999999999999999999999999999999999"
+
+	It("should estimate from the backlog and send rate", func(d Done) {
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		mockDone := make(chan interface{})
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+
+			release := make(chan struct{})
+			c.OnBeforeSend(func(n *apns.Notification) error {
+				if n.ID == "hold" {
+					<-release
+				}
+				return nil
+			})
+
+			drain, ok := c.EstimatedDrainTime()
+			Expect(ok).To(BeTrue())
+			Expect(drain).To(BeZero())
+
+			send := func(id string) {
+				n := apns.NewNotification()
+				n.ID = id
+				n.DeviceToken = tok
+				c.Send(n)
+			}
+
+			for i := 0; i < 5; i++ {
+				send(fmt.Sprint("n", i))
+			}
+			Eventually(c.SendRate).Should(BeNumerically(">", 0))
+
+			// Hold the connection up so the rest stay queued.
+			send("hold")
+			for i := 0; i < 10; i++ {
+				send(fmt.Sprint("q", i))
+			}
+
+			rate := c.SendRate()
+			Eventually(func() time.Duration {
+				drain, _ := c.EstimatedDrainTime()
+				return drain
+			}).Should(BeNumerically("~", time.Duration(10/rate*float64(time.Second)), 100*time.Millisecond))
+
+			close(release)
+			close(mockDone)
+			close(d)
+		})
+	})
+})
@@ -0,0 +1,112 @@
+package apns
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DeviceKeyStore looks up the key shared with the app on a device, e.g.
+// one negotiated when the device registered its token.
+type DeviceKeyStore interface {
+	DeviceKey(token string) (keyID string, key []byte, err error)
+}
+
+// EncryptedField replaces the value of a field encrypted by
+// FieldEncrypter. Nonce and Ciphertext are base64 encoded in JSON.
+type EncryptedField struct {
+	KeyID      string `json:"kid"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ct"`
+}
+
+// FieldEncrypter encrypts one custom payload field end to end with AES-GCM
+// under a per-device key, for the app's Notification Service Extension to
+// decrypt. The plaintext is the field's JSON encoding and the field name is
+// bound as additional data. Register its Encrypt method with
+// Client.OnBeforeSend to encrypt every notification at send time.
+type FieldEncrypter struct {
+	Keys  DeviceKeyStore
+	Field string
+}
+
+// Encrypt replaces the field with an EncryptedField and sets
+// mutable-content so the extension gets to decrypt it. Notifications
+// without the field are left alone, as are ones already encrypted.
+//
+// The notification gets its own copy of the payload, so a Payload shared
+// by notifications to several devices is left as it was and each device
+// gets a ciphertext under its own key.
+func (e *FieldEncrypter) Encrypt(n *Notification) error {
+	if n.payload != nil {
+		return errors.New("cannot encrypt a field of a precomputed payload")
+	}
+	if n.Payload == nil {
+		return nil
+	}
+
+	v, ok := n.Payload.customValues[e.Field]
+	if !ok {
+		return nil
+	}
+	if _, done := v.(EncryptedField); done {
+		return nil
+	}
+
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	keyID, key, err := e.Keys.DeviceKey(n.DeviceToken)
+	if err != nil {
+		return err
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	p := n.Payload.copy()
+	p.customValues[e.Field] = EncryptedField{
+		KeyID:      keyID,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, []byte(e.Field)),
+	}
+	p.APS.MutableContent = 1
+	n.Payload = p
+
+	return nil
+}
+
+// DecryptField reverses FieldEncrypter.Encrypt for field, returning the
+// JSON encoding of the original value.
+func DecryptField(key []byte, field string, f EncryptedField) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(f.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("nonce is %d bytes, want %d", len(f.Nonce), aead.NonceSize())
+	}
+
+	return aead.Open(nil, f.Nonce, f.Ciphertext, []byte(field))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,108 @@
+package apns_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+type mockDeviceKeys map[string][]byte
+
+func (m mockDeviceKeys) DeviceKey(token string) (string, []byte, error) {
+	k, ok := m[token]
+	if !ok {
+		return "", nil, errors.New("no key for device")
+	}
+	return "device-key", k, nil
+}
+
+var _ = Describe("FieldEncrypter", func() {
+	tok := "9999999999999999999999999999999999999999999999999999999999999999"
+	key := bytes.Repeat([]byte{7}, 32)
+	e := &apns.FieldEncrypter{Keys: mockDeviceKeys{tok: key}, Field: "message"}
+
+	Describe("#Encrypt", func() {
+		It("should encrypt the field for the device", func() {
+			n := apns.NewNotification()
+			n.DeviceToken = tok
+			n.Payload.SetCustomValue("message", map[string]string{"text": "hi"})
+
+			Expect(e.Encrypt(&n)).To(BeNil())
+
+			b, _ := json.Marshal(n.Payload)
+			Expect(string(b)).NotTo(ContainSubstring(`"text"`))
+			Expect(n.Payload.APS.MutableContent).To(Equal(1))
+
+			var sent struct {
+				Message apns.EncryptedField `json:"message"`
+			}
+			json.Unmarshal(b, &sent)
+			Expect(sent.Message.KeyID).To(Equal("device-key"))
+
+			plaintext, err := apns.DecryptField(key, "message", sent.Message)
+			Expect(err).To(BeNil())
+			Expect(plaintext).To(MatchJSON(`{"text":"hi"}`))
+
+			_, err = apns.DecryptField(key, "other", sent.Message)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should not encrypt twice", func() {
+			n := apns.NewNotification()
+			n.DeviceToken = tok
+			n.Payload.SetCustomValue("message", "hi")
+
+			e.Encrypt(&n)
+			first, _ := json.Marshal(n.Payload)
+			Expect(e.Encrypt(&n)).To(BeNil())
+			second, _ := json.Marshal(n.Payload)
+
+			Expect(second).To(Equal(first))
+		})
+
+		It("should encrypt a shared payload separately for each device", func() {
+			other := "8888888888888888888888888888888888888888888888888888888888888888"
+			otherKey := bytes.Repeat([]byte{9}, 32)
+			e := &apns.FieldEncrypter{Keys: mockDeviceKeys{tok: key, other: otherKey}, Field: "message"}
+
+			p := apns.NewPayload()
+			p.SetCustomValue("message", "hi")
+			a, b := apns.NewNotification(), apns.NewNotification()
+			a.DeviceToken, a.Payload = tok, p
+			b.DeviceToken, b.Payload = other, p
+
+			Expect(e.Encrypt(&a)).To(BeNil())
+			Expect(e.Encrypt(&b)).To(BeNil())
+
+			shared, _ := json.Marshal(p)
+			Expect(shared).To(MatchJSON(`{"aps":{},"message":"hi"}`))
+
+			for _, d := range []struct {
+				n   apns.Notification
+				key []byte
+			}{{a, key}, {b, otherKey}} {
+				var sent struct {
+					Message apns.EncryptedField `json:"message"`
+				}
+				body, _ := json.Marshal(d.n.Payload)
+				json.Unmarshal(body, &sent)
+
+				plaintext, err := apns.DecryptField(d.key, "message", sent.Message)
+				Expect(err).To(BeNil())
+				Expect(plaintext).To(MatchJSON(`"hi"`))
+			}
+		})
+
+		It("should fail without a device key", func() {
+			n := apns.NewNotification()
+			n.DeviceToken = "unknown"
+			n.Payload.SetCustomValue("message", "hi")
+
+			Expect(e.Encrypt(&n)).To(MatchError("no key for device"))
+		})
+	})
+})
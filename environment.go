@@ -0,0 +1,127 @@
+package apns
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// The binary protocol's addresses, grouped by kind. They are the same as
+// ProductionGateway and friends.
+const (
+	GatewayProduction = ProductionGateway
+	GatewaySandbox    = SandboxGateway
+
+	FeedbackProduction = ProductionFeedbackGateway
+	FeedbackSandbox    = SandboxFeedbackGateway
+)
+
+// Environment selects between Apple's production and sandbox services, so
+// the addresses for each protocol come from one switch instead of strings
+// that have to be kept in step:
+//
+//	env := apns.Production
+//	if debug {
+//		env = apns.Sandbox
+//	}
+//	c := apns.NewClientFor(env, cert)
+//	f := apns.NewFeedbackFor(env, cert)
+type Environment int
+
+const (
+	Production Environment = iota
+
+	// Sandbox is what Apple calls the development environment. Apps built
+	// for development get sandbox device tokens.
+	Sandbox
+)
+
+func (e Environment) String() string {
+	if e == Sandbox {
+		return "sandbox"
+	}
+	return "production"
+}
+
+// ParseEnvironment parses "production" or "sandbox", as in configuration
+// files. "development" is accepted for the sandbox too.
+func ParseEnvironment(s string) (Environment, error) {
+	switch strings.ToLower(s) {
+	case "production":
+		return Production, nil
+	case "sandbox", "development":
+		return Sandbox, nil
+	}
+	return Production, fmt.Errorf("apns: unknown environment %q", s)
+}
+
+// Gateway returns the binary protocol gateway, as host:port.
+func (e Environment) Gateway() string {
+	if e == Sandbox {
+		return GatewaySandbox
+	}
+	return GatewayProduction
+}
+
+// FeedbackGateway returns the feedback service, as host:port.
+func (e Environment) FeedbackGateway() string {
+	if e == Sandbox {
+		return FeedbackSandbox
+	}
+	return FeedbackProduction
+}
+
+// Host returns the HTTP/2 provider API, as a URL.
+func (e Environment) Host() string {
+	if e == Sandbox {
+		return DevelopmentHost
+	}
+	return ProductionHost
+}
+
+// ChannelHost returns the broadcast channel management API, as a URL.
+func (e Environment) ChannelHost() string {
+	if e == Sandbox {
+		return ChannelDevelopmentHost
+	}
+	return ChannelProductionHost
+}
+
+// NewClientFor creates a Client for env's gateway that authenticates with
+// cert.
+func NewClientFor(env Environment, cert tls.Certificate) *Client {
+	return NewClientWithCert(env.Gateway(), cert)
+}
+
+// NewClient2For creates a Client2 for env's HTTP/2 host that authenticates
+// with cert.
+func NewClient2For(env Environment, cert tls.Certificate) *Client2 {
+	return NewClient2(env.Host(), cert)
+}
+
+// NewFeedbackFor creates a Feedback for env's feedback service that
+// authenticates with cert.
+func NewFeedbackFor(env Environment, cert tls.Certificate) Feedback {
+	return NewFeedbackWithCert(env.FeedbackGateway(), cert)
+}
+
+// checkGateway adds a problem if gw isn't a host:port address, such as an
+// HTTP/2 URL passed where a binary gateway belongs.
+func (e *ConfigError) checkGateway(field, gw string) {
+	if strings.Contains(gw, "://") {
+		e.add(field, fmt.Sprintf("%q is a URL, not a host:port address", gw), "use GatewayProduction or GatewaySandbox; URLs are for Client2")
+		return
+	}
+	if _, _, err := net.SplitHostPort(gw); err != nil {
+		e.add(field, fmt.Sprintf("%q has no port", gw), "use GatewayProduction or GatewaySandbox")
+	}
+}
+
+// checkHost adds a problem if host isn't an https URL, such as a binary
+// gateway passed where an HTTP/2 host belongs.
+func (e *ConfigError) checkHost(field, host string) {
+	if !strings.HasPrefix(host, "https://") {
+		e.add(field, fmt.Sprintf("%q is not an https URL", host), "use ProductionHost or DevelopmentHost; host:port addresses are for Client")
+	}
+}
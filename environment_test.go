@@ -0,0 +1,48 @@
+package apns_test
+
+import (
+	"crypto/tls"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Environment", func() {
+	It("should pick each service's address", func() {
+		Expect(apns.Production.Gateway()).To(Equal("gateway.push.apple.com:2195"))
+		Expect(apns.Production.FeedbackGateway()).To(Equal("feedback.push.apple.com:2196"))
+		Expect(apns.Production.Host()).To(Equal(apns.ProductionHost))
+		Expect(apns.Production.ChannelHost()).To(Equal(apns.ChannelProductionHost))
+
+		Expect(apns.Sandbox.Gateway()).To(Equal("gateway.sandbox.push.apple.com:2195"))
+		Expect(apns.Sandbox.FeedbackGateway()).To(Equal("feedback.sandbox.push.apple.com:2196"))
+		Expect(apns.Sandbox.Host()).To(Equal(apns.DevelopmentHost))
+		Expect(apns.Sandbox.ChannelHost()).To(Equal(apns.ChannelDevelopmentHost))
+	})
+
+	It("should parse names from configuration", func() {
+		for s, want := range map[string]apns.Environment{
+			"production":  apns.Production,
+			"Sandbox":     apns.Sandbox,
+			"development": apns.Sandbox,
+		} {
+			env, err := apns.ParseEnvironment(s)
+			Expect(err).To(BeNil())
+			Expect(env).To(Equal(want))
+		}
+
+		_, err := apns.ParseEnvironment("staging")
+		Expect(err).NotTo(BeNil())
+		Expect(apns.Sandbox.String()).To(Equal("sandbox"))
+	})
+
+	It("should create clients for the environment", func() {
+		c := apns.NewClientFor(apns.Sandbox, tls.Certificate{})
+		defer c.Close()
+		Expect(c.Conn.Conf.ServerName).To(Equal("gateway.sandbox.push.apple.com"))
+
+		Expect(apns.NewClient2For(apns.Sandbox, tls.Certificate{}).Host).To(Equal(apns.DevelopmentHost))
+		Expect(apns.NewFeedbackFor(apns.Production, tls.Certificate{}).Conn.Conf.ServerName).To(Equal("feedback.push.apple.com"))
+	})
+})
@@ -3,6 +3,7 @@ package apns
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 )
 
 const (
@@ -18,6 +19,17 @@ const (
 	ErrInvalidToken       = "Invalid token"
 	ErrShutdown           = "Shutdown"
 	ErrUnknown            = "None (unknown)"
+
+	// Error strings for notifications the client rejects locally, without
+	// sending them to APNS.
+	ErrSuppressed = "Suppressed"
+	ErrDuplicate  = "Duplicate"
+	ErrForgotten  = "Forgotten"
+	ErrCancelled  = "Cancelled"
+	ErrDeadline   = "Deadline passed"
+	ErrSkipped    = "Skipped"
+	ErrExpired    = "Expired"
+	ErrOverflow   = "Overflow"
 )
 
 var errorMapping = map[uint8]string{
@@ -33,16 +45,160 @@ var errorMapping = map[uint8]string{
 	255: ErrUnknown,
 }
 
+// Stable, machine-readable codes for Error.Code. Unlike the error strings,
+// they will never change.
+const (
+	CodeProcessing         = "processing_error"
+	CodeMissingDeviceToken = "missing_device_token"
+	CodeMissingTopic       = "missing_topic"
+	CodeMissingPayload     = "missing_payload"
+	CodeInvalidTokenSize   = "invalid_token_size"
+	CodeInvalidTopicSize   = "invalid_topic_size"
+	CodeInvalidPayloadSize = "invalid_payload_size"
+	CodeInvalidToken       = "invalid_token"
+	CodeShutdown           = "shutdown"
+	CodeSuppressed         = "suppressed"
+	CodeDuplicate          = "duplicate"
+	CodeForgotten          = "forgotten"
+	CodeCancelled          = "cancelled"
+	CodeDeadline           = "deadline_passed"
+	CodeSkipped            = "skipped"
+	CodeExpired            = "expired"
+	CodeOverflow           = "overflow"
+	CodeUnknown            = "unknown"
+)
+
+var codeMapping = map[string]string{
+	ErrProcessing:         CodeProcessing,
+	ErrMissingDeviceToken: CodeMissingDeviceToken,
+	ErrMissingTopic:       CodeMissingTopic,
+	ErrMissingPayload:     CodeMissingPayload,
+	ErrInvalidTokenSize:   CodeInvalidTokenSize,
+	ErrInvalidTopicSize:   CodeInvalidTopicSize,
+	ErrInvalidPayloadSize: CodeInvalidPayloadSize,
+	ErrInvalidToken:       CodeInvalidToken,
+	ErrShutdown:           CodeShutdown,
+	ErrSuppressed:         CodeSuppressed,
+	ErrDuplicate:          CodeDuplicate,
+	ErrForgotten:          CodeForgotten,
+	ErrCancelled:          CodeCancelled,
+	ErrDeadline:           CodeDeadline,
+	ErrSkipped:            CodeSkipped,
+	ErrExpired:            CodeExpired,
+	ErrOverflow:           CodeOverflow,
+}
+
+// ErrorDescriptions holds the human-readable description of each code,
+// returned by Error.Description. Replace or extend it to localize them.
+var ErrorDescriptions = map[string]string{
+	CodeProcessing:         "APNS failed to process the notification. Resend it.",
+	CodeMissingDeviceToken: "The notification has no device token.",
+	CodeMissingTopic:       "The notification has no topic.",
+	CodeMissingPayload:     "The notification has no payload.",
+	CodeInvalidTokenSize:   "The device token is not 32 bytes long.",
+	CodeInvalidTopicSize:   "The topic is too long.",
+	CodeInvalidPayloadSize: "The payload is larger than APNS accepts.",
+	CodeInvalidToken:       "The device token is not valid for this app and environment. It may be from the sandbox, or the app may have been uninstalled; stop sending to it.",
+	CodeShutdown:           "APNS closed the connection for maintenance. Notifications after this one are resent.",
+	CodeSuppressed:         "The token is on the client's suppression list, so the notification wasn't sent.",
+	CodeDuplicate:          "The same content went to the same token within the client's DedupeWindow, so the notification wasn't sent.",
+	CodeForgotten:          "Client.Forget removed the notification before it was sent.",
+	CodeCancelled:          "SendGroup.Cancel cancelled the notification before it was sent.",
+	CodeDeadline:           "The notification wasn't written by its send deadline, so it was dropped rather than sent late.",
+	CodeSkipped:            "The client couldn't connect to APNS and is in SoftFail mode, so the notification was skipped.",
+	CodeExpired:            "The notification's Expiration passed before it was sent, so it was dropped; APNS would have discarded it anyway.",
+	CodeOverflow:           "A buffer or queue the notification had to pass through was full, so it was dropped.",
+	CodeUnknown:            "An unknown error occurred.",
+}
+
+// Where Error.DocURL points.
+const (
+	apnsErrorDocURL  = "https://developer.apple.com/library/ios/documentation/NetworkingInternet/Conceptual/RemoteNotificationsPG/Chapters/CommunicatingWIthAPS.html#//apple_ref/doc/uid/TP40008194-CH101-SW12"
+	localErrorDocURL = "https://godoc.org/github.com/timehop/apns#pkg-constants"
+)
+
 type Error struct {
 	Command    uint8
 	Status     uint8
 	Identifier uint32
 	ErrStr     string
+
+	// Err is the error behind a notification the client rejected itself,
+	// such as a *LimitError, if there is one. ErrStr is its message.
+	Err error
+
+	kind localKind
+}
+
+// localKind says why the client failed a notification itself, so results
+// don't depend on what an error's message happens to say.
+type localKind int
+
+const (
+	notLocal localKind = iota
+
+	// localRejected is for errors described by their own message, like a
+	// failed hook or a broken limit.
+	localRejected
+
+	localSuppressed
+	localDuplicate
+	localForgotten
+	localCancelled
+	localDeadline
+	localSkipped
+	localExpired
+	localOverflow
+)
+
+var localKinds = map[localKind]struct {
+	errStr      string
+	code        string
+	disposition Disposition
+}{
+	localRejected:   {"", CodeUnknown, FailedPermanent},
+	localSuppressed: {ErrSuppressed, CodeSuppressed, Suppressed},
+	localDuplicate:  {ErrDuplicate, CodeDuplicate, Suppressed},
+	localForgotten:  {ErrForgotten, CodeForgotten, Suppressed},
+	localCancelled:  {ErrCancelled, CodeCancelled, Cancelled},
+	localDeadline:   {ErrDeadline, CodeDeadline, DroppedDeadline},
+	localSkipped:    {ErrSkipped, CodeSkipped, Skipped},
+	localExpired:    {ErrExpired, CodeExpired, DroppedExpired},
+	localOverflow:   {ErrOverflow, CodeOverflow, DroppedOverflow},
+}
+
+// localError is the Error n fails with for kind, which must have its own
+// error string.
+func localError(n Notification, kind localKind) Error {
+	return Error{Identifier: n.Identifier, ErrStr: localKinds[kind].errStr, kind: kind}
+}
+
+const errorFrameLength = 1 + 1 + 4
+
+// FrameError reports a frame read from APNS that couldn't be parsed, for
+// example because it was truncated by a middlebox.
+type FrameError struct {
+	Frame  string // "error" or "feedback"
+	Len    int
+	Reason string
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("malformed %s frame (%d bytes): %s", e.Frame, e.Len, e.Reason)
 }
 
+// NewError parses an error frame. Malformed frames yield an Error with
+// ErrUnknown; use ParseError to tell them apart.
 func NewError(p []byte) Error {
-	if len(p) != 1+1+4 {
-		return Error{ErrStr: ErrUnknown}
+	e, _ := ParseError(p)
+	return e
+}
+
+// ParseError parses an error frame, returning a *FrameError alongside an
+// ErrUnknown Error if p isn't a well-formed frame.
+func ParseError(p []byte) (Error, error) {
+	if len(p) != errorFrameLength {
+		return Error{ErrStr: ErrUnknown}, &FrameError{Frame: "error", Len: len(p), Reason: fmt.Sprintf("want %d bytes", errorFrameLength)}
 	}
 
 	r := bytes.NewBuffer(p)
@@ -57,9 +213,47 @@ func NewError(p []byte) Error {
 		e.ErrStr = ErrUnknown
 	}
 
-	return e
+	return e, nil
 }
 
 func (e *Error) Error() string {
 	return e.ErrStr
 }
+
+// Unwrap returns Err.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Code returns a stable code for the error, such as CodeInvalidToken, for
+// tooling to act on. Errors it doesn't recognize, including local failures
+// described by their own message, are CodeUnknown.
+func (e Error) Code() string {
+	if e.kind != notLocal {
+		return localKinds[e.kind].code
+	}
+	if code, ok := codeMapping[e.ErrStr]; ok {
+		return code
+	}
+	return CodeUnknown
+}
+
+// Description returns a human-readable explanation of the error from
+// ErrorDescriptions.
+func (e Error) Description() string {
+	if d, ok := ErrorDescriptions[e.Code()]; ok {
+		return d
+	}
+	return ErrorDescriptions[CodeUnknown]
+}
+
+// DocURL returns where the error is documented: Apple's table of error
+// codes for errors APNS reported, or this package's documentation for
+// notifications the client rejected itself.
+func (e Error) DocURL() string {
+	switch e.Code() {
+	case CodeSuppressed, CodeDuplicate, CodeForgotten, CodeCancelled, CodeDeadline, CodeSkipped, CodeExpired, CodeOverflow:
+		return localErrorDocURL
+	}
+	return apnsErrorDocURL
+}
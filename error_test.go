@@ -91,10 +91,50 @@ var _ = Describe("Error", func() {
 		})
 	})
 
+	Describe(".ParseError", func() {
+		Context("truncated frame", func() {
+			It("should return a FrameError", func() {
+				e, err := apns.ParseError([]byte{8, 8, 0})
+				Expect(err).To(BeAssignableToTypeOf(&apns.FrameError{}))
+				Expect(err.Error()).To(ContainSubstring("3 bytes"))
+				Expect(e.ErrStr).To(Equal(apns.ErrUnknown))
+			})
+		})
+
+		Context("well-formed frame", func() {
+			It("should not return an error", func() {
+				e, err := apns.ParseError([]byte{8, 8, 0, 0, 0, 9})
+				Expect(err).To(BeNil())
+				Expect(e.ErrStr).To(Equal(apns.ErrInvalidToken))
+			})
+		})
+	})
+
 	Describe("#Error", func() {
 		It("should have an error string", func() {
 			e := apns.Error{ErrStr: "this is an error string"}
 			Expect(e.Error()).To(Equal("this is an error string"))
 		})
 	})
+
+	Describe("#Code", func() {
+		It("should be stable for APNS errors", func() {
+			e := apns.NewError([]byte{8, 8, 0, 0, 0, 9})
+			Expect(e.Code()).To(Equal(apns.CodeInvalidToken))
+			Expect(e.Description()).To(ContainSubstring("not valid"))
+			Expect(e.DocURL()).To(ContainSubstring("developer.apple.com"))
+		})
+
+		It("should cover local failures", func() {
+			e := apns.Error{ErrStr: apns.ErrSuppressed}
+			Expect(e.Code()).To(Equal(apns.CodeSuppressed))
+			Expect(e.DocURL()).To(ContainSubstring("godoc.org"))
+		})
+
+		It("should be unknown for other messages", func() {
+			e := apns.Error{ErrStr: "payload size 5000 exceeds 4096"}
+			Expect(e.Code()).To(Equal(apns.CodeUnknown))
+			Expect(e.Description()).To(Equal(apns.ErrorDescriptions[apns.CodeUnknown]))
+		})
+	})
 })
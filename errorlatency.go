@@ -0,0 +1,120 @@
+package apns
+
+import (
+	"sync"
+	"time"
+)
+
+// errorLatencyBounds are the upper bounds of the ErrorLatency buckets.
+// Errors slower than the last one are counted in a final, unbounded
+// bucket.
+var errorLatencyBounds = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyBucket counts the errors that arrived within UpTo of the write,
+// and after the previous bucket's UpTo. The last bucket's UpTo is 0 and
+// counts everything slower.
+type LatencyBucket struct {
+	UpTo  time.Duration `json:"up_to"`
+	Count int64         `json:"count"`
+}
+
+// ErrorLatency is the distribution of how long APNS took to answer a
+// notification with an error frame, from writing it to reading the
+// error. Errors arriving after notifications have left the resend buffer
+// are lost, so BufferSize must cover the sends made during the slowest
+// of them; InFlightWindow should be at least as long.
+type ErrorLatency struct {
+	Count   int64           `json:"count"`
+	Min     time.Duration   `json:"min"`
+	Max     time.Duration   `json:"max"`
+	Mean    time.Duration   `json:"mean"`
+	Buckets []LatencyBucket `json:"buckets"`
+}
+
+// Quantile returns the upper bound of the bucket holding the q quantile,
+// such as 0.99, or Max if it's in the unbounded bucket.
+func (l ErrorLatency) Quantile(q float64) time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+
+	rank := int64(q * float64(l.Count))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for _, b := range l.Buckets {
+		seen += b.Count
+		if seen >= rank {
+			if b.UpTo == 0 || b.UpTo > l.Max {
+				return l.Max
+			}
+			return b.UpTo
+		}
+	}
+	return l.Max
+}
+
+// errorLatencies collects ErrorLatency.
+type errorLatencies struct {
+	mu     sync.Mutex
+	count  int64
+	total  time.Duration
+	min    time.Duration
+	max    time.Duration
+	counts [10]int64
+}
+
+func (e *errorLatencies) observe(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.count == 0 || d < e.min {
+		e.min = d
+	}
+	if d > e.max {
+		e.max = d
+	}
+	e.count++
+	e.total += d
+
+	i := 0
+	for i < len(errorLatencyBounds) && d > errorLatencyBounds[i] {
+		i++
+	}
+	e.counts[i]++
+}
+
+func (e *errorLatencies) snapshot() ErrorLatency {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	l := ErrorLatency{Count: e.count, Min: e.min, Max: e.max}
+	if e.count > 0 {
+		l.Mean = e.total / time.Duration(e.count)
+	}
+	for i, n := range e.counts {
+		b := LatencyBucket{Count: n}
+		if i < len(errorLatencyBounds) {
+			b.UpTo = errorLatencyBounds[i]
+		}
+		l.Buckets = append(l.Buckets, b)
+	}
+	return l
+}
+
+// ErrorLatency returns how long APNS has taken to send error frames for
+// the client's notifications.
+func (c *Client) ErrorLatency() ErrorLatency {
+	return c.errLatency.snapshot()
+}
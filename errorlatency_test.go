@@ -0,0 +1,76 @@
+package apns_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("ErrorLatency", func() {
+	Describe("Client#ErrorLatency", func() {
+		It("should time error frames from the write", func(d Done) {
+			n := apns.NewNotification()
+			n.Identifier = 9
+			n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+			nb, _ := n.ToBinary()
+
+			errPayload := bytes.NewBuffer([]byte{})
+			binary.Write(errPayload, binary.BigEndian, uint8(8))
+			binary.Write(errPayload, binary.BigEndian, uint8(8))
+			binary.Write(errPayload, binary.BigEndian, uint32(9))
+
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+					serverAction{action: readAction, data: make([]byte, len(nb)), cb: func(a serverAction) {
+						time.Sleep(20 * time.Millisecond)
+					}},
+					serverAction{action: writeAction, data: errPayload.Bytes()},
+					serverAction{action: closeAction, data: []byte{}},
+				},
+			}
+
+			mockDone := make(chan interface{})
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				defer c.Close()
+
+				Expect(c.Send(n)).To(BeNil())
+				<-c.FailedNotifs
+
+				l := c.ErrorLatency()
+				Expect(l.Count).To(Equal(int64(1)))
+				Expect(l.Min).To(BeNumerically(">=", 20*time.Millisecond))
+				Expect(l.Min).To(Equal(l.Max))
+				Expect(l.Buckets[1]).To(Equal(apns.LatencyBucket{UpTo: 50 * time.Millisecond, Count: 1}))
+
+				close(mockDone)
+				close(d)
+			})
+		}, 5)
+	})
+
+	Describe("#Quantile", func() {
+		It("should return the bucket bound holding the quantile", func() {
+			l := apns.ErrorLatency{
+				Count: 100,
+				Max:   3 * time.Second,
+				Buckets: []apns.LatencyBucket{
+					{UpTo: 100 * time.Millisecond, Count: 90},
+					{UpTo: time.Second, Count: 8},
+					{Count: 2},
+				},
+			}
+
+			Expect(l.Quantile(0.5)).To(Equal(100 * time.Millisecond))
+			Expect(l.Quantile(0.98)).To(Equal(time.Second))
+			Expect(l.Quantile(0.99)).To(Equal(3 * time.Second))
+			Expect(apns.ErrorLatency{}.Quantile(0.99)).To(Equal(time.Duration(0)))
+		})
+	})
+})
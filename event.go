@@ -0,0 +1,63 @@
+package apns
+
+import "time"
+
+// EventType identifies something noteworthy that happened inside a Client.
+type EventType string
+
+const (
+	// EventBufferUnderrun means APNS reported an error for a notification
+	// that wasn't in the resend buffer. The client resends the buffered
+	// notifications written after it; any that left the buffer along with
+	// it are lost. A larger BufferSize avoids it.
+	EventBufferUnderrun EventType = "buffer-underrun"
+
+	// EventRequeue is emitted after a reconnect when buffered notifications
+	// are queued for redelivery. Count holds the batch size.
+	EventRequeue EventType = "requeue"
+
+	// EventCutover is emitted when the client switches to its secondary
+	// certificate because the primary kept failing handshakes.
+	EventCutover EventType = "cutover"
+
+	// EventLintWarning is emitted for notifications Notification.Lint
+	// finds problems with when the client isn't Strict.
+	EventLintWarning EventType = "lint-warning"
+
+	// EventClockSkewDetected is emitted when APNS rejects a provider token
+	// and its clock differs enough from the local one to explain it.
+	EventClockSkewDetected EventType = "clock-skew-detected"
+
+	// EventLockLost is emitted when the connection lock set with SetLock
+	// reports it was lost while held. The client disconnects until it
+	// holds the lock again.
+	EventLockLost EventType = "lock-lost"
+)
+
+// Event is emitted on Client.Events.
+type Event struct {
+	Type    EventType
+	Time    time.Time
+	ConnID  uint64
+	Message string
+	Count   int
+}
+
+// DefaultEventsBuffer is the capacity of Client.Events.
+const DefaultEventsBuffer = 100
+
+// emit publishes e without blocking. Events are dropped if the channel is
+// full.
+func (c *Client) emit(e Event) {
+	e.Time = time.Now()
+	e.ConnID = c.Conn.ID
+
+	c.logln("Event:", string(e.Type), e.Message)
+	c.recent.add(e)
+	c.publishEvent(e)
+
+	select {
+	case c.Events <- e:
+	default:
+	}
+}
@@ -0,0 +1,57 @@
+package apns
+
+// ExpirationPolicy says what APNS does with a notification it can't
+// deliver right away, such as when the device is offline.
+type ExpirationPolicy int
+
+const (
+	// ExpirationDefault leaves it to the protocol: the binary protocol asks
+	// for a single attempt, and Client2 sends no apns-expiration, leaving
+	// it to APNS's default storage policy. Notifications without an
+	// Expiration get it.
+	ExpirationDefault ExpirationPolicy = iota
+
+	// ExpirationImmediate delivers now or never: the expiration is sent as
+	// 0 explicitly, so APNS doesn't store the notification at all.
+	ExpirationImmediate
+
+	// ExpirationAt stores the notification until Expiration. Notifications
+	// with an Expiration get it.
+	ExpirationAt
+)
+
+var expirationPolicyNames = map[ExpirationPolicy]string{
+	ExpirationDefault:   "default",
+	ExpirationImmediate: "immediate",
+	ExpirationAt:        "at",
+}
+
+func (p ExpirationPolicy) String() string {
+	if s, ok := expirationPolicyNames[p]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// ExpireImmediately returns a copy of n that APNS delivers now or never;
+// see ExpirationImmediate.
+func (n Notification) ExpireImmediately() Notification {
+	n.ExpirationPolicy = ExpirationImmediate
+	n.Expiration = nil
+	return n
+}
+
+// expirationPolicy returns the policy n is sent with: ExpirationPolicy if
+// set, otherwise ExpirationAt if it has an Expiration. ExpirationAt
+// without an Expiration falls back to ExpirationDefault.
+func (n Notification) expirationPolicy() ExpirationPolicy {
+	hasExpiration := n.Expiration != nil && !n.Expiration.IsZero()
+
+	switch {
+	case n.ExpirationPolicy == ExpirationImmediate:
+		return ExpirationImmediate
+	case hasExpiration:
+		return ExpirationAt
+	}
+	return ExpirationDefault
+}
@@ -0,0 +1,128 @@
+package apns
+
+import "strings"
+
+// InGroup queues the notification in the named send group, such as a
+// campaign. The send queue takes turns between groups in proportion to
+// Client.GroupWeights, so a huge campaign can't starve transactional
+// notifications, which are in the unnamed group unless sent with InGroup.
+func InGroup(name string) SendOption {
+	return func(n *Notification) {
+		n.group = name
+	}
+}
+
+// fairQueue holds queued notifications by send group and hands them out in
+// weighted round-robin order: the group at the front of the rotation sends
+// up to its weight in notifications, then moves to the back.
+type fairQueue struct {
+	weight func(group string) int
+
+	groups map[string][]Notification
+	order  []string
+	credit int
+	len    int
+}
+
+func newFairQueue(weight func(group string) int) *fairQueue {
+	return &fairQueue{weight: weight, groups: map[string][]Notification{}}
+}
+
+func (q *fairQueue) push(n Notification) {
+	if len(q.groups[n.group]) == 0 {
+		q.order = append(q.order, n.group)
+		if len(q.order) == 1 {
+			q.credit = q.weight(n.group)
+		}
+	}
+	q.groups[n.group] = append(q.groups[n.group], n)
+	q.len++
+}
+
+// peek returns the notification pop would remove. The queue must not be
+// empty.
+func (q *fairQueue) peek() Notification {
+	return q.groups[q.order[0]][0]
+}
+
+func (q *fairQueue) pop() {
+	g := q.order[0]
+	q.groups[g] = q.groups[g][1:]
+	q.len--
+	q.credit--
+
+	switch {
+	case len(q.groups[g]) == 0:
+		delete(q.groups, g)
+		q.order = q.order[1:]
+	case q.credit <= 0:
+		q.order = append(q.order[1:], g)
+	default:
+		return
+	}
+	if len(q.order) > 0 {
+		q.credit = q.weight(q.order[0])
+	}
+}
+
+// purge removes and returns the notifications addressed to token.
+func (q *fairQueue) purge(token string) []Notification {
+	return q.remove(func(n Notification) bool { return strings.EqualFold(n.DeviceToken, token) })
+}
+
+// remove removes and returns the notifications matching match.
+func (q *fairQueue) remove(match func(Notification) bool) []Notification {
+	var purged []Notification
+
+	order := q.order[:0]
+	for i, g := range q.order {
+		kept := q.groups[g][:0]
+		for _, n := range q.groups[g] {
+			if match(n) {
+				purged = append(purged, n)
+				continue
+			}
+			kept = append(kept, n)
+		}
+
+		if len(kept) == 0 {
+			delete(q.groups, g)
+			if i == 0 {
+				q.credit = 0
+			}
+			continue
+		}
+		q.groups[g] = kept
+		order = append(order, g)
+	}
+	q.order = order
+	q.len -= len(purged)
+
+	if q.credit == 0 && len(q.order) > 0 {
+		q.credit = q.weight(q.order[0])
+	}
+
+	return purged
+}
+
+// drain removes and returns everything queued.
+func (q *fairQueue) drain() []Notification {
+	var all []Notification
+	for _, g := range q.order {
+		all = append(all, q.groups[g]...)
+	}
+
+	q.groups = map[string][]Notification{}
+	q.order = nil
+	q.len = 0
+
+	return all
+}
+
+// groupWeight returns the weight of group in GroupWeights, at least 1.
+func (c *Client) groupWeight(group string) int {
+	if w := c.GroupWeights[group]; w > 0 {
+		return w
+	}
+	return 1
+}
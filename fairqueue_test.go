@@ -0,0 +1,87 @@
+package apns_test
+
+import (
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Send groups", func() {
+	tok := "9999999999999999999999999999999999999999999999999999999999999999"
+
+	// sendOrder queues a backlog of five campaign notifications and one
+	// transactional one, and returns the order they were sent in.
+	sendOrder := func(weights map[string]int) []string {
+		var order []string
+
+		mockDone := make(chan interface{})
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+			c.GroupWeights = weights
+			defer c.Close()
+
+			var mu sync.Mutex
+			release := make(chan struct{})
+			c.OnBeforeSend(func(n *apns.Notification) error {
+				mu.Lock()
+				order = append(order, n.ID)
+				first := len(order) == 1
+				mu.Unlock()
+
+				// Hold the first one back so the rest pile up in the queue.
+				if first {
+					<-release
+				}
+				return nil
+			})
+
+			send := func(id string, opts ...apns.SendOption) {
+				n := apns.NewNotification()
+				n.ID = id
+				n.DeviceToken = tok
+				c.Send(n, opts...)
+			}
+
+			send("c1", apns.InGroup("campaign"))
+			Eventually(func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(order)
+			}).Should(Equal(1))
+
+			for i := 2; i <= 5; i++ {
+				send(fmt.Sprint("c", i), apns.InGroup("campaign"))
+			}
+			send("t1")
+			close(release)
+
+			Eventually(func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(order)
+			}).Should(Equal(6))
+
+			close(mockDone)
+		})
+
+		return order
+	}
+
+	It("should take turns between groups", func() {
+		Expect(sendOrder(nil)).To(Equal([]string{"c1", "c2", "t1", "c3", "c4", "c5"}))
+	})
+
+	It("should give groups turns in proportion to their weights", func() {
+		Expect(sendOrder(map[string]int{"campaign": 2})).To(Equal([]string{"c1", "c2", "c3", "t1", "c4", "c5"}))
+	})
+})
@@ -1,10 +1,11 @@
 package apns
 
 import (
-	"bytes"
 	"crypto/tls"
 	"encoding/binary"
 	"encoding/hex"
+	"fmt"
+	"io"
 	"time"
 )
 
@@ -18,23 +19,29 @@ type FeedbackTuple struct {
 	DeviceToken string
 }
 
-func feedbackTupleFromBytes(b []byte) FeedbackTuple {
-	r := bytes.NewReader(b)
+const feedbackHeaderLength = 4 + 2
 
-	var ts uint32
-	binary.Read(r, binary.BigEndian, &ts)
+// ParseFeedbackTuple parses one feedback tuple: a big-endian timestamp,
+// token length and the token itself. It returns a *FrameError if b is
+// truncated or its token length doesn't match.
+func ParseFeedbackTuple(b []byte) (FeedbackTuple, error) {
+	if len(b) < feedbackHeaderLength {
+		return FeedbackTuple{}, &FrameError{Frame: "feedback", Len: len(b), Reason: "truncated header"}
+	}
 
-	var tokLen uint16
-	binary.Read(r, binary.BigEndian, &tokLen)
+	ts := binary.BigEndian.Uint32(b[0:4])
+	tokLen := binary.BigEndian.Uint16(b[4:6])
+	tok := b[feedbackHeaderLength:]
 
-	tok := make([]byte, tokLen)
-	binary.Read(r, binary.BigEndian, &tok)
+	if len(tok) != int(tokLen) {
+		return FeedbackTuple{}, &FrameError{Frame: "feedback", Len: len(b), Reason: fmt.Sprintf("token length %d, have %d bytes", tokLen, len(tok))}
+	}
 
 	return FeedbackTuple{
 		Timestamp:   time.Unix(int64(ts), 0),
 		TokenLength: tokLen,
 		DeviceToken: hex.EncodeToString(tok),
-	}
+	}, nil
 }
 
 func NewFeedbackWithCert(gw string, cert tls.Certificate) Feedback {
@@ -78,16 +85,26 @@ func (f Feedback) receive(fc chan FeedbackTuple) {
 	defer f.Conn.Close()
 
 	for {
-		b := make([]byte, 38)
-
 		f.Conn.NetConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
 
-		_, err := f.Conn.Read(b)
+		b := make([]byte, feedbackHeaderLength)
+		if _, err := io.ReadFull(f.Conn, b); err != nil {
+			close(fc)
+			return
+		}
+
+		tok := make([]byte, binary.BigEndian.Uint16(b[4:6]))
+		if _, err := io.ReadFull(f.Conn, tok); err != nil {
+			close(fc)
+			return
+		}
+
+		ft, err := ParseFeedbackTuple(append(b, tok...))
 		if err != nil {
 			close(fc)
 			return
 		}
 
-		fc <- feedbackTupleFromBytes(b)
+		fc <- ft
 	}
 }
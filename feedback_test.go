@@ -67,6 +67,22 @@ var _ = Describe("Feedback", func() {
 		})
 	})
 
+	Describe(".ParseFeedbackTuple", func() {
+		Context("token length longer than the tuple", func() {
+			It("should return a FrameError", func() {
+				_, err := apns.ParseFeedbackTuple([]byte{0, 0, 0, 1, 0, 32, 0xab})
+				Expect(err).To(BeAssignableToTypeOf(&apns.FrameError{}))
+			})
+		})
+
+		Context("truncated header", func() {
+			It("should return a FrameError", func() {
+				_, err := apns.ParseFeedbackTuple([]byte{0, 0})
+				Expect(err).To(BeAssignableToTypeOf(&apns.FrameError{}))
+			})
+		})
+	})
+
 	Describe("#Receive", func() {
 		Context("could not connect", func() {
 			It("should not receive anything", func() {
@@ -0,0 +1,23 @@
+package apns
+
+// FileProviderRootContainer is the container identifier of a File
+// Provider domain's root, NSFileProviderRootContainerItemIdentifier.
+const FileProviderRootContainer = "NSFileProviderRootContainerItemIdentifier"
+
+// NewFileProviderNotification returns a push telling the File Provider
+// extension of the app with the given bundle ID that items in the
+// container with the given identifier changed, so it enumerates them
+// again. token is the push token the extension registered, and the topic
+// gets the .pushkit.fileprovider suffix. Set domain to the domain
+// identifier if the extension has several.
+func NewFileProviderNotification(token, bundleID, containerID, domain string) Notification {
+	n := NewNotification()
+	n.DeviceToken = token
+	n.PushType = PushTypeFileProvider
+	n.Topic = bundleID + TopicSuffix(PushTypeFileProvider)
+	n.Payload.SetCustomValue("container-identifier", containerID)
+	if domain != "" {
+		n.Payload.SetCustomValue("domain", domain)
+	}
+	return n
+}
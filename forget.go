@@ -0,0 +1,132 @@
+package apns
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+)
+
+// ForgetReport describes what Client.Forget purged for a device token.
+type ForgetReport struct {
+	Token string
+
+	// Queued is the number of notifications removed from the send queue.
+	Queued int
+
+	// Buffered is the number of notifications removed from the resend
+	// buffer and the requeue backlog.
+	Buffered int
+
+	// Suppressed is true if the token was removed from the client's
+	// suppression list.
+	Suppressed bool
+
+	// Correlations is the number of send records removed from
+	// Client.Correlations.
+	Correlations int
+
+	// Recovered is the number of records removed from Client.RecoveryFile.
+	Recovered int
+}
+
+type forgetRequest struct {
+	token string
+	done  chan int
+}
+
+// purge removes every buffered or requeued notification addressed to r's
+// token and returns the (possibly advanced) cursor and the remaining
+// requeued notifications.
+func (c *Client) purge(r forgetRequest, sent *buffer, cursor *list.Element, retry []Notification) (*list.Element, []Notification) {
+	purged := 0
+
+	for e := sent.Front(); e != nil; {
+		next := e.Next()
+
+		if n, ok := e.Value.(Notification); ok && strings.EqualFold(n.DeviceToken, r.token) {
+			if e == cursor {
+				cursor = next
+			}
+			sent.Remove(e)
+			c.report(forgottenResult(n))
+			purged++
+		}
+
+		e = next
+	}
+
+	kept := retry[:0]
+	for _, n := range retry {
+		if strings.EqualFold(n.DeviceToken, r.token) {
+			c.report(forgottenResult(n))
+			purged++
+			continue
+		}
+		kept = append(kept, n)
+	}
+
+	r.done <- purged
+	return cursor, kept
+}
+
+// Forget removes token from the client's state so privacy deletion
+// requests can be honored: the send queue, the resend buffer, the
+// duplicate window, the suppression list, Correlations and RecoveryFile.
+// Correlations must implement CorrelationForgetter. It doesn't wait for a
+// connection. After Close it returns ErrClosed, as there is nothing left
+// to purge. Audit logs written from results are the application's; use
+// ForgetAuditLog on them.
+func (c *Client) Forget(token string) (ForgetReport, error) {
+	r := ForgetReport{Token: token}
+
+	req := forgetRequest{token: token, done: make(chan int, 1)}
+	select {
+	case c.queuePurges <- req:
+		r.Queued = <-req.done
+	case <-c.closed:
+		return r, ErrClosed
+	}
+
+	req = forgetRequest{token: token, done: make(chan int, 1)}
+	select {
+	case c.forgets <- req:
+		r.Buffered = <-req.done
+	case <-c.closed:
+		return r, ErrClosed
+	}
+
+	if c.Suppressions != nil {
+		suppressed, err := c.Suppressions.Store.Contains(tokenKey(token))
+		if err != nil {
+			return r, err
+		}
+		if suppressed {
+			if err := c.Suppressions.UnsuppressToken(token); err != nil {
+				return r, err
+			}
+			r.Suppressed = true
+		}
+	}
+
+	if c.Correlations != nil {
+		f, ok := c.Correlations.(CorrelationForgetter)
+		if !ok {
+			return r, fmt.Errorf("apns: %T can't forget device tokens; it needs a ForgetToken method", c.Correlations)
+		}
+		n, err := f.ForgetToken(token)
+		if err != nil {
+			return r, err
+		}
+		r.Correlations = n
+	}
+
+	if c.RecoveryFile != "" {
+		n, err := ForgetAuditLog(c.RecoveryFile, token)
+		if err != nil {
+			return r, err
+		}
+		r.Recovered = n
+	}
+
+	return r, nil
+}
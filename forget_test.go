@@ -0,0 +1,112 @@
+package apns_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Forget", func() {
+	tok := "9999999999999999999999999999999999999999999999999999999999999999"
+
+	as := [][]serverAction{
+		[]serverAction{
+			serverAction{action: readAction, data: []byte{}},
+		},
+	}
+
+	It("should purge the resend buffer and suppression list", func(d Done) {
+		mockDone := make(chan interface{})
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+			c.Suppressions = apns.NewSuppressionList(nil)
+
+			n := apns.NewNotification()
+			n.DeviceToken = tok
+			c.Send(n)
+
+			c.Suppressions.SuppressToken(tok)
+
+			r, err := c.Forget(tok)
+			Expect(err).To(BeNil())
+			Expect(r.Queued + r.Buffered).To(Equal(1))
+			Expect(r.Suppressed).To(BeTrue())
+
+			r, _ = c.Forget(tok)
+			Expect(r.Queued + r.Buffered).To(Equal(0))
+			Expect(r.Suppressed).To(BeFalse())
+
+			close(mockDone)
+			close(d)
+		})
+	})
+
+	It("should not wait for a connection", func() {
+		// The client never connects.
+		c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+		defer c.Close()
+		sub := c.Subscribe(1, apns.DropNewest)
+
+		n := apns.NewNotification()
+		n.DeviceToken = tok
+		c.Send(n)
+
+		done := make(chan apns.ForgetReport, 1)
+		go func() {
+			r, _ := c.Forget(tok)
+			done <- r
+		}()
+
+		var r apns.ForgetReport
+		Eventually(done, time.Second).Should(Receive(&r))
+		Expect(r.Queued + r.Buffered).To(Equal(1))
+
+		var res apns.Result
+		Eventually(sub.Results).Should(Receive(&res))
+		Expect(res.Disposition).To(Equal(apns.Suppressed))
+	})
+
+	It("should purge correlations and the recovery file", func() {
+		dir, _ := ioutil.TempDir("", "apns-forget")
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "recovery.jsonl")
+
+		f, _ := os.Create(path)
+		l := apns.NewAuditLog(f)
+		for _, t := range []string{tok, "11", tok} {
+			n := apns.NewNotification()
+			n.DeviceToken = t
+			l.Write(apns.Result{Notification: n, Err: apns.ErrClosed})
+		}
+		f.Close()
+
+		c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+		defer c.Close()
+		c.RecoveryFile = path
+		store := apns.NewMemoryCorrelationStore(10)
+		store.Put("1", apns.SendRecord{DeviceToken: tok})
+		store.Put("2", apns.SendRecord{DeviceToken: "11"})
+		c.Correlations = store
+
+		r, err := c.Forget(tok)
+		Expect(err).To(BeNil())
+		Expect(r.Correlations).To(Equal(1))
+		Expect(r.Recovered).To(Equal(2))
+
+		_, ok, _ := store.Get("1")
+		Expect(ok).To(BeFalse())
+		_, ok, _ = store.Get("2")
+		Expect(ok).To(BeTrue())
+
+		ns, err := apns.LoadRecovery(path)
+		Expect(err).To(BeNil())
+		Expect(ns).To(HaveLen(1))
+		Expect(ns[0].DeviceToken).To(Equal("11"))
+	})
+})
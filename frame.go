@@ -0,0 +1,83 @@
+package apns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// frameEncoder builds binary protocol frames into a buffer it reuses, so
+// the run loop doesn't allocate a frame per notification. Lengths are
+// filled in once known.
+type frameEncoder struct {
+	buf bytes.Buffer
+}
+
+// encode returns the frame for n. It is only valid until the next call.
+func (e *frameEncoder) encode(n Notification) ([]byte, error) {
+	e.buf.Reset()
+
+	if hex.DecodedLen(len(n.DeviceToken)) != deviceTokenItemLength {
+		if _, err := hex.DecodeString(n.DeviceToken); err != nil {
+			return nil, fmt.Errorf("convert token to hex error: %s", err)
+		}
+		return nil, fmt.Errorf("device token is %d bytes, want %d", hex.DecodedLen(len(n.DeviceToken)), deviceTokenItemLength)
+	}
+
+	// Command and frame length, filled in at the end.
+	e.buf.Write([]byte{commandID, 0, 0, 0, 0})
+
+	// Token
+	e.item(deviceTokenItemID, deviceTokenItemLength)
+	var tok [deviceTokenItemLength]byte
+	if _, err := hex.Decode(tok[:], []byte(n.DeviceToken)); err != nil {
+		return nil, fmt.Errorf("convert token to hex error: %s", err)
+	}
+	e.buf.Write(tok[:])
+
+	// Payload, with its length filled in once encoded.
+	e.item(payloadItemID, 0)
+	start := e.buf.Len()
+	payload := n.payload
+	if payload == nil {
+		var err error
+		if payload, err = json.Marshal(n.Payload); err != nil {
+			return nil, err
+		}
+	}
+	e.buf.Write(payload)
+	size := e.buf.Len() - start
+	if size > math.MaxUint16 {
+		return nil, fmt.Errorf("payload is %d bytes, too large for a frame item", size)
+	}
+
+	// Identifier
+	e.item(notificationIdentifierItemID, notificationIdentifierItemLength)
+	e.uint32(n.Identifier)
+
+	// Expiry
+	e.item(expirationDateItemID, expirationDateItemLength)
+	e.uint32(uint32(n.expiry()))
+
+	// Priority
+	e.item(priorityItemID, priorityItemLength)
+	e.buf.WriteByte(uint8(n.EffectivePriority()))
+
+	b := e.buf.Bytes()
+	binary.BigEndian.PutUint16(b[start-2:], uint16(size))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(b)-5))
+	return b, nil
+}
+
+func (e *frameEncoder) item(id uint8, length uint16) {
+	e.buf.Write([]byte{id, byte(length >> 8), byte(length)})
+}
+
+func (e *frameEncoder) uint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.buf.Write(b[:])
+}
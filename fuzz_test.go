@@ -0,0 +1,61 @@
+//go:build go1.18
+
+package apns_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/timehop/apns"
+)
+
+func FuzzParseError(f *testing.F) {
+	f.Add([]byte{8, 8, 0, 0, 0, 9})
+	f.Add([]byte{8, 255, 255, 255, 255, 255})
+	f.Add([]byte{8})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, p []byte) {
+		e, err := apns.ParseError(p)
+		if err != nil {
+			if _, ok := err.(*apns.FrameError); !ok {
+				t.Fatalf("got %T, want *apns.FrameError", err)
+			}
+			if e.ErrStr != apns.ErrUnknown {
+				t.Fatalf("malformed frame parsed as %q", e.ErrStr)
+			}
+			return
+		}
+
+		if len(p) != 6 {
+			t.Fatalf("accepted a %d byte frame", len(p))
+		}
+		if e.Command != p[0] || e.Status != p[1] || e.ErrStr == "" {
+			t.Fatalf("misread frame %x as %+v", p, e)
+		}
+	})
+}
+
+func FuzzParseFeedbackTuple(f *testing.F) {
+	f.Add([]byte{0x53, 0xb0, 0xe8, 0xb1, 0, 2, 0xab, 0xcd})
+	f.Add([]byte{0x53, 0xb0, 0xe8, 0xb1, 0, 32})
+	f.Add([]byte{0x53, 0xb0})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		ft, err := apns.ParseFeedbackTuple(b)
+		if err != nil {
+			if _, ok := err.(*apns.FrameError); !ok {
+				t.Fatalf("got %T, want *apns.FrameError", err)
+			}
+			return
+		}
+
+		tok, err := hex.DecodeString(ft.DeviceToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tok) != int(ft.TokenLength) || len(b) != 6+len(tok) {
+			t.Fatalf("misread tuple %x as %+v", b, ft)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package apns
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// SendGroup is a handle on a send group, such as a campaign, that can be
+// cancelled while it's still being sent. Notifications sent through it are
+// queued in the group name, like InGroup; every SendGroup is cancelled on
+// its own, even if another shares its name.
+type SendGroup struct {
+	Name string
+
+	// Deadline, if set, is the SendBy deadline of the group's
+	// notifications. Set it before sending.
+	Deadline time.Time
+
+	c         *Client
+	cancelled int32
+}
+
+type cancelRequest struct {
+	group *SendGroup
+	done  chan int
+}
+
+// Group returns a new SendGroup named name.
+func (c *Client) Group(name string) *SendGroup {
+	return &SendGroup{Name: name, c: c}
+}
+
+// Send queues n in the group; see Client.Send. Once the group is
+// cancelled, n is reported as Cancelled instead of being sent.
+func (g *SendGroup) Send(n Notification, opts ...SendOption) error {
+	n.sendGroup = g
+	opts = append(opts, InGroup(g.Name))
+	if !g.Deadline.IsZero() {
+		opts = append(opts, SendBy(g.Deadline))
+	}
+	return g.c.Send(n, opts...)
+}
+
+// GroupContext returns a new SendGroup named name whose Deadline is ctx's,
+// and which is cancelled when ctx is done before then, so a campaign job
+// can hand its own context down.
+func (c *Client) GroupContext(ctx context.Context, name string) *SendGroup {
+	g := c.Group(name)
+	if d, ok := ctx.Deadline(); ok {
+		g.Deadline = d
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.Canceled {
+				g.Cancel(context.Background())
+			}
+		case <-c.closed:
+		}
+	}()
+	return g
+}
+
+// Cancelled reports whether Cancel has been called.
+func (g *SendGroup) Cancelled() bool {
+	return g != nil && atomic.LoadInt32(&g.cancelled) == 1
+}
+
+// Cancel stops sending the group's notifications: those still queued are
+// removed right away, and those waiting to be resent or sent later are
+// dropped when they come up. All of them are reported as Cancelled to
+// OnResult callbacks. Notifications already written to APNS can't be
+// recalled.
+//
+// Cancel returns how many queued notifications it removed. Sending stops
+// as soon as Cancel is called, even if ctx is done before they are
+// removed.
+func (g *SendGroup) Cancel(ctx context.Context) (int, error) {
+	atomic.StoreInt32(&g.cancelled, 1)
+
+	req := cancelRequest{group: g, done: make(chan int, 1)}
+	select {
+	case g.c.cancels <- req:
+		return <-req.done, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-g.c.closed:
+		return 0, ErrClosed
+	}
+}
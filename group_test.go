@@ -0,0 +1,208 @@
+package apns_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("SendGroup", func() {
+	tok := "9999999999999999999999999999999999999999999999999999999999999999"
+
+	It("should stop sending once cancelled", func(d Done) {
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		mockDone := make(chan interface{})
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+
+			var mu sync.Mutex
+			var sent []string
+			release := make(chan struct{})
+			c.OnBeforeSend(func(n *apns.Notification) error {
+				mu.Lock()
+				sent = append(sent, n.ID)
+				first := len(sent) == 1
+				mu.Unlock()
+
+				// Hold the first one back so the rest stay queued.
+				if first {
+					<-release
+				}
+				return nil
+			})
+
+			results := make(chan apns.Result, 10)
+			g := c.Group("campaign")
+			for i := 1; i <= 4; i++ {
+				n := apns.NewNotification()
+				n.ID = fmt.Sprint("c", i)
+				n.DeviceToken = tok
+				g.Send(n, apns.OnResult(func(r apns.Result) { results <- r }))
+			}
+			Eventually(func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(sent)
+			}).Should(Equal(1))
+
+			queued, err := g.Cancel(context.Background())
+			Expect(err).To(BeNil())
+			Expect(g.Cancelled()).To(BeTrue())
+			close(release)
+
+			var cancelled []string
+			for len(cancelled) < 3 {
+				r := <-results
+				if r.Notification.ID == "c1" {
+					continue
+				}
+				Expect(r.Disposition).To(Equal(apns.Cancelled))
+				cancelled = append(cancelled, r.Notification.ID)
+			}
+			Expect(queued).To(Equal(3))
+			Expect(cancelled).To(ConsistOf("c2", "c3", "c4"))
+
+			n := apns.NewNotification()
+			n.DeviceToken = tok
+			n.ID = "c5"
+			g.Send(n, apns.OnResult(func(r apns.Result) { results <- r }))
+			r := <-results
+			for r.Notification.ID == "c1" {
+				r = <-results
+			}
+			Expect(r.Notification.ID).To(Equal("c5"))
+			Expect(r.Disposition).To(Equal(apns.Cancelled))
+
+			mu.Lock()
+			Expect(sent).To(Equal([]string{"c1"}))
+			mu.Unlock()
+
+			close(mockDone)
+			close(d)
+		})
+	})
+
+	It("should publish cancelled notifications to subscribers", func() {
+		// The client never connects, so everything stays queued.
+		c := apns.NewClientWithCert("127.0.0.1:1", tls.Certificate{})
+		defer c.Close()
+		sub := c.Subscribe(10, apns.DropNewest)
+
+		g := c.Group("campaign")
+		for i := 1; i <= 2; i++ {
+			n := apns.NewNotification()
+			n.ID = fmt.Sprint("c", i)
+			n.DeviceToken = tok
+			g.Send(n)
+		}
+		g.Cancel(context.Background())
+
+		for i := 0; i < 2; i++ {
+			var r apns.Result
+			Eventually(sub.Results).Should(Receive(&r))
+			Expect(r.Disposition).To(Equal(apns.Cancelled))
+		}
+	})
+
+	Describe("deadlines", func() {
+		It("should drop notifications past their SendBy deadline", func() {
+			c := apns.NewClientWithCert("127.0.0.1:1", tls.Certificate{})
+			defer c.Close()
+
+			sub := c.Subscribe(1, apns.DropNewest)
+
+			results := make(chan apns.Result, 1)
+			n := apns.NewNotification()
+			n.DeviceToken = tok
+			c.Send(n, apns.SendBy(time.Now().Add(-time.Second)), apns.OnResult(func(r apns.Result) { results <- r }))
+
+			r := <-results
+			Expect(r.Disposition).To(Equal(apns.DroppedDeadline))
+			Expect(r.Disposition.String()).To(Equal("dropped-deadline"))
+
+			// Subscribers, and so audit logs and exporters, see it too.
+			Eventually(sub.Results).Should(Receive(&r))
+			Expect(r.Disposition).To(Equal(apns.DroppedDeadline))
+		})
+
+		It("should drop queued notifications once the group's deadline passes", func(d Done) {
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+				},
+			}
+
+			mockDone := make(chan interface{})
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+
+				var mu sync.Mutex
+				var sent []string
+				held := make(chan struct{})
+				release := make(chan struct{})
+				c.OnBeforeSend(func(n *apns.Notification) error {
+					mu.Lock()
+					sent = append(sent, n.ID)
+					mu.Unlock()
+
+					if n.ID == "hold" {
+						close(held)
+						<-release
+					}
+					return nil
+				})
+
+				n := apns.NewNotification()
+				n.ID = "hold"
+				n.DeviceToken = tok
+				c.Send(n)
+				<-held
+
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+				g := c.GroupContext(ctx, "reminders")
+				Expect(g.Deadline.IsZero()).To(BeFalse())
+
+				results := make(chan apns.Result, 1)
+				n.ID = "late"
+				g.Send(n, apns.OnResult(func(r apns.Result) { results <- r }))
+
+				time.Sleep(100 * time.Millisecond)
+				close(release)
+
+				Expect((<-results).Disposition).To(Equal(apns.DroppedDeadline))
+				mu.Lock()
+				Expect(sent).To(Equal([]string{"hold"}))
+				mu.Unlock()
+
+				close(mockDone)
+				close(d)
+			})
+		})
+
+		It("should cancel the group when its context is cancelled", func() {
+			c := apns.NewClientWithCert("127.0.0.1:1", tls.Certificate{})
+			defer c.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			g := c.GroupContext(ctx, "reminders")
+			Expect(g.Deadline.IsZero()).To(BeTrue())
+
+			cancel()
+			Eventually(g.Cancelled).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,90 @@
+package apns
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// ContentHash returns a SHA-256 hash of n's payload in canonical form (keys
+// sorted, insignificant whitespace removed), so identical content hashes
+// the same regardless of how or where it was built.
+func (n Notification) ContentHash() (string, error) {
+	if n.contentHash != "" {
+		return n.contentHash, nil
+	}
+
+	j, err := n.payloadBytes()
+	if err != nil {
+		return "", err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return "", err
+	}
+
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+type dedupeEntry struct {
+	key  string
+	seen time.Time
+}
+
+// dedupeWindow remembers which content was sent to which token recently.
+type dedupeWindow struct {
+	seen  map[string]time.Time
+	order []dedupeEntry
+}
+
+func dedupeKey(token, hash string) string {
+	return strings.ToLower(token) + ":" + hash
+}
+
+func (w *dedupeWindow) expire(now time.Time, window time.Duration) {
+	i := 0
+	for ; i < len(w.order) && now.Sub(w.order[i].seen) >= window; i++ {
+		if w.seen[w.order[i].key] == w.order[i].seen {
+			delete(w.seen, w.order[i].key)
+		}
+	}
+	w.order = w.order[i:]
+}
+
+// duplicate reports whether key was added within window of now.
+func (w *dedupeWindow) duplicate(key string, now time.Time, window time.Duration) bool {
+	w.expire(now, window)
+	_, ok := w.seen[key]
+	return ok
+}
+
+// forget drops every key for token.
+func (w *dedupeWindow) forget(token string) {
+	prefix := strings.ToLower(token) + ":"
+	for k := range w.seen {
+		if strings.HasPrefix(k, prefix) {
+			delete(w.seen, k)
+		}
+	}
+}
+
+func (w *dedupeWindow) add(key string, now time.Time) {
+	if w.seen == nil {
+		w.seen = map[string]time.Time{}
+	}
+	w.seen[key] = now
+	w.order = append(w.order, dedupeEntry{key, now})
+}
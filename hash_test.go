@@ -0,0 +1,80 @@
+package apns_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Content hashing", func() {
+	Describe("Notification#ContentHash", func() {
+		It("should ignore key order and whitespace", func() {
+			a := apns.Notification{}.WithPrecomputedPayload([]byte(`{"aps":{"alert":"hi","badge":1},"id":7}`))
+			b := apns.Notification{}.WithPrecomputedPayload([]byte(`{ "id": 7, "aps": { "badge": 1, "alert": "hi" } }`))
+
+			ha, err := a.ContentHash()
+			Expect(err).To(BeNil())
+			hb, _ := b.ContentHash()
+
+			Expect(ha).To(HaveLen(64))
+			Expect(ha).To(Equal(hb))
+		})
+
+		It("should match the built payload", func() {
+			n := apns.NewNotification()
+			n.Payload.APS.Alert.Body = "hi"
+			m := apns.Notification{}.WithPrecomputedPayload([]byte(`{"aps":{"alert":"hi"}}`))
+
+			hn, _ := n.ContentHash()
+			hm, _ := m.ContentHash()
+			Expect(hn).To(Equal(hm))
+		})
+
+		It("should differ for different content", func() {
+			a := apns.Notification{}.WithPrecomputedPayload([]byte(`{"aps":{"alert":"hi"}}`))
+			b := apns.Notification{}.WithPrecomputedPayload([]byte(`{"aps":{"alert":"bye"}}`))
+
+			ha, _ := a.ContentHash()
+			hb, _ := b.ContentHash()
+			Expect(ha).NotTo(Equal(hb))
+		})
+	})
+
+	Describe("Client#DedupeWindow", func() {
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		It("should drop repeated content for a token", func(d Done) {
+			mockDone := make(chan interface{})
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				c.DedupeWindow = time.Minute
+				sub := c.Subscribe(2, apns.DropNewest)
+
+				n := apns.NewNotification()
+				n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+				n.Payload.APS.Alert.Body = "hi"
+				c.Send(n)
+				c.Send(n)
+
+				delivered := <-sub.Results
+				Expect(delivered.Disposition).To(Equal(apns.Delivered))
+				Expect(delivered.ContentHash).NotTo(BeEmpty())
+
+				dup := <-sub.Results
+				Expect(dup.Disposition).To(Equal(apns.Suppressed))
+				Expect(dup.Err).To(MatchError(apns.ErrDuplicate))
+				Expect(dup.ContentHash).To(Equal(delivered.ContentHash))
+
+				close(mockDone)
+				close(d)
+			})
+		})
+	})
+})
@@ -0,0 +1,19 @@
+//go:build go1.24
+// +build go1.24
+
+package apns
+
+import (
+	"net/http"
+	"time"
+)
+
+// setPings configures HTTP/2 health-check pings on t.
+func setPings(t *http.Transport, interval, timeout time.Duration) error {
+	if t.HTTP2 == nil {
+		t.HTTP2 = &http.HTTP2Config{}
+	}
+	t.HTTP2.SendPingTimeout = interval
+	t.HTTP2.PingTimeout = timeout
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !go1.24
+// +build !go1.24
+
+package apns
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// setPings fails, as http.Transport can't be told to send HTTP/2 pings
+// before Go 1.24.
+func setPings(t *http.Transport, interval, timeout time.Duration) error {
+	return errors.New("apns: HTTP/2 health checks need Go 1.24")
+}
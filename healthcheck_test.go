@@ -0,0 +1,48 @@
+//go:build go1.24
+// +build go1.24
+
+package apns_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Client2 health checks", func() {
+	var s *mockHTTP2Server
+
+	BeforeEach(func() {
+		s = newMockHTTP2Server()
+	})
+
+	AfterEach(func() {
+		s.Close()
+	})
+
+	It("should replace connections that stop answering pings", func() {
+		p := newBlackholeProxy(s.Listener.Addr().String())
+		defer p.Close()
+
+		c := newTestClient2(s)
+		c.Host = "https://" + p.Addr().String()
+		Expect(c.SetHealthCheck(20*time.Millisecond, 50*time.Millisecond)).To(BeNil())
+
+		n := apns.NewNotification()
+		n.DeviceToken = "abcd"
+		_, err := c.SendSync(context.Background(), n)
+		Expect(err).To(BeNil())
+
+		p.blackhole()
+		time.Sleep(200 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err = c.SendSync(ctx, n)
+		Expect(err).To(BeNil())
+		Expect(s.requests[1].RemoteAddr).NotTo(Equal(s.requests[0].RemoteAddr))
+	})
+})
@@ -0,0 +1,105 @@
+package apns
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultHedgePushTypes are the push types Client2 hedges unless
+// HedgePushTypes says otherwise: calls, where a late push is a missed one.
+var DefaultHedgePushTypes = []PushType{PushTypeVoIP, PushTypePushToTalk}
+
+// hedges reports whether requests for n should be hedged.
+func (c *Client2) hedges(n Notification) bool {
+	if c.HedgeAfter <= 0 {
+		return false
+	}
+
+	types := c.HedgePushTypes
+	if types == nil {
+		types = DefaultHedgePushTypes
+	}
+	return hasPushType(types, n.PushType)
+}
+
+type hedgeOutcome struct {
+	r   Response
+	err error
+}
+
+// sendHedged sends the request through client and, if it hasn't been
+// answered after HedgeAfter or failed outright, a duplicate on a separate
+// hedge connection. The first answer wins and the other request is
+// cancelled.
+func (c *Client2) sendHedged(ctx context.Context, client *http.Client, path string, header http.Header, body []byte) (Response, error) {
+	if header.Get("apns-id") == "" {
+		id, err := newUUID()
+		if err != nil {
+			return Response{}, err
+		}
+		header.Set("apns-id", id)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outcomes := make(chan hedgeOutcome, 2)
+	go func() {
+		r, err := c.send(ctx, client, path, header, body)
+		outcomes <- hedgeOutcome{r, err}
+	}()
+
+	hedged := false
+	hedge := func() {
+		hedged = true
+		go func() {
+			r, err := c.send(ctx, c.dedicatedClient(&c.hedgeClient), path, header, body)
+			r.Hedged = true
+			outcomes <- hedgeOutcome{r, err}
+		}()
+	}
+
+	timer := time.NewTimer(c.HedgeAfter)
+	defer timer.Stop()
+
+	var first *hedgeOutcome
+	for pending := 1; pending > 0; {
+		select {
+		case o := <-outcomes:
+			pending--
+			if o.err == nil {
+				return o.r, nil
+			}
+			if first == nil {
+				first = &o
+			}
+			if !hedged {
+				hedge()
+				pending++
+			}
+		case <-timer.C:
+			if !hedged {
+				hedge()
+				pending++
+			}
+		}
+	}
+
+	return first.r, first.err
+}
+
+// newUUID returns a random (version 4) UUID, the format APNS expects for
+// apns-id.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,94 @@
+package apns_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Hedging", func() {
+	var (
+		s     *mockHTTP2Server
+		calls int32
+	)
+
+	BeforeEach(func() {
+		calls = 0
+		s = newMockHTTP2Server()
+		// Only the first request is slow.
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				select {
+				case <-time.After(500 * time.Millisecond):
+				case <-r.Context().Done():
+				}
+			}
+		}
+	})
+
+	AfterEach(func() {
+		s.Close()
+	})
+
+	voip := func() apns.Notification {
+		n := apns.NewNotification()
+		n.DeviceToken = "abcd"
+		n.PushType = apns.PushTypeVoIP
+		return n
+	}
+
+	It("should duplicate slow requests on a second connection", func() {
+		c := newTestClient2(s)
+		c.HedgeAfter = 20 * time.Millisecond
+
+		start := time.Now()
+		r, err := c.SendSync(context.Background(), voip())
+
+		Expect(err).To(BeNil())
+		Expect(r.OK()).To(BeTrue())
+		Expect(r.Hedged).To(BeTrue())
+		Expect(time.Since(start)).To(BeNumerically("<", 400*time.Millisecond))
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		Expect(s.requests).To(HaveLen(2))
+		Expect(s.requests[0].Header.Get("apns-id")).NotTo(BeEmpty())
+		Expect(s.requests[1].Header.Get("apns-id")).To(Equal(s.requests[0].Header.Get("apns-id")))
+		Expect(s.requests[1].RemoteAddr).NotTo(Equal(s.requests[0].RemoteAddr))
+	})
+
+	It("should not hedge fast requests", func() {
+		atomic.StoreInt32(&calls, 1)
+		c := newTestClient2(s)
+		c.HedgeAfter = 200 * time.Millisecond
+
+		r, err := c.SendSync(context.Background(), voip())
+
+		Expect(err).To(BeNil())
+		Expect(r.Hedged).To(BeFalse())
+		Consistently(func() int {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return len(s.requests)
+		}, 300*time.Millisecond).Should(Equal(1))
+	})
+
+	It("should only hedge the configured push types", func() {
+		c := newTestClient2(s)
+		c.HedgeAfter = 20 * time.Millisecond
+
+		n := voip()
+		n.PushType = apns.PushTypeBackground
+		n.Payload.APS.ContentAvailable = 1
+		r, err := c.SendSync(context.Background(), n)
+
+		Expect(err).To(BeNil())
+		Expect(r.Hedged).To(BeFalse())
+		Expect(s.requests).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,265 @@
+package apns
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultHTTP2ShimWorkers is the default number of requests an HTTP2Shim
+// has in flight.
+const DefaultHTTP2ShimWorkers = 8
+
+// reasonStatuses maps the HTTP/2 API's rejection reasons to the binary
+// protocol's error statuses, so they read the same on FailedNotifs.
+var reasonStatuses = map[string]uint8{
+	"MissingDeviceToken":     2,
+	"MissingTopic":           3,
+	"PayloadEmpty":           4,
+	"BadTopic":               6,
+	"TopicDisallowed":        6,
+	"PayloadTooLarge":        7,
+	"BadDeviceToken":         8,
+	"DeviceTokenNotForTopic": 8,
+	"Unregistered":           8,
+	"InternalServerError":    1,
+	"ServiceUnavailable":     10,
+	"Shutdown":               10,
+}
+
+// reasonError converts a rejection by the HTTP/2 API into the Error the
+// binary protocol would have reported. Reasons without an equivalent keep
+// their name as ErrStr.
+func reasonError(n Notification, r Response) Error {
+	e := Error{Command: 8, Identifier: n.Identifier, Status: 255, ErrStr: r.Reason}
+	if status, ok := reasonStatuses[r.Reason]; ok {
+		e.Status, e.ErrStr = status, errorMapping[status]
+	}
+	if e.ErrStr == "" {
+		e.ErrStr = http.StatusText(r.StatusCode)
+	}
+	return e
+}
+
+// HTTP2Shim exposes Client's Send and FailedNotifs over a Client2, so code
+// written against Client can move to the HTTP/2 provider API by changing
+// how the client is created:
+//
+//	c := apns.NewHTTP2Shim(apns.NewClient2(apns.ProductionHost, cert))
+//	go func() {
+//		for f := range c.FailedNotifs {
+//			log.Println(f.Notif.ID, f.Err.ErrStr)
+//		}
+//	}()
+//	c.Send(n)
+//
+// Rejections are reported on FailedNotifs with the binary protocol's
+// error for the reason, such as ErrInvalidToken for BadDeviceToken, and to
+// OnResult callbacks. Requests that fail outright are reported with the
+// error's message. Every result is also published to Subscriptions, so an
+// AuditLog can be fed from one as with Client.
+type HTTP2Shim struct {
+	Client2 *Client2
+
+	// FailedNotifs receives failures as Client.FailedNotifs does: they're
+	// dropped if nothing is receiving.
+	FailedNotifs chan NotificationResult
+
+	mu     sync.RWMutex
+	sent   int
+	failed int
+	subs   []*Subscription
+
+	queue     chan Notification
+	closeOnce sync.Once
+	closed    chan struct{}
+	done      sync.WaitGroup
+	cancel    context.CancelFunc
+}
+
+// NewHTTP2Shim starts an HTTP2Shim sending through c with
+// DefaultHTTP2ShimWorkers requests in flight and a queue of
+// DefaultQueueSize.
+func NewHTTP2Shim(c *Client2) *HTTP2Shim {
+	return NewHTTP2ShimWithWorkers(c, DefaultHTTP2ShimWorkers, DefaultQueueSize)
+}
+
+// NewHTTP2ShimWithWorkers starts an HTTP2Shim sending through c with the
+// given number of requests in flight and queue size.
+func NewHTTP2ShimWithWorkers(c *Client2, workers, queueSize int) *HTTP2Shim {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &HTTP2Shim{
+		Client2:      c,
+		FailedNotifs: make(chan NotificationResult),
+		queue:        make(chan Notification, queueSize),
+		closed:       make(chan struct{}),
+		cancel:       cancel,
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	s.done.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.work(ctx)
+	}
+	return s
+}
+
+// Send queues n for delivery. It blocks while the queue is full and
+// returns ErrClosed once the shim has been closed.
+func (s *HTTP2Shim) Send(n Notification, opts ...SendOption) error {
+	for _, opt := range opts {
+		opt(&n)
+	}
+
+	select {
+	case <-s.closed:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case s.queue <- n:
+		return nil
+	case <-s.closed:
+		return ErrClosed
+	}
+}
+
+// Subscribe returns a new Subscription to the shim's results, as
+// Client.Subscribe does. The shim publishes no events. After Close the
+// returned subscription's channels are already closed.
+func (s *HTTP2Shim) Subscribe(size int, policy DropPolicy) *Subscription {
+	sub := newSubscription(size, policy)
+	sub.unsubscribe = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.subs = sub.removeFrom(s.subs)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.closed:
+		sub.close()
+	default:
+		s.subs = append(s.subs, sub)
+	}
+
+	return sub
+}
+
+// Sent returns how many notifications APNS has accepted.
+func (s *HTTP2Shim) Sent() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sent
+}
+
+// Failed returns how many notifications were rejected or couldn't be
+// sent.
+func (s *HTTP2Shim) Failed() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.failed
+}
+
+// report publishes r to subscribers and passes it to its notification's
+// OnResult callback.
+func (s *HTTP2Shim) report(r Result) {
+	s.mu.RLock()
+	for _, sub := range s.subs {
+		sub.sendResult(r)
+	}
+	s.mu.RUnlock()
+
+	r.Notification.callback.fire(r)
+}
+
+// Len returns how many notifications are queued.
+func (s *HTTP2Shim) Len() int {
+	return len(s.queue)
+}
+
+// Close stops the shim, abandoning requests in flight. Notifications
+// still queued are reported as FailedRetryable with ErrClosed, and then
+// every Subscription is unsubscribed. It is safe to call more than once.
+func (s *HTTP2Shim) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.cancel()
+		s.done.Wait()
+
+		for len(s.queue) > 0 {
+			s.report(closedResult(<-s.queue))
+		}
+
+		s.mu.RLock()
+		subs := append([]*Subscription(nil), s.subs...)
+		s.mu.RUnlock()
+
+		for _, sub := range subs {
+			sub.Unsubscribe()
+		}
+	})
+	return nil
+}
+
+func (s *HTTP2Shim) work(ctx context.Context) {
+	defer s.done.Done()
+
+	for {
+		select {
+		case <-s.closed:
+			return
+		case n := <-s.queue:
+			if r, drop := unsendable(n, time.Now()); drop {
+				s.report(r)
+				continue
+			}
+			s.send(ctx, n)
+		}
+	}
+}
+
+func (s *HTTP2Shim) send(ctx context.Context, n Notification) {
+	r, err := s.Client2.SendSync(ctx, n)
+	if ctx.Err() != nil {
+		s.report(closedResult(n))
+		return
+	}
+
+	if err == nil && r.OK() {
+		s.mu.Lock()
+		s.sent++
+		s.mu.Unlock()
+
+		s.report(Result{Notification: n, Disposition: Delivered, Time: time.Now()})
+		return
+	}
+
+	e := Error{Identifier: n.Identifier}
+	if err != nil {
+		e.ErrStr = err.Error()
+	} else {
+		e = reasonError(n, r)
+	}
+
+	s.mu.Lock()
+	s.failed++
+	s.mu.Unlock()
+
+	f := NotificationResult{Notif: n, Err: e, RemoteAddr: s.Client2.Host}
+	s.report(f.Result())
+
+	select {
+	case s.FailedNotifs <- f:
+	default:
+	}
+}
@@ -0,0 +1,105 @@
+package apns_test
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("HTTP2Shim", func() {
+	tok := "9999999999999999999999999999999999999999999999999999999999999999"
+
+	It("should deliver through the Client2", func() {
+		s := newMockHTTP2Server()
+		defer s.Close()
+
+		c := apns.NewHTTP2Shim(newTestClient2(s))
+		defer c.Close()
+
+		results := make(chan apns.Result, 1)
+		n := apns.NewNotification()
+		n.DeviceToken = tok
+		Expect(c.Send(n, apns.OnResult(func(r apns.Result) { results <- r }))).To(BeNil())
+
+		Expect((<-results).Disposition).To(Equal(apns.Delivered))
+		s.mu.Lock()
+		Expect(s.requests).To(HaveLen(1))
+		Expect(s.requests[0].URL.Path).To(Equal("/3/device/" + tok))
+		s.mu.Unlock()
+	})
+
+	It("should report rejections on FailedNotifs as the binary protocol would", func() {
+		s := newMockHTTP2Server()
+		defer s.Close()
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+		}
+
+		c := apns.NewHTTP2Shim(newTestClient2(s))
+		defer c.Close()
+
+		results := make(chan apns.Result, 1)
+		n := apns.NewNotification()
+		n.DeviceToken = tok
+		n.Identifier = 42
+		c.Send(n, apns.OnResult(func(r apns.Result) { results <- r }))
+
+		f := <-c.FailedNotifs
+		Expect(f.Err.Status).To(Equal(uint8(8)))
+		Expect(f.Err.ErrStr).To(Equal(apns.ErrInvalidToken))
+		Expect(f.Err.Identifier).To(Equal(uint32(42)))
+		Expect((<-results).Disposition).To(Equal(apns.FailedPermanent))
+	})
+
+	It("should keep reasons without a binary equivalent", func() {
+		s := newMockHTTP2Server()
+		defer s.Close()
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"reason":"TooManyRequests"}`))
+		}
+
+		c := apns.NewHTTP2Shim(newTestClient2(s))
+		defer c.Close()
+
+		n := apns.NewNotification()
+		n.DeviceToken = tok
+		c.Send(n)
+
+		f := <-c.FailedNotifs
+		Expect(f.Err.Status).To(Equal(uint8(255)))
+		Expect(f.Err.ErrStr).To(Equal("TooManyRequests"))
+	})
+
+	It("should publish results to subscribers and count them", func() {
+		s := newMockHTTP2Server()
+		defer s.Close()
+
+		c := apns.NewHTTP2Shim(newTestClient2(s))
+		sub := c.Subscribe(1, apns.DropNewest)
+
+		n := apns.NewNotification()
+		n.DeviceToken = tok
+		c.Send(n)
+
+		Expect((<-sub.Results).Disposition).To(Equal(apns.Delivered))
+		Expect(c.Sent()).To(Equal(1))
+		Expect(c.Failed()).To(BeZero())
+
+		c.Close()
+		Eventually(sub.Results).Should(BeClosed())
+	})
+
+	It("should refuse to send once closed", func() {
+		s := newMockHTTP2Server()
+		defer s.Close()
+
+		c := apns.NewHTTP2Shim(newTestClient2(s))
+		Expect(c.Close()).To(BeNil())
+		Expect(c.Close()).To(BeNil())
+		Expect(c.Send(apns.NewNotification())).To(Equal(apns.ErrClosed))
+	})
+})
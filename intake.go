@@ -0,0 +1,61 @@
+package apns
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultQueueSize is the default Client.QueueSize.
+const DefaultQueueSize = 1000
+
+// intakeLoop accepts notifications from Send into a bounded queue and hands
+// them to the connection loop as it becomes ready. Because it never waits on
+// the connection, producers only block once the queue is full, not while
+// the client is reconnecting. Send groups take turns; see InGroup.
+func (c *Client) intakeLoop(queueSize int) {
+	queue := newFairQueue(c.groupWeight)
+	defer close(c.intakeDone)
+
+	for {
+		atomic.StoreInt64(&c.gauges.queued, int64(queue.len))
+
+		in := c.intake
+		if queue.len >= queueSize {
+			in = nil
+		}
+
+		var out chan Notification
+		var head Notification
+		if queue.len > 0 {
+			out = c.notifs
+			head = queue.peek()
+		}
+
+		select {
+		case n := <-in:
+			if c.dropUnsendable(n, time.Now()) {
+				continue
+			}
+			queue.push(n)
+		case out <- head:
+			queue.pop()
+		case req := <-c.queuePurges:
+			purged := queue.purge(req.token)
+			for _, n := range purged {
+				c.report(forgottenResult(n))
+			}
+			req.done <- len(purged)
+		case req := <-c.cancels:
+			cancelled := queue.remove(func(n Notification) bool { return n.sendGroup == req.group })
+			for _, n := range cancelled {
+				c.report(cancelledResult(n))
+			}
+			req.done <- len(cancelled)
+		case <-c.closed:
+			for _, n := range queue.drain() {
+				c.discard(n)
+			}
+			return
+		}
+	}
+}
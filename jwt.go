@@ -0,0 +1,202 @@
+package apns
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TokenProvider signs the ES256 JSON Web Tokens used to authenticate with
+// the HTTP/2 provider API instead of a certificate. Tokens are cached by a
+// TokenGuard and re-signed after DefaultTokenRefreshAfter, well before
+// Apple stops accepting them.
+type TokenProvider struct {
+	// KeyID is replaced by Rotate; use CurrentKeyID to read it while
+	// the provider is in use.
+	KeyID  string
+	TeamID string
+
+	// Guard caches the signed token. Set its OnWarning and OnEvent to
+	// observe refreshes.
+	Guard *TokenGuard
+
+	mu  sync.RWMutex
+	key *ecdsa.PrivateKey
+}
+
+// NewTokenProvider creates a TokenProvider from the contents of an AuthKey
+// .p8 file downloaded from the developer account, along with its key ID
+// and the team ID.
+func NewTokenProvider(keyID, teamID string, p8 []byte) (*TokenProvider, error) {
+	key, err := parseP8(p8)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &TokenProvider{KeyID: keyID, TeamID: teamID, key: key}
+	p.Guard = NewTokenGuard(p.sign)
+	return p, nil
+}
+
+// NewTokenProviderWithFile creates a TokenProvider from the AuthKey .p8
+// file at path.
+func NewTokenProviderWithFile(keyID, teamID, path string) (*TokenProvider, error) {
+	p8, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTokenProvider(keyID, teamID, p8)
+}
+
+// Token returns the current provider token, signing a new one if needed.
+func (p *TokenProvider) Token() (string, error) {
+	return p.Guard.Token()
+}
+
+// Rotate replaces the signing key and key ID with those of another AuthKey
+// .p8 file, for scheduled key rotation without a restart. The cached token
+// is dropped and a new one signed right away; if signing fails, the old key
+// stays in use. Signing is local, so it doesn't tell whether APNS accepts
+// the key: a wrong key ID or team only shows up as InvalidProviderToken on
+// the next push. Revoke the old key in the developer account only after
+// every provider using it has rotated and pushed successfully.
+func (p *TokenProvider) Rotate(keyID string, p8 []byte) error {
+	key, err := parseP8(p8)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	oldID, oldKey := p.KeyID, p.key
+	p.KeyID, p.key = keyID, key
+	p.mu.Unlock()
+
+	// Apple treats a token signed with a new key as a new token, so this
+	// doesn't count against MinTokenRefreshInterval.
+	p.Guard.discard()
+	if _, err := p.Guard.Token(); err != nil {
+		p.mu.Lock()
+		p.KeyID, p.key = oldID, oldKey
+		p.mu.Unlock()
+		p.Guard.discard()
+		return err
+	}
+	return nil
+}
+
+// CurrentKeyID returns the ID of the key tokens are signed with.
+func (p *TokenProvider) CurrentKeyID() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.KeyID
+}
+
+// RotateHandler returns an http.Handler calling Rotate with the .p8 file
+// POSTed as the request body and the key ID from the key_id query
+// parameter:
+//
+//	curl --data-binary @AuthKey_NEWKEY.p8 'localhost:8080/debug/rotate-key?key_id=NEWKEY'
+//
+// It accepts signing keys, so only mount it where the debug endpoints are
+// protected.
+func (p *TokenProvider) RotateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "POST the .p8 key with ?key_id=", http.StatusMethodNotAllowed)
+			return
+		}
+
+		keyID := r.URL.Query().Get("key_id")
+		if keyID == "" {
+			http.Error(w, "missing key_id", http.StatusBadRequest)
+			return
+		}
+
+		p8, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := p.Rotate(keyID, p8); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"key_id":    keyID,
+			"issued_at": p.Guard.IssuedAt(),
+		})
+	})
+}
+
+func parseP8(p8 []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(p8)
+	if block == nil {
+		return nil, errors.New("apns: no PEM block in .p8 key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	ec, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("apns: .p8 key is not an ECDSA key")
+	}
+	return ec, nil
+}
+
+// sign returns a JWT issued at issuedAt.
+func (p *TokenProvider) sign(issuedAt time.Time) (string, error) {
+	p.mu.RLock()
+	keyID, key := p.KeyID, p.key
+	p.mu.RUnlock()
+
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "kid": keyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{"iss": p.TeamID, "iat": issuedAt.Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// JWS wants the raw, fixed-size r and s rather than ASN.1.
+	size := (key.Curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2*size)
+	fillBytes(r, sig[:size])
+	fillBytes(s, sig[size:])
+
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// fillBytes writes x big-endian into b, zero-padded on the left.
+func fillBytes(x *big.Int, b []byte) {
+	xb := x.Bytes()
+	copy(b[len(b)-len(xb):], xb)
+}
@@ -0,0 +1,139 @@
+package apns_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+// newP8 returns a fresh P-256 key and its .p8 encoding.
+func newP8() (*ecdsa.PrivateKey, []byte) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+var _ = Describe("TokenProvider", func() {
+	It("should sign ES256 tokens", func() {
+		key, p8 := newP8()
+		p, err := apns.NewTokenProvider("KEY123", "TEAM456", p8)
+		Expect(err).To(BeNil())
+
+		token, err := p.Token()
+		Expect(err).To(BeNil())
+
+		parts := strings.Split(token, ".")
+		Expect(parts).To(HaveLen(3))
+
+		var header, claims map[string]interface{}
+		b, _ := base64.RawURLEncoding.DecodeString(parts[0])
+		json.Unmarshal(b, &header)
+		b, _ = base64.RawURLEncoding.DecodeString(parts[1])
+		json.Unmarshal(b, &claims)
+
+		Expect(header).To(Equal(map[string]interface{}{"alg": "ES256", "kid": "KEY123"}))
+		Expect(claims["iss"]).To(Equal("TEAM456"))
+		Expect(claims["iat"]).To(BeNumerically("~", time.Now().Unix(), 2))
+
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		Expect(sig).To(HaveLen(64))
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		Expect(ecdsa.Verify(&key.PublicKey, digest[:], r, s)).To(BeTrue())
+	})
+
+	It("should reuse the cached token", func() {
+		_, p8 := newP8()
+		p, _ := apns.NewTokenProvider("KEY123", "TEAM456", p8)
+
+		a, _ := p.Token()
+		b, _ := p.Token()
+		Expect(a).To(Equal(b))
+	})
+
+	It("should reject malformed keys", func() {
+		_, err := apns.NewTokenProvider("KEY123", "TEAM456", []byte("not a key"))
+		Expect(err).NotTo(BeNil())
+
+		_, err = apns.NewTokenProvider("KEY123", "TEAM456", []byte(DummyKey))
+		Expect(err).NotTo(BeNil())
+	})
+
+	Describe("Rotate", func() {
+		kid := func(token string) string {
+			var header map[string]string
+			b, _ := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+			json.Unmarshal(b, &header)
+			return header["kid"]
+		}
+
+		It("should sign new tokens with the new key straight away", func() {
+			_, p8 := newP8()
+			p, _ := apns.NewTokenProvider("OLDKEY", "TEAM456", p8)
+			old, _ := p.Token()
+
+			key, p8 := newP8()
+			Expect(p.Rotate("NEWKEY", p8)).To(BeNil())
+			Expect(p.CurrentKeyID()).To(Equal("NEWKEY"))
+
+			token, err := p.Token()
+			Expect(err).To(BeNil())
+			Expect(token).NotTo(Equal(old))
+			Expect(kid(token)).To(Equal("NEWKEY"))
+
+			parts := strings.Split(token, ".")
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+			Expect(ecdsa.Verify(&key.PublicKey, digest[:], r, s)).To(BeTrue())
+		})
+
+		It("should keep the old key if the new one is malformed", func() {
+			_, p8 := newP8()
+			p, _ := apns.NewTokenProvider("OLDKEY", "TEAM456", p8)
+			old, _ := p.Token()
+
+			Expect(p.Rotate("NEWKEY", []byte("not a key"))).NotTo(BeNil())
+			Expect(p.CurrentKeyID()).To(Equal("OLDKEY"))
+			token, _ := p.Token()
+			Expect(token).To(Equal(old))
+		})
+
+		It("should rotate through the debug handler", func() {
+			_, p8 := newP8()
+			p, _ := apns.NewTokenProvider("OLDKEY", "TEAM456", p8)
+			h := p.RotateHandler()
+
+			_, p8 = newP8()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/debug/rotate-key?key_id=NEWKEY", strings.NewReader(string(p8))))
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring(`"key_id":"NEWKEY"`))
+
+			token, _ := p.Token()
+			Expect(kid(token)).To(Equal("NEWKEY"))
+
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/debug/rotate-key", strings.NewReader(string(p8))))
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/rotate-key?key_id=NEWKEY", nil))
+			Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
@@ -0,0 +1,150 @@
+package apns
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxPayloadSize is the largest payload APNS accepts for most push
+	// types.
+	MaxPayloadSize = 4096
+
+	// MaxVoIPPayloadSize is the largest payload APNS accepts for VoIP
+	// pushes.
+	MaxVoIPPayloadSize = 5120
+
+	// MaxCollapseIDSize is the longest collapse identifier APNS accepts.
+	MaxCollapseIDSize = 64
+
+	// MaxContentStateSize is the largest Live Activity content-state
+	// ActivityKit accepts.
+	MaxContentStateSize = 4096
+
+	// MaxLocationPushesPerHour is how many location pushes APNS delivers to
+	// a device per hour; the rest are throttled.
+	MaxLocationPushesPerHour = 10
+)
+
+// Limit names an APNS constraint a notification can exceed.
+type Limit string
+
+const (
+	LimitPayloadSize  Limit = "payload size"
+	LimitCollapseID   Limit = "collapse id"
+	LimitLocationRate Limit = "location pushes per hour"
+	LimitContentState Limit = "content-state size"
+)
+
+// LimitError is returned when a notification exceeds one of Apple's limits.
+type LimitError struct {
+	Limit    Limit
+	PushType PushType
+	Size     int
+	Max      int
+}
+
+func (e *LimitError) Error() string {
+	if e.PushType != "" {
+		return fmt.Sprintf("%s %d exceeds %d for %s pushes", e.Limit, e.Size, e.Max, e.PushType)
+	}
+	return fmt.Sprintf("%s %d exceeds %d", e.Limit, e.Size, e.Max)
+}
+
+// PayloadLimit returns the largest payload APNS accepts for t.
+func PayloadLimit(t PushType) int {
+	if t == PushTypeVoIP {
+		return MaxVoIPPayloadSize
+	}
+	return MaxPayloadSize
+}
+
+// ValidateCollapseID returns a *LimitError if id is too long to be used as
+// a collapse identifier.
+func ValidateCollapseID(id string) error {
+	if len(id) > MaxCollapseIDSize {
+		return &LimitError{Limit: LimitCollapseID, Size: len(id), Max: MaxCollapseIDSize}
+	}
+	return nil
+}
+
+// payloadBytes returns the payload as it will be sent.
+func (n Notification) payloadBytes() ([]byte, error) {
+	if n.payload != nil {
+		return n.payload, nil
+	}
+	return json.Marshal(n.Payload)
+}
+
+// ValidateLimits returns a *LimitError if n exceeds a limit Apple enforces
+// for its push type.
+func (n Notification) ValidateLimits() error {
+	j, err := n.payloadBytes()
+	if err != nil {
+		return err
+	}
+
+	if max := PayloadLimit(n.PushType); len(j) > max {
+		return &LimitError{Limit: LimitPayloadSize, PushType: n.PushType, Size: len(j), Max: max}
+	}
+
+	if err := ValidateCollapseID(n.CollapseID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// locationRates counts location pushes per device token so the client can
+// fail the ones Apple would throttle. Tokens idle for an hour are swept out
+// once an hour, so it only holds recently pushed-to tokens.
+type locationRates struct {
+	sent  map[string][]time.Time
+	swept time.Time
+}
+
+// allow records a location push to token at now, returning false if the
+// token already had MaxLocationPushesPerHour within the last hour.
+func (r *locationRates) allow(token string, now time.Time) bool {
+	if r.sent == nil {
+		r.sent = map[string][]time.Time{}
+		r.swept = now
+	}
+	if now.Sub(r.swept) >= time.Hour {
+		r.sweep(now)
+	}
+
+	token = strings.ToLower(token)
+
+	sent := r.sent[token]
+	i := 0
+	for i < len(sent) && now.Sub(sent[i]) >= time.Hour {
+		i++
+	}
+	sent = sent[i:]
+
+	if len(sent) >= MaxLocationPushesPerHour {
+		r.sent[token] = sent
+		return false
+	}
+
+	r.sent[token] = append(sent, now)
+	return true
+}
+
+// sweep drops tokens without a push in the hour before now.
+func (r *locationRates) sweep(now time.Time) {
+	for token, sent := range r.sent {
+		if now.Sub(sent[len(sent)-1]) >= time.Hour {
+			delete(r.sent, token)
+		}
+	}
+	r.swept = now
+}
+
+// forget drops token's pushes.
+func (r *locationRates) forget(token string) {
+	delete(r.sent, strings.ToLower(token))
+}
@@ -0,0 +1,84 @@
+package apns_test
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Limits", func() {
+	payloadOf := func(size int) []byte {
+		// {"a":"xxx"} is 8 bytes of framing around the value.
+		return []byte(`{"a":"` + strings.Repeat("x", size-8) + `"}`)
+	}
+
+	DescribeTable("Notification#ValidateLimits",
+		func(t apns.PushType, size int, ok bool) {
+			n := apns.Notification{PushType: t}.WithPrecomputedPayload(payloadOf(size))
+
+			err := n.ValidateLimits()
+			if ok {
+				Expect(err).To(BeNil())
+				return
+			}
+
+			Expect(err).To(BeAssignableToTypeOf(&apns.LimitError{}))
+			Expect(err.(*apns.LimitError).Limit).To(Equal(apns.LimitPayloadSize))
+			Expect(err.(*apns.LimitError).Size).To(Equal(size))
+		},
+		Entry("alert at 4KB", apns.PushTypeAlert, 4096, true),
+		Entry("alert over 4KB", apns.PushTypeAlert, 4097, false),
+		Entry("background over 4KB", apns.PushTypeBackground, 4097, false),
+		Entry("VoIP at 5KB", apns.PushTypeVoIP, 5120, true),
+		Entry("VoIP over 5KB", apns.PushTypeVoIP, 5121, false),
+	)
+
+	Describe(".ValidateCollapseID", func() {
+		It("should allow 64 bytes", func() {
+			Expect(apns.ValidateCollapseID(strings.Repeat("c", 64))).To(BeNil())
+		})
+
+		It("should reject anything longer", func() {
+			err := apns.ValidateCollapseID(strings.Repeat("c", 65))
+			Expect(err).To(MatchError("collapse id 65 exceeds 64"))
+		})
+
+		It("should be checked by Notification#ValidateLimits", func() {
+			n := apns.NewNotification()
+			n.CollapseID = strings.Repeat("c", 65)
+			Expect(n.ValidateLimits()).To(BeAssignableToTypeOf(&apns.LimitError{}))
+		})
+	})
+
+	It("should report the LimitError for notifications the client rejects", func(d Done) {
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		mockDone := make(chan interface{})
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+
+			results := make(chan apns.Result, 1)
+			n := apns.NewNotification().WithPrecomputedPayload(payloadOf(4097))
+			n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+			c.Send(n, apns.OnResult(func(r apns.Result) { results <- r }))
+
+			r := <-results
+			var le *apns.LimitError
+			Expect(errors.As(r.Err, &le)).To(BeTrue())
+			Expect(le.Limit).To(Equal(apns.LimitPayloadSize))
+			Expect(r.Disposition).To(Equal(apns.FailedPermanent))
+
+			close(mockDone)
+			close(d)
+		})
+	})
+})
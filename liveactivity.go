@@ -0,0 +1,111 @@
+//go:build go1.18
+// +build go1.18
+
+package apns
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ContentStateError is returned when a Live Activity content-state is
+// missing a required field.
+type ContentStateError struct {
+	Field string
+}
+
+func (e *ContentStateError) Error() string {
+	return fmt.Sprintf("content-state: missing required field %q", e.Field)
+}
+
+// SetContentState sets the Live Activity content-state of p to state,
+// which should mirror the activity's ContentState type in the app:
+//
+//	type Score struct {
+//		Home   int    `json:"home"`
+//		Away   int    `json:"away"`
+//		Status string `json:"status" apns:"required"`
+//	}
+//
+// Fields tagged apns:"required" must not be zero, and the encoded state
+// must fit in MaxContentStateSize. A *ContentStateError or *LimitError is
+// returned otherwise and p is left unchanged.
+func SetContentState[T any](p *Payload, state T) error {
+	if err := validateRequired(reflect.ValueOf(state)); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if len(b) > MaxContentStateSize {
+		return &LimitError{Limit: LimitContentState, Size: len(b), Max: MaxContentStateSize}
+	}
+
+	p.APS.ContentState = b
+	return nil
+}
+
+// GetContentState decodes the Live Activity content-state of p into a T.
+func GetContentState[T any](p *Payload) (T, error) {
+	var state T
+	if len(p.APS.ContentState) == 0 {
+		return state, errors.New("content-state: not set")
+	}
+
+	err := json.Unmarshal(p.APS.ContentState, &state)
+	return state, err
+}
+
+// SetAttributes sets the attributes of a Live Activity started with a
+// push-to-start token: attrs, which should mirror the activity's
+// attributes type in the app, and the name of that type. Fields tagged
+// apns:"required" must not be zero, as with SetContentState.
+func SetAttributes[T any](p *Payload, attributesType string, attrs T) error {
+	if err := validateRequired(reflect.ValueOf(attrs)); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		return err
+	}
+
+	p.APS.AttributesType = attributesType
+	p.APS.Attributes = b
+	return nil
+}
+
+// validateRequired checks that the fields of struct v tagged
+// apns:"required" are set.
+func validateRequired(v reflect.Value) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return errors.New("content-state: nil")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("apns") != "required" || !v.Field(i).IsZero() {
+			continue
+		}
+
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+			name = tag
+		}
+		return &ContentStateError{Field: name}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package apns
+
+import "time"
+
+// LiveActivityEvent is the event of a Live Activity push.
+type LiveActivityEvent string
+
+const (
+	// LiveActivityStart starts a Live Activity, sent to a push-to-start
+	// token along with AttributesType and Attributes.
+	LiveActivityStart LiveActivityEvent = "start"
+
+	LiveActivityUpdate LiveActivityEvent = "update"
+	LiveActivityEnd    LiveActivityEvent = "end"
+)
+
+// NewLiveActivityNotification returns a Live Activity push of event for
+// the app with the given bundle ID, timestamped now. token is the
+// activity's push token, or the app's push-to-start token for
+// LiveActivityStart. Set the content-state with SetContentState, and the
+// attributes of start events with SetAttributes.
+func NewLiveActivityNotification(token, bundleID string, event LiveActivityEvent) Notification {
+	n := NewNotification()
+	n.DeviceToken = token
+	n.PushType = PushTypeLiveActivity
+	n.Topic = bundleID + TopicSuffix(PushTypeLiveActivity)
+	n.Payload.APS.Event = event
+	n.Payload.APS.Timestamp = time.Now()
+	return n
+}
+
+// unixTime is the inverse of Time.Unix, except that 0 is the zero Time.
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,101 @@
+//go:build go1.18
+// +build go1.18
+
+package apns_test
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+type matchState struct {
+	Home   int    `json:"home"`
+	Away   int    `json:"away"`
+	Status string `json:"status" apns:"required"`
+	Note   string `json:"note,omitempty"`
+}
+
+var _ = Describe("Live Activity content-state", func() {
+	Describe("SetContentState", func() {
+		It("should set the content-state", func() {
+			p := apns.NewPayload()
+			Expect(apns.SetContentState(p, matchState{Home: 1, Status: "live"})).To(BeNil())
+
+			b, _ := json.Marshal(p)
+			Expect(b).To(MatchJSON(`{"aps":{"content-state":{"home":1,"away":0,"status":"live"}}}`))
+		})
+
+		It("should reject missing required fields", func() {
+			p := apns.NewPayload()
+			err := apns.SetContentState(p, &matchState{Home: 1})
+
+			Expect(err).To(Equal(&apns.ContentStateError{Field: "status"}))
+			Expect(p.APS.ContentState).To(BeEmpty())
+		})
+
+		It("should reject states over the size limit", func() {
+			p := apns.NewPayload()
+			err := apns.SetContentState(p, matchState{Status: "live", Note: strings.Repeat("x", apns.MaxContentStateSize)})
+
+			Expect(err).To(BeAssignableToTypeOf(&apns.LimitError{}))
+			Expect(err.(*apns.LimitError).Limit).To(Equal(apns.LimitContentState))
+		})
+	})
+
+	Describe("GetContentState", func() {
+		It("should decode the state of an unmarshaled payload", func() {
+			p := apns.NewPayload()
+			json.Unmarshal([]byte(`{"aps":{"content-state":{"home":2,"away":3,"status":"final"}}}`), p)
+
+			s, err := apns.GetContentState[matchState](p)
+			Expect(err).To(BeNil())
+			Expect(s).To(Equal(matchState{Home: 2, Away: 3, Status: "final"}))
+		})
+
+		It("should fail if no state is set", func() {
+			_, err := apns.GetContentState[matchState](apns.NewPayload())
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("SetAttributes", func() {
+		It("should set the attributes and their type", func() {
+			p := apns.NewPayload()
+			Expect(apns.SetAttributes(p, "MatchAttributes", matchState{Status: "live"})).To(BeNil())
+
+			b, _ := json.Marshal(p)
+			Expect(b).To(MatchJSON(`{"aps":{"attributes-type":"MatchAttributes","attributes":{"home":0,"away":0,"status":"live"}}}`))
+		})
+
+		It("should reject missing required fields", func() {
+			p := apns.NewPayload()
+			Expect(apns.SetAttributes(p, "MatchAttributes", matchState{})).To(Equal(&apns.ContentStateError{Field: "status"}))
+		})
+	})
+
+	Describe("NewLiveActivityNotification", func() {
+		It("should build a timestamped push with the Live Activity topic", func() {
+			n := apns.NewLiveActivityNotification("abcd", "com.example.app", apns.LiveActivityEnd)
+			n.Payload.APS.ContentState = json.RawMessage(`{}`)
+			n.Payload.APS.DismissalDate = time.Unix(1700000600, 0)
+			n.Payload.APS.Timestamp = time.Unix(1700000000, 0)
+
+			Expect(n.PushType).To(Equal(apns.PushTypeLiveActivity))
+			Expect(n.Topic).To(Equal("com.example.app.push-type.liveactivity"))
+
+			b, _ := json.Marshal(n.Payload)
+			Expect(b).To(MatchJSON(`{"aps":{"event":"end","timestamp":1700000000,"dismissal-date":1700000600,"content-state":{}}}`))
+
+			p := apns.NewPayload()
+			Expect(json.Unmarshal(b, p)).To(BeNil())
+			Expect(p.APS.Event).To(Equal(apns.LiveActivityEnd))
+			Expect(p.APS.DismissalDate.Equal(n.Payload.APS.DismissalDate)).To(BeTrue())
+			Expect(p.APS.StaleDate.IsZero()).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,15 @@
+package apns
+
+// NewLocationNotification returns a location push for the app with the
+// given bundle ID, which wakes its location push service extension. token
+// is the location push token, not the app's device token. The topic gets
+// the .location-query suffix and the payload is left empty, as Apple
+// requires. Location pushes are sent with PriorityImmediate unless
+// Priority is set to PriorityPowerConserve.
+func NewLocationNotification(token, bundleID string) Notification {
+	n := NewNotification()
+	n.DeviceToken = token
+	n.PushType = PushTypeLocation
+	n.Topic = bundleID + TopicSuffix(PushTypeLocation)
+	return n
+}
@@ -0,0 +1,241 @@
+package apns
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Locker coordinates processes so only one of them connects to APNS with
+// a given certificate at a time. Apple tears down connections when the
+// same certificate is used from too many places, so accidental duplicate
+// deployments otherwise end up in a reconnect tug-of-war.
+type Locker interface {
+	// Lock blocks until the lock is held or ctx is done.
+	Lock(ctx context.Context) error
+	Unlock() error
+}
+
+// DefaultLockRetryInterval is how often locks poll while held elsewhere.
+const DefaultLockRetryInterval = time.Second
+
+// CertLockName returns a name identifying cert, for naming locks shared by
+// every process using the same certificate.
+func CertLockName(cert tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return "apns-cert-none"
+	}
+	sum := sha256.Sum256(cert.Certificate[0])
+	return "apns-cert-" + hex.EncodeToString(sum[:8])
+}
+
+// SetLock makes the client hold l whenever it is connected. If the client
+// is already connected it reconnects once the lock is held. Close releases
+// the lock.
+//
+// If l has a Lost method, as RedisLock does, the client disconnects when
+// the channel it returns is closed and waits to hold l again, emitting
+// EventLockLost.
+func (c *Client) SetLock(l Locker) {
+	c.mu.Lock()
+	c.lock = l
+	c.mu.Unlock()
+
+	select {
+	case c.relock <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Client) currentLock() Locker {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.lock
+}
+
+// lockLost returns the channel l closes when it loses the lock it holds,
+// or nil if l can't tell.
+func lockLost(l Locker) <-chan struct{} {
+	if ll, ok := l.(interface{ Lost() <-chan struct{} }); ok {
+		return ll.Lost()
+	}
+	return nil
+}
+
+// acquireLock blocks until l is held, giving up when the client is closed.
+// Forget requests are passed to forget meanwhile, as the lock may be held
+// elsewhere for a long time.
+func (c *Client) acquireLock(l Locker, forget func(forgetRequest)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	locked := make(chan error, 1)
+	go func() {
+		locked <- l.Lock(ctx)
+	}()
+
+	closed := c.closed
+	for {
+		select {
+		case err := <-locked:
+			return err
+		case <-closed:
+			cancel()
+			closed = nil
+		case req := <-c.forgets:
+			forget(req)
+		}
+	}
+}
+
+// RedisLockClient is the subset of a Redis client RedisLock needs.
+type RedisLockClient interface {
+	// SetNX sets key to value with a TTL if key doesn't exist, as
+	// SET key value NX PX ttl does.
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+
+	// Eval runs a Lua script, as EVAL does.
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+const (
+	redisUnlockScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+	redisExtendScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+)
+
+// RedisLock is a Locker backed by a Redis key holding a random token. The
+// key expires after TTL unless the holder keeps extending it, so a crashed
+// process doesn't hold the lock forever. If extending fails, because the
+// key expired or was taken over, or Redis couldn't be reached for a whole
+// TTL, the lock is lost and Lost reports it.
+type RedisLock struct {
+	Client RedisLockClient
+	Key    string
+
+	// TTL must be at least MinRedisLockTTL, as the key is extended every
+	// third of it in whole milliseconds.
+	TTL time.Duration
+
+	// RetryInterval is how often Lock polls while the key is held
+	// elsewhere. DefaultLockRetryInterval is used if zero.
+	RetryInterval time.Duration
+
+	mu    sync.Mutex
+	token string
+	stop  chan struct{}
+	lost  chan struct{}
+}
+
+// MinRedisLockTTL is the shortest TTL a RedisLock accepts.
+const MinRedisLockTTL = 3 * time.Millisecond
+
+func NewRedisLock(client RedisLockClient, key string, ttl time.Duration) *RedisLock {
+	return &RedisLock{Client: client, Key: key, TTL: ttl}
+}
+
+// Lock fails without trying if TTL is below MinRedisLockTTL.
+func (l *RedisLock) Lock(ctx context.Context) error {
+	if l.TTL < MinRedisLockTTL {
+		return fmt.Errorf("redis lock TTL %v is below the %v minimum", l.TTL, MinRedisLockTTL)
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(b)
+
+	retry := l.RetryInterval
+	if retry == 0 {
+		retry = DefaultLockRetryInterval
+	}
+
+	for {
+		ok, err := l.Client.SetNX(ctx, l.Key, token, l.TTL)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+
+	l.mu.Lock()
+	l.token = token
+	l.stop = make(chan struct{})
+	l.lost = make(chan struct{})
+	go l.extend(token, l.stop, l.lost)
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Lost returns a channel that is closed if the lock is lost while held,
+// or nil if it isn't held.
+func (l *RedisLock) Lost() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lost
+}
+
+// extend keeps pushing the key's expiry out while the lock is held, and
+// closes lost if it can't.
+func (l *RedisLock) extend(token string, stop, lost chan struct{}) {
+	interval := l.TTL / 3
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	extended := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		res, err := l.Client.Eval(ctx, redisExtendScript, []string{l.Key}, token, l.TTL.Milliseconds())
+		cancel()
+
+		switch {
+		case err == nil && res == int64(1):
+			extended = time.Now()
+			continue
+		case err != nil && time.Since(extended) < l.TTL:
+			// The key is still ours until it expires; try again.
+			continue
+		}
+
+		close(lost)
+		return
+	}
+}
+
+func (l *RedisLock) Unlock() error {
+	l.mu.Lock()
+	token, stop := l.token, l.stop
+	l.token, l.stop = "", nil
+	l.mu.Unlock()
+
+	if stop == nil {
+		return errors.New("redis lock not held")
+	}
+	close(stop)
+
+	_, err := l.Client.Eval(context.Background(), redisUnlockScript, []string{l.Key}, token)
+	return err
+}
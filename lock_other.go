@@ -0,0 +1,29 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package apns
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// FileLock is a Locker backed by flock(2). It isn't supported on this
+// platform; use RedisLock or a Locker of your own.
+type FileLock struct {
+	Path          string
+	RetryInterval time.Duration
+}
+
+func NewFileLock(path string) *FileLock {
+	return &FileLock{Path: path}
+}
+
+func (l *FileLock) Lock(ctx context.Context) error {
+	return errors.New("file locks are not supported on this platform")
+}
+
+func (l *FileLock) Unlock() error {
+	return errors.New("file locks are not supported on this platform")
+}
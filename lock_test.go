@@ -0,0 +1,215 @@
+package apns_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+type mockRedis struct {
+	mu      sync.Mutex
+	keys    map[string]string
+	evalErr error
+}
+
+func (m *mockRedis) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.keys[key]; ok {
+		return false, nil
+	}
+	m.keys[key] = value
+	return true, nil
+}
+
+func (m *mockRedis) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.evalErr != nil {
+		return nil, m.evalErr
+	}
+	if m.keys[keys[0]] != args[0] {
+		return int64(0), nil
+	}
+	if strings.Contains(script, `"del"`) {
+		delete(m.keys, keys[0])
+	}
+	return int64(1), nil
+}
+
+var _ = Describe("Locks", func() {
+	Describe(".CertLockName", func() {
+		It("should be stable for a certificate", func() {
+			cert, _ := tls.X509KeyPair([]byte(DummyCert), []byte(DummyKey))
+
+			Expect(apns.CertLockName(cert)).To(HavePrefix("apns-cert-"))
+			Expect(apns.CertLockName(cert)).To(Equal(apns.CertLockName(cert)))
+		})
+	})
+
+	Describe("FileLock", func() {
+		It("should exclude a second holder until unlocked", func() {
+			dir, err := os.MkdirTemp("", "apns-lock")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "apns.lock")
+			a := apns.NewFileLock(path)
+			b := apns.NewFileLock(path)
+			b.RetryInterval = 10 * time.Millisecond
+
+			Expect(a.Lock(context.Background())).To(BeNil())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			Expect(b.Lock(ctx)).To(Equal(context.DeadlineExceeded))
+
+			Expect(a.Unlock()).To(BeNil())
+			Expect(b.Lock(context.Background())).To(BeNil())
+			Expect(b.Unlock()).To(BeNil())
+		})
+	})
+
+	Describe("RedisLock", func() {
+		It("should exclude a second holder until unlocked", func() {
+			r := &mockRedis{keys: map[string]string{}}
+			a := apns.NewRedisLock(r, "apns-lock", time.Minute)
+			b := apns.NewRedisLock(r, "apns-lock", time.Minute)
+			b.RetryInterval = 10 * time.Millisecond
+
+			Expect(a.Lock(context.Background())).To(BeNil())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			Expect(b.Lock(ctx)).To(Equal(context.DeadlineExceeded))
+
+			Expect(a.Unlock()).To(BeNil())
+			Expect(b.Lock(context.Background())).To(BeNil())
+			Expect(b.Unlock()).To(BeNil())
+			Expect(b.Unlock()).NotTo(BeNil())
+		})
+
+		It("should report the lock lost when the key is taken over", func() {
+			r := &mockRedis{keys: map[string]string{}}
+			l := apns.NewRedisLock(r, "apns-lock", 30*time.Millisecond)
+			Expect(l.Lost()).To(BeNil())
+
+			Expect(l.Lock(context.Background())).To(BeNil())
+			Consistently(l.Lost(), 50*time.Millisecond).ShouldNot(BeClosed())
+
+			r.mu.Lock()
+			r.keys["apns-lock"] = "someone else"
+			r.mu.Unlock()
+			Eventually(l.Lost()).Should(BeClosed())
+			Expect(l.Unlock()).To(BeNil())
+		})
+
+		It("should refuse a TTL too short to extend", func() {
+			r := &mockRedis{keys: map[string]string{}}
+
+			for _, ttl := range []time.Duration{0, time.Millisecond} {
+				l := apns.NewRedisLock(r, "apns-lock", ttl)
+				Expect(l.Lock(context.Background())).To(MatchError(ContainSubstring("below")))
+			}
+			Expect(r.keys).To(BeEmpty())
+		})
+
+		It("should report the lock lost when Redis is unreachable for a TTL", func() {
+			r := &mockRedis{keys: map[string]string{}}
+			l := apns.NewRedisLock(r, "apns-lock", 60*time.Millisecond)
+			Expect(l.Lock(context.Background())).To(BeNil())
+
+			r.mu.Lock()
+			r.evalErr = errors.New("connection refused")
+			r.mu.Unlock()
+
+			lost := l.Lost()
+			Consistently(lost, 30*time.Millisecond).ShouldNot(BeClosed())
+			Eventually(lost).Should(BeClosed())
+		})
+	})
+
+	Describe("Client#SetLock", func() {
+		It("should not connect while another process holds the lock", func() {
+			r := &mockRedis{keys: map[string]string{}}
+			other := apns.NewRedisLock(r, "apns-lock", time.Minute)
+			other.Lock(context.Background())
+
+			var attempts int
+			var mu sync.Mutex
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			lock := apns.NewRedisLock(r, "apns-lock", time.Minute)
+			lock.RetryInterval = 10 * time.Millisecond
+			c.SetLock(lock)
+			time.Sleep(50 * time.Millisecond)
+
+			c.Conn.OnConnect = func(apns.ConnectTiming) {
+				mu.Lock()
+				attempts++
+				mu.Unlock()
+			}
+			Consistently(func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return attempts
+			}, 100*time.Millisecond).Should(Equal(0))
+
+			other.Unlock()
+			Eventually(func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return attempts
+			}, 2*time.Second).Should(BeNumerically(">", 0))
+
+			c.Close()
+			Expect(r.keys).To(BeEmpty())
+		})
+
+		It("should disconnect when the lock is lost", func(d Done) {
+			mockDone := make(chan interface{})
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+				},
+			}
+
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				r := &mockRedis{keys: map[string]string{}}
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				defer c.Close()
+
+				c.SetLock(apns.NewRedisLock(r, "apns-lock", 30*time.Millisecond))
+				Eventually(func() int {
+					r.mu.Lock()
+					defer r.mu.Unlock()
+					return len(r.keys)
+				}).Should(Equal(1))
+
+				r.mu.Lock()
+				r.keys["apns-lock"] = "someone else"
+				r.mu.Unlock()
+
+				for e := range c.Events {
+					if e.Type == apns.EventLockLost {
+						break
+					}
+				}
+
+				close(mockDone)
+				close(d)
+			})
+		})
+	})
+})
@@ -0,0 +1,81 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package apns
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FileLock is a Locker backed by flock(2) on a file, for processes sharing
+// a host or a filesystem that supports it. The lock is released by the
+// kernel if the process dies.
+type FileLock struct {
+	Path string
+
+	// RetryInterval is how often Lock polls while the file is locked
+	// elsewhere. DefaultLockRetryInterval is used if zero.
+	RetryInterval time.Duration
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+func NewFileLock(path string) *FileLock {
+	return &FileLock{Path: path}
+}
+
+func (l *FileLock) Lock(ctx context.Context) error {
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+
+	retry := l.RetryInterval
+	if retry == 0 {
+		retry = DefaultLockRetryInterval
+	}
+
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if err != syscall.EWOULDBLOCK {
+			f.Close()
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+
+	l.mu.Lock()
+	l.f = f
+	l.mu.Unlock()
+
+	return nil
+}
+
+func (l *FileLock) Unlock() error {
+	l.mu.Lock()
+	f := l.f
+	l.f = nil
+	l.mu.Unlock()
+
+	if f == nil {
+		return errors.New("file lock not held")
+	}
+
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return f.Close()
+}
@@ -0,0 +1,62 @@
+package apns
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+)
+
+// oidUserID is the UID attribute of an MDM push certificate's subject,
+// which holds its topic.
+var oidUserID = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}
+
+// ErrNoMDMTopic is returned by MDMTopic for certificates without a UID.
+var ErrNoMDMTopic = errors.New("apns: certificate has no UID to use as MDM topic")
+
+// MDMTopic returns the topic of MDM pushes sent with cert: the UID in its
+// subject, such as "com.apple.mgmt.External.<uuid>".
+func MDMTopic(cert tls.Certificate) (string, error) {
+	if len(cert.Certificate) == 0 {
+		return "", ErrNoMDMTopic
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range leaf.Subject.Names {
+		if name.Type.Equal(oidUserID) {
+			if uid, ok := name.Value.(string); ok && uid != "" {
+				return uid, nil
+			}
+		}
+	}
+	return "", ErrNoMDMTopic
+}
+
+// NewMDMNotification returns an MDM push asking the device with the given
+// token to check in with its MDM server. pushMagic is the PushMagic the
+// device sent when it enrolled, and topic the MDM certificate's; see
+// MDMTopic.
+func NewMDMNotification(token, pushMagic, topic string) Notification {
+	n := NewNotification()
+	n.DeviceToken = token
+	n.PushType = PushTypeMDM
+	n.Topic = topic
+	n.Payload.MDM = pushMagic
+	return n
+}
+
+// NewMDMClient2 creates a Client2 for device management, authenticating
+// with the MDM push certificate cert and sending to its topic by default.
+func NewMDMClient2(host string, cert tls.Certificate) (*Client2, error) {
+	topic, err := MDMTopic(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewClient2(host, cert)
+	c.DefaultTopic = topic
+	return c, nil
+}
@@ -0,0 +1,91 @@
+package apns_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+// newMDMCert returns a self-signed certificate with uid as its subject's
+// UID.
+func newMDMCert(uid string) tls.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName: "APSP:" + uid,
+			ExtraNames: []pkix.AttributeTypeAndValue{
+				{Type: asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}, Value: uid},
+			},
+		},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+var _ = Describe("MDM", func() {
+	const topic = "com.apple.mgmt.External.2b6ec4a1-0000-4c3a-9d1e-5a8e3b0c7f11"
+
+	Describe(".MDMTopic", func() {
+		It("should read the certificate's UID", func() {
+			Expect(apns.MDMTopic(newMDMCert(topic))).To(Equal(topic))
+		})
+
+		It("should fail without a UID", func() {
+			_, err := apns.MDMTopic(newPushCert("Apple Push Services: com.example.app"))
+			Expect(err).To(Equal(apns.ErrNoMDMTopic))
+		})
+	})
+
+	Describe(".NewMDMNotification", func() {
+		It("should only carry the PushMagic", func() {
+			n := apns.NewMDMNotification("abcd", "5A1F", topic)
+
+			Expect(n.ValidatePushType()).To(BeNil())
+			b, _ := n.Payload.MarshalJSON()
+			Expect(b).To(MatchJSON(`{"mdm":"5A1F"}`))
+		})
+
+		It("should reject other payload keys", func() {
+			n := apns.NewMDMNotification("abcd", "5A1F", topic)
+			n.Payload.SetCustomValue("extra", 1)
+
+			Expect(n.ValidatePushType()).To(MatchError("mdm push: payload must only have mdm"))
+		})
+	})
+
+	Describe(".NewMDMClient2", func() {
+		It("should push to the certificate's topic", func() {
+			s := newMockHTTP2Server()
+			defer s.Close()
+
+			c, err := apns.NewMDMClient2(s.URL, newMDMCert(topic))
+			Expect(err).To(BeNil())
+			Expect(c.DefaultTopic).To(Equal(topic))
+
+			c.HTTPClient = newTestClient2(s).HTTPClient
+			n := apns.NewMDMNotification("abcd", "5A1F", "")
+			r, err := c.SendSync(context.Background(), n)
+			Expect(err).To(BeNil())
+			Expect(r.OK()).To(BeTrue())
+
+			Expect(s.requests[0].Header.Get("apns-topic")).To(Equal(topic))
+			Expect(s.requests[0].Header.Get("apns-push-type")).To(Equal("mdm"))
+			Expect(s.bodies[0]).To(MatchJSON(`{"mdm":"5A1F"}`))
+		})
+	})
+})
@@ -0,0 +1,58 @@
+package apns
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Message attributes understood by the queue consumers when building a
+// notification. Any other attributes are copied into Notification.Metadata.
+const (
+	MessageAttrDeviceToken = "DeviceToken"
+	MessageAttrPriority    = "Priority"
+	MessageAttrExpiration  = "Expiration" // unix seconds
+	MessageAttrPushType    = "PushType"
+	MessageAttrID          = "ID"
+)
+
+// notificationFromMessage builds a notification from a queued message whose
+// body is the JSON payload. The message ID is recorded in metadata under
+// idKey so the consumer can find the message again once the notification's
+// fate is known.
+func notificationFromMessage(body []byte, attrs map[string]string, idKey string, id string) (Notification, error) {
+	n := NewNotification()
+	n.Metadata = map[string]string{idKey: id}
+
+	if err := json.Unmarshal(body, n.Payload); err != nil {
+		return n, err
+	}
+
+	for k, v := range attrs {
+		switch k {
+		case MessageAttrDeviceToken:
+			n.DeviceToken = v
+		case MessageAttrPriority:
+			p, err := strconv.Atoi(v)
+			if err != nil {
+				return n, err
+			}
+			n.Priority = p
+		case MessageAttrExpiration:
+			sec, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return n, err
+			}
+			t := time.Unix(sec, 0)
+			n.Expiration = &t
+		case MessageAttrPushType:
+			n.PushType = PushType(v)
+		case MessageAttrID:
+			n.ID = v
+		default:
+			n.Metadata[k] = v
+		}
+	}
+
+	return n, nil
+}
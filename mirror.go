@@ -0,0 +1,150 @@
+package apns
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// MirrorMetadataKey is the Notification.Metadata key marking mirrored
+// notifications, holding the device token the original was sent to.
+const MirrorMetadataKey = "mirrored-from"
+
+// DefaultMirrorBuffer is how many mirrored notifications may wait for the
+// mirror client before further ones are dropped.
+const DefaultMirrorBuffer = 100
+
+// errStopped is returned by Client.send when it gives up waiting for room
+// in the queue.
+var errStopped = errors.New("apns: send stopped")
+
+// Mirror copies a sample of the notifications a client delivers to another
+// client, typically one connected to the sandbox, so QA can watch real
+// traffic arrive on test devices. Mirroring never slows the original
+// client down: copies that can't be queued right away are dropped, and
+// reported to the mirror client's subscribers as DroppedOverflow.
+type Mirror struct {
+	// Client receives the copies.
+	Client *Client
+
+	// SampleRate is the fraction of delivered notifications copied, from
+	// 0 to 1.
+	SampleRate float64
+
+	// MapToken returns the test device token to send a copy to in place of
+	// token. Copies are skipped if it returns false. If nil, copies go to
+	// the original token.
+	MapToken func(token string) (string, bool)
+
+	dropped uint64
+	queue   chan Notification
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Dropped returns how many sampled copies were dropped because the mirror
+// client was behind or closed.
+func (m *Mirror) Dropped() uint64 {
+	return atomic.LoadUint64(&m.dropped)
+}
+
+func (m *Mirror) start() {
+	m.queue = make(chan Notification, DefaultMirrorBuffer)
+	m.stop = make(chan struct{})
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		for {
+			select {
+			case n := <-m.queue:
+				// The mirror client's queue may be full for good, say
+				// while it can't connect, so stopping mustn't wait on it.
+				if err := m.Client.send(n, m.stop); err != nil {
+					atomic.AddUint64(&m.dropped, 1)
+				}
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (m *Mirror) close() {
+	close(m.stop)
+	m.wg.Wait()
+}
+
+// offer queues a copy of n if it's sampled and its token maps to a test
+// device.
+func (m *Mirror) offer(n Notification) {
+	if m.SampleRate < 1 && rand.Float64() >= m.SampleRate {
+		return
+	}
+
+	token := n.DeviceToken
+	if m.MapToken != nil {
+		var ok bool
+		if token, ok = m.MapToken(token); !ok {
+			return
+		}
+	}
+
+	// The copy gets its own payload bytes so the mirror client's hooks
+	// can't touch the original's Payload.
+	b, err := n.payloadBytes()
+	if err != nil {
+		return
+	}
+
+	metadata := map[string]string{MirrorMetadataKey: n.DeviceToken}
+	for k, v := range n.Metadata {
+		metadata[k] = v
+	}
+
+	cp := Notification{
+		ID:          n.ID,
+		DeviceToken: token,
+		Expiration:  n.Expiration,
+		Priority:    n.Priority,
+		PushType:    n.PushType,
+		CollapseID:  n.CollapseID,
+		Topic:       n.Topic,
+		Metadata:    metadata,
+
+		ExpirationPolicy: n.ExpirationPolicy,
+	}.WithPrecomputedPayload(b)
+
+	select {
+	case m.queue <- cp:
+	default:
+		atomic.AddUint64(&m.dropped, 1)
+		m.Client.report(overflowResult(cp))
+	}
+}
+
+// SetMirror starts copying a sample of delivered notifications to m.Client,
+// replacing any previous mirror. A nil m stops mirroring. The mirror also
+// stops when the client is closed; m.Client is left open.
+func (c *Client) SetMirror(m *Mirror) {
+	if m != nil {
+		m.start()
+	}
+
+	c.mu.Lock()
+	old := c.mirror
+	c.mirror = m
+	c.mu.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+}
+
+func (c *Client) currentMirror() *Mirror {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.mirror
+}
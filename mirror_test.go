@@ -0,0 +1,121 @@
+package apns_test
+
+import (
+	"crypto/tls"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Mirror", func() {
+	prod := strings.Repeat("99", 32)
+	test := strings.Repeat("11", 32)
+
+	as := [][]serverAction{
+		[]serverAction{
+			serverAction{action: readAction, data: []byte{}},
+		},
+	}
+
+	It("should copy delivered notifications to the mirror client", func(d Done) {
+		mockDone := make(chan interface{})
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+			defer c.Close()
+
+			// The mirror client never connects, so copies stay queued.
+			sandbox, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			defer sandbox.Close()
+
+			c.SetMirror(&apns.Mirror{
+				Client:     sandbox,
+				SampleRate: 1,
+				MapToken: func(token string) (string, bool) {
+					return test, token == prod
+				},
+			})
+
+			n := apns.NewNotification()
+			n.DeviceToken = prod
+			n.Payload.APS.Alert.Body = "hi"
+			c.Send(n)
+
+			n.DeviceToken = strings.Repeat("22", 32)
+			c.Send(n)
+
+			Eventually(func() int { return sandbox.Len }).Should(Equal(1))
+			Consistently(func() int { return sandbox.Len }, "50ms").Should(Equal(1))
+
+			close(mockDone)
+			close(d)
+		})
+	})
+
+	It("should not hold up Close while the mirror client's queue is full", func(d Done) {
+		mockDone := make(chan interface{})
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+
+			sandbox, _ := apns.NewClientWithConfig("127.0.0.1:1", tls.Certificate{}, func(c *apns.Client) error {
+				c.QueueSize = 1
+				return nil
+			})
+			defer sandbox.Close()
+
+			c.SetMirror(&apns.Mirror{Client: sandbox, SampleRate: 1})
+
+			n := apns.NewNotification()
+			n.DeviceToken = prod
+			for i := 0; i < 3; i++ {
+				c.Send(n)
+			}
+
+			// One copy fills the sandbox queue and the next one waits.
+			Eventually(func() int { return sandbox.Len }).Should(Equal(1))
+
+			closed := make(chan error)
+			go func() { closed <- c.Close() }()
+			Eventually(closed).Should(Receive(BeNil()))
+
+			close(mockDone)
+			close(d)
+		})
+	})
+
+	It("should report copies dropped while the mirror is behind", func(d Done) {
+		mockDone := make(chan interface{})
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+			defer c.Close()
+
+			sandbox, _ := apns.NewClientWithConfig("127.0.0.1:1", tls.Certificate{}, func(c *apns.Client) error {
+				c.QueueSize = 1
+				return nil
+			})
+			defer sandbox.Close()
+			sub := sandbox.Subscribe(1, apns.DropNewest)
+
+			m := &apns.Mirror{Client: sandbox, SampleRate: 1}
+			c.SetMirror(m)
+
+			n := apns.NewNotification()
+			n.DeviceToken = prod
+			for i := 0; i < apns.DefaultMirrorBuffer+10; i++ {
+				c.Send(n)
+			}
+
+			var r apns.Result
+			Eventually(sub.Results).Should(Receive(&r))
+			Expect(r.Disposition).To(Equal(apns.DroppedOverflow))
+			Expect(m.Dropped()).To(BeNumerically(">", 0))
+
+			close(mockDone)
+			close(d)
+		})
+	})
+})
@@ -1,18 +1,57 @@
 package apns
 
 import (
-	"bytes"
-	"encoding/binary"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"time"
 )
 
+// Priorities APNS accepts. A zero Notification.Priority picks a default;
+// see EffectivePriority.
 const (
 	PriorityImmediate     = 10
 	PriorityPowerConserve = 5
+
+	// PriorityLow lets the device hold the notification back and deliver
+	// it grouped with others to save power.
+	PriorityLow = 1
+)
+
+// PushType describes the kind of notification being sent, mirroring the
+// values Apple accepts in the apns-push-type header.
+type PushType string
+
+const (
+	PushTypeAlert      PushType = "alert"
+	PushTypeBackground PushType = "background"
+	PushTypeVoIP       PushType = "voip"
+
+	// PushTypeLocation asks a device's Location Push Service Extension for
+	// its location. The payload must not carry any aps keys.
+	PushTypeLocation PushType = "location"
+
+	// PushTypePushToTalk wakes a Push to Talk app to report a remote
+	// speaker. It must be sent immediately, without an expiration, and
+	// its payload must not carry any aps keys.
+	PushTypePushToTalk PushType = "pushtotalk"
+
+	// PushTypeLiveActivity updates a Live Activity. Its content-state is
+	// decoded by the app into the activity's ContentState type.
+	PushTypeLiveActivity PushType = "liveactivity"
+
+	// PushTypeComplication updates a watchOS complication. Its payload
+	// carries the app's own data rather than an alert, badge or sound.
+	PushTypeComplication PushType = "complication"
+
+	// PushTypeFileProvider signals a File Provider extension that items
+	// in a container changed remotely. Its payload names the container
+	// and must not carry any aps keys.
+	PushTypeFileProvider PushType = "fileprovider"
+
+	// PushTypeMDM tells a managed device to check in with its MDM server.
+	// Its payload only carries the device's PushMagic; see
+	// NewMDMNotification.
+	PushTypeMDM PushType = "mdm"
 )
 
 const (
@@ -35,6 +74,10 @@ const (
 type NotificationResult struct {
 	Notif Notification
 	Err   Error
+
+	// ConnID and RemoteAddr identify the connection the result came from.
+	ConnID     uint64
+	RemoteAddr string
 }
 
 type Alert struct {
@@ -61,9 +104,27 @@ type APS struct {
 	Badge            BadgeNumber
 	Sound            string
 	ContentAvailable int
-	URLArgs          []string
-	Category         string // requires iOS 8+
-	AccountId        string // for email push notifications
+	MutableContent   int      // lets a Notification Service Extension modify the alert
+	URLArgs          []string // sent when not nil, even if empty
+	Category         string   // requires iOS 8+
+	AccountId        string   // for email push notifications
+
+	// ContentState is the JSON content-state of a Live Activity push. Set
+	// it with SetContentState.
+	ContentState json.RawMessage
+
+	// Event, Timestamp, StaleDate and DismissalDate describe a Live
+	// Activity push; see NewLiveActivityNotification. Dates are sent in
+	// whole seconds and left out if zero.
+	Event         LiveActivityEvent
+	Timestamp     time.Time
+	StaleDate     time.Time
+	DismissalDate time.Time
+
+	// AttributesType and Attributes start a Live Activity with a
+	// push-to-start token. Set them with SetAttributes.
+	AttributesType string
+	Attributes     json.RawMessage
 }
 
 func (aps APS) MarshalJSON() ([]byte, error) {
@@ -85,19 +146,96 @@ func (aps APS) MarshalJSON() ([]byte, error) {
 	if aps.ContentAvailable != 0 {
 		data["content-available"] = aps.ContentAvailable
 	}
+	if aps.MutableContent != 0 {
+		data["mutable-content"] = aps.MutableContent
+	}
 	if aps.Category != "" {
 		data["category"] = aps.Category
 	}
-	if aps.URLArgs != nil && len(aps.URLArgs) != 0 {
+	if aps.URLArgs != nil {
 		data["url-args"] = aps.URLArgs
 	}
 	if aps.AccountId != "" {
 		data["account-id"] = aps.AccountId
 	}
+	if len(aps.ContentState) != 0 {
+		data["content-state"] = aps.ContentState
+	}
+	if aps.Event != "" {
+		data["event"] = aps.Event
+	}
+	if !aps.Timestamp.IsZero() {
+		data["timestamp"] = aps.Timestamp.Unix()
+	}
+	if !aps.StaleDate.IsZero() {
+		data["stale-date"] = aps.StaleDate.Unix()
+	}
+	if !aps.DismissalDate.IsZero() {
+		data["dismissal-date"] = aps.DismissalDate.Unix()
+	}
+	if aps.AttributesType != "" {
+		data["attributes-type"] = aps.AttributesType
+	}
+	if len(aps.Attributes) != 0 {
+		data["attributes"] = aps.Attributes
+	}
 
 	return json.Marshal(data)
 }
 
+// UnmarshalJSON reverses MarshalJSON, accepting the alert as either a plain
+// string or a dictionary.
+func (aps *APS) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Alert            json.RawMessage `json:"alert"`
+		Badge            *BadgeNumber    `json:"badge"`
+		Sound            string          `json:"sound"`
+		ContentAvailable int             `json:"content-available"`
+		MutableContent   int             `json:"mutable-content"`
+		URLArgs          []string        `json:"url-args"`
+		Category         string          `json:"category"`
+		AccountId        string          `json:"account-id"`
+		ContentState     json.RawMessage `json:"content-state"`
+		Event            string          `json:"event"`
+		Timestamp        int64           `json:"timestamp"`
+		StaleDate        int64           `json:"stale-date"`
+		DismissalDate    int64           `json:"dismissal-date"`
+		AttributesType   string          `json:"attributes-type"`
+		Attributes       json.RawMessage `json:"attributes"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	*aps = APS{
+		Sound:            raw.Sound,
+		ContentAvailable: raw.ContentAvailable,
+		MutableContent:   raw.MutableContent,
+		URLArgs:          raw.URLArgs,
+		Category:         raw.Category,
+		AccountId:        raw.AccountId,
+		ContentState:     raw.ContentState,
+		Event:            LiveActivityEvent(raw.Event),
+		Timestamp:        unixTime(raw.Timestamp),
+		StaleDate:        unixTime(raw.StaleDate),
+		DismissalDate:    unixTime(raw.DismissalDate),
+		AttributesType:   raw.AttributesType,
+		Attributes:       raw.Attributes,
+	}
+	if raw.Badge != nil {
+		aps.Badge = *raw.Badge
+	}
+
+	if len(raw.Alert) != 0 {
+		if raw.Alert[0] == '"' {
+			return json.Unmarshal(raw.Alert, &aps.Alert.Body)
+		}
+		return json.Unmarshal(raw.Alert, &aps.Alert)
+	}
+
+	return nil
+}
+
 type Payload struct {
 	APS APS
 	// MDM for mobile device management
@@ -105,21 +243,79 @@ type Payload struct {
 	customValues map[string]interface{}
 }
 
+// ErrCustomAPS is returned when "aps" is used as a custom payload key.
+// APNS only accepts the dictionary built from Payload.APS under it.
+var ErrCustomAPS = errors.New(`apns: "aps" is reserved for Payload.APS and can't be set as a custom value`)
+
 func (p *Payload) MarshalJSON() ([]byte, error) {
+	if _, ok := p.customValues["aps"]; ok {
+		return nil, ErrCustomAPS
+	}
+
+	m := make(map[string]interface{}, len(p.customValues)+1)
+	for k, v := range p.customValues {
+		m[k] = v
+	}
 	if len(p.MDM) != 0 {
-		p.customValues["mdm"] = p.MDM
+		m["mdm"] = p.MDM
 	} else {
-		p.customValues["aps"] = p.APS
+		m["aps"] = p.APS
+	}
+
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON reads a payload previously produced by MarshalJSON, so
+// notifications can be stored and rebuilt later.
+func (p *Payload) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	*p = Payload{customValues: map[string]interface{}{}}
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "aps":
+			err = json.Unmarshal(v, &p.APS)
+		case "mdm":
+			err = json.Unmarshal(v, &p.MDM)
+		default:
+			var cv interface{}
+			err = json.Unmarshal(v, &cv)
+			p.customValues[k] = cv
+		}
+		if err != nil {
+			return err
+		}
 	}
 
-	return json.Marshal(p.customValues)
+	return nil
+}
+
+// copy returns a copy of p that can be changed without changing p, for
+// hooks that rewrite one notification's payload when several notifications
+// share it.
+func (p *Payload) copy() *Payload {
+	c := *p
+	c.customValues = make(map[string]interface{}, len(p.customValues))
+	for k, v := range p.customValues {
+		c.customValues[k] = v
+	}
+	return &c
 }
 
+// SetCustomValue sets a top-level payload key alongside "aps". It returns
+// ErrCustomAPS for "aps" itself.
 func (p *Payload) SetCustomValue(key string, value interface{}) error {
 	if key == "aps" {
-		return errors.New("cannot assign a custom APS value in payload")
+		return ErrCustomAPS
 	}
 
+	if p.customValues == nil {
+		p.customValues = map[string]interface{}{}
+	}
 	p.customValues[key] = value
 
 	return nil
@@ -131,62 +327,85 @@ type Notification struct {
 	Identifier  uint32
 	Expiration  *time.Time
 	Priority    int
+	PushType    PushType
 	Payload     *Payload
+
+	// ExpirationPolicy tells an explicit expiration of 0 apart from an
+	// unset one; see ExpireImmediately. It's ExpirationAt when Expiration
+	// is set, so it can be left alone otherwise.
+	ExpirationPolicy ExpirationPolicy
+
+	// CollapseID groups notifications so a device only shows the latest,
+	// e.g. successive score updates. Client2 sends it as apns-collapse-id;
+	// the binary protocol has no equivalent and ignores it. It may be at
+	// most MaxCollapseIDSize bytes.
+	CollapseID string
+
+	// Topic is the topic to push to, such as the app's bundle ID or its
+	// .voip or .complication topic, which lets one universal certificate
+	// serve all of them. Client2 sends it as apns-topic in place of its
+	// DefaultTopic; the binary protocol derives the topic from the
+	// certificate and ignores it.
+	Topic string
+
+	// Metadata is never sent to Apple. It carries application context
+	// (such as the schema a notification was built against) through the
+	// client and back out on results.
+	Metadata map[string]string
+
+	payload     []byte
+	contentHash string
+	callback    *resultCallback
+	group       string
+	sendGroup   *SendGroup
+	deadline    time.Time
+	writtenAt   time.Time
 }
 
 func NewNotification() Notification {
 	return Notification{Payload: NewPayload()}
 }
 
-func NewPayload() *Payload {
-	return &Payload{customValues: map[string]interface{}{}}
+// WithPrecomputedPayload returns a copy of n that is sent with b as its
+// payload instead of marshaling Payload, so a fanout to many tokens only
+// marshals the payload once. b is used as is; only its size is checked.
+func (n Notification) WithPrecomputedPayload(b []byte) Notification {
+	n.payload = b
+	return n
 }
 
-func (n Notification) ToBinary() ([]byte, error) {
-	b := []byte{}
-
-	binTok, err := hex.DecodeString(n.DeviceToken)
-	if err != nil {
-		return b, fmt.Errorf("convert token to hex error: %s", err)
-	}
-
-	j, _ := json.Marshal(n.Payload)
-
-	buf := bytes.NewBuffer(b)
-
-	// Token
-	binary.Write(buf, binary.BigEndian, uint8(deviceTokenItemID))
-	binary.Write(buf, binary.BigEndian, uint16(deviceTokenItemLength))
-	binary.Write(buf, binary.BigEndian, binTok)
-
-	// Payload
-	binary.Write(buf, binary.BigEndian, uint8(payloadItemID))
-	binary.Write(buf, binary.BigEndian, uint16(len(j)))
-	binary.Write(buf, binary.BigEndian, j)
+// ExpireAt returns a copy of n that APNS stores until t if the device is
+// offline, by setting Expiration. Without an expiration, the binary
+// protocol asks APNS for a single attempt, while Client2 leaves it to
+// APNS's default storage policy; see ExpirationPolicy.
+func (n Notification) ExpireAt(t time.Time) Notification {
+	n.Expiration = &t
+	n.ExpirationPolicy = ExpirationAt
+	return n
+}
 
-	// Identifier
-	binary.Write(buf, binary.BigEndian, uint8(notificationIdentifierItemID))
-	binary.Write(buf, binary.BigEndian, uint16(notificationIdentifierItemLength))
-	binary.Write(buf, binary.BigEndian, uint32(n.Identifier))
+// ExpireAfter returns a copy of n that APNS stores for d from now if the
+// device is offline.
+func (n Notification) ExpireAfter(d time.Duration) Notification {
+	return n.ExpireAt(time.Now().Add(d))
+}
 
-	// Expiry
-	binary.Write(buf, binary.BigEndian, uint8(expirationDateItemID))
-	binary.Write(buf, binary.BigEndian, uint16(expirationDateItemLength))
-	if n.Expiration == nil {
-		binary.Write(buf, binary.BigEndian, uint32(0))
-	} else {
-		binary.Write(buf, binary.BigEndian, uint32(n.Expiration.Unix()))
+// expiry returns Expiration in Unix seconds, or 0 unless the policy is
+// ExpirationAt. The binary protocol has no way to tell the other policies
+// apart: both mean a single attempt.
+func (n Notification) expiry() int64 {
+	if n.expirationPolicy() != ExpirationAt {
+		return 0
 	}
+	return n.Expiration.Unix()
+}
 
-	// Priority
-	binary.Write(buf, binary.BigEndian, uint8(priorityItemID))
-	binary.Write(buf, binary.BigEndian, uint16(priorityItemLength))
-	binary.Write(buf, binary.BigEndian, uint8(n.Priority))
-
-	framebuf := bytes.NewBuffer([]byte{})
-	binary.Write(framebuf, binary.BigEndian, uint8(commandID))
-	binary.Write(framebuf, binary.BigEndian, uint32(buf.Len()))
-	binary.Write(framebuf, binary.BigEndian, buf.Bytes())
+func NewPayload() *Payload {
+	return &Payload{customValues: map[string]interface{}{}}
+}
 
-	return framebuf.Bytes(), nil
+// ToBinary returns n as a binary protocol frame.
+func (n Notification) ToBinary() ([]byte, error) {
+	var e frameEncoder
+	return e.encode(n)
 }
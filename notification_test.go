@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -150,6 +151,32 @@ var _ = Describe("Notifications", func() {
 				})
 			})
 
+			Context("with a custom aps value", func() {
+				It("should refuse it", func() {
+					p := apns.NewPayload()
+
+					Expect(p.SetCustomValue("aps", map[string]string{"alert": "hi"})).To(Equal(apns.ErrCustomAPS))
+
+					b, err := json.Marshal(p)
+					Expect(err).To(BeNil())
+					Expect(b).To(Equal([]byte(`{"aps":{}}`)))
+				})
+			})
+
+			Context("after switching to MDM", func() {
+				It("should not keep the APS from an earlier marshal", func() {
+					p := apns.NewPayload()
+					p.APS.Alert.Body = "testing"
+					json.Marshal(p)
+
+					p.MDM = "00000000-1111-3333-4444-555555555555"
+					b, err := json.Marshal(p)
+
+					Expect(err).To(BeNil())
+					Expect(b).To(Equal([]byte(`{"mdm":"00000000-1111-3333-4444-555555555555"}`)))
+				})
+			})
+
 			Context("with only MDM", func() {
 				It("should marshal MDM", func() {
 					p := apns.NewPayload()
@@ -165,6 +192,42 @@ var _ = Describe("Notifications", func() {
 		})
 	})
 
+	Describe("Payload", func() {
+		Describe("#UnmarshalJSON", func() {
+			Context("round trip", func() {
+				It("should rebuild the same payload", func() {
+					p := apns.NewPayload()
+					p.APS.Alert.Body = "testing"
+					p.APS.Badge.Set(0)
+					p.APS.Sound = "ping.aiff"
+					p.SetCustomValue("email", "come@me.bro")
+
+					b, _ := json.Marshal(p)
+
+					q := apns.NewPayload()
+					Expect(json.Unmarshal(b, q)).To(BeNil())
+					Expect(q.APS.Alert.Body).To(Equal("testing"))
+					Expect(q.APS.Badge.IsSet).To(BeTrue())
+
+					c, err := json.Marshal(q)
+					Expect(err).To(BeNil())
+					Expect(c).To(Equal(b))
+				})
+			})
+
+			Context("alert dictionary", func() {
+				It("should unmarshal the fields", func() {
+					q := apns.NewPayload()
+					err := json.Unmarshal([]byte(`{"aps":{"alert":{"title":"Hi","body":"there"}}}`), q)
+
+					Expect(err).To(BeNil())
+					Expect(q.APS.Alert.Title).To(Equal("Hi"))
+					Expect(q.APS.Alert.Body).To(Equal("there"))
+				})
+			})
+		})
+	})
+
 	Describe("APS", func() {
 		Context("badge with a zero (clears notifications)", func() {
 			It("should contain zero", func() {
@@ -310,6 +373,94 @@ var _ = Describe("Notifications", func() {
 					Expect(priorityLen).To(Equal(uint16(1)))
 					Expect(priority).To(Equal(uint8(10)))
 				})
+
+				It("should frame large payloads as they marshal", func() {
+					n := apns.NewNotification()
+					n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+					n.Payload.SetCustomValue("data", strings.Repeat("<&>", 1300))
+
+					b, err := n.ToBinary()
+					Expect(err).To(BeNil())
+
+					j, _ := json.Marshal(n.Payload)
+					Expect(binary.BigEndian.Uint32(b[1:])).To(Equal(uint32(len(b) - 5)))
+					Expect(binary.BigEndian.Uint16(b[1+4+35+1:])).To(Equal(uint16(len(j))))
+					Expect(b[1+4+35+3 : 1+4+35+3+len(j)]).To(Equal(j))
+
+					p, err := n.WithPrecomputedPayload(j).ToBinary()
+					Expect(err).To(BeNil())
+					Expect(p).To(Equal(b))
+				})
+			})
+
+			Context("expiration", func() {
+				tok := "9999999999999999999999999999999999999999999999999999999999999999"
+
+				// expiry reads the expiry item out of a frame with an empty
+				// payload.
+				expiry := func(n apns.Notification) uint32 {
+					b, err := n.ToBinary()
+					Expect(err).To(BeNil())
+					return binary.BigEndian.Uint32(b[1+4+35+13+7+3:])
+				}
+
+				It("should write the time set with ExpireAt", func() {
+					n := apns.NewNotification()
+					n.DeviceToken = tok
+
+					Expect(expiry(n.ExpireAt(time.Unix(1404102833, 0)))).To(Equal(uint32(1404102833)))
+					Expect(n.Expiration).To(BeNil())
+				})
+
+				It("should write the time set with ExpireAfter", func() {
+					n := apns.NewNotification()
+					n.DeviceToken = tok
+
+					want := time.Now().Add(time.Hour).Unix()
+					Expect(expiry(n.ExpireAfter(time.Hour))).To(BeNumerically("~", want, 1))
+				})
+
+				It("should treat a zero time as no expiration", func() {
+					n := apns.NewNotification()
+					n.DeviceToken = tok
+
+					Expect(expiry(n.ExpireAt(time.Time{}))).To(Equal(uint32(0)))
+				})
+
+				It("should write 0 for ExpireImmediately", func() {
+					n := apns.NewNotification()
+					n.DeviceToken = tok
+
+					n = n.ExpireAt(time.Unix(1404102833, 0)).ExpireImmediately()
+					Expect(n.ExpirationPolicy).To(Equal(apns.ExpirationImmediate))
+					Expect(expiry(n)).To(Equal(uint32(0)))
+				})
+			})
+
+			Context("precomputed payload", func() {
+				tok := "9999999999999999999999999999999999999999999999999999999999999999"
+
+				It("should match the frame built from the payload", func() {
+					n := apns.NewNotification()
+					n.DeviceToken = tok
+					n.Payload.APS.Alert.Body = "Hello"
+
+					j, _ := json.Marshal(n.Payload)
+					want, _ := n.ToBinary()
+
+					m := apns.Notification{DeviceToken: tok}.WithPrecomputedPayload(j)
+					b, err := m.ToBinary()
+
+					Expect(err).To(BeNil())
+					Expect(b).To(Equal(want))
+				})
+
+				It("should reject a payload too large for a frame item", func() {
+					n := apns.Notification{DeviceToken: tok}.WithPrecomputedPayload(make([]byte, 1<<16))
+
+					_, err := n.ToBinary()
+					Expect(err).NotTo(BeNil())
+				})
 			})
 		})
 	})
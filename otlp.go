@@ -0,0 +1,211 @@
+package apns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// OTLPScopeName is the instrumentation scope of exported log records.
+const OTLPScopeName = "github.com/timehop/apns"
+
+// OTLP severity numbers used for results.
+const (
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+)
+
+// OTLPLogExporter sends Results to an OpenTelemetry collector as OTLP log
+// records, using the OTLP/HTTP JSON encoding so no OpenTelemetry SDK is
+// needed. Feed it from a Subscription, batching as suits the collector:
+//
+//	sub := client.Subscribe(1000, apns.DropOldest)
+//	exp := apns.NewOTLPLogExporter("http://localhost:4318/v1/logs")
+//	go func() {
+//		for r := range sub.Results {
+//			exp.Export(r)
+//		}
+//	}()
+type OTLPLogExporter struct {
+	// Endpoint is the full URL of the collector's logs endpoint.
+	Endpoint string
+
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string
+
+	// ServiceName, if set, is reported as the service.name resource
+	// attribute.
+	ServiceName string
+
+	// HTTPClient is used for requests. http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+func NewOTLPLogExporter(endpoint string) *OTLPLogExporter {
+	return &OTLPLogExporter{Endpoint: endpoint}
+}
+
+// Export sends rs to the collector in a single request.
+func (e *OTLPLogExporter) Export(rs ...Result) error {
+	if len(rs) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(e.request(rs))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := e.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("apns: OTLP export failed: %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string          `json:"timeUnixNano"`
+	SeverityNumber int             `json:"severityNumber"`
+	SeverityText   string          `json:"severityText"`
+	Body           otlpValue       `json:"body"`
+	Attributes     []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+func otlpString(k, v string) otlpAttribute {
+	return otlpAttribute{Key: k, Value: otlpValue{StringValue: &v}}
+}
+
+func otlpInt(k string, v int64) otlpAttribute {
+	s := strconv.FormatInt(v, 10)
+	return otlpAttribute{Key: k, Value: otlpValue{IntValue: &s}}
+}
+
+func (e *OTLPLogExporter) request(rs []Result) otlpRequest {
+	var sl otlpScopeLogs
+	sl.Scope.Name = OTLPScopeName
+	for _, r := range rs {
+		sl.LogRecords = append(sl.LogRecords, newOTLPLogRecord(r))
+	}
+
+	rl := otlpResourceLogs{ScopeLogs: []otlpScopeLogs{sl}}
+	rl.Resource.Attributes = []otlpAttribute{}
+	if e.ServiceName != "" {
+		rl.Resource.Attributes = append(rl.Resource.Attributes, otlpString("service.name", e.ServiceName))
+	}
+
+	return otlpRequest{ResourceLogs: []otlpResourceLogs{rl}}
+}
+
+// newOTLPLogRecord describes r as a log record. Delivered results are
+// logged at INFO, suppressed or dropped ones at WARN and failures at ERROR.
+func newOTLPLogRecord(r Result) otlpLogRecord {
+	n := r.Notification
+
+	severity, text := otlpSeverityError, "ERROR"
+	switch r.Disposition {
+	case Delivered:
+		severity, text = otlpSeverityInfo, "INFO"
+	case Suppressed, DroppedExpired, DroppedOverflow, Cancelled, DroppedDeadline, Skipped:
+		severity, text = otlpSeverityWarn, "WARN"
+	}
+
+	body := "apns " + r.Disposition.String()
+	if r.Err != nil {
+		body += ": " + r.Err.Error()
+	}
+
+	attrs := []otlpAttribute{
+		otlpString("apns.disposition", r.Disposition.String()),
+		otlpString("apns.device_token", n.DeviceToken),
+		otlpInt("apns.identifier", int64(n.Identifier)),
+		otlpInt("apns.conn_id", int64(r.ConnID)),
+	}
+	if n.ID != "" {
+		attrs = append(attrs, otlpString("apns.id", n.ID))
+	}
+	if n.PushType != "" {
+		attrs = append(attrs, otlpString("apns.push_type", string(n.PushType)))
+	}
+	if n.Priority != 0 {
+		attrs = append(attrs, otlpInt("apns.priority", int64(n.Priority)))
+	}
+	if n.CollapseID != "" {
+		attrs = append(attrs, otlpString("apns.collapse_id", n.CollapseID))
+	}
+	if n.Topic != "" {
+		attrs = append(attrs, otlpString("apns.topic", n.Topic))
+	}
+	if r.ContentHash != "" {
+		attrs = append(attrs, otlpString("apns.content_hash", r.ContentHash))
+	}
+	if r.RemoteAddr != "" {
+		attrs = append(attrs, otlpString("network.peer.address", r.RemoteAddr))
+	}
+	if r.Err != nil {
+		attrs = append(attrs, otlpString("error.message", r.Err.Error()))
+	}
+	for k, v := range n.Metadata {
+		attrs = append(attrs, otlpString("apns.metadata."+k, v))
+	}
+
+	return otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(r.Time.UnixNano(), 10),
+		SeverityNumber: severity,
+		SeverityText:   text,
+		Body:           otlpValue{StringValue: &body},
+		Attributes:     attrs,
+	}
+}
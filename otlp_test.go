@@ -0,0 +1,111 @@
+package apns_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("OTLPLogExporter", func() {
+	var (
+		server   *httptest.Server
+		status   int
+		requests []map[string]interface{}
+		headers  []http.Header
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		requests, headers = nil, nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			requests = append(requests, body)
+			headers = append(headers, r.Header)
+			w.WriteHeader(status)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	records := func(req map[string]interface{}) []interface{} {
+		rl := req["resourceLogs"].([]interface{})[0].(map[string]interface{})
+		sl := rl["scopeLogs"].([]interface{})[0].(map[string]interface{})
+		return sl["logRecords"].([]interface{})
+	}
+
+	attr := func(record interface{}, key string) map[string]interface{} {
+		for _, a := range record.(map[string]interface{})["attributes"].([]interface{}) {
+			a := a.(map[string]interface{})
+			if a["key"] == key {
+				return a["value"].(map[string]interface{})
+			}
+		}
+		return nil
+	}
+
+	It("should post every result as a log record", func() {
+		e := apns.NewOTLPLogExporter(server.URL + "/v1/logs")
+		e.ServiceName = "pusher"
+		e.Headers = map[string]string{"Authorization": "Bearer secret"}
+
+		n := apns.NewNotification()
+		n.DeviceToken = "abc"
+		n.Identifier = 7
+		n.Metadata = map[string]string{"campaign": "spring"}
+		t := time.Unix(1500000000, 5)
+
+		err := e.Export(
+			apns.Result{Notification: n, Disposition: apns.Delivered, Time: t},
+			apns.Result{Notification: n, Disposition: apns.FailedPermanent, Err: errors.New("INVALID_TOKEN"), Time: t},
+		)
+		Expect(err).To(BeNil())
+
+		Expect(requests).To(HaveLen(1))
+		Expect(headers[0].Get("Authorization")).To(Equal("Bearer secret"))
+		Expect(headers[0].Get("Content-Type")).To(Equal("application/json"))
+
+		rs := records(requests[0])
+		Expect(rs).To(HaveLen(2))
+
+		delivered := rs[0].(map[string]interface{})
+		Expect(delivered["timeUnixNano"]).To(Equal("1500000000000000005"))
+		Expect(delivered["severityText"]).To(Equal("INFO"))
+		Expect(attr(rs[0], "apns.device_token")).To(HaveKeyWithValue("stringValue", "abc"))
+		Expect(attr(rs[0], "apns.identifier")).To(HaveKeyWithValue("intValue", "7"))
+		Expect(attr(rs[0], "apns.metadata.campaign")).To(HaveKeyWithValue("stringValue", "spring"))
+
+		failed := rs[1].(map[string]interface{})
+		Expect(failed["severityText"]).To(Equal("ERROR"))
+		Expect(failed["body"]).To(HaveKeyWithValue("stringValue", "apns failed-permanent: INVALID_TOKEN"))
+		Expect(attr(rs[1], "error.message")).To(HaveKeyWithValue("stringValue", "INVALID_TOKEN"))
+
+		rl := requests[0]["resourceLogs"].([]interface{})[0].(map[string]interface{})
+		Expect(rl["resource"]).To(Equal(map[string]interface{}{
+			"attributes": []interface{}{
+				map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "pusher"}},
+			},
+		}))
+	})
+
+	It("should not post empty batches", func() {
+		e := apns.NewOTLPLogExporter(server.URL)
+		Expect(e.Export()).To(BeNil())
+		Expect(requests).To(BeEmpty())
+	})
+
+	It("should fail on collector errors", func() {
+		status = http.StatusBadRequest
+		e := apns.NewOTLPLogExporter(server.URL)
+
+		Expect(e.Export(apns.Result{Disposition: apns.Delivered})).To(MatchError(ContainSubstring("400")))
+	})
+})
@@ -0,0 +1,164 @@
+package apns
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OutboxMetadataKey is the Notification.Metadata key holding the outbox
+// row ID, so failures read off FailedNotifs can be traced back to a row.
+const OutboxMetadataKey = "outbox-id"
+
+// OutboxRow is a pending push read from an application's outbox table.
+type OutboxRow struct {
+	ID           int64
+	Notification Notification
+}
+
+// OutboxStore is implemented by the application's outbox table. Claim must
+// atomically move the rows it returns out of the pending state so that
+// concurrent pollers never send the same row twice.
+type OutboxStore interface {
+	Claim(limit int) ([]OutboxRow, error)
+	MarkSent(id int64) error
+	MarkFailed(id int64, reason string) error
+}
+
+// Outbox polls an OutboxStore and sends what it finds through a Client.
+// Rows are marked sent or failed from the notifications' results, so a row
+// is only marked sent once APNS has accepted it; see OnResult.
+type Outbox struct {
+	Store     OutboxStore
+	Client    *Client
+	Interval  time.Duration
+	BatchSize int
+
+	// Errors receives store errors encountered while polling or marking
+	// rows. Errors are dropped if nobody is listening.
+	Errors chan error
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewOutbox(store OutboxStore, client *Client) *Outbox {
+	return &Outbox{
+		Store:     store,
+		Client:    client,
+		Interval:  time.Second,
+		BatchSize: 100,
+		Errors:    make(chan error),
+	}
+}
+
+// Start begins polling in the background until Stop is called.
+func (o *Outbox) Start() {
+	o.stop = make(chan struct{})
+	o.wg.Add(1)
+
+	go func() {
+		defer o.wg.Done()
+
+		t := time.NewTicker(o.Interval)
+		defer t.Stop()
+
+		for {
+			// Keep draining while full batches come back.
+			for {
+				i, err := o.Poll()
+				if err != nil {
+					o.report(err)
+				}
+				if err != nil || i < o.BatchSize {
+					break
+				}
+			}
+
+			select {
+			case <-o.stop:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// Stop ends polling and waits for an in-progress poll to finish.
+func (o *Outbox) Stop() {
+	close(o.stop)
+	o.wg.Wait()
+}
+
+// Poll claims one batch of rows and sends them. Each row is marked once
+// its notification's result is known. If the client refuses a row, say
+// because it has been closed, that row and the rest of the batch are marked
+// failed with the error so none are left claimed but unsent. It returns the
+// number of rows claimed.
+func (o *Outbox) Poll() (int, error) {
+	rows, err := o.Store.Claim(o.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, r := range rows {
+		n := r.Notification
+		if n.Metadata == nil {
+			n.Metadata = map[string]string{}
+		}
+		n.Metadata[OutboxMetadataKey] = strconv.FormatInt(r.ID, 10)
+
+		id := r.ID
+		err := o.Client.Send(n, OnResult(func(r Result) {
+			o.mark(id, r)
+		}))
+		if err != nil {
+			for _, r := range rows[i:] {
+				if merr := o.Store.MarkFailed(r.ID, err.Error()); merr != nil {
+					o.report(merr)
+				}
+			}
+			return len(rows), err
+		}
+	}
+
+	return len(rows), nil
+}
+
+// mark marks row id sent if r is Delivered and failed otherwise.
+func (o *Outbox) mark(id int64, r Result) {
+	var err error
+	if r.Disposition == Delivered {
+		err = o.Store.MarkSent(id)
+	} else {
+		err = o.Store.MarkFailed(id, r.Err.Error())
+	}
+	if err != nil {
+		o.report(err)
+	}
+}
+
+func (o *Outbox) report(err error) {
+	select {
+	case o.Errors <- err:
+	default:
+	}
+}
+
+// Record marks the row behind a failed notification as failed. Rows sent
+// by Poll are marked from their results already; Record is for failures
+// read off FailedNotifs for notifications sent some other way. Results for
+// notifications that didn't come from the outbox are ignored.
+func (o *Outbox) Record(r NotificationResult) error {
+	v, ok := r.Notif.Metadata[OutboxMetadataKey]
+	if !ok {
+		return nil
+	}
+
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	return o.Store.MarkFailed(id, r.Err.Error())
+}
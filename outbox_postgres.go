@@ -0,0 +1,86 @@
+package apns
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PostgresOutboxStore is an example OutboxStore for a PostgreSQL table
+// shaped like:
+//
+//	CREATE TABLE apns_outbox (
+//	    id           bigserial PRIMARY KEY,
+//	    device_token text NOT NULL,
+//	    payload      jsonb NOT NULL,
+//	    priority     int NOT NULL DEFAULT 10,
+//	    expiration   timestamptz,
+//	    status       text NOT NULL DEFAULT 'pending',
+//	    reason       text,
+//	    updated_at   timestamptz
+//	);
+//
+// Rows are claimed with FOR UPDATE SKIP LOCKED, so several pollers can share
+// a table. The caller registers the driver; this package doesn't import one.
+type PostgresOutboxStore struct {
+	DB    *sql.DB
+	Table string // trusted; interpolated into queries
+}
+
+func NewPostgresOutboxStore(db *sql.DB, table string) *PostgresOutboxStore {
+	return &PostgresOutboxStore{DB: db, Table: table}
+}
+
+func (s *PostgresOutboxStore) Claim(limit int) ([]OutboxRow, error) {
+	q := fmt.Sprintf(`UPDATE %[1]s SET status = 'sending', updated_at = now()
+		WHERE id IN (
+			SELECT id FROM %[1]s WHERE status = 'pending'
+			ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, device_token, payload, priority, expiration`, s.Table)
+
+	rows, err := s.DB.Query(q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []OutboxRow
+	for rows.Next() {
+		var r OutboxRow
+		var payload []byte
+		var expiration sql.NullTime
+
+		n := NewNotification()
+		if err := rows.Scan(&r.ID, &n.DeviceToken, &payload, &n.Priority, &expiration); err != nil {
+			return out, err
+		}
+		if err := json.Unmarshal(payload, n.Payload); err != nil {
+			return out, err
+		}
+		if expiration.Valid {
+			t := expiration.Time
+			n.Expiration = &t
+		}
+
+		r.Notification = n
+		out = append(out, r)
+	}
+
+	return out, rows.Err()
+}
+
+func (s *PostgresOutboxStore) MarkSent(id int64) error {
+	return s.mark(id, "sent", "")
+}
+
+func (s *PostgresOutboxStore) MarkFailed(id int64, reason string) error {
+	return s.mark(id, "failed", reason)
+}
+
+func (s *PostgresOutboxStore) mark(id int64, status string, reason string) error {
+	q := fmt.Sprintf(`UPDATE %s SET status = $1, reason = $2, updated_at = $3 WHERE id = $4`, s.Table)
+	_, err := s.DB.Exec(q, status, reason, time.Now(), id)
+	return err
+}
@@ -0,0 +1,152 @@
+package apns_test
+
+import (
+	"crypto/tls"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+type memOutboxStore struct {
+	sync.Mutex
+	pending []apns.OutboxRow
+	status  map[int64]string
+}
+
+func (m *memOutboxStore) Claim(limit int) ([]apns.OutboxRow, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if limit > len(m.pending) {
+		limit = len(m.pending)
+	}
+	rows := m.pending[:limit]
+	m.pending = m.pending[limit:]
+	return rows, nil
+}
+
+func (m *memOutboxStore) MarkSent(id int64) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.status[id] = "sent"
+	return nil
+}
+
+func (m *memOutboxStore) MarkFailed(id int64, reason string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.status[id] = "failed: " + reason
+	return nil
+}
+
+var _ = Describe("Outbox", func() {
+	var store *memOutboxStore
+
+	BeforeEach(func() {
+		store = &memOutboxStore{status: map[int64]string{}}
+		for i := int64(1); i <= 3; i++ {
+			n := apns.NewNotification()
+			n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+			store.pending = append(store.pending, apns.OutboxRow{ID: i, Notification: n})
+		}
+	})
+
+	Describe("#Poll", func() {
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		status := func() map[int64]string {
+			store.Lock()
+			defer store.Unlock()
+
+			m := map[int64]string{}
+			for id, s := range store.status {
+				m[id] = s
+			}
+			return m
+		}
+
+		It("should send a batch and mark it once delivered", func(d Done) {
+			mockDone := make(chan interface{})
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				cert, _ := tls.X509KeyPair([]byte(DummyCert), []byte(DummyKey))
+				c, _ := apns.NewClientWithConfig(s.Address(), cert, func(c *apns.Client) error {
+					c.Conn.Conf.InsecureSkipVerify = true
+					c.InFlightWindow = 200 * time.Millisecond
+					return nil
+				})
+				defer c.Close()
+
+				o := apns.NewOutbox(store, c)
+				o.BatchSize = 2
+
+				i, err := o.Poll()
+				Expect(err).To(BeNil())
+				Expect(i).To(Equal(2))
+
+				// Not until APNS has had the chance to reject them.
+				Consistently(status, 100*time.Millisecond).Should(BeEmpty())
+				Eventually(status).Should(Equal(map[int64]string{1: "sent", 2: "sent"}))
+
+				close(mockDone)
+				close(d)
+			})
+		})
+
+		It("should mark rows failed if they aren't sent", func() {
+			// The client never connects, so Close discards the batch.
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+
+			o := apns.NewOutbox(store, c)
+			o.BatchSize = 2
+			o.Poll()
+			c.Close()
+
+			Eventually(status).Should(Equal(map[int64]string{
+				1: "failed: " + apns.ErrClosed.Error(),
+				2: "failed: " + apns.ErrClosed.Error(),
+			}))
+		})
+
+		It("should mark the rest of the batch failed if the client refuses a row", func() {
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			c.Close()
+
+			o := apns.NewOutbox(store, c)
+			o.BatchSize = 2
+			i, err := o.Poll()
+			Expect(err).To(Equal(apns.ErrClosed))
+			Expect(i).To(Equal(2))
+
+			Expect(status()).To(Equal(map[int64]string{
+				1: "failed: " + apns.ErrClosed.Error(),
+				2: "failed: " + apns.ErrClosed.Error(),
+			}))
+		})
+	})
+
+	Describe("#Record", func() {
+		It("should mark the originating row failed", func() {
+			o := apns.NewOutbox(store, nil)
+
+			n := apns.NewNotification()
+			n.Metadata = map[string]string{apns.OutboxMetadataKey: "3"}
+			Expect(o.Record(apns.NotificationResult{Notif: n, Err: apns.Error{ErrStr: apns.ErrInvalidToken}})).To(BeNil())
+			Expect(store.status[3]).To(Equal("failed: " + apns.ErrInvalidToken))
+		})
+
+		It("should ignore other notifications", func() {
+			o := apns.NewOutbox(store, nil)
+			Expect(o.Record(apns.NotificationResult{Notif: apns.NewNotification()})).To(BeNil())
+			Expect(store.status).To(BeEmpty())
+		})
+	})
+})
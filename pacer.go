@@ -0,0 +1,70 @@
+package apns
+
+import (
+	"context"
+	"time"
+)
+
+// Pacer spreads a batch of Total sends evenly over Window, so recipients
+// opening the app don't all hit the sender's backend at once. Send i of the
+// batch is due at Window*i/Total after the first one.
+type Pacer struct {
+	Total  int
+	Window time.Duration
+
+	start time.Time
+	next  int
+}
+
+func NewPacer(total int, window time.Duration) *Pacer {
+	return &Pacer{Total: total, Window: window}
+}
+
+// Due returns when the next send is due.
+func (p *Pacer) Due() time.Time {
+	if p.start.IsZero() {
+		return time.Now()
+	}
+	if p.Total <= 0 {
+		return p.start
+	}
+	return p.start.Add(time.Duration(int64(p.Window) * int64(p.next) / int64(p.Total)))
+}
+
+// Wait blocks until the next send is due, or ctx is done.
+func (p *Pacer) Wait(ctx context.Context) error {
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+
+	if d := time.Until(p.Due()); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	p.next++
+	return ctx.Err()
+}
+
+// SendPaced sends ns spread evenly over window and returns how many were
+// sent. It stops early if ctx is done or Send fails.
+func (c *Client) SendPaced(ctx context.Context, ns []Notification, window time.Duration) (int, error) {
+	p := NewPacer(len(ns), window)
+
+	for i, n := range ns {
+		if err := p.Wait(ctx); err != nil {
+			return i, err
+		}
+		if err := c.Send(n); err != nil {
+			return i, err
+		}
+	}
+
+	return len(ns), nil
+}
@@ -0,0 +1,54 @@
+package apns_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Pacer", func() {
+	Describe("#Wait", func() {
+		It("should spread sends evenly over the window", func() {
+			p := apns.NewPacer(5, 100*time.Millisecond)
+
+			start := time.Now()
+			var at []time.Duration
+			for i := 0; i < 5; i++ {
+				Expect(p.Wait(context.Background())).To(BeNil())
+				at = append(at, time.Since(start))
+			}
+
+			Expect(at[0]).To(BeNumerically("<", 10*time.Millisecond))
+			for i := 1; i < 5; i++ {
+				Expect(at[i]).To(BeNumerically(">=", time.Duration(i)*20*time.Millisecond))
+			}
+			Expect(at[4]).To(BeNumerically("<", 100*time.Millisecond))
+		})
+
+		It("should stop when the context is done", func() {
+			p := apns.NewPacer(2, time.Hour)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			Expect(p.Wait(ctx)).To(BeNil())
+			Expect(p.Wait(ctx)).To(Equal(context.DeadlineExceeded))
+		})
+	})
+
+	Describe("Client#SendPaced", func() {
+		It("should report how many were sent before stopping", func() {
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			ns := []apns.Notification{apns.NewNotification(), apns.NewNotification(), apns.NewNotification()}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			sent, err := c.SendPaced(ctx, ns, time.Hour)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+			Expect(sent).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,27 @@
+package apns
+
+import "context"
+
+// NewPassUpdateNotification returns a Wallet pass update push: an empty
+// payload sent to a pass's push token, with the pass type identifier as
+// topic. Wallet then asks the pass's web service for the passes that
+// changed.
+func NewPassUpdateNotification(token, passTypeID string) Notification {
+	n := NewNotification()
+	n.DeviceToken = token
+	n.Topic = passTypeID
+	return n.WithPrecomputedPayload([]byte("{}"))
+}
+
+// SendPassUpdate tells Wallet on the device with the given pass push
+// token that passes of type passTypeID have changed. The client must
+// authenticate with that pass type's certificate.
+func (c *Client) SendPassUpdate(token, passTypeID string) error {
+	return c.Send(NewPassUpdateNotification(token, passTypeID))
+}
+
+// SendPassUpdate is like Client.SendPassUpdate, but waits for APNS's
+// answer.
+func (c *Client2) SendPassUpdate(ctx context.Context, token, passTypeID string) (Response, error) {
+	return c.SendSync(ctx, NewPassUpdateNotification(token, passTypeID))
+}
@@ -0,0 +1,38 @@
+package apns_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("PassKit", func() {
+	Describe(".NewPassUpdateNotification", func() {
+		It("should have an empty payload and the pass type as topic", func() {
+			n := apns.NewPassUpdateNotification("abcd", "pass.com.example.ticket")
+
+			Expect(n.DeviceToken).To(Equal("abcd"))
+			Expect(n.Topic).To(Equal("pass.com.example.ticket"))
+			Expect(n.ValidateLimits()).To(BeNil())
+		})
+	})
+
+	Describe("Client2#SendPassUpdate", func() {
+		It("should post an empty payload", func() {
+			s := newMockHTTP2Server()
+			defer s.Close()
+
+			r, err := newTestClient2(s).SendPassUpdate(context.Background(), "abcd", "pass.com.example.ticket")
+			Expect(err).To(BeNil())
+			Expect(r.OK()).To(BeTrue())
+
+			req := s.requests[0]
+			Expect(req.URL.Path).To(Equal("/3/device/abcd"))
+			Expect(req.Header.Get("apns-topic")).To(Equal("pass.com.example.ticket"))
+			Expect(req.Header.Get("apns-push-type")).To(BeEmpty())
+			Expect(s.bodies[0]).To(Equal("{}"))
+		})
+	})
+})
@@ -0,0 +1,47 @@
+package apns
+
+import "time"
+
+// DefaultInFlightWindow is how long after being written a notification is
+// considered unacknowledged, since APNS only ever reports failures.
+const DefaultInFlightWindow = time.Second
+
+// writeWindow tracks recent writes on a connection to cap how many
+// notifications are in flight at once.
+type writeWindow struct {
+	writes []time.Time
+}
+
+// expire forgets writes older than window.
+func (w *writeWindow) expire(now time.Time, window time.Duration) {
+	i := 0
+	for i < len(w.writes) && now.Sub(w.writes[i]) >= window {
+		i++
+	}
+	w.writes = w.writes[i:]
+}
+
+// wait blocks until fewer than max writes happened within window. A max of
+// zero never blocks.
+func (w *writeWindow) wait(max int, window time.Duration) {
+	if max <= 0 {
+		return
+	}
+
+	for {
+		now := time.Now()
+		w.expire(now, window)
+		if len(w.writes) < max {
+			return
+		}
+		time.Sleep(window - now.Sub(w.writes[0]))
+	}
+}
+
+func (w *writeWindow) add(t time.Time) {
+	w.writes = append(w.writes, t)
+}
+
+func (w *writeWindow) reset() {
+	w.writes = nil
+}
@@ -0,0 +1,66 @@
+package apns
+
+import (
+	"context"
+	"net/http"
+)
+
+// poolMember is one of the connections of Client2's pool.
+type poolMember struct {
+	client   *http.Client
+	inFlight int
+}
+
+// pooled reports whether SendSync spreads requests over a pool.
+func (c *Client2) pooled() bool {
+	return c.PoolSize > 1 || c.MaxStreams > 0
+}
+
+// acquirePool returns the pool member with the fewest requests in flight,
+// waiting while every one has MaxStreams, or until ctx is done. The first
+// member is HTTPClient; the others copy it with transports of their own.
+func (c *Client2) acquirePool(ctx context.Context) (*poolMember, error) {
+	for {
+		c.mu.Lock()
+		if c.pool == nil {
+			c.pool = []*poolMember{{client: c.HTTPClient}}
+			c.poolChanged = make(chan struct{})
+		}
+		for len(c.pool) < c.PoolSize {
+			client := *c.HTTPClient
+			if t, ok := client.Transport.(*http.Transport); ok {
+				client.Transport = t.Clone()
+			}
+			c.pool = append(c.pool, &poolMember{client: &client})
+		}
+
+		m := c.pool[0]
+		for _, o := range c.pool[1:] {
+			if o.inFlight < m.inFlight {
+				m = o
+			}
+		}
+		if c.MaxStreams <= 0 || m.inFlight < c.MaxStreams {
+			m.inFlight++
+			c.mu.Unlock()
+			return m, nil
+		}
+		changed := c.poolChanged
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (c *Client2) releasePool(m *poolMember) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m.inFlight--
+	close(c.poolChanged)
+	c.poolChanged = make(chan struct{})
+}
@@ -0,0 +1,124 @@
+package apns_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Client2 pool", func() {
+	var s *mockHTTP2Server
+
+	BeforeEach(func() {
+		s = newMockHTTP2Server()
+	})
+
+	AfterEach(func() {
+		s.Close()
+	})
+
+	// sendAll sends count notifications at once and waits for them.
+	sendAll := func(c *apns.Client2, count int) {
+		var wg sync.WaitGroup
+		for i := 0; i < count; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				n := apns.NewNotification()
+				n.DeviceToken = "abcd"
+				_, err := c.SendSync(context.Background(), n)
+				Expect(err).To(BeNil())
+			}()
+		}
+		wg.Wait()
+	}
+
+	It("should spread requests over PoolSize connections", func() {
+		c := newTestClient2(s)
+		c.PoolSize = 3
+		c.MaxStreams = 2
+
+		// Hold requests until all six are in, so none can reuse a
+		// connection that has gone idle.
+		arrived := make(chan struct{}, 6)
+		all := make(chan struct{})
+		go func() {
+			for i := 0; i < 6; i++ {
+				<-arrived
+			}
+			close(all)
+		}()
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			arrived <- struct{}{}
+			<-all
+		}
+
+		sendAll(c, 6)
+
+		addrs := map[string]int{}
+		for _, r := range s.requests {
+			addrs[r.RemoteAddr]++
+		}
+		Expect(addrs).To(HaveLen(3))
+		for _, n := range addrs {
+			Expect(n).To(Equal(2))
+		}
+	})
+
+	It("should cap the streams in flight per connection", func() {
+		c := newTestClient2(s)
+		c.MaxStreams = 1
+
+		var mu sync.Mutex
+		inFlight, most := 0, 0
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			inFlight++
+			if inFlight > most {
+				most = inFlight
+			}
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}
+
+		sendAll(c, 4)
+		Expect(most).To(Equal(1))
+	})
+
+	It("should stop waiting for a stream when the context is done", func() {
+		c := newTestClient2(s)
+		c.MaxStreams = 1
+
+		release := make(chan struct{})
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}
+		defer close(release)
+
+		n := apns.NewNotification()
+		n.DeviceToken = "abcd"
+		go c.SendSync(context.Background(), n)
+		Eventually(func() int {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return len(s.requests)
+		}).Should(Equal(1))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := c.SendSync(ctx, n)
+		Expect(err).To(Equal(context.DeadlineExceeded))
+	})
+})
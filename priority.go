@@ -0,0 +1,35 @@
+package apns
+
+import "fmt"
+
+// PriorityError is returned for priorities APNS doesn't accept.
+type PriorityError struct {
+	Priority int
+}
+
+func (e *PriorityError) Error() string {
+	return fmt.Sprintf("priority %d is not %d, %d or %d", e.Priority, PriorityLow, PriorityPowerConserve, PriorityImmediate)
+}
+
+// EffectivePriority returns the priority n is sent with. Unless Priority
+// is set, background pushes go out with PriorityPowerConserve, as Apple
+// requires, and everything else with PriorityImmediate.
+func (n Notification) EffectivePriority() int {
+	if n.Priority != 0 {
+		return n.Priority
+	}
+	if n.InferPushType() == PushTypeBackground {
+		return PriorityPowerConserve
+	}
+	return PriorityImmediate
+}
+
+// validatePriority returns a *PriorityError if n has a priority APNS
+// doesn't accept.
+func (n Notification) validatePriority() error {
+	switch n.Priority {
+	case 0, PriorityLow, PriorityPowerConserve, PriorityImmediate:
+		return nil
+	}
+	return &PriorityError{Priority: n.Priority}
+}
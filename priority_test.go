@@ -0,0 +1,39 @@
+package apns_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Priority", func() {
+	DescribeTable("#EffectivePriority",
+		func(build func(n *apns.Notification), want int) {
+			n := apns.NewNotification()
+			build(&n)
+			Expect(n.EffectivePriority()).To(Equal(want))
+		},
+		Entry("explicit", func(n *apns.Notification) { n.Priority = apns.PriorityLow }, apns.PriorityLow),
+		Entry("alert", func(n *apns.Notification) { n.Payload.APS.Alert.Body = "hi" }, apns.PriorityImmediate),
+		Entry("content-available", func(n *apns.Notification) { n.Payload.APS.ContentAvailable = 1 }, apns.PriorityPowerConserve),
+		Entry("background push type", func(n *apns.Notification) { n.PushType = apns.PushTypeBackground }, apns.PriorityPowerConserve),
+		Entry("voip", func(n *apns.Notification) { n.PushType = apns.PushTypeVoIP }, apns.PriorityImmediate),
+	)
+
+	It("should reject priorities APNS doesn't accept", func() {
+		n := apns.NewNotification()
+		n.Priority = 7
+
+		Expect(n.ValidatePushType()).To(Equal(&apns.PriorityError{Priority: 7}))
+	})
+
+	It("should require power-conserving priority for background pushes", func() {
+		n := apns.NewNotification()
+		n.PushType = apns.PushTypeBackground
+		n.Payload.APS.ContentAvailable = 1
+		n.Priority = apns.PriorityImmediate
+
+		Expect(n.ValidatePushType()).To(MatchError("background push: priority must be 5"))
+	})
+})
@@ -0,0 +1,132 @@
+package apns
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+)
+
+// PubSubMetadataKey is the Notification.Metadata key holding the ID of the
+// Pub/Sub message a notification was built from.
+const PubSubMetadataKey = "pubsub-message-id"
+
+// PubSubMessage is the subset of a Pub/Sub message PubSubConsumer needs. The
+// data holds the JSON payload; attributes are mapped as for SQS.
+type PubSubMessage struct {
+	ID          string
+	Data        []byte
+	Attributes  map[string]string
+	OrderingKey string
+	Ack         func()
+	Nack        func()
+}
+
+// PubSubSubscription is the narrow slice of the Pub/Sub API used by
+// PubSubConsumer. Receive blocks, calling fn for each message, until ctx is
+// done.
+type PubSubSubscription interface {
+	Receive(ctx context.Context, fn func(context.Context, PubSubMessage)) error
+}
+
+// PubSubConsumer receives push requests from a Pub/Sub subscription and
+// sends them through one of several clients. Messages sharing an ordering
+// key always go through the same client, so notifications that rely on
+// ordering (for instance successive updates under one collapse ID) stay on a
+// single connection. A message is acked once its notification is written and
+// nacked if it fails.
+type PubSubConsumer struct {
+	Subscription PubSubSubscription
+	Clients      []*Client
+
+	mu      sync.Mutex
+	pending map[string]PubSubMessage
+	next    int
+}
+
+func NewPubSubConsumer(sub PubSubSubscription, clients ...*Client) *PubSubConsumer {
+	p := &PubSubConsumer{
+		Subscription: sub,
+		Clients:      clients,
+		pending:      map[string]PubSubMessage{},
+	}
+
+	for _, c := range clients {
+		c.OnAfterSend(func(n Notification) {
+			if id, ok := n.Metadata[PubSubMetadataKey]; ok {
+				go p.settle(id, true)
+			}
+		})
+	}
+
+	return p
+}
+
+// Receive consumes the subscription until ctx is done. It fails right away
+// if there are no Clients to send through.
+func (p *PubSubConsumer) Receive(ctx context.Context) error {
+	if len(p.Clients) == 0 {
+		return errors.New("apns: PubSubConsumer has no clients")
+	}
+
+	return p.Subscription.Receive(ctx, func(ctx context.Context, m PubSubMessage) {
+		n, err := notificationFromMessage(m.Data, m.Attributes, PubSubMetadataKey, m.ID)
+		if err != nil {
+			m.Nack()
+			return
+		}
+
+		p.mu.Lock()
+		p.pending[m.ID] = m
+		p.mu.Unlock()
+
+		if err := p.shard(m.OrderingKey).Send(n); err != nil {
+			p.mu.Lock()
+			delete(p.pending, m.ID)
+			p.mu.Unlock()
+
+			m.Nack()
+		}
+	})
+}
+
+// shard picks the client for an ordering key. Messages without one are
+// spread round-robin.
+func (p *PubSubConsumer) shard(key string) *Client {
+	if key == "" {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.next = (p.next + 1) % len(p.Clients)
+		return p.Clients[p.next]
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return p.Clients[h.Sum32()%uint32(len(p.Clients))]
+}
+
+// Record nacks the message behind a failed notification so Pub/Sub
+// redelivers it.
+func (p *PubSubConsumer) Record(r NotificationResult) {
+	if id, ok := r.Notif.Metadata[PubSubMetadataKey]; ok {
+		p.settle(id, false)
+	}
+}
+
+func (p *PubSubConsumer) settle(id string, ack bool) {
+	p.mu.Lock()
+	m, ok := p.pending[id]
+	delete(p.pending, id)
+	p.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if ack {
+		m.Ack()
+	} else {
+		m.Nack()
+	}
+}
@@ -0,0 +1,102 @@
+package apns_test
+
+import (
+	"context"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+type mockSubscription struct {
+	msgs []apns.PubSubMessage
+}
+
+func (s mockSubscription) Receive(ctx context.Context, fn func(context.Context, apns.PubSubMessage)) error {
+	for _, m := range s.msgs {
+		fn(ctx, m)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type settlements struct {
+	sync.Mutex
+	acked, nacked []string
+}
+
+func (s *settlements) message(id string, data string) apns.PubSubMessage {
+	return apns.PubSubMessage{
+		ID:   id,
+		Data: []byte(data),
+		Attributes: map[string]string{
+			apns.MessageAttrDeviceToken: "9999999999999999999999999999999999999999999999999999999999999999",
+		},
+		Ack: func() {
+			s.Lock()
+			defer s.Unlock()
+			s.acked = append(s.acked, id)
+		},
+		Nack: func() {
+			s.Lock()
+			defer s.Unlock()
+			s.nacked = append(s.nacked, id)
+		},
+	}
+}
+
+func (s *settlements) Acked() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string{}, s.acked...)
+}
+
+func (s *settlements) Nacked() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string{}, s.nacked...)
+}
+
+var _ = Describe("PubSubConsumer", func() {
+	as := [][]serverAction{
+		[]serverAction{
+			serverAction{action: readAction, data: []byte{}},
+		},
+	}
+
+	It("should ack written messages and nack undecodable ones", func(d Done) {
+		mockDone := make(chan interface{})
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+
+			st := &settlements{}
+			sub := mockSubscription{msgs: []apns.PubSubMessage{
+				st.message("good", `{"aps":{}}`),
+				st.message("bad", `not json`),
+			}}
+
+			p := apns.NewPubSubConsumer(sub, c)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go p.Receive(ctx)
+
+			Eventually(st.Acked).Should(Equal([]string{"good"}))
+			Eventually(st.Nacked).Should(Equal([]string{"bad"}))
+			cancel()
+
+			close(mockDone)
+			close(d)
+		})
+	})
+
+	It("should fail without clients", func() {
+		var got settlements
+		p := apns.NewPubSubConsumer(mockSubscription{msgs: []apns.PubSubMessage{got.message("1", `{"aps":{}}`)}})
+
+		Expect(p.Receive(context.Background())).NotTo(BeNil())
+		Expect(got.Acked()).To(BeEmpty())
+		Expect(got.Nacked()).To(BeEmpty())
+	})
+})
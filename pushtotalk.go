@@ -0,0 +1,16 @@
+package apns
+
+// NewPushToTalkNotification returns a Push to Talk push for the app with
+// the given bundle ID, which wakes it to report a remote participant. token
+// is the PTT channel's push token, not the app's device token. The topic
+// gets the .voip-ptt suffix, priority is PriorityImmediate and the
+// expiration is sent as 0, as Apple requires; custom keys carry the app's
+// data, since the aps dictionary must stay empty.
+func NewPushToTalkNotification(token, bundleID string) Notification {
+	n := NewNotification()
+	n.DeviceToken = token
+	n.PushType = PushTypePushToTalk
+	n.Topic = bundleID + TopicSuffix(PushTypePushToTalk)
+	n.Priority = PriorityImmediate
+	return n.ExpireImmediately()
+}
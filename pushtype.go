@@ -0,0 +1,268 @@
+package apns
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TopicSuffix returns what Apple expects appended to an app's bundle ID in
+// the topic of pushes of type t.
+func TopicSuffix(t PushType) string {
+	switch t {
+	case PushTypeVoIP:
+		return ".voip"
+	case PushTypeLocation:
+		return ".location-query"
+	case PushTypePushToTalk:
+		return ".voip-ptt"
+	case PushTypeLiveActivity:
+		return ".push-type.liveactivity"
+	case PushTypeComplication:
+		return ".complication"
+	case PushTypeFileProvider:
+		return ".pushkit.fileprovider"
+	}
+	return ""
+}
+
+// PushTypeError is returned when a notification breaks the rules of its
+// push type.
+type PushTypeError struct {
+	PushType PushType
+	Reason   string
+}
+
+func (e *PushTypeError) Error() string {
+	return fmt.Sprintf("%s push: %s", e.PushType, e.Reason)
+}
+
+// ValidatePushType returns a *PushTypeError if n doesn't follow the rules
+// Apple sets for its push type, including the topic suffix and whether
+// the payload has the shape the type implies: alerts need something to
+// show and background pushes must only wake the app. A *PriorityError is
+// returned for priorities APNS doesn't accept at all.
+func (n Notification) ValidatePushType() error {
+	if err := n.validatePriority(); err != nil {
+		return err
+	}
+	if suffix := TopicSuffix(n.PushType); n.Topic != "" && !strings.HasSuffix(n.Topic, suffix) {
+		return &PushTypeError{PushType: n.PushType, Reason: "topic must end with " + suffix}
+	}
+
+	switch n.PushType {
+	case PushTypeAlert:
+		aps, err := n.apsFields()
+		if err != nil {
+			return err
+		}
+		if !aps.has("alert") && !aps.has("badge") && !aps.has("sound") {
+			return &PushTypeError{PushType: n.PushType, Reason: "needs an alert, badge or sound"}
+		}
+		if isWebsitePushID(n.Topic) {
+			return n.validateWebsite(aps)
+		}
+
+	case PushTypeBackground:
+		aps, err := n.apsFields()
+		if err != nil {
+			return err
+		}
+		if string(aps["content-available"]) != "1" {
+			return &PushTypeError{PushType: n.PushType, Reason: "needs content-available set to 1"}
+		}
+		for _, k := range []string{"alert", "badge", "sound", "mutable-content"} {
+			if aps.has(k) {
+				return &PushTypeError{PushType: n.PushType, Reason: fmt.Sprintf("must not have %s", k)}
+			}
+		}
+		if n.Priority != 0 && n.Priority != PriorityPowerConserve {
+			return &PushTypeError{PushType: n.PushType, Reason: fmt.Sprintf("priority must be %d", PriorityPowerConserve)}
+		}
+
+	case PushTypeLiveActivity:
+		aps, err := n.apsFields()
+		if err != nil {
+			return err
+		}
+		if !aps.has("content-state") {
+			return &PushTypeError{PushType: n.PushType, Reason: "needs a content-state"}
+		}
+		switch string(aps["event"]) {
+		case "", `"update"`, `"end"`:
+		case `"start"`:
+			if !aps.has("attributes-type") || !aps.has("attributes") {
+				return &PushTypeError{PushType: n.PushType, Reason: "start event needs attributes-type and attributes"}
+			}
+		default:
+			return &PushTypeError{PushType: n.PushType, Reason: fmt.Sprintf("unknown event %s", aps["event"])}
+		}
+
+	case PushTypeComplication:
+		aps, err := n.apsFields()
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"alert", "badge", "sound"} {
+			if aps.has(k) {
+				return &PushTypeError{PushType: n.PushType, Reason: fmt.Sprintf("must not have %s", k)}
+			}
+		}
+
+	case PushTypeFileProvider:
+		raw, err := n.payloadFields()
+		if err != nil {
+			return err
+		}
+		if !raw.has("container-identifier") {
+			return &PushTypeError{PushType: n.PushType, Reason: "needs a container-identifier"}
+		}
+
+		return n.validateEmptyAPS()
+
+	case PushTypeVoIP:
+		if n.Priority != 0 && n.Priority != PriorityImmediate {
+			return &PushTypeError{PushType: n.PushType, Reason: fmt.Sprintf("priority must be %d", PriorityImmediate)}
+		}
+
+	case PushTypeLocation:
+		if n.Priority != 0 && n.Priority != PriorityImmediate && n.Priority != PriorityPowerConserve {
+			return &PushTypeError{PushType: n.PushType, Reason: fmt.Sprintf("priority must be %d or %d", PriorityImmediate, PriorityPowerConserve)}
+		}
+
+		return n.validateEmptyAPS()
+
+	case PushTypeMDM:
+		raw, err := n.payloadFields()
+		if err != nil {
+			return err
+		}
+		if _, ok := raw["mdm"]; !ok || len(raw) != 1 {
+			return &PushTypeError{PushType: n.PushType, Reason: "payload must only have mdm"}
+		}
+
+	case PushTypePushToTalk:
+		if n.Priority != 0 && n.Priority != PriorityImmediate {
+			return &PushTypeError{PushType: n.PushType, Reason: fmt.Sprintf("priority must be %d", PriorityImmediate)}
+		}
+		if n.expiry() != 0 {
+			return &PushTypeError{PushType: n.PushType, Reason: "must not expire"}
+		}
+
+		return n.validateEmptyAPS()
+	}
+
+	return nil
+}
+
+func (n Notification) validateEmptyAPS() error {
+	empty, err := n.hasEmptyAPS()
+	if err != nil {
+		return err
+	}
+	if !empty {
+		return &PushTypeError{PushType: n.PushType, Reason: "aps dictionary must be empty"}
+	}
+	return nil
+}
+
+// InferPushType guesses the push type of n from its payload when none is
+// set: alert if it has an alert, badge or sound, background if it only has
+// content-available and mdm if it has a PushMagic. It returns an empty
+// type otherwise, as other push types can't be told apart by payload.
+func (n Notification) InferPushType() PushType {
+	if n.PushType != "" {
+		return n.PushType
+	}
+	if n.payload == nil && n.Payload != nil {
+		return n.Payload.pushType()
+	}
+
+	raw, err := n.payloadFields()
+	if err != nil {
+		return ""
+	}
+	if raw.has("mdm") {
+		return PushTypeMDM
+	}
+
+	aps, err := n.apsFields()
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case aps.has("alert") || aps.has("badge") || aps.has("sound"):
+		return PushTypeAlert
+	case string(aps["content-available"]) == "1":
+		return PushTypeBackground
+	}
+	return ""
+}
+
+// pushType infers the push type from p's fields as InferPushType does from
+// an encoded payload, but without encoding p, since every frame needs it
+// for its priority.
+func (p *Payload) pushType() PushType {
+	if _, ok := p.customValues["aps"]; ok {
+		return "" // p can't be encoded
+	}
+
+	aps := p.APS
+	switch {
+	case p.MDM != "":
+		return PushTypeMDM
+	case !aps.Alert.isZero() || aps.Badge.IsSet || aps.Sound != "":
+		return PushTypeAlert
+	case aps.ContentAvailable == 1:
+		return PushTypeBackground
+	}
+	return ""
+}
+
+// apsDict holds the keys of a payload dictionary, usually aps.
+type apsDict map[string]json.RawMessage
+
+func (d apsDict) has(k string) bool {
+	v, ok := d[k]
+	return ok && string(v) != "null"
+}
+
+// payloadFields returns the top-level keys of the payload as sent.
+func (n Notification) payloadFields() (apsDict, error) {
+	j, err := n.payloadBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var raw apsDict
+	if err := json.Unmarshal(j, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// apsFields returns the aps dictionary of the payload as sent.
+func (n Notification) apsFields() (apsDict, error) {
+	raw, err := n.payloadFields()
+	if err != nil {
+		return nil, err
+	}
+
+	var aps apsDict
+	if v, ok := raw["aps"]; ok && string(v) != "null" {
+		if err := json.Unmarshal(v, &aps); err != nil {
+			return nil, err
+		}
+	}
+	return aps, nil
+}
+
+// hasEmptyAPS reports whether the payload as sent has no aps keys.
+func (n Notification) hasEmptyAPS() (bool, error) {
+	aps, err := n.apsFields()
+	if err != nil {
+		return false, err
+	}
+	return len(aps) == 0, nil
+}
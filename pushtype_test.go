@@ -0,0 +1,425 @@
+package apns_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Push types", func() {
+	Describe(".TopicSuffix", func() {
+		It("should return Apple's suffixes", func() {
+			Expect(apns.TopicSuffix(apns.PushTypeAlert)).To(Equal(""))
+			Expect(apns.TopicSuffix(apns.PushTypeVoIP)).To(Equal(".voip"))
+			Expect(apns.TopicSuffix(apns.PushTypeComplication)).To(Equal(".complication"))
+			Expect(apns.TopicSuffix(apns.PushTypeFileProvider)).To(Equal(".pushkit.fileprovider"))
+			Expect(apns.TopicSuffix(apns.PushTypeLocation)).To(Equal(".location-query"))
+			Expect(apns.TopicSuffix(apns.PushTypePushToTalk)).To(Equal(".voip-ptt"))
+			Expect(apns.TopicSuffix(apns.PushTypeLiveActivity)).To(Equal(".push-type.liveactivity"))
+		})
+	})
+
+	Describe("alert", func() {
+		It("should accept an alert", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypeAlert
+			n.Payload.APS.Alert.Body = "Hi"
+
+			Expect(n.ValidatePushType()).To(BeNil())
+		})
+
+		It("should reject payloads with nothing to show", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypeAlert
+			n.Payload.APS.ContentAvailable = 1
+
+			Expect(n.ValidatePushType()).To(MatchError("alert push: needs an alert, badge or sound"))
+		})
+	})
+
+	Describe("background", func() {
+		It("should accept content-available", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypeBackground
+			n.Payload.APS.ContentAvailable = 1
+			n.Payload.SetCustomValue("sync", "inbox")
+
+			Expect(n.ValidatePushType()).To(BeNil())
+		})
+
+		It("should require content-available", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypeBackground
+
+			Expect(n.ValidatePushType()).To(MatchError("background push: needs content-available set to 1"))
+		})
+
+		It("should reject user-visible keys", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypeBackground
+			n.Payload.APS.ContentAvailable = 1
+			n.Payload.APS.Sound = "ping.aiff"
+
+			Expect(n.ValidatePushType()).To(MatchError("background push: must not have sound"))
+		})
+
+		It("should check precomputed payloads", func() {
+			n := apns.Notification{PushType: apns.PushTypeBackground}.WithPrecomputedPayload([]byte(`{"aps":{"content-available":1,"badge":3}}`))
+
+			Expect(n.ValidatePushType()).To(MatchError("background push: must not have badge"))
+		})
+
+		It("should build background pushes", func() {
+			n := apns.NewBackgroundNotification("abcd", "com.example.app")
+			n.Payload.SetCustomValue("sync", "inbox")
+
+			Expect(n.Topic).To(Equal("com.example.app"))
+			Expect(n.PushType).To(Equal(apns.PushTypeBackground))
+			Expect(n.Priority).To(Equal(apns.PriorityPowerConserve))
+			Expect(n.ValidatePushType()).To(BeNil())
+
+			b, err := json.Marshal(n.Payload)
+			Expect(err).To(BeNil())
+			Expect(b).To(MatchJSON(`{"aps":{"content-available":1},"sync":"inbox"}`))
+		})
+
+		It("should reject payloads Apple would throttle", func() {
+			n := apns.NewBackgroundNotification("abcd", "com.example.app")
+			n.Priority = apns.PriorityImmediate
+			Expect(n.ValidatePushType()).To(MatchError("background push: priority must be 5"))
+
+			n = apns.NewBackgroundNotification("abcd", "com.example.app")
+			n.Payload.APS.MutableContent = 1
+			Expect(n.ValidatePushType()).To(MatchError("background push: must not have mutable-content"))
+		})
+	})
+
+	Describe("liveactivity", func() {
+		It("should require a content-state", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypeLiveActivity
+
+			Expect(n.ValidatePushType()).To(MatchError("liveactivity push: needs a content-state"))
+		})
+
+		It("should require attributes to start an activity", func() {
+			n := apns.NewLiveActivityNotification("abcd", "com.example.app", apns.LiveActivityStart)
+			n.Payload.APS.ContentState = []byte(`{}`)
+
+			Expect(n.ValidatePushType()).To(MatchError("liveactivity push: start event needs attributes-type and attributes"))
+
+			n.Payload.APS.AttributesType = "MatchAttributes"
+			n.Payload.APS.Attributes = []byte(`{"home":"A"}`)
+			Expect(n.ValidatePushType()).To(BeNil())
+		})
+
+		It("should reject unknown events", func() {
+			n := apns.NewLiveActivityNotification("abcd", "com.example.app", "pause")
+			n.Payload.APS.ContentState = []byte(`{}`)
+
+			Expect(n.ValidatePushType()).To(MatchError(`liveactivity push: unknown event "pause"`))
+		})
+	})
+
+	Describe("voip", func() {
+		It("should build valid VoIP pushes", func() {
+			n := apns.NewVoIPNotification("abcd", "com.example.app")
+			n.Payload.SetCustomValue("call", strings.Repeat("x", 5000))
+
+			Expect(n.Topic).To(Equal("com.example.app.voip"))
+			Expect(n.PushType).To(Equal(apns.PushTypeVoIP))
+			Expect(n.Priority).To(Equal(apns.PriorityImmediate))
+			Expect(n.ValidatePushType()).To(BeNil())
+			Expect(n.ValidateLimits()).To(BeNil())
+		})
+
+		It("should reject other priorities", func() {
+			n := apns.NewVoIPNotification("abcd", "com.example.app")
+			n.Priority = apns.PriorityPowerConserve
+
+			Expect(n.ValidatePushType()).To(MatchError("voip push: priority must be 10"))
+		})
+
+		It("should reject topics without the .voip suffix", func() {
+			n := apns.NewVoIPNotification("abcd", "com.example.app")
+			n.Topic = "com.example.app"
+
+			Expect(n.ValidatePushType()).To(MatchError("voip push: topic must end with .voip"))
+		})
+
+		It("should reject payloads over 5KB", func() {
+			n := apns.NewVoIPNotification("abcd", "com.example.app")
+			n.Payload.SetCustomValue("call", strings.Repeat("x", 5200))
+
+			Expect(n.ValidateLimits()).To(HaveOccurred())
+		})
+	})
+
+	Describe("complication", func() {
+		It("should build complication pushes", func() {
+			n := apns.NewComplicationNotification("abcd", "com.example.watchkitapp")
+			n.Payload.SetCustomValue("score", "3-1")
+
+			Expect(n.Topic).To(Equal("com.example.watchkitapp.complication"))
+			Expect(n.PushType).To(Equal(apns.PushTypeComplication))
+			Expect(n.ValidatePushType()).To(BeNil())
+		})
+
+		It("should reject alerts", func() {
+			n := apns.NewComplicationNotification("abcd", "com.example.watchkitapp")
+			n.Payload.APS.Alert.Body = "Goal!"
+
+			Expect(n.ValidatePushType()).To(MatchError("complication push: must not have alert"))
+		})
+
+		It("should reject topics without the .complication suffix", func() {
+			n := apns.NewComplicationNotification("abcd", "com.example.watchkitapp")
+			n.Topic = "com.example.watchkitapp"
+
+			Expect(n.ValidatePushType()).To(MatchError("complication push: topic must end with .complication"))
+		})
+	})
+
+	Describe("fileprovider", func() {
+		It("should build File Provider pushes", func() {
+			n := apns.NewFileProviderNotification("abcd", "com.example.app", apns.FileProviderRootContainer, "docs")
+
+			Expect(n.Topic).To(Equal("com.example.app.pushkit.fileprovider"))
+			Expect(n.ValidatePushType()).To(BeNil())
+
+			b, _ := n.Payload.MarshalJSON()
+			Expect(b).To(MatchJSON(`{"aps":{},"container-identifier":"NSFileProviderRootContainerItemIdentifier","domain":"docs"}`))
+		})
+
+		It("should need a container identifier", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypeFileProvider
+
+			Expect(n.ValidatePushType()).To(MatchError("fileprovider push: needs a container-identifier"))
+		})
+
+		It("should reject aps keys", func() {
+			n := apns.NewFileProviderNotification("abcd", "com.example.app", apns.FileProviderRootContainer, "")
+			n.Payload.APS.Badge.Set(1)
+
+			Expect(n.ValidatePushType()).To(MatchError("fileprovider push: aps dictionary must be empty"))
+		})
+	})
+
+	Describe("#InferPushType", func() {
+		It("should keep an explicit type", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypeVoIP
+			n.Payload.APS.Alert.Body = "Hi"
+
+			Expect(n.InferPushType()).To(Equal(apns.PushTypeVoIP))
+		})
+
+		It("should infer alerts and background pushes", func() {
+			n := apns.NewNotification()
+			n.Payload.APS.Badge.Set(0)
+			Expect(n.InferPushType()).To(Equal(apns.PushTypeAlert))
+
+			n = apns.NewNotification()
+			n.Payload.APS.ContentAvailable = 1
+			Expect(n.InferPushType()).To(Equal(apns.PushTypeBackground))
+
+			Expect(apns.NewNotification().InferPushType()).To(BeEmpty())
+		})
+
+		It("should infer mdm pushes from a precomputed payload", func() {
+			n := apns.Notification{}.WithPrecomputedPayload([]byte(`{"mdm":"5A1F"}`))
+			Expect(n.InferPushType()).To(Equal(apns.PushTypeMDM))
+		})
+
+		It("should infer the same from payload fields as from the encoded payload", func() {
+			payloads := []func(p *apns.Payload){
+				func(p *apns.Payload) {},
+				func(p *apns.Payload) { p.APS.Alert.Body = "Hi" },
+				func(p *apns.Payload) { p.APS.Alert.Title = "Hi" },
+				func(p *apns.Payload) { p.APS.Sound = "default" },
+				func(p *apns.Payload) { p.APS.ContentAvailable = 1 },
+				func(p *apns.Payload) { p.APS.ContentAvailable = 1; p.APS.Badge.Set(1) },
+				func(p *apns.Payload) { p.APS.MutableContent = 1 },
+				func(p *apns.Payload) { p.SetCustomValue("acme", 1) },
+			}
+
+			for _, set := range payloads {
+				n := apns.NewNotification()
+				set(n.Payload)
+				b, err := json.Marshal(n.Payload)
+				Expect(err).To(BeNil())
+
+				Expect(n.InferPushType()).To(Equal(apns.NewNotification().WithPrecomputedPayload(b).InferPushType()), string(b))
+			}
+		})
+	})
+
+	Describe("location", func() {
+		It("should accept an empty payload", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypeLocation
+
+			Expect(n.ValidatePushType()).To(BeNil())
+		})
+
+		It("should reject aps keys", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypeLocation
+			n.Payload.APS.Alert.Body = "Where are you?"
+
+			Expect(n.ValidatePushType()).To(MatchError("location push: aps dictionary must be empty"))
+		})
+
+		It("should build location queries", func() {
+			n := apns.NewLocationNotification("abcd", "com.example.app")
+
+			Expect(n.Topic).To(Equal("com.example.app.location-query"))
+			Expect(n.EffectivePriority()).To(Equal(apns.PriorityImmediate))
+			Expect(n.ValidatePushType()).To(BeNil())
+
+			n.Topic = "com.example.app"
+			Expect(n.ValidatePushType()).To(MatchError("location push: topic must end with .location-query"))
+		})
+
+		It("should reject other priorities", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypeLocation
+			n.Priority = 1
+
+			Expect(n.ValidatePushType()).To(BeAssignableToTypeOf(&apns.PushTypeError{}))
+		})
+
+		It("should fail pushes over the hourly limit", func(d Done) {
+			mockDone := make(chan interface{})
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+				},
+			}
+
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+
+				for i := 0; i <= apns.MaxLocationPushesPerHour; i++ {
+					n := apns.NewNotification()
+					n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+					n.PushType = apns.PushTypeLocation
+					c.Send(n)
+				}
+
+				f := <-c.FailedNotifs
+				Expect(f.Err.Error()).To(Equal("location pushes per hour 11 exceeds 10"))
+
+				close(mockDone)
+				close(d)
+			})
+		})
+
+		It("should not count resends against the hourly limit", func(d Done) {
+			mockDone := make(chan interface{})
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}, cb: func(a serverAction) {
+						// Let the client write them all.
+						time.Sleep(200 * time.Millisecond)
+					}},
+					// Fail the first, so the rest are resent.
+					serverAction{action: writeAction, data: []byte{8, 1, 0, 0, 0, 1}},
+					serverAction{action: closeAction},
+				},
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+				},
+			}
+
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				sub := c.Subscribe(2*apns.MaxLocationPushesPerHour, apns.DropNewest)
+
+				throttled := func() bool {
+					for {
+						select {
+						case r := <-sub.Results:
+							var le *apns.LimitError
+							if errors.As(r.Err, &le) {
+								return true
+							}
+						default:
+							return false
+						}
+					}
+				}
+
+				for i := 0; i < apns.MaxLocationPushesPerHour; i++ {
+					n := apns.NewNotification()
+					n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+					n.Identifier = uint32(i + 1)
+					n.PushType = apns.PushTypeLocation
+					c.Send(n)
+				}
+
+				f := <-c.FailedNotifs
+				Expect(f.Err.ErrStr).To(Equal(apns.ErrProcessing))
+				Consistently(throttled, 300*time.Millisecond).Should(BeFalse())
+
+				close(mockDone)
+				close(d)
+			})
+		})
+	})
+
+	Describe("pushtotalk", func() {
+		It("should accept custom keys", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypePushToTalk
+			n.Priority = apns.PriorityImmediate
+			n.Payload.SetCustomValue("activeSpeaker", "Alex")
+
+			Expect(n.ValidatePushType()).To(BeNil())
+		})
+
+		It("should reject an expiration", func() {
+			t := time.Now().Add(time.Minute)
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypePushToTalk
+			n.Expiration = &t
+
+			Expect(n.ValidatePushType()).To(MatchError("pushtotalk push: must not expire"))
+		})
+
+		It("should reject power-conserving priority", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypePushToTalk
+			n.Priority = apns.PriorityPowerConserve
+
+			Expect(n.ValidatePushType()).To(MatchError("pushtotalk push: priority must be 10"))
+		})
+
+		It("should reject aps keys", func() {
+			n := apns.NewNotification()
+			n.PushType = apns.PushTypePushToTalk
+			n.Payload.APS.Sound = "default"
+
+			Expect(n.ValidatePushType()).To(MatchError("pushtotalk push: aps dictionary must be empty"))
+		})
+
+		It("should build Push to Talk pushes", func() {
+			n := apns.NewPushToTalkNotification("abcd", "com.example.app")
+			n.Payload.SetCustomValue("activeSpeaker", "Alex")
+
+			Expect(n.Topic).To(Equal("com.example.app.voip-ptt"))
+			Expect(n.PushType).To(Equal(apns.PushTypePushToTalk))
+			Expect(n.Priority).To(Equal(apns.PriorityImmediate))
+			Expect(n.ExpirationPolicy).To(Equal(apns.ExpirationImmediate))
+			Expect(n.ValidatePushType()).To(BeNil())
+		})
+	})
+})
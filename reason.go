@@ -0,0 +1,117 @@
+package apns
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The reasons the HTTP/2 provider API gives for rejecting a request, for
+// use with errors.Is on the errors returned by Response.Err. They're
+// prefixed with ErrReason to keep them apart from the binary protocol's
+// error strings, some of which share a name:
+//
+//	r, err := client.SendSync(ctx, n)
+//	if err == nil {
+//		err = r.Err()
+//	}
+//	if errors.Is(err, apns.ErrReasonUnregistered) {
+//		forget(n.DeviceToken)
+//	}
+var (
+	ErrReasonBadCollapseID               = errors.New("apns: BadCollapseId")
+	ErrReasonBadDeviceToken              = errors.New("apns: BadDeviceToken")
+	ErrReasonBadExpirationDate           = errors.New("apns: BadExpirationDate")
+	ErrReasonBadMessageID                = errors.New("apns: BadMessageId")
+	ErrReasonBadPriority                 = errors.New("apns: BadPriority")
+	ErrReasonBadTopic                    = errors.New("apns: BadTopic")
+	ErrReasonDeviceTokenNotForTopic      = errors.New("apns: DeviceTokenNotForTopic")
+	ErrReasonDuplicateHeaders            = errors.New("apns: DuplicateHeaders")
+	ErrReasonIdleTimeout                 = errors.New("apns: IdleTimeout")
+	ErrReasonInvalidPushType             = errors.New("apns: InvalidPushType")
+	ErrReasonMissingDeviceToken          = errors.New("apns: MissingDeviceToken")
+	ErrReasonMissingTopic                = errors.New("apns: MissingTopic")
+	ErrReasonPayloadEmpty                = errors.New("apns: PayloadEmpty")
+	ErrReasonTopicDisallowed             = errors.New("apns: TopicDisallowed")
+	ErrReasonBadCertificate              = errors.New("apns: BadCertificate")
+	ErrReasonBadCertificateEnvironment   = errors.New("apns: BadCertificateEnvironment")
+	ErrReasonExpiredProviderToken        = errors.New("apns: ExpiredProviderToken")
+	ErrReasonForbidden                   = errors.New("apns: Forbidden")
+	ErrReasonInvalidProviderToken        = errors.New("apns: InvalidProviderToken")
+	ErrReasonMissingProviderToken        = errors.New("apns: MissingProviderToken")
+	ErrReasonBadPath                     = errors.New("apns: BadPath")
+	ErrReasonMethodNotAllowed            = errors.New("apns: MethodNotAllowed")
+	ErrReasonExpiredToken                = errors.New("apns: ExpiredToken")
+	ErrReasonUnregistered                = errors.New("apns: Unregistered")
+	ErrReasonPayloadTooLarge             = errors.New("apns: PayloadTooLarge")
+	ErrReasonTooManyProviderTokenUpdates = errors.New("apns: TooManyProviderTokenUpdates")
+	ErrReasonTooManyRequests             = errors.New("apns: TooManyRequests")
+	ErrReasonInternalServerError         = errors.New("apns: InternalServerError")
+	ErrReasonServiceUnavailable          = errors.New("apns: ServiceUnavailable")
+	ErrReasonShutdown                    = errors.New("apns: Shutdown")
+)
+
+var reasonErrors = map[string]error{
+	"BadCollapseId":               ErrReasonBadCollapseID,
+	"BadDeviceToken":              ErrReasonBadDeviceToken,
+	"BadExpirationDate":           ErrReasonBadExpirationDate,
+	"BadMessageId":                ErrReasonBadMessageID,
+	"BadPriority":                 ErrReasonBadPriority,
+	"BadTopic":                    ErrReasonBadTopic,
+	"DeviceTokenNotForTopic":      ErrReasonDeviceTokenNotForTopic,
+	"DuplicateHeaders":            ErrReasonDuplicateHeaders,
+	"IdleTimeout":                 ErrReasonIdleTimeout,
+	"InvalidPushType":             ErrReasonInvalidPushType,
+	"MissingDeviceToken":          ErrReasonMissingDeviceToken,
+	"MissingTopic":                ErrReasonMissingTopic,
+	"PayloadEmpty":                ErrReasonPayloadEmpty,
+	"TopicDisallowed":             ErrReasonTopicDisallowed,
+	"BadCertificate":              ErrReasonBadCertificate,
+	"BadCertificateEnvironment":   ErrReasonBadCertificateEnvironment,
+	"ExpiredProviderToken":        ErrReasonExpiredProviderToken,
+	"Forbidden":                   ErrReasonForbidden,
+	"InvalidProviderToken":        ErrReasonInvalidProviderToken,
+	"MissingProviderToken":        ErrReasonMissingProviderToken,
+	"BadPath":                     ErrReasonBadPath,
+	"MethodNotAllowed":            ErrReasonMethodNotAllowed,
+	"ExpiredToken":                ErrReasonExpiredToken,
+	"Unregistered":                ErrReasonUnregistered,
+	"PayloadTooLarge":             ErrReasonPayloadTooLarge,
+	"TooManyProviderTokenUpdates": ErrReasonTooManyProviderTokenUpdates,
+	"TooManyRequests":             ErrReasonTooManyRequests,
+	"InternalServerError":         ErrReasonInternalServerError,
+	"ServiceUnavailable":          ErrReasonServiceUnavailable,
+	"Shutdown":                    ErrReasonShutdown,
+}
+
+// ReasonError is a request rejected by the HTTP/2 provider API. It wraps
+// the ErrReason value for its reason, so errors.Is matches it against
+// ErrReasonBadDeviceToken and the like; reasons this package doesn't know
+// yet wrap nothing.
+type ReasonError struct {
+	StatusCode int
+	Reason     string
+
+	// Timestamp is set for Unregistered, as in Response.
+	Timestamp time.Time
+}
+
+func (e *ReasonError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("apns: request failed with %d", e.StatusCode)
+	}
+	return fmt.Sprintf("apns: request failed with %d %s", e.StatusCode, e.Reason)
+}
+
+func (e *ReasonError) Unwrap() error {
+	return reasonErrors[e.Reason]
+}
+
+// Err returns a *ReasonError if APNS rejected the push, or nil if it
+// accepted it.
+func (r Response) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return &ReasonError{StatusCode: r.StatusCode, Reason: r.Reason, Timestamp: r.Timestamp}
+}
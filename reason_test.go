@@ -0,0 +1,58 @@
+package apns_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Response.Err", func() {
+	It("should be nil for accepted pushes", func() {
+		Expect(apns.Response{StatusCode: http.StatusOK}.Err()).To(BeNil())
+	})
+
+	It("should match the reason with errors.Is", func() {
+		err := apns.Response{StatusCode: http.StatusBadRequest, Reason: "BadDeviceToken"}.Err()
+
+		Expect(errors.Is(err, apns.ErrReasonBadDeviceToken)).To(BeTrue())
+		Expect(errors.Is(err, apns.ErrReasonTopicDisallowed)).To(BeFalse())
+		Expect(err.Error()).To(Equal("apns: request failed with 400 BadDeviceToken"))
+
+		var re *apns.ReasonError
+		Expect(errors.As(err, &re)).To(BeTrue())
+		Expect(re.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should keep reasons it doesn't know", func() {
+		err := apns.Response{StatusCode: http.StatusBadRequest, Reason: "SomethingNew"}.Err()
+
+		var re *apns.ReasonError
+		Expect(errors.As(err, &re)).To(BeTrue())
+		Expect(re.Reason).To(Equal("SomethingNew"))
+		Expect(errors.Unwrap(err)).To(BeNil())
+	})
+
+	It("should decode the reason APNS sends", func() {
+		s := newMockHTTP2Server()
+		defer s.Close()
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered","timestamp":1500000000000}`))
+		}
+
+		n := apns.NewNotification()
+		n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+		r, err := newTestClient2(s).SendSync(context.Background(), n)
+		Expect(err).To(BeNil())
+
+		err = r.Err()
+		Expect(errors.Is(err, apns.ErrReasonUnregistered)).To(BeTrue())
+		var re *apns.ReasonError
+		Expect(errors.As(err, &re)).To(BeTrue())
+		Expect(re.Timestamp.Unix()).To(Equal(int64(1500000000)))
+	})
+})
@@ -0,0 +1,115 @@
+package apns
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RecipientInfo is what policy features such as quiet hours and
+// time-window pacing need to know about the owner of a device token.
+type RecipientInfo struct {
+	// Location is the recipient's time zone; UTC if nil.
+	Location *time.Location
+
+	// Locale is the recipient's locale, such as "en_US", or empty if
+	// unknown.
+	Locale string
+}
+
+// In returns t in the recipient's time zone.
+func (i RecipientInfo) In(t time.Time) time.Time {
+	if i.Location == nil {
+		return t.UTC()
+	}
+	return t.In(i.Location)
+}
+
+// RecipientInfoResolver looks up the RecipientInfo of device tokens,
+// usually from an existing user profile service.
+type RecipientInfoResolver interface {
+	ResolveRecipient(ctx context.Context, token string) (RecipientInfo, error)
+}
+
+// RecipientInfoResolverFunc adapts a function to a RecipientInfoResolver.
+type RecipientInfoResolverFunc func(ctx context.Context, token string) (RecipientInfo, error)
+
+func (f RecipientInfoResolverFunc) ResolveRecipient(ctx context.Context, token string) (RecipientInfo, error) {
+	return f(ctx, token)
+}
+
+type cachedRecipient struct {
+	info    RecipientInfo
+	expires time.Time
+}
+
+// CachedRecipientInfoResolver remembers what Resolver returns for TTL, so
+// a campaign to the same recipients doesn't query the profile service for
+// every send. Errors aren't cached.
+type CachedRecipientInfoResolver struct {
+	Resolver RecipientInfoResolver
+	TTL      time.Duration
+
+	// MaxEntries bounds the cache; unbounded if zero. When it's full,
+	// expired entries are dropped first, then arbitrary ones.
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]cachedRecipient
+}
+
+func NewCachedRecipientInfoResolver(r RecipientInfoResolver, ttl time.Duration) *CachedRecipientInfoResolver {
+	return &CachedRecipientInfoResolver{Resolver: r, TTL: ttl}
+}
+
+func (c *CachedRecipientInfoResolver) ResolveRecipient(ctx context.Context, token string) (RecipientInfo, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[token]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.info, nil
+	}
+
+	info, err := c.Resolver.ResolveRecipient(ctx, token)
+	if err != nil {
+		return RecipientInfo{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]cachedRecipient{}
+	}
+	if c.MaxEntries > 0 && len(c.entries) >= c.MaxEntries {
+		c.evict(now)
+	}
+	c.entries[token] = cachedRecipient{info: info, expires: now.Add(c.TTL)}
+	return info, nil
+}
+
+// evict makes room for one entry.
+func (c *CachedRecipientInfoResolver) evict(now time.Time) {
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	for k := range c.entries {
+		if len(c.entries) < c.MaxEntries {
+			return
+		}
+		delete(c.entries, k)
+	}
+}
+
+// Forget drops token from the cache, for when the recipient's profile
+// changes.
+func (c *CachedRecipientInfoResolver) Forget(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, token)
+}
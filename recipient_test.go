@@ -0,0 +1,91 @@
+package apns_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Recipient info", func() {
+	var (
+		lookups int
+		fail    bool
+		tokyo   *time.Location
+		r       apns.RecipientInfoResolver
+	)
+
+	BeforeEach(func() {
+		lookups, fail = 0, false
+		tokyo = time.FixedZone("JST", 9*60*60)
+		r = apns.RecipientInfoResolverFunc(func(ctx context.Context, token string) (apns.RecipientInfo, error) {
+			lookups++
+			if fail {
+				return apns.RecipientInfo{}, errors.New("profile service down")
+			}
+			return apns.RecipientInfo{Location: tokyo, Locale: "ja_JP"}, nil
+		})
+	})
+
+	Describe("#In", func() {
+		It("should convert to the recipient's time zone, or UTC", func() {
+			t := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+			Expect(apns.RecipientInfo{Location: tokyo}.In(t).Hour()).To(Equal(21))
+			Expect(apns.RecipientInfo{}.In(t).Location()).To(Equal(time.UTC))
+		})
+	})
+
+	Describe("CachedRecipientInfoResolver", func() {
+		It("should cache lookups for the TTL", func() {
+			c := apns.NewCachedRecipientInfoResolver(r, time.Hour)
+
+			for i := 0; i < 3; i++ {
+				info, err := c.ResolveRecipient(context.Background(), "abcd")
+				Expect(err).To(BeNil())
+				Expect(info.Locale).To(Equal("ja_JP"))
+			}
+			Expect(lookups).To(Equal(1))
+
+			c.Forget("abcd")
+			c.ResolveRecipient(context.Background(), "abcd")
+			Expect(lookups).To(Equal(2))
+		})
+
+		It("should look up again once entries expire", func() {
+			c := apns.NewCachedRecipientInfoResolver(r, time.Millisecond)
+
+			c.ResolveRecipient(context.Background(), "abcd")
+			time.Sleep(5 * time.Millisecond)
+			c.ResolveRecipient(context.Background(), "abcd")
+			Expect(lookups).To(Equal(2))
+		})
+
+		It("should not cache errors", func() {
+			c := apns.NewCachedRecipientInfoResolver(r, time.Hour)
+
+			fail = true
+			_, err := c.ResolveRecipient(context.Background(), "abcd")
+			Expect(err).To(MatchError("profile service down"))
+
+			fail = false
+			_, err = c.ResolveRecipient(context.Background(), "abcd")
+			Expect(err).To(BeNil())
+			Expect(lookups).To(Equal(2))
+		})
+
+		It("should stay within MaxEntries", func() {
+			c := apns.NewCachedRecipientInfoResolver(r, time.Hour)
+			c.MaxEntries = 2
+
+			for _, tok := range []string{"a", "b", "c"} {
+				c.ResolveRecipient(context.Background(), tok)
+			}
+			c.ResolveRecipient(context.Background(), "c")
+			Expect(lookups).To(Equal(3))
+		})
+	})
+})
@@ -0,0 +1,112 @@
+package apns
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"sync"
+)
+
+// recovery collects the notifications Close discards, for writing to
+// Client.RecoveryFile.
+type recovery struct {
+	mu     sync.Mutex
+	notifs []Notification
+}
+
+func (r *recovery) add(n Notification) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notifs = append(r.notifs, n)
+}
+
+// discard reports n as discarded by Close and keeps it for the recovery
+// file.
+func (c *Client) discard(n Notification) {
+	c.report(closedResult(n))
+	if c.RecoveryFile != "" {
+		c.recovery.add(n)
+	}
+}
+
+// writeRecovery writes the notifications discarded by Close to
+// RecoveryFile as audit records, gzipped if CompressRecovery is set.
+// Nothing is written if none were.
+func (c *Client) writeRecovery() error {
+	c.recovery.mu.Lock()
+	defer c.recovery.mu.Unlock()
+
+	if c.RecoveryFile == "" || len(c.recovery.notifs) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(c.RecoveryFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = f
+	var zw *gzip.Writer
+	if c.CompressRecovery {
+		zw = gzip.NewWriter(f)
+		w = zw
+	}
+
+	l := NewAuditLog(w)
+	for _, n := range c.recovery.notifs {
+		if err := l.Write(closedResult(n)); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	c.recovery.notifs = nil
+
+	return f.Close()
+}
+
+// LoadRecovery reads the notifications a previous client left in its
+// RecoveryFile at path, so they can be sent again, whether or not it was
+// compressed. A missing file yields no notifications. The file is left in place; remove it once the
+// notifications have been queued:
+//
+//	ns, err := apns.LoadRecovery(path)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	os.Remove(path)
+//	for _, n := range ns {
+//		client.Send(n)
+//	}
+func LoadRecovery(path string) ([]Notification, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, _, err := gunzipped(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var ns []Notification
+	err = ReadAuditLog(r, func(a AuditRecord) error {
+		n, err := a.Notification()
+		if err != nil {
+			return err
+		}
+		ns = append(ns, n)
+		return nil
+	})
+
+	return ns, err
+}
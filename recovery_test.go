@@ -0,0 +1,97 @@
+package apns_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Recovery", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "apns-recovery")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should write notifications discarded by Close for the next run", func() {
+		path := filepath.Join(dir, "recovery.jsonl")
+
+		// The client never connects, so everything stays queued.
+		c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+		c.RecoveryFile = path
+		sub := c.Subscribe(2, apns.DropNewest)
+
+		for _, tok := range []string{"11", "22"} {
+			n := apns.NewNotification()
+			n.DeviceToken = tok
+			n.Payload.APS.Alert.Body = "hi"
+			c.Send(n)
+		}
+		Expect(c.Close()).To(BeNil())
+
+		for r := range sub.Results {
+			Expect(r.Disposition).To(Equal(apns.FailedRetryable))
+			Expect(r.Err).To(Equal(apns.ErrClosed))
+		}
+		Expect(sub.Dropped()).To(BeZero())
+
+		ns, err := apns.LoadRecovery(path)
+		Expect(err).To(BeNil())
+		Expect(ns).To(HaveLen(2))
+		Expect([]string{ns[0].DeviceToken, ns[1].DeviceToken}).To(ConsistOf("11", "22"))
+		Expect(ns[0].Payload.APS.Alert.Body).To(Equal("hi"))
+	})
+
+	It("should not write a file if nothing was discarded", func() {
+		path := filepath.Join(dir, "recovery.jsonl")
+
+		c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+		c.RecoveryFile = path
+		Expect(c.Close()).To(BeNil())
+
+		_, err := os.Stat(path)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should compress the file if asked to", func() {
+		path := filepath.Join(dir, "recovery.jsonl.gz")
+
+		// Each run appends to what the last one left.
+		for _, tok := range []string{"11", "22"} {
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			c.RecoveryFile = path
+			c.CompressRecovery = true
+
+			n := apns.NewNotification()
+			n.DeviceToken = tok
+			n.Payload.APS.Alert.Body = strings.Repeat("hi ", 100)
+			c.Send(n)
+			Expect(c.Close()).To(BeNil())
+		}
+
+		b, _ := ioutil.ReadFile(path)
+		Expect(b[:2]).To(Equal([]byte{0x1f, 0x8b}))
+		Expect(len(b)).To(BeNumerically("<", 600))
+
+		ns, err := apns.LoadRecovery(path)
+		Expect(err).To(BeNil())
+		Expect([]string{ns[0].DeviceToken, ns[1].DeviceToken}).To(Equal([]string{"11", "22"}))
+	})
+
+	Describe(".LoadRecovery", func() {
+		It("should return nothing for a missing file", func() {
+			ns, err := apns.LoadRecovery(filepath.Join(dir, "missing.jsonl"))
+			Expect(err).To(BeNil())
+			Expect(ns).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,117 @@
+package apns
+
+import "time"
+
+// Disposition says what finally happened to a notification.
+type Disposition int
+
+const (
+	// Delivered means the notification was written to APNS. Since APNS only
+	// reports failures, a later error frame may still follow it.
+	Delivered Disposition = iota
+
+	// FailedPermanent means APNS or the client rejected the notification
+	// and resending it unchanged won't help.
+	FailedPermanent
+
+	// FailedRetryable means APNS failed the notification for reasons of
+	// its own, such as a processing error or a shutdown.
+	FailedRetryable
+
+	// DroppedExpired means the notification's Expiration passed before it
+	// was sent.
+	DroppedExpired
+
+	// DroppedOverflow means the notification was discarded because a
+	// buffer or queue was full, such as a Mirror's.
+	DroppedOverflow
+
+	// Suppressed means the notification matched the suppression list, or
+	// repeated content sent within the client's DedupeWindow.
+	Suppressed
+
+	// Cancelled means the notification's SendGroup was cancelled before
+	// it was sent.
+	Cancelled
+
+	// DroppedDeadline means the notification wasn't written by its send
+	// deadline, so it was dropped rather than sent late; see SendBy.
+	DroppedDeadline
+
+	// Skipped means the notification was never sent because the client
+	// couldn't connect and is in SoftFail mode.
+	Skipped
+)
+
+var dispositionNames = map[Disposition]string{
+	Delivered:       "delivered",
+	FailedPermanent: "failed-permanent",
+	FailedRetryable: "failed-retryable",
+	DroppedExpired:  "dropped-expired",
+	DroppedOverflow: "dropped-overflow",
+	Suppressed:      "suppressed",
+	Cancelled:       "cancelled",
+	DroppedDeadline: "dropped-deadline",
+	Skipped:         "skipped",
+}
+
+func (d Disposition) String() string {
+	if s, ok := dispositionNames[d]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// Result reports the outcome of a single notification, successful or not.
+type Result struct {
+	Notification Notification
+	Disposition  Disposition
+
+	// Err is nil for delivered notifications and an *Error otherwise.
+	Err error
+
+	// ConnID and RemoteAddr identify the connection the result came from.
+	ConnID     uint64
+	RemoteAddr string
+	Time       time.Time
+
+	// ContentHash is the notification's Notification.ContentHash.
+	ContentHash string
+
+	// SampleRate is the Client.SuccessSampleRate a delivered Result was
+	// sampled at. It is zero for failures, which are never sampled.
+	SampleRate float64
+}
+
+// Result converts a failure into a Result.
+func (r NotificationResult) Result() Result {
+	err := r.Err
+	hash, _ := r.Notif.ContentHash()
+
+	return Result{
+		Notification: r.Notif,
+		Disposition:  dispositionOf(err),
+		Err:          &err,
+		ConnID:       r.ConnID,
+		RemoteAddr:   r.RemoteAddr,
+		Time:         time.Now(),
+		ContentHash:  hash,
+	}
+}
+
+// dispositionOf classifies a failure. Failures the client raised itself
+// carry their kind; other errors without a command never reached APNS.
+func dispositionOf(e Error) Disposition {
+	if k, ok := localKinds[e.kind]; ok {
+		return k.disposition
+	}
+	if e.Command == 0 {
+		return FailedPermanent
+	}
+
+	switch e.ErrStr {
+	case ErrProcessing, ErrShutdown, ErrUnknown:
+		return FailedRetryable
+	}
+	return FailedPermanent
+}
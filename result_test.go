@@ -0,0 +1,133 @@
+package apns_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Result", func() {
+	DescribeTable("NotificationResult#Result",
+		func(err apns.Error, want apns.Disposition) {
+			r := apns.NotificationResult{Err: err}.Result()
+
+			Expect(r.Disposition).To(Equal(want))
+			Expect(r.Err).To(MatchError(err.ErrStr))
+		},
+		Entry("invalid token", apns.NewError([]byte{8, 8, 0, 0, 0, 1}), apns.FailedPermanent),
+		Entry("processing error", apns.NewError([]byte{8, 1, 0, 0, 0, 1}), apns.FailedRetryable),
+		Entry("shutdown", apns.NewError([]byte{8, 10, 0, 0, 0, 1}), apns.FailedRetryable),
+		Entry("local rejection", apns.Error{ErrStr: "user opted out"}, apns.FailedPermanent),
+		Entry("local rejection named like a status", apns.Error{ErrStr: apns.ErrSuppressed}, apns.FailedPermanent),
+	)
+
+	It("should not take a hook's error message for a status", func(d Done) {
+		mockDone := make(chan interface{})
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+			c.OnBeforeSend(func(*apns.Notification) error {
+				return errors.New(apns.ErrExpired)
+			})
+
+			sub := c.Subscribe(1, apns.DropNewest)
+			c.Send(apns.NewNotification())
+
+			r := <-sub.Results
+			Expect(r.Disposition).To(Equal(apns.FailedPermanent))
+			Expect(r.Err.(*apns.Error).Code()).To(Equal(apns.CodeUnknown))
+
+			close(mockDone)
+			close(d)
+		})
+	})
+
+	It("should drop notifications past their Expiration", func() {
+		c := apns.NewClientWithCert("127.0.0.1:1", tls.Certificate{})
+		defer c.Close()
+
+		results := make(chan apns.Result, 1)
+		n := apns.NewNotification().ExpireAt(time.Now().Add(-time.Second))
+		n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+		c.Send(n, apns.OnResult(func(r apns.Result) { results <- r }))
+
+		r := <-results
+		Expect(r.Disposition).To(Equal(apns.DroppedExpired))
+		Expect(r.Err.(*apns.Error).Code()).To(Equal(apns.CodeExpired))
+	})
+
+	It("should be published for delivered notifications", func(d Done) {
+		mockDone := make(chan interface{})
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+			sub := c.Subscribe(1, apns.DropNewest)
+
+			n := apns.NewNotification()
+			n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+			c.Send(n)
+
+			r := <-sub.Results
+			Expect(r.Disposition).To(Equal(apns.Delivered))
+			Expect(r.Err).To(BeNil())
+			Expect(r.ConnID).To(Equal(c.Conn.ID))
+
+			close(mockDone)
+			close(d)
+		})
+	})
+
+	It("should sample delivered results but not failures", func(d Done) {
+		mockDone := make(chan interface{})
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+			c.SuccessSampleRate = 0
+			c.OnBeforeSend(func(n *apns.Notification) error {
+				if n.ID == "rejected" {
+					return errors.New("rejected")
+				}
+				return nil
+			})
+			sub := c.Subscribe(2, apns.DropNewest)
+
+			n := apns.NewNotification()
+			n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+			c.Send(n)
+
+			n.ID = "rejected"
+			c.Send(n)
+
+			r := <-sub.Results
+			Expect(r.Disposition).To(Equal(apns.FailedPermanent))
+			Expect(r.Notification.ID).To(Equal("rejected"))
+			Expect(sub.Results).To(BeEmpty())
+			Expect(c.Sent).To(Equal(1))
+
+			close(mockDone)
+			close(d)
+		})
+	})
+})
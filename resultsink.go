@@ -0,0 +1,275 @@
+package apns
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for ResultSink.
+const (
+	DefaultResultBatchSize     = 500
+	DefaultResultFlushInterval = 5 * time.Second
+	DefaultResultRetryBackoff  = time.Second
+	maxResultRetryBackoff      = time.Minute
+)
+
+// ResultWriter persists a batch of results, e.g. as one insert into
+// ClickHouse or one BigQuery streaming request. A batch is written whole or
+// not at all; after an error the same batch is written again, so stores
+// that need exactly-once should dedupe on the notification ID and
+// disposition. WriteBatch must not keep the slice.
+type ResultWriter interface {
+	WriteBatch([]Result) error
+}
+
+// ResultWriterFunc adapts a function to ResultWriter.
+type ResultWriterFunc func([]Result) error
+
+func (f ResultWriterFunc) WriteBatch(rs []Result) error {
+	return f(rs)
+}
+
+// ResultSink batches results for a ResultWriter. Every result accepted by
+// Write is written at least once: a batch that fails is retried with
+// backoff until it succeeds, and while it does the sink's buffer fills and
+// Write blocks, pushing back on whoever feeds it. Feed it from a
+// Subscription:
+//
+//	sink := apns.NewResultSink(store, apns.DefaultResultBatchSize)
+//	sub := client.Subscribe(1000, apns.DropOldest)
+//	go func() {
+//		for r := range sub.Results {
+//			sink.Write(r)
+//		}
+//		sink.Close()
+//	}()
+type ResultSink struct {
+	Writer ResultWriter
+
+	// BatchSize is the most results written at once.
+	BatchSize int
+
+	// FlushInterval is how long a partial batch waits before it's written.
+	FlushInterval time.Duration
+
+	// RetryBackoff is the wait after the first failed write, doubling with
+	// each failure up to a minute.
+	RetryBackoff time.Duration
+
+	// OnError, if set, is called with every failed write.
+	OnError func(error)
+
+	in        chan Result
+	flushes   chan chan struct{}
+	closed    chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+
+	mu        sync.Mutex
+	unwritten []Result
+	lastErr   error
+
+	written uint64
+	retries uint64
+}
+
+// NewResultSink returns a ResultSink writing batches of up to batchSize
+// results to w. Write blocks once 2*batchSize results are waiting. The
+// fields may be changed until the first call to Write.
+func NewResultSink(w ResultWriter, batchSize int) *ResultSink {
+	if batchSize < 1 {
+		batchSize = DefaultResultBatchSize
+	}
+
+	return &ResultSink{
+		Writer:        w,
+		BatchSize:     batchSize,
+		FlushInterval: DefaultResultFlushInterval,
+		RetryBackoff:  DefaultResultRetryBackoff,
+		in:            make(chan Result, batchSize),
+		flushes:       make(chan chan struct{}),
+		closed:        make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+func (s *ResultSink) start() {
+	s.startOnce.Do(func() {
+		go s.loop()
+	})
+}
+
+// Write queues r for writing, blocking while the sink is backed up. It
+// returns ErrClosed once the sink has been closed.
+func (s *ResultSink) Write(r Result) error {
+	s.start()
+
+	select {
+	case <-s.closed:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case s.in <- r:
+		return nil
+	case <-s.closed:
+		return ErrClosed
+	}
+}
+
+// Flush writes the pending batch now and waits until it's been written,
+// retrying as needed.
+func (s *ResultSink) Flush() {
+	s.start()
+
+	ack := make(chan struct{})
+	select {
+	case s.flushes <- ack:
+		<-ack
+	case <-s.done:
+	}
+}
+
+// Written returns how many results have been written.
+func (s *ResultSink) Written() uint64 {
+	return atomic.LoadUint64(&s.written)
+}
+
+// Retries returns how many writes have failed and been retried.
+func (s *ResultSink) Retries() uint64 {
+	return atomic.LoadUint64(&s.retries)
+}
+
+// Close writes what's pending with one last attempt. If that fails it
+// returns the error, and Unwritten returns the results so they can be kept
+// elsewhere. It is safe to call more than once.
+func (s *ResultSink) Close() error {
+	s.start()
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Unwritten returns the results Close couldn't write.
+func (s *ResultSink) Unwritten() []Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Result(nil), s.unwritten...)
+}
+
+func (s *ResultSink) loop() {
+	defer close(s.done)
+
+	t := time.NewTicker(s.FlushInterval)
+	defer t.Stop()
+
+	batch := make([]Result, 0, s.BatchSize)
+	for {
+		select {
+		case r := <-s.in:
+			batch = append(batch, r)
+			if len(batch) >= s.BatchSize {
+				if !s.writeUntilDone(batch) {
+					s.finish(batch)
+					return
+				}
+				batch = batch[:0]
+			}
+		case <-t.C:
+			if len(batch) > 0 {
+				if !s.writeUntilDone(batch) {
+					s.finish(batch)
+					return
+				}
+				batch = batch[:0]
+			}
+		case ack := <-s.flushes:
+			for len(batch) < cap(batch) {
+				select {
+				case r := <-s.in:
+					batch = append(batch, r)
+					continue
+				default:
+				}
+				break
+			}
+			ok := len(batch) == 0 || s.writeUntilDone(batch)
+			close(ack)
+			if !ok {
+				s.finish(batch)
+				return
+			}
+			batch = batch[:0]
+		case <-s.closed:
+			s.finish(batch)
+			return
+		}
+	}
+}
+
+// writeUntilDone writes batch, retrying with backoff until it succeeds. It
+// gives up, returning false, only when the sink is closed.
+func (s *ResultSink) writeUntilDone(batch []Result) bool {
+	backoff := s.RetryBackoff
+	for {
+		err := s.Writer.WriteBatch(batch)
+		if err == nil {
+			atomic.AddUint64(&s.written, uint64(len(batch)))
+			return true
+		}
+		atomic.AddUint64(&s.retries, 1)
+		if s.OnError != nil {
+			s.OnError(err)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-s.closed:
+			return false
+		}
+		if backoff *= 2; backoff > maxResultRetryBackoff {
+			backoff = maxResultRetryBackoff
+		}
+	}
+}
+
+// finish makes the last attempt at batch and everything still buffered,
+// keeping them if it fails.
+func (s *ResultSink) finish(batch []Result) {
+	rest := append([]Result(nil), batch...)
+	for {
+		select {
+		case r := <-s.in:
+			rest = append(rest, r)
+			continue
+		default:
+		}
+		break
+	}
+
+	var err error
+	for len(rest) > 0 && err == nil {
+		n := len(rest)
+		if n > s.BatchSize {
+			n = s.BatchSize
+		}
+		if err = s.Writer.WriteBatch(rest[:n]); err == nil {
+			atomic.AddUint64(&s.written, uint64(n))
+			rest = rest[n:]
+		}
+	}
+
+	s.mu.Lock()
+	s.lastErr = err
+	s.unwritten = rest
+	s.mu.Unlock()
+}
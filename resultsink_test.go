@@ -0,0 +1,120 @@
+package apns_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+// batchRecorder is a ResultWriter that keeps the IDs it's written and
+// fails while failing is set.
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]string
+	failing bool
+}
+
+func (b *batchRecorder) WriteBatch(rs []apns.Result) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failing {
+		return errors.New("store unavailable")
+	}
+	var ids []string
+	for _, r := range rs {
+		ids = append(ids, r.Notification.ID)
+	}
+	b.batches = append(b.batches, ids)
+	return nil
+}
+
+func (b *batchRecorder) setFailing(failing bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failing = failing
+}
+
+func (b *batchRecorder) written() [][]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([][]string(nil), b.batches...)
+}
+
+func sinkResult(id string) apns.Result {
+	n := apns.NewNotification()
+	n.ID = id
+	return apns.Result{Notification: n, Disposition: apns.Delivered}
+}
+
+var _ = Describe("ResultSink", func() {
+	It("should write full batches and flush partial ones", func() {
+		w := &batchRecorder{}
+		s := apns.NewResultSink(w, 2)
+		s.FlushInterval = 20 * time.Millisecond
+
+		for i := 1; i <= 3; i++ {
+			Expect(s.Write(sinkResult(fmt.Sprint("r", i)))).To(BeNil())
+		}
+
+		Eventually(w.written).Should(Equal([][]string{{"r1", "r2"}, {"r3"}}))
+		Expect(s.Written()).To(Equal(uint64(3)))
+		Expect(s.Close()).To(BeNil())
+		Expect(s.Write(sinkResult("r4"))).To(Equal(apns.ErrClosed))
+	})
+
+	It("should retry failed batches and push back meanwhile", func() {
+		w := &batchRecorder{failing: true}
+		s := apns.NewResultSink(w, 1)
+		s.RetryBackoff = 10 * time.Millisecond
+		var errs int
+		var mu sync.Mutex
+		s.OnError = func(error) {
+			mu.Lock()
+			errs++
+			mu.Unlock()
+		}
+
+		// One is being retried and one is buffered; the third must wait.
+		s.Write(sinkResult("r1"))
+		s.Write(sinkResult("r2"))
+		blocked := make(chan struct{})
+		go func() {
+			s.Write(sinkResult("r3"))
+			close(blocked)
+		}()
+		Consistently(blocked, 50*time.Millisecond).ShouldNot(BeClosed())
+		Expect(s.Retries()).To(BeNumerically(">", 1))
+
+		w.setFailing(false)
+		Eventually(blocked).Should(BeClosed())
+		s.Flush()
+		Expect(w.written()).To(Equal([][]string{{"r1"}, {"r2"}, {"r3"}}))
+		mu.Lock()
+		Expect(errs).To(BeNumerically(">", 1))
+		mu.Unlock()
+		Expect(s.Close()).To(BeNil())
+	})
+
+	It("should keep what Close couldn't write", func() {
+		w := &batchRecorder{failing: true}
+		s := apns.NewResultSink(w, 10)
+		s.FlushInterval = time.Hour
+
+		s.Write(sinkResult("r1"))
+		s.Write(sinkResult("r2"))
+
+		Expect(s.Close()).To(MatchError("store unavailable"))
+		var ids []string
+		for _, r := range s.Unwritten() {
+			ids = append(ids, r.Notification.ID)
+		}
+		Expect(ids).To(Equal([]string{"r1", "r2"}))
+		Expect(s.Written()).To(BeZero())
+	})
+})
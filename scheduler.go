@@ -0,0 +1,226 @@
+package apns
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies the device tokens a scheduled job sends to. It is
+// consulted at the start of every run.
+type TokenSource interface {
+	Tokens() ([]string, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource.
+type TokenSourceFunc func() ([]string, error)
+
+func (f TokenSourceFunc) Tokens() ([]string, error) {
+	return f()
+}
+
+// ScheduledRun records one execution of a scheduled job.
+type ScheduledRun struct {
+	Job   string
+	Start time.Time
+	End   time.Time
+	Sent  int
+
+	// Skipped is true if the run didn't happen because the previous run of
+	// the same job was still in progress.
+	Skipped bool
+	Err     error
+}
+
+type scheduledJob struct {
+	name     string
+	schedule *CronSchedule
+	template Notification
+	tokens   TokenSource
+
+	running bool
+	history []ScheduledRun
+	stop    chan struct{}
+}
+
+// Scheduler sends a notification template to a token source on cron
+// schedules. A job never overlaps with itself: if a run is still going when
+// the next one is due, the new one is skipped and recorded as such.
+type Scheduler struct {
+	Client *Client
+
+	// HistorySize is the number of runs kept per job.
+	HistorySize int
+
+	mu      sync.Mutex
+	jobs    map[string]*scheduledJob
+	started bool
+}
+
+func NewScheduler(c *Client) *Scheduler {
+	return &Scheduler{
+		Client:      c,
+		HistorySize: 20,
+		jobs:        map[string]*scheduledJob{},
+	}
+}
+
+// Add registers a job. The template's DeviceToken is replaced with each
+// token from tokens on every run.
+func (s *Scheduler) Add(name string, expr string, template Notification, tokens TokenSource) error {
+	sched, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("scheduler: job %q already exists", name)
+	}
+
+	j := &scheduledJob{name: name, schedule: sched, template: template, tokens: tokens}
+	s.jobs[name] = j
+
+	if s.started {
+		s.startJob(j)
+	}
+
+	return nil
+}
+
+// Remove stops and forgets a job. A run in progress is allowed to finish.
+func (s *Scheduler) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if j, ok := s.jobs[name]; ok {
+		if j.stop != nil {
+			close(j.stop)
+		}
+		delete(s.jobs, name)
+	}
+}
+
+// Start begins firing jobs on their schedules.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.started = true
+	for _, j := range s.jobs {
+		s.startJob(j)
+	}
+}
+
+// Stop stops firing jobs. Runs in progress are allowed to finish.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.started = false
+	for _, j := range s.jobs {
+		if j.stop != nil {
+			close(j.stop)
+			j.stop = nil
+		}
+	}
+}
+
+// startJob must be called with s.mu held.
+func (s *Scheduler) startJob(j *scheduledJob) {
+	j.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		for {
+			next := j.schedule.Next(time.Now())
+			if next.IsZero() {
+				return
+			}
+
+			t := time.NewTimer(next.Sub(time.Now()))
+			select {
+			case <-stop:
+				t.Stop()
+				return
+			case <-t.C:
+				go s.run(j)
+			}
+		}
+	}(j.stop)
+}
+
+// RunNow runs a job immediately, subject to the same overlap protection as
+// scheduled runs, and returns the recorded run.
+func (s *Scheduler) RunNow(name string) (ScheduledRun, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+
+	if !ok {
+		return ScheduledRun{}, fmt.Errorf("scheduler: no job %q", name)
+	}
+
+	return s.run(j), nil
+}
+
+func (s *Scheduler) run(j *scheduledJob) ScheduledRun {
+	r := ScheduledRun{Job: j.name, Start: time.Now()}
+
+	s.mu.Lock()
+	if j.running {
+		r.Skipped = true
+		r.End = r.Start
+		s.record(j, r)
+		s.mu.Unlock()
+		return r
+	}
+	j.running = true
+	s.mu.Unlock()
+
+	toks, err := j.tokens.Tokens()
+	if err != nil {
+		r.Err = err
+	}
+
+	for _, tok := range toks {
+		n := j.template
+		n.DeviceToken = tok
+		if err := s.Client.Send(n); err != nil {
+			r.Err = err
+			break
+		}
+		r.Sent++
+	}
+
+	r.End = time.Now()
+
+	s.mu.Lock()
+	j.running = false
+	s.record(j, r)
+	s.mu.Unlock()
+
+	return r
+}
+
+// record must be called with s.mu held.
+func (s *Scheduler) record(j *scheduledJob, r ScheduledRun) {
+	j.history = append(j.history, r)
+	if len(j.history) > s.HistorySize {
+		j.history = j.history[len(j.history)-s.HistorySize:]
+	}
+}
+
+// History returns the recent runs of a job, oldest first.
+func (s *Scheduler) History(name string) []ScheduledRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[name]
+	if !ok {
+		return nil
+	}
+
+	return append([]ScheduledRun{}, j.history...)
+}
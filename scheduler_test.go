@@ -0,0 +1,62 @@
+package apns_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Scheduler", func() {
+	var s *apns.Scheduler
+
+	BeforeEach(func() {
+		s = apns.NewScheduler(nil)
+	})
+
+	Describe("#Add", func() {
+		It("should reject bad expressions", func() {
+			Expect(s.Add("digest", "every day", apns.NewNotification(), nil)).NotTo(BeNil())
+		})
+
+		It("should reject duplicate names", func() {
+			src := apns.TokenSourceFunc(func() ([]string, error) { return nil, nil })
+			Expect(s.Add("digest", "0 8 * * *", apns.NewNotification(), src)).To(BeNil())
+			Expect(s.Add("digest", "0 8 * * *", apns.NewNotification(), src)).NotTo(BeNil())
+		})
+	})
+
+	Describe("#RunNow", func() {
+		It("should record errors from the token source", func() {
+			src := apns.TokenSourceFunc(func() ([]string, error) { return nil, errors.New("db down") })
+			s.Add("digest", "0 8 * * *", apns.NewNotification(), src)
+
+			r, err := s.RunNow("digest")
+			Expect(err).To(BeNil())
+			Expect(r.Err).To(MatchError("db down"))
+			Expect(s.History("digest")).To(HaveLen(1))
+		})
+
+		It("should skip overlapping runs", func() {
+			entered := make(chan bool)
+			release := make(chan bool)
+			src := apns.TokenSourceFunc(func() ([]string, error) {
+				entered <- true
+				<-release
+				return nil, nil
+			})
+			s.Add("digest", "0 8 * * *", apns.NewNotification(), src)
+
+			go s.RunNow("digest")
+			<-entered
+
+			r, _ := s.RunNow("digest")
+			Expect(r.Skipped).To(BeTrue())
+
+			close(release)
+			Eventually(func() int { return len(s.History("digest")) }).Should(Equal(2))
+			Expect(s.History("digest")[1].Skipped).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,106 @@
+package apns
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// SchemaMetadataKey is the Notification.Metadata key naming the schema a
+// notification should be validated against.
+const SchemaMetadataKey = "schema"
+
+// Schema describes the shape a family of notifications is expected to have.
+// Zero values disable the corresponding check.
+type Schema struct {
+	Name           string
+	RequiredKeys   []string   // custom payload keys that must be present
+	PushTypes      []PushType // allowed push types; empty allows any
+	MaxPayloadSize int        // in bytes, as marshaled
+}
+
+// SchemaError is returned when a notification doesn't conform to the schema
+// declared in its metadata.
+type SchemaError struct {
+	Schema string
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("schema %q: %s", e.Schema, e.Reason)
+}
+
+// SchemaRegistry holds named schemas. Notifications that don't declare a
+// schema pass validation untouched.
+type SchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]Schema
+}
+
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{schemas: map[string]Schema{}}
+}
+
+// Register adds or replaces the schema with the same name.
+func (r *SchemaRegistry) Register(s Schema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.schemas[s.Name] = s
+}
+
+// Lookup returns the schema registered under name.
+func (r *SchemaRegistry) Lookup(name string) (Schema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.schemas[name]
+	return s, ok
+}
+
+// Validate checks n against the schema named in its metadata.
+func (r *SchemaRegistry) Validate(n Notification) error {
+	name, ok := n.Metadata[SchemaMetadataKey]
+	if !ok {
+		return nil
+	}
+
+	s, ok := r.Lookup(name)
+	if !ok {
+		return &SchemaError{Schema: name, Reason: "not registered"}
+	}
+
+	if len(s.PushTypes) != 0 {
+		allowed := false
+		for _, t := range s.PushTypes {
+			if t == n.PushType {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &SchemaError{Schema: name, Reason: fmt.Sprintf("push type %q not allowed", n.PushType)}
+		}
+	}
+
+	for _, k := range s.RequiredKeys {
+		if n.Payload == nil {
+			return &SchemaError{Schema: name, Reason: "missing payload"}
+		}
+		if _, ok := n.Payload.customValues[k]; !ok {
+			return &SchemaError{Schema: name, Reason: fmt.Sprintf("missing required key %q", k)}
+		}
+	}
+
+	if s.MaxPayloadSize > 0 {
+		j, err := json.Marshal(n.Payload)
+		if err != nil {
+			return err
+		}
+		if len(j) > s.MaxPayloadSize {
+			return &SchemaError{Schema: name, Reason: fmt.Sprintf("payload is %d bytes, max %d", len(j), s.MaxPayloadSize)}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package apns_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("SchemaRegistry", func() {
+	var r *apns.SchemaRegistry
+
+	BeforeEach(func() {
+		r = apns.NewSchemaRegistry()
+		r.Register(apns.Schema{
+			Name:           "chat",
+			RequiredKeys:   []string{"thread"},
+			PushTypes:      []apns.PushType{apns.PushTypeAlert},
+			MaxPayloadSize: 64,
+		})
+	})
+
+	Describe("#Validate", func() {
+		var n apns.Notification
+
+		BeforeEach(func() {
+			n = apns.NewNotification()
+			n.PushType = apns.PushTypeAlert
+			n.Metadata = map[string]string{apns.SchemaMetadataKey: "chat"}
+			n.Payload.SetCustomValue("thread", "t1")
+		})
+
+		Context("without a declared schema", func() {
+			It("should pass", func() {
+				Expect(r.Validate(apns.NewNotification())).To(BeNil())
+			})
+		})
+
+		Context("conforming notification", func() {
+			It("should pass", func() {
+				Expect(r.Validate(n)).To(BeNil())
+			})
+		})
+
+		Context("unregistered schema", func() {
+			It("should fail", func() {
+				n.Metadata[apns.SchemaMetadataKey] = "billing"
+				err := r.Validate(n)
+				Expect(err).To(BeAssignableToTypeOf(&apns.SchemaError{}))
+				Expect(err.Error()).To(ContainSubstring("not registered"))
+			})
+		})
+
+		Context("disallowed push type", func() {
+			It("should fail", func() {
+				n.PushType = apns.PushTypeBackground
+				Expect(r.Validate(n).Error()).To(ContainSubstring("push type"))
+			})
+		})
+
+		Context("missing required key", func() {
+			It("should fail", func() {
+				n.Payload = apns.NewPayload()
+				Expect(r.Validate(n).Error()).To(ContainSubstring(`"thread"`))
+			})
+		})
+
+		Context("oversized payload", func() {
+			It("should fail", func() {
+				n.Payload.APS.Alert.Body = "this body pushes the payload well past the sixty four byte limit"
+				Expect(r.Validate(n).Error()).To(ContainSubstring("max 64"))
+			})
+		})
+	})
+})
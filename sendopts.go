@@ -0,0 +1,148 @@
+package apns
+
+import (
+	"sync"
+	"time"
+)
+
+// SendOption configures a single call to Client.Send.
+type SendOption func(*Notification)
+
+// OnResult registers fn to be called exactly once with the outcome of the
+// notification, whatever it is, without consuming Client.FailedNotifs or a
+// Subscription. Since APNS only reports failures, Delivered is reported
+// once the notification has been written and InFlightWindow has passed
+// without an error. Notifications still queued when the client is closed
+// are reported as FailedRetryable with ErrClosed, ones removed by
+// Forget as Suppressed, ones of a cancelled SendGroup as Cancelled, ones
+// past their SendBy deadline as DroppedDeadline and ones past their
+// Expiration as DroppedExpired.
+//
+// fn runs on its own goroutine and must not block the caller for long.
+func OnResult(fn func(Result)) SendOption {
+	return func(n *Notification) {
+		n.callback = &resultCallback{fn: fn}
+	}
+}
+
+// resultCallback is shared by every copy of a notification, so it fires
+// once no matter how often the notification is requeued.
+type resultCallback struct {
+	once sync.Once
+	fn   func(Result)
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+func (cb *resultCallback) fire(r Result) {
+	if cb == nil {
+		return
+	}
+	cb.hold()
+	cb.once.Do(func() { go cb.fn(r) })
+}
+
+// deliverAfter fires r once d has passed, unless something else fires
+// first or the notification is requeued.
+func (cb *resultCallback) deliverAfter(d time.Duration, r Result) {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.timer != nil {
+		cb.timer.Stop()
+	}
+	cb.timer = time.AfterFunc(d, func() { cb.fire(r) })
+}
+
+// hold cancels a pending delivery, as when the notification has to be
+// resent after all.
+func (cb *resultCallback) hold() {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.timer != nil {
+		cb.timer.Stop()
+		cb.timer = nil
+	}
+}
+
+// closedResult is reported for notifications discarded by Close.
+func closedResult(n Notification) Result {
+	return Result{Notification: n, Disposition: FailedRetryable, Err: ErrClosed, Time: time.Now()}
+}
+
+// SendBy drops the notification if it hasn't been written to APNS by t,
+// reporting it as DroppedDeadline, for notifications that are worthless
+// once late, like a reminder for a match that has already kicked off.
+func SendBy(t time.Time) SendOption {
+	return func(n *Notification) {
+		if n.deadline.IsZero() || t.Before(n.deadline) {
+			n.deadline = t
+		}
+	}
+}
+
+// deadlineResult is reported for notifications dropped by SendBy.
+func deadlineResult(n Notification) Result {
+	return NotificationResult{Notif: n, Err: localError(n, localDeadline)}.Result()
+}
+
+// unsendable returns n's Cancelled, DroppedDeadline or DroppedExpired
+// Result and true if it must not be sent anymore.
+func unsendable(n Notification, now time.Time) (Result, bool) {
+	switch {
+	case n.sendGroup.Cancelled():
+		return cancelledResult(n), true
+	case !n.deadline.IsZero() && !now.Before(n.deadline):
+		return deadlineResult(n), true
+	case n.expirationPolicy() == ExpirationAt && !now.Before(*n.Expiration):
+		return expiredResult(n), true
+	}
+	return Result{}, false
+}
+
+// dropUnsendable reports n if it must not be sent anymore, and returns
+// true if so.
+func (c *Client) dropUnsendable(n Notification, now time.Time) bool {
+	r, drop := unsendable(n, now)
+	if drop {
+		c.report(r)
+	}
+	return drop
+}
+
+// cancelledResult is reported for notifications of a cancelled SendGroup.
+func cancelledResult(n Notification) Result {
+	return NotificationResult{Notif: n, Err: localError(n, localCancelled)}.Result()
+}
+
+// expiredResult is reported for notifications whose Expiration passed
+// before they were sent.
+func expiredResult(n Notification) Result {
+	return NotificationResult{Notif: n, Err: localError(n, localExpired)}.Result()
+}
+
+// overflowResult is reported for notifications dropped because a buffer
+// was full.
+func overflowResult(n Notification) Result {
+	return NotificationResult{Notif: n, Err: localError(n, localOverflow)}.Result()
+}
+
+// skippedResult is reported for notifications skipped in SoftFail mode.
+func skippedResult(n Notification) Result {
+	return NotificationResult{Notif: n, Err: localError(n, localSkipped)}.Result()
+}
+
+// forgottenResult is reported for notifications removed by Forget.
+func forgottenResult(n Notification) Result {
+	return NotificationResult{Notif: n, Err: localError(n, localForgotten)}.Result()
+}
@@ -0,0 +1,129 @@
+package apns_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Send options", func() {
+	Describe("OnResult", func() {
+		tok := "9999999999999999999999999999999999999999999999999999999999999999"
+
+		It("should report delivery once the in-flight window passes", func(d Done) {
+			mockDone := make(chan interface{})
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+				},
+			}
+
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				c.InFlightWindow = 20 * time.Millisecond
+
+				results := make(chan apns.Result, 2)
+				n := apns.NewNotification()
+				n.DeviceToken = tok
+				start := time.Now()
+				c.Send(n, apns.OnResult(func(r apns.Result) { results <- r }))
+
+				r := <-results
+				Expect(r.Disposition).To(Equal(apns.Delivered))
+				Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+				Consistently(results, 50*time.Millisecond).ShouldNot(Receive())
+
+				close(mockDone)
+				close(d)
+			})
+		})
+
+		It("should report failures from APNS instead of delivery", func(d Done) {
+			n := apns.NewNotification()
+			n.DeviceToken = tok
+			n.Identifier = 1
+			b, _ := n.ToBinary()
+
+			errPayload := bytes.NewBuffer([]byte{})
+			binary.Write(errPayload, binary.BigEndian, uint8(8))
+			binary.Write(errPayload, binary.BigEndian, uint8(8))
+			binary.Write(errPayload, binary.BigEndian, uint32(1))
+
+			mockDone := make(chan interface{})
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: make([]byte, len(b))},
+					serverAction{action: writeAction, data: errPayload.Bytes()},
+					serverAction{action: closeAction},
+				},
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+				},
+			}
+
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				c.InFlightWindow = time.Second
+
+				results := make(chan apns.Result, 2)
+				c.Send(n, apns.OnResult(func(r apns.Result) { results <- r }))
+
+				var r apns.Result
+				Eventually(results).Should(Receive(&r))
+				Expect(r.Disposition).To(Equal(apns.FailedPermanent))
+				Expect(r.Err).To(MatchError(apns.ErrInvalidToken))
+				Consistently(results, 1200*time.Millisecond).ShouldNot(Receive())
+
+				close(mockDone)
+				close(d)
+			})
+		}, 3)
+
+		It("should report local failures", func(d Done) {
+			mockDone := make(chan interface{})
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+				},
+			}
+
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+
+				results := make(chan apns.Result, 2)
+				n := apns.NewNotification()
+				n.DeviceToken = tok
+				n.CollapseID = string(make([]byte, apns.MaxCollapseIDSize+1))
+				c.Send(n, apns.OnResult(func(r apns.Result) { results <- r }))
+
+				r := <-results
+				Expect(r.Disposition).To(Equal(apns.FailedPermanent))
+
+				close(mockDone)
+				close(d)
+			})
+		})
+
+		It("should report notifications discarded by Close", func() {
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+
+			results := make(chan apns.Result, 2)
+			n := apns.NewNotification()
+			n.DeviceToken = tok
+			Expect(c.Send(n, apns.OnResult(func(r apns.Result) { results <- r }))).To(BeNil())
+			c.Close()
+
+			var r apns.Result
+			Eventually(results).Should(Receive(&r))
+			Expect(r.Disposition).To(Equal(apns.FailedRetryable))
+			Expect(r.Err).To(Equal(apns.ErrClosed))
+		})
+	})
+})
@@ -0,0 +1,74 @@
+package apns_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("PayloadSigner", func() {
+	key := apns.HMACKey{ID: "k1", Secret: []byte("secret")}
+
+	Describe("#Sign", func() {
+		It("should sign the selected fields", func() {
+			s := &apns.PayloadSigner{Key: key, Fields: []string{"aps", "order"}}
+
+			n := apns.NewNotification()
+			n.Payload.APS.Alert.Body = "Your order shipped"
+			n.Payload.SetCustomValue("order", "1234")
+			n.Payload.SetCustomValue("unsigned", true)
+
+			Expect(s.Sign(&n)).To(BeNil())
+
+			b, _ := json.Marshal(n.Payload)
+			var sent struct {
+				Signature apns.Signature `json:"signature"`
+			}
+			Expect(json.Unmarshal(b, &sent)).To(BeNil())
+
+			m := hmac.New(sha256.New, []byte("secret"))
+			m.Write([]byte(`{"aps":{"alert":"Your order shipped"},"order":"1234"}`))
+
+			Expect(sent.Signature.KeyID).To(Equal("k1"))
+			Expect(sent.Signature.Fields).To(Equal([]string{"aps", "order"}))
+			Expect(sent.Signature.Value).To(Equal(base64.StdEncoding.EncodeToString(m.Sum(nil))))
+		})
+
+		It("should honor a custom signature key", func() {
+			s := &apns.PayloadSigner{Key: key, Fields: []string{"aps"}, SignatureKey: "x-sig"}
+
+			n := apns.NewNotification()
+			Expect(s.Sign(&n)).To(BeNil())
+
+			b, _ := json.Marshal(n.Payload)
+			Expect(string(b)).To(ContainSubstring(`"x-sig":`))
+		})
+
+		It("should leave a shared payload unsigned", func() {
+			s := &apns.PayloadSigner{Key: key, Fields: []string{"aps"}}
+
+			p := apns.NewPayload()
+			n := apns.NewNotification()
+			n.Payload = p
+			Expect(s.Sign(&n)).To(BeNil())
+
+			shared, _ := json.Marshal(p)
+			Expect(shared).To(MatchJSON(`{"aps":{}}`))
+
+			signed, _ := json.Marshal(n.Payload)
+			Expect(string(signed)).To(ContainSubstring(`"signature":`))
+		})
+
+		It("should refuse precomputed payloads", func() {
+			s := &apns.PayloadSigner{Key: key, Fields: []string{"aps"}}
+
+			n := apns.NewNotification().WithPrecomputedPayload([]byte(`{"aps":{}}`))
+			Expect(s.Sign(&n)).NotTo(BeNil())
+		})
+	})
+})
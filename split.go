@@ -0,0 +1,134 @@
+package apns
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	// Metadata keys set on notifications built by a Split.
+	ExperimentMetadataKey = "experiment"
+	VariantMetadataKey    = "variant"
+
+	VariantA = "A"
+	VariantB = "B"
+)
+
+// VariantStats counts what happened to the notifications of one variant.
+type VariantStats struct {
+	Sent   int
+	Failed int
+}
+
+// Split deterministically assigns device tokens to one of two payload
+// variants. The same experiment name and token always land on the same
+// variant, so repeated sends don't flip users between arms.
+type Split struct {
+	Name  string
+	A, B  *Payload
+	Ratio float64 // fraction of tokens that receive A, from 0 to 1
+
+	mu    sync.Mutex
+	stats map[string]*VariantStats
+}
+
+func NewSplit(name string, a, b *Payload, ratio float64) *Split {
+	return &Split{
+		Name:  name,
+		A:     a,
+		B:     b,
+		Ratio: ratio,
+		stats: map[string]*VariantStats{VariantA: {}, VariantB: {}},
+	}
+}
+
+// Variant returns VariantA or VariantB for token.
+func (s *Split) Variant(token string) string {
+	h := fnv.New32a()
+	h.Write([]byte(s.Name))
+	h.Write([]byte{0})
+	h.Write([]byte(token))
+
+	if float64(h.Sum32()%10000) < s.Ratio*10000 {
+		return VariantA
+	}
+	return VariantB
+}
+
+// Notification builds a notification for token carrying its assigned
+// payload, tagged with the experiment and variant in its metadata so the
+// assignment shows up on results.
+func (s *Split) Notification(token string) Notification {
+	v := s.Variant(token)
+
+	n := NewNotification()
+	n.DeviceToken = token
+	n.Payload = s.A
+	if v == VariantB {
+		n.Payload = s.B
+	}
+	n.Metadata = map[string]string{
+		ExperimentMetadataKey: s.Name,
+		VariantMetadataKey:    v,
+	}
+
+	return n
+}
+
+// Send pushes a notification to every token through c, using prepare (if
+// non-nil) to fill in fields shared by both variants such as priority. It
+// stops at the first token the client refuses.
+func (s *Split) Send(c *Client, tokens []string, prepare func(*Notification)) error {
+	for _, tok := range tokens {
+		n := s.Notification(tok)
+		if prepare != nil {
+			prepare(&n)
+		}
+
+		if err := c.Send(n); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		s.variantStats(s.Variant(tok)).Sent++
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// variantStats returns the counters for v, creating them for Splits not
+// made by NewSplit. s.mu must be held.
+func (s *Split) variantStats(v string) *VariantStats {
+	if s.stats == nil {
+		s.stats = map[string]*VariantStats{VariantA: {}, VariantB: {}}
+	}
+	return s.stats[v]
+}
+
+// Record counts a failed notification against its variant. Results that
+// belong to other experiments are ignored.
+func (s *Split) Record(r NotificationResult) {
+	if r.Notif.Metadata[ExperimentMetadataKey] != s.Name {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if vs := s.variantStats(r.Notif.Metadata[VariantMetadataKey]); vs != nil {
+		vs.Failed++
+	}
+}
+
+// Stats returns a copy of the per-variant counters.
+func (s *Split) Stats() map[string]VariantStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := map[string]VariantStats{}
+	for v, vs := range s.stats {
+		stats[v] = *vs
+	}
+	return stats
+}
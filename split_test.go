@@ -0,0 +1,74 @@
+package apns_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Split", func() {
+	var s *apns.Split
+
+	BeforeEach(func() {
+		s = apns.NewSplit("welcome-copy", apns.NewPayload(), apns.NewPayload(), 0.5)
+	})
+
+	Describe("#Variant", func() {
+		It("should be stable for a token", func() {
+			Expect(s.Variant("abcd")).To(Equal(s.Variant("abcd")))
+		})
+
+		It("should respect the ratio extremes", func() {
+			s.Ratio = 1
+			Expect(s.Variant("abcd")).To(Equal(apns.VariantA))
+			s.Ratio = 0
+			Expect(s.Variant("abcd")).To(Equal(apns.VariantB))
+		})
+
+		It("should roughly follow the ratio", func() {
+			a := 0
+			for i := 0; i < 1000; i++ {
+				if s.Variant(fmt.Sprint(i)) == apns.VariantA {
+					a++
+				}
+			}
+			Expect(a).To(BeNumerically("~", 500, 75))
+		})
+	})
+
+	Describe("#Notification", func() {
+		It("should tag the assignment in metadata", func() {
+			n := s.Notification("abcd")
+			Expect(n.DeviceToken).To(Equal("abcd"))
+			Expect(n.Metadata[apns.ExperimentMetadataKey]).To(Equal("welcome-copy"))
+			Expect(n.Metadata[apns.VariantMetadataKey]).To(Equal(s.Variant("abcd")))
+		})
+	})
+
+	Describe("#Record", func() {
+		It("should count failures per variant", func() {
+			n := s.Notification("abcd")
+			s.Record(apns.NotificationResult{Notif: n})
+			s.Record(apns.NotificationResult{Notif: apns.NewNotification()})
+
+			Expect(s.Stats()[n.Metadata[apns.VariantMetadataKey]].Failed).To(Equal(1))
+		})
+	})
+
+	Describe("#Send", func() {
+		It("should count sends for a Split made without NewSplit", func() {
+			s := &apns.Split{Name: "welcome-copy", A: apns.NewPayload(), B: apns.NewPayload(), Ratio: 1}
+
+			// The client never connects; Send only queues.
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			defer c.Close()
+
+			Expect(s.Send(c, []string{"abcd", "ef01"}, nil)).To(BeNil())
+			s.Record(apns.NotificationResult{Notif: s.Notification("abcd")})
+
+			Expect(s.Stats()[apns.VariantA]).To(Equal(apns.VariantStats{Sent: 2, Failed: 1}))
+		})
+	})
+})
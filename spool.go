@@ -0,0 +1,395 @@
+package apns
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSpoolSegmentSize is the default Spool.SegmentSize.
+const DefaultSpoolSegmentSize = 4 << 20
+
+// spoolSuffix names segment files in a spool directory, and
+// spoolTmpSuffix segments being rewritten.
+const (
+	spoolSuffix    = ".spool"
+	spoolTmpSuffix = ".spool-tmp"
+)
+
+// SpoolEntry is a notification held by a Spool, with the sequence number
+// to acknowledge it by.
+type SpoolEntry struct {
+	Seq          uint64
+	Notification Notification
+}
+
+// spoolLine is one line of a segment: an entry, or an acknowledgement of
+// one.
+type spoolLine struct {
+	Seq    uint64       `json:"seq,omitempty"`
+	Record *AuditRecord `json:"record,omitempty"`
+	Ack    uint64       `json:"ack,omitempty"`
+}
+
+// spoolSegment is a segment file and how many of its entries are still
+// unacknowledged.
+type spoolSegment struct {
+	path    string
+	pending int
+}
+
+// Spool is an on-disk queue of notifications accepted for sending, so they
+// survive a crash as well as Close. Add records a notification before it
+// is sent and Ack forgets it once it has a result there's no point
+// retrying; whatever wasn't acknowledged is returned by OpenSpool on the
+// next run:
+//
+//	s, pending, err := apns.OpenSpool(dir)
+//	...
+//	seq, err := s.Add(n)
+//	if err == nil {
+//		err = s.Sync()
+//	}
+//	client.Send(n, apns.OnResult(func(r apns.Result) {
+//		if !errors.Is(r.Err, apns.ErrClosed) {
+//			s.Ack(seq)
+//		}
+//	}))
+//
+// The spool is a directory of segment files of JSON lines. A segment is
+// sealed once it reaches SegmentSize: it is rewritten without the entries
+// acknowledged by then, gzipped if Compress is set. It is deleted once its
+// entries and every older segment's have been acknowledged.
+type Spool struct {
+	// SegmentSize is how large a segment grows before a new one is
+	// started. Compress gzips sealed segments, which suits the large
+	// backlogs built up while APNS can't be reached. Set them before
+	// adding entries.
+	SegmentSize int64
+	Compress    bool
+
+	dir      string
+	mu       sync.Mutex
+	seq      uint64
+	active   *os.File
+	size     int64
+	segments []*spoolSegment // oldest first, ending with active
+	entries  map[uint64]*spoolSegment
+}
+
+// OpenSpool opens the spool in dir, creating the directory if needed, and
+// returns the entries the previous run left unacknowledged, oldest first.
+func OpenSpool(dir string) (*Spool, []SpoolEntry, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, err
+	}
+
+	// Rewrites interrupted by a crash leave temporary files behind.
+	tmps, err := filepath.Glob(filepath.Join(dir, "*"+spoolTmpSuffix))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tmp := range tmps {
+		os.Remove(tmp)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+spoolSuffix))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(paths)
+
+	s := &Spool{
+		SegmentSize: DefaultSpoolSegmentSize,
+		dir:         dir,
+		entries:     map[uint64]*spoolSegment{},
+	}
+
+	records := map[uint64]AuditRecord{}
+	for _, path := range paths {
+		seg := &spoolSegment{path: path}
+		err := readSpoolSegment(path, func(l spoolLine) {
+			switch {
+			case l.Record != nil:
+				records[l.Seq] = *l.Record
+				s.entries[l.Seq] = seg
+				seg.pending++
+				if l.Seq > s.seq {
+					s.seq = l.Seq
+				}
+			case l.Ack != 0:
+				if owner, ok := s.entries[l.Ack]; ok {
+					delete(records, l.Ack)
+					delete(s.entries, l.Ack)
+					owner.pending--
+				}
+			}
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		s.segments = append(s.segments, seg)
+	}
+
+	pending := make([]SpoolEntry, 0, len(records))
+	for seq, a := range records {
+		n, err := a.Notification()
+		if err != nil {
+			return nil, nil, fmt.Errorf("spool entry %d: %v", seq, err)
+		}
+		pending = append(pending, SpoolEntry{Seq: seq, Notification: n})
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Seq < pending[j].Seq })
+
+	if err := s.roll(); err != nil {
+		return nil, nil, err
+	}
+	return s, pending, nil
+}
+
+// readSpoolSegment calls fn with every line of the segment at path,
+// compressed or not. A truncated last line, left by a crash mid-write, is
+// cut off so the segment can be appended to again.
+func readSpoolSegment(path string, fn func(spoolLine)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, compressed, err := gunzipped(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+
+	d := json.NewDecoder(r)
+	for {
+		end := d.InputOffset()
+
+		var l spoolLine
+		switch err := d.Decode(&l); {
+		case err == io.EOF:
+			return nil
+		case err == io.ErrUnexpectedEOF && !compressed:
+			return os.Truncate(path, end)
+		case err != nil:
+			return fmt.Errorf("%s: %v", filepath.Base(path), err)
+		}
+		fn(l)
+	}
+}
+
+// Add appends n to the spool and returns its sequence number. The entry
+// isn't durable until Sync returns.
+func (s *Spool) Add(n Notification) (uint64, error) {
+	a, err := NewAuditRecord(Result{Notification: n, Time: time.Now()})
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return 0, ErrClosed
+	}
+	if s.size >= s.SegmentSize {
+		if err := s.roll(); err != nil {
+			return 0, err
+		}
+	}
+
+	seq := s.seq + 1
+	if err := s.write(spoolLine{Seq: seq, Record: &a}); err != nil {
+		return 0, err
+	}
+	s.seq = seq
+
+	seg := s.segments[len(s.segments)-1]
+	s.entries[seq] = seg
+	seg.pending++
+	return seq, nil
+}
+
+// Sync makes the entries added so far durable.
+func (s *Spool) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return ErrClosed
+	}
+	return s.active.Sync()
+}
+
+// Ack removes entry seq from the spool, deleting the segments no longer
+// needed. Acknowledgements aren't synced: losing one to a crash only means
+// the entry is returned again by OpenSpool. Unknown entries are ignored.
+func (s *Spool) Ack(seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return ErrClosed
+	}
+	seg, ok := s.entries[seq]
+	if !ok {
+		return nil
+	}
+	if err := s.write(spoolLine{Ack: seq}); err != nil {
+		return err
+	}
+	delete(s.entries, seq)
+	seg.pending--
+
+	return s.compact()
+}
+
+// Len returns how many entries are unacknowledged.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+// Close closes the spool, leaving unacknowledged entries for the next
+// OpenSpool.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return nil
+	}
+	err := s.active.Close()
+	s.active = nil
+	return err
+}
+
+func (s *Spool) write(l spoolLine) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	n, err := s.active.Write(b)
+	s.size += int64(n)
+	return err
+}
+
+// roll seals the last segment, the active one or the one the previous run
+// left, and starts a new one named after the next sequence number, so
+// segments sort oldest first. The last segment is kept if nothing has been
+// added to it.
+func (s *Spool) roll() error {
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.seq+1, spoolSuffix))
+
+	if n := len(s.segments); n > 0 && s.segments[n-1].path != path {
+		if s.active != nil {
+			if err := s.active.Close(); err != nil {
+				return err
+			}
+			s.active = nil
+		}
+		if err := s.seal(s.segments[n-1]); err != nil {
+			return err
+		}
+	} else if s.active != nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.active, s.size = f, fi.Size()
+	if n := len(s.segments); n == 0 || s.segments[n-1].path != path {
+		s.segments = append(s.segments, &spoolSegment{path: path})
+	}
+	return s.compact()
+}
+
+// seal rewrites seg, which is no longer written to, without the entries
+// acknowledged so far or their acknowledgements, and gzipped if Compress is
+// set. Acknowledgements of older segments' entries are kept.
+func (s *Spool) seal(seg *spoolSegment) error {
+	var lines []spoolLine
+	own := map[uint64]bool{}
+	err := readSpoolSegment(seg.path, func(l spoolLine) {
+		lines = append(lines, l)
+		if l.Record != nil {
+			own[l.Seq] = true
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "*"+spoolTmpSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	var w io.Writer = tmp
+	var zw *gzip.Writer
+	if s.Compress {
+		zw = gzip.NewWriter(tmp)
+		w = zw
+	}
+
+	enc := json.NewEncoder(w)
+	for _, l := range lines {
+		switch {
+		case l.Record != nil && s.entries[l.Seq] != seg:
+			continue
+		case l.Ack != 0 && own[l.Ack]:
+			continue
+		}
+		if err := enc.Encode(l); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), seg.path)
+}
+
+// compact deletes sealed segments with nothing pending, oldest first. A
+// segment can hold acknowledgements of entries in older ones, so it has to
+// outlive them.
+func (s *Spool) compact() error {
+	for len(s.segments) > 1 && s.segments[0].pending == 0 {
+		if err := os.Remove(s.segments[0].path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		s.segments = s.segments[1:]
+	}
+	return nil
+}
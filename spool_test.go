@@ -0,0 +1,169 @@
+package apns_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Spool", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "apns-spool")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	notif := func(tok string) apns.Notification {
+		n := apns.NewNotification()
+		n.DeviceToken = tok
+		n.Payload.APS.Alert.Body = "hi"
+		return n
+	}
+
+	tokens := func(es []apns.SpoolEntry) []string {
+		var toks []string
+		for _, e := range es {
+			toks = append(toks, e.Notification.DeviceToken)
+		}
+		return toks
+	}
+
+	segments := func() []string {
+		paths, _ := filepath.Glob(filepath.Join(dir, "*.spool"))
+		return paths
+	}
+
+	It("should return what wasn't acknowledged on the next open", func() {
+		s, pending, err := apns.OpenSpool(dir)
+		Expect(err).To(BeNil())
+		Expect(pending).To(BeEmpty())
+
+		var seqs []uint64
+		for _, tok := range []string{"11", "22", "33"} {
+			seq, err := s.Add(notif(tok))
+			Expect(err).To(BeNil())
+			seqs = append(seqs, seq)
+		}
+		Expect(s.Sync()).To(BeNil())
+		Expect(s.Ack(seqs[1])).To(BeNil())
+		Expect(s.Len()).To(Equal(2))
+		Expect(s.Close()).To(BeNil())
+
+		s, pending, err = apns.OpenSpool(dir)
+		Expect(err).To(BeNil())
+		defer s.Close()
+		Expect(tokens(pending)).To(Equal([]string{"11", "33"}))
+		Expect(pending[0].Notification.Payload.APS.Alert.Body).To(Equal("hi"))
+
+		// Entries keep their numbers, and new ones don't reuse them.
+		Expect(s.Ack(pending[0].Seq)).To(BeNil())
+		seq, _ := s.Add(notif("44"))
+		Expect(seq).To(BeNumerically(">", seqs[2]))
+		Expect(s.Len()).To(Equal(2))
+	})
+
+	It("should delete segments once everything in them is acknowledged", func() {
+		s, _, _ := apns.OpenSpool(dir)
+		defer s.Close()
+		s.SegmentSize = 1 // a segment per entry
+
+		var seqs []uint64
+		for _, tok := range []string{"11", "22", "33"} {
+			seq, _ := s.Add(notif(tok))
+			seqs = append(seqs, seq)
+		}
+		Expect(segments()).To(HaveLen(3))
+
+		// The first segment holds an unacknowledged entry, so the
+		// second has to stay too.
+		s.Ack(seqs[1])
+		Expect(segments()).To(HaveLen(3))
+
+		s.Ack(seqs[0])
+		Expect(segments()).To(HaveLen(1))
+	})
+
+	It("should drop acknowledged entries from sealed segments", func() {
+		s, _, _ := apns.OpenSpool(dir)
+		a, _ := s.Add(notif("11"))
+		b, _ := s.Add(notif("22"))
+		s.Ack(b)
+
+		s.SegmentSize = 1
+		s.Add(notif("33"))
+
+		paths := segments()
+		Expect(paths).To(HaveLen(2))
+		sealed, _ := ioutil.ReadFile(paths[0])
+		Expect(string(sealed)).To(ContainSubstring(`"11"`))
+		Expect(string(sealed)).NotTo(ContainSubstring(`"22"`))
+
+		s.Ack(a)
+		s.Close()
+		Expect(segments()).To(HaveLen(1))
+
+		s, pending, err := apns.OpenSpool(dir)
+		Expect(err).To(BeNil())
+		defer s.Close()
+		Expect(tokens(pending)).To(Equal([]string{"33"}))
+	})
+
+	It("should compress sealed segments if asked to", func() {
+		s, _, _ := apns.OpenSpool(dir)
+		s.SegmentSize = 1
+		s.Compress = true
+		for _, tok := range []string{"11", "22", "33"} {
+			s.Add(notif(tok))
+		}
+		s.Close()
+
+		paths := segments()
+		Expect(paths).To(HaveLen(3))
+		for _, path := range paths[:2] {
+			b, _ := ioutil.ReadFile(path)
+			Expect(b[:2]).To(Equal([]byte{0x1f, 0x8b}))
+		}
+
+		s, pending, err := apns.OpenSpool(dir)
+		Expect(err).To(BeNil())
+		defer s.Close()
+		Expect(tokens(pending)).To(Equal([]string{"11", "22", "33"}))
+	})
+
+	It("should ignore a line cut short by a crash", func() {
+		s, _, _ := apns.OpenSpool(dir)
+		s.Add(notif("11"))
+		s.Close()
+
+		f, _ := os.OpenFile(segments()[0], os.O_WRONLY|os.O_APPEND, 0600)
+		f.WriteString(`{"seq":2,"record":{"device_tok`)
+		f.Close()
+
+		s, pending, err := apns.OpenSpool(dir)
+		Expect(err).To(BeNil())
+		Expect(tokens(pending)).To(Equal([]string{"11"}))
+		s.Add(notif("22"))
+		s.Close()
+
+		s, pending, err = apns.OpenSpool(dir)
+		Expect(err).To(BeNil())
+		defer s.Close()
+		Expect(tokens(pending)).To(Equal([]string{"11", "22"}))
+	})
+
+	It("should fail once closed", func() {
+		s, _, _ := apns.OpenSpool(dir)
+		s.Close()
+
+		_, err := s.Add(notif("11"))
+		Expect(err).To(Equal(apns.ErrClosed))
+	})
+})
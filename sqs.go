@@ -0,0 +1,193 @@
+package apns
+
+import (
+	"sync"
+	"time"
+)
+
+// SQSMetadataKey is the Notification.Metadata key holding the ID of the SQS
+// message a notification was built from.
+const SQSMetadataKey = "sqs-message-id"
+
+// SQSMessage is the subset of an SQS message SQSConsumer needs. The body
+// holds the JSON payload.
+type SQSMessage struct {
+	MessageID     string
+	ReceiptHandle string
+	Body          string
+	Attributes    map[string]string
+}
+
+// SQSQueue is the narrow slice of the SQS API used by SQSConsumer, so
+// callers can adapt whichever AWS SDK version they already depend on.
+type SQSQueue interface {
+	ReceiveMessages(max int, wait time.Duration) ([]SQSMessage, error)
+	DeleteMessage(receiptHandle string) error
+	ChangeMessageVisibility(receiptHandle string, timeout time.Duration) error
+}
+
+// SQSConsumer long-polls an SQS queue of push requests and sends them
+// through a Client. A message is deleted only once its notification has been
+// written to APNS; until then its visibility is extended so it isn't
+// redelivered while the client is backing off.
+type SQSConsumer struct {
+	Queue             SQSQueue
+	Client            *Client
+	MaxMessages       int
+	WaitTime          time.Duration
+	VisibilityTimeout time.Duration
+
+	// Errors receives queue and decoding errors. Errors are dropped if
+	// nobody is listening.
+	Errors chan error
+
+	mu       sync.Mutex
+	inflight map[string]string // message ID -> receipt handle
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewSQSConsumer(q SQSQueue, c *Client) *SQSConsumer {
+	s := &SQSConsumer{
+		Queue:             q,
+		Client:            c,
+		MaxMessages:       10,
+		WaitTime:          20 * time.Second,
+		VisibilityTimeout: 30 * time.Second,
+		Errors:            make(chan error),
+		inflight:          map[string]string{},
+	}
+
+	c.OnAfterSend(func(n Notification) {
+		if id, ok := n.Metadata[SQSMetadataKey]; ok {
+			go s.ack(id)
+		}
+	})
+
+	return s
+}
+
+// Start begins consuming in the background until Stop is called.
+func (s *SQSConsumer) Start() {
+	s.stop = make(chan struct{})
+	s.wg.Add(2)
+
+	go s.receiveLoop()
+	go s.extendLoop()
+}
+
+// Stop ends consumption. Messages still in flight become visible again once
+// their visibility timeout lapses.
+func (s *SQSConsumer) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *SQSConsumer) reportErr(err error) {
+	select {
+	case s.Errors <- err:
+	default:
+	}
+}
+
+func (s *SQSConsumer) receiveLoop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		msgs, err := s.Queue.ReceiveMessages(s.MaxMessages, s.WaitTime)
+		if err != nil {
+			s.reportErr(err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for _, m := range msgs {
+			n, err := NotificationFromSQS(m)
+			if err != nil {
+				s.reportErr(err)
+				continue
+			}
+
+			s.mu.Lock()
+			s.inflight[m.MessageID] = m.ReceiptHandle
+			s.mu.Unlock()
+
+			if err := s.Client.Send(n); err != nil {
+				s.mu.Lock()
+				delete(s.inflight, m.MessageID)
+				s.mu.Unlock()
+
+				s.reportErr(err)
+			}
+		}
+	}
+}
+
+// ack deletes the message behind a written notification.
+func (s *SQSConsumer) ack(id string) {
+	s.mu.Lock()
+	h, ok := s.inflight[id]
+	delete(s.inflight, id)
+	s.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if err := s.Queue.DeleteMessage(h); err != nil {
+		s.reportErr(err)
+	}
+}
+
+// Record stops extending the visibility of the message behind a failed
+// notification, letting SQS redeliver it (or move it to a dead-letter
+// queue) once its visibility timeout lapses.
+func (s *SQSConsumer) Record(r NotificationResult) {
+	id, ok := r.Notif.Metadata[SQSMetadataKey]
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.inflight, id)
+	s.mu.Unlock()
+}
+
+func (s *SQSConsumer) extendLoop() {
+	defer s.wg.Done()
+
+	t := time.NewTicker(s.VisibilityTimeout / 2)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+		}
+
+		s.mu.Lock()
+		handles := make([]string, 0, len(s.inflight))
+		for _, h := range s.inflight {
+			handles = append(handles, h)
+		}
+		s.mu.Unlock()
+
+		for _, h := range handles {
+			if err := s.Queue.ChangeMessageVisibility(h, s.VisibilityTimeout); err != nil {
+				s.reportErr(err)
+			}
+		}
+	}
+}
+
+// NotificationFromSQS maps an SQS message onto a Notification.
+func NotificationFromSQS(m SQSMessage) (Notification, error) {
+	return notificationFromMessage([]byte(m.Body), m.Attributes, SQSMetadataKey, m.MessageID)
+}
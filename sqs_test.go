@@ -0,0 +1,120 @@
+package apns_test
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+type mockSQSQueue struct {
+	sync.Mutex
+	msgs     []apns.SQSMessage
+	deleted  []string
+	extended []string
+}
+
+func (q *mockSQSQueue) ReceiveMessages(max int, wait time.Duration) ([]apns.SQSMessage, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	msgs := q.msgs
+	q.msgs = nil
+	if len(msgs) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return msgs, nil
+}
+
+func (q *mockSQSQueue) DeleteMessage(h string) error {
+	q.Lock()
+	defer q.Unlock()
+
+	q.deleted = append(q.deleted, h)
+	return nil
+}
+
+func (q *mockSQSQueue) ChangeMessageVisibility(h string, timeout time.Duration) error {
+	q.Lock()
+	defer q.Unlock()
+
+	q.extended = append(q.extended, h)
+	return nil
+}
+
+func (q *mockSQSQueue) deletedHandles() []string {
+	q.Lock()
+	defer q.Unlock()
+
+	return append([]string{}, q.deleted...)
+}
+
+var _ = Describe("SQS", func() {
+	Describe(".NotificationFromSQS", func() {
+		It("should map the body and attributes", func() {
+			n, err := apns.NotificationFromSQS(apns.SQSMessage{
+				MessageID: "m1",
+				Body:      `{"aps":{"alert":"hi"}}`,
+				Attributes: map[string]string{
+					apns.MessageAttrDeviceToken: "abcd",
+					apns.MessageAttrPriority:    "5",
+					apns.MessageAttrExpiration:  "1404102833",
+					apns.MessageAttrPushType:    "alert",
+					"tenant":                    "acme",
+				},
+			})
+
+			Expect(err).To(BeNil())
+			Expect(n.DeviceToken).To(Equal("abcd"))
+			Expect(n.Priority).To(Equal(5))
+			Expect(n.Expiration.Unix()).To(Equal(int64(1404102833)))
+			Expect(n.PushType).To(Equal(apns.PushTypeAlert))
+			Expect(n.Payload.APS.Alert.Body).To(Equal("hi"))
+			Expect(n.Metadata).To(Equal(map[string]string{apns.SQSMetadataKey: "m1", "tenant": "acme"}))
+		})
+
+		It("should reject a bad priority", func() {
+			_, err := apns.NotificationFromSQS(apns.SQSMessage{
+				Body:       `{}`,
+				Attributes: map[string]string{apns.MessageAttrPriority: "high"},
+			})
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("SQSConsumer", func() {
+		as := [][]serverAction{
+			[]serverAction{
+				serverAction{action: readAction, data: []byte{}},
+			},
+		}
+
+		It("should delete messages once written", func(d Done) {
+			mockDone := make(chan interface{})
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+
+				q := &mockSQSQueue{msgs: []apns.SQSMessage{{
+					MessageID:     "m1",
+					ReceiptHandle: "h1",
+					Body:          `{"aps":{}}`,
+					Attributes: map[string]string{
+						apns.MessageAttrDeviceToken: "9999999999999999999999999999999999999999999999999999999999999999",
+					},
+				}}}
+
+				sc := apns.NewSQSConsumer(q, c)
+				sc.Start()
+
+				Eventually(q.deletedHandles).Should(Equal([]string{"h1"}))
+				sc.Stop()
+
+				close(mockDone)
+				close(d)
+			})
+		})
+	})
+})
@@ -0,0 +1,120 @@
+package apns
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// knownAPSKeys are the aps keys Apple documents, for Lint.
+var knownAPSKeys = map[string]bool{
+	"alert": true, "badge": true, "sound": true, "thread-id": true,
+	"category": true, "content-available": true, "mutable-content": true,
+	"target-content-id": true, "interruption-level": true,
+	"relevance-score": true, "filter-criteria": true, "url-args": true,
+	"content-state": true, "timestamp": true, "event": true,
+	"stale-date": true, "dismissal-date": true, "attributes-type": true,
+	"attributes": true, "account-id": true,
+}
+
+// knownAlertKeys are the alert keys Apple documents, mapped to whether
+// they're deprecated. Safari website pushes still use action for their
+// button label.
+var knownAlertKeys = map[string]bool{
+	"title": false, "subtitle": false, "body": false, "launch-image": false,
+	"title-loc-key": false, "title-loc-args": false,
+	"subtitle-loc-key": false, "subtitle-loc-args": false,
+	"loc-key": false, "loc-args": false,
+	"action": true, "action-loc-key": true,
+}
+
+// LintError is returned by Lint, listing every problem found.
+type LintError struct {
+	Problems []string
+}
+
+func (e *LintError) Error() string {
+	return "apns: " + strings.Join(e.Problems, "; ")
+}
+
+// Lint returns a *LintError if n has mistakes APNS may let through but
+// that are likely integration bugs: aps or alert keys Apple doesn't
+// document, which are usually typos or precomputed payloads gone stale,
+// deprecated alert keys, and a push type that is neither set nor
+// inferable from the payload. Clients check it on every send, failing
+// the notification in Strict mode and warning otherwise; Client skips
+// the push type, which the binary protocol doesn't send.
+func (n Notification) Lint() error {
+	e := &LintError{}
+	if n.InferPushType() == "" {
+		e.Problems = append(e.Problems, "no push type set or inferable from the payload")
+	}
+	if err := n.lintPayload(e); err != nil {
+		return err
+	}
+	return e.err()
+}
+
+// lintPayload adds the problems with n's payload keys to e.
+func (n Notification) lintPayload(e *LintError) error {
+	aps, err := n.apsFields()
+	if err != nil {
+		return err
+	}
+	for _, k := range sortedKeys(aps) {
+		if !knownAPSKeys[k] {
+			e.Problems = append(e.Problems, fmt.Sprintf("unknown aps key %q", k))
+		}
+	}
+
+	var alert apsDict
+	if aps.has("alert") && json.Unmarshal(aps["alert"], &alert) == nil {
+		for _, k := range sortedKeys(alert) {
+			deprecated, known := knownAlertKeys[k]
+			switch {
+			case !known:
+				e.Problems = append(e.Problems, fmt.Sprintf("unknown alert key %q", k))
+			case deprecated && !(k == "action" && isWebsitePushID(n.Topic)):
+				e.Problems = append(e.Problems, fmt.Sprintf("deprecated alert key %q", k))
+			}
+		}
+	}
+	return nil
+}
+
+// err returns e, or nil if no problems were found.
+func (e *LintError) err() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+func sortedKeys(d apsDict) []string {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lint is Lint as Client2 applies it, also requiring a topic when
+// authenticating with provider tokens.
+func (c *Client2) lint(n Notification) error {
+	err := n.Lint()
+	if c.Tokens == nil || n.Topic != "" {
+		return err
+	}
+
+	e, ok := err.(*LintError)
+	if err != nil && !ok {
+		return err
+	}
+	if e == nil {
+		e = &LintError{}
+	}
+	e.Problems = append(e.Problems, "no topic, which token authentication needs")
+	return e
+}
@@ -0,0 +1,163 @@
+package apns_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Strict mode", func() {
+	Describe("Notification#Lint", func() {
+		It("should pass documented payloads", func() {
+			n := apns.NewNotification()
+			n.Payload.APS.Alert.Title = "Hi"
+			n.Payload.APS.Alert.LocKey = "GREETING"
+
+			Expect(n.Lint()).To(BeNil())
+		})
+
+		It("should pass the keys APS produces", func() {
+			n := apns.NewNotification()
+			n.Payload.APS.Alert.Body = "Hi"
+			n.Payload.APS.URLArgs = []string{"a"}
+			n.Payload.APS.AccountId = "1234"
+
+			Expect(n.Lint()).To(BeNil())
+		})
+
+		It("should list unknown and deprecated keys, and a missing push type", func() {
+			n := apns.NewNotification().WithPrecomputedPayload([]byte(`{"aps":{"badg":1,"alert":{"body":"hi","action":"Open"}}}`))
+
+			err := n.Lint()
+			Expect(err).To(BeAssignableToTypeOf(&apns.LintError{}))
+			Expect(err.(*apns.LintError).Problems).To(Equal([]string{
+				`unknown aps key "badg"`,
+				`deprecated alert key "action"`,
+			}))
+
+			Expect(apns.NewNotification().Lint()).To(MatchError("apns: no push type set or inferable from the payload"))
+		})
+	})
+
+	Describe("Client2", func() {
+		var s *mockHTTP2Server
+
+		BeforeEach(func() {
+			s = newMockHTTP2Server()
+		})
+
+		AfterEach(func() {
+			s.Close()
+		})
+
+		It("should fail sends with problems when strict", func() {
+			c := newTestClient2(s)
+			c.Strict = true
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			_, err := c.SendSync(context.Background(), n)
+			Expect(err).To(MatchError("apns: no push type set or inferable from the payload"))
+			Expect(s.requests).To(BeEmpty())
+		})
+
+		It("should only warn otherwise", func() {
+			var warned []error
+			c := newTestClient2(s)
+			c.Warn = func(n apns.Notification, err error) { warned = append(warned, err) }
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			r, err := c.SendSync(context.Background(), n)
+			Expect(err).To(BeNil())
+			Expect(r.OK()).To(BeTrue())
+			Expect(warned).To(HaveLen(1))
+		})
+
+		It("should only log warnings when verbose", func() {
+			var logged bytes.Buffer
+			log.SetOutput(&logged)
+			defer log.SetOutput(os.Stderr)
+
+			c := newTestClient2(s)
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			c.SendSync(context.Background(), n)
+			Expect(logged.String()).To(BeEmpty())
+
+			c.Verbose = true
+			c.SendSync(context.Background(), n)
+			Expect(logged.String()).To(ContainSubstring("no push type"))
+		})
+
+		It("should require a topic with token authentication", func() {
+			_, p8 := newP8()
+			p, _ := apns.NewTokenProvider("KEY123", "TEAM456", p8)
+			c := apns.NewClient2WithToken(s.URL, p, "")
+			c.HTTPClient = s.Client()
+			c.Strict = true
+
+			n := apns.NewNotification()
+			n.DeviceToken = "abcd"
+			n.Payload.APS.Alert.Body = "hi"
+			_, err := c.SendSync(context.Background(), n)
+			Expect(err).To(MatchError("apns: no topic, which token authentication needs"))
+		})
+	})
+
+	Describe("Client", func() {
+		lintee := apns.NewNotification().WithPrecomputedPayload([]byte(`{"aps":{"badg":1}}`))
+		lintee.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+
+		It("should fail notifications with problems when strict", func(d Done) {
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+				},
+			}
+
+			mockDone := make(chan interface{})
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				c.Strict = true
+				defer c.Close()
+
+				c.Send(lintee)
+				f := <-c.FailedNotifs
+				Expect(f.Err.ErrStr).To(Equal(`apns: unknown aps key "badg"`))
+
+				close(mockDone)
+				close(d)
+			})
+		})
+
+		It("should emit a warning event otherwise", func(d Done) {
+			as := [][]serverAction{
+				[]serverAction{
+					serverAction{action: readAction, data: []byte{}},
+				},
+			}
+
+			mockDone := make(chan interface{})
+			withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+				c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+				c.Conn.Conf.InsecureSkipVerify = true
+				defer c.Close()
+
+				c.Send(lintee)
+				e := <-c.Events
+				Expect(e.Type).To(Equal(apns.EventLintWarning))
+				Expect(e.Message).To(Equal(`apns: unknown aps key "badg"`))
+
+				close(mockDone)
+				close(d)
+			})
+		})
+	})
+})
@@ -0,0 +1,167 @@
+package apns
+
+import "sync/atomic"
+
+// DropPolicy decides what a Subscription does with a new item when its
+// buffer is full.
+type DropPolicy int
+
+const (
+	// DropNewest discards the incoming item.
+	DropNewest DropPolicy = iota
+
+	// DropOldest discards the oldest buffered item to make room.
+	DropOldest
+)
+
+// Subscription receives its own copy of every Result and event published
+// by a Client, independently of FailedNotifs, Events and
+// other subscribers. A slow subscriber only ever loses its own items.
+type Subscription struct {
+	Results <-chan Result
+	Events  <-chan Event
+
+	unsubscribe func()
+	results     chan Result
+	events      chan Event
+	policy      DropPolicy
+	dropped     uint64
+}
+
+func newSubscription(size int, policy DropPolicy) *Subscription {
+	s := &Subscription{
+		results: make(chan Result, size),
+		events:  make(chan Event, size),
+		policy:  policy,
+	}
+	s.Results = s.results
+	s.Events = s.events
+	return s
+}
+
+// Subscribe returns a new Subscription whose channels each buffer up to
+// size items, applying policy once they are full. After Close the returned
+// subscription's channels are already closed.
+func (c *Client) Subscribe(size int, policy DropPolicy) *Subscription {
+	s := newSubscription(size, policy)
+	s.unsubscribe = func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.subs = s.removeFrom(c.subs)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.closed:
+		s.close()
+	default:
+		c.subs = append(c.subs, s)
+	}
+
+	return s
+}
+
+// Dropped returns how many items the subscription has discarded because
+// its buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe stops delivery to the subscription and closes its channels.
+// It is safe to call more than once.
+func (s *Subscription) Unsubscribe() {
+	s.unsubscribe()
+}
+
+// removeFrom returns subs without s, closing s's channels if it was there.
+// The caller holds the lock publishing to subs.
+func (s *Subscription) removeFrom(subs []*Subscription) []*Subscription {
+	for i, sub := range subs {
+		if sub == s {
+			s.close()
+			return append(subs[:i], subs[i+1:]...)
+		}
+	}
+	return subs
+}
+
+func (s *Subscription) close() {
+	close(s.results)
+	close(s.events)
+}
+
+func (s *Subscription) sendResult(r Result) {
+	select {
+	case s.results <- r:
+		return
+	default:
+	}
+
+	if s.policy == DropOldest {
+		select {
+		case <-s.results:
+		default:
+		}
+		select {
+		case s.results <- r:
+			atomic.AddUint64(&s.dropped, 1)
+			return
+		default:
+		}
+	}
+
+	atomic.AddUint64(&s.dropped, 1)
+}
+
+func (s *Subscription) sendEvent(e Event) {
+	select {
+	case s.events <- e:
+		return
+	default:
+	}
+
+	if s.policy == DropOldest {
+		select {
+		case <-s.events:
+		default:
+		}
+		select {
+		case s.events <- e:
+			atomic.AddUint64(&s.dropped, 1)
+			return
+		default:
+		}
+	}
+
+	atomic.AddUint64(&s.dropped, 1)
+}
+
+// publishResult fans r out to every subscriber.
+func (c *Client) publishResult(r Result) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, s := range c.subs {
+		s.sendResult(r)
+	}
+}
+
+// report publishes r and passes it to its notification's OnResult
+// callback, for outcomes the client decides on itself.
+func (c *Client) report(r Result) {
+	c.publishResult(r)
+	r.Notification.callback.fire(r)
+}
+
+// publishEvent fans e out to every subscriber.
+func (c *Client) publishEvent(e Event) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, s := range c.subs {
+		s.sendEvent(e)
+	}
+}
@@ -0,0 +1,78 @@
+package apns_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Subscription", func() {
+	as := [][]serverAction{
+		[]serverAction{
+			serverAction{action: readAction, data: []byte{}},
+		},
+	}
+
+	It("should deliver every result to each subscriber", func(d Done) {
+		mockDone := make(chan interface{})
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+			c.OnBeforeSend(func(n *apns.Notification) error {
+				return errors.New("user opted out")
+			})
+
+			s1 := c.Subscribe(1, apns.DropNewest)
+			s2 := c.Subscribe(1, apns.DropOldest)
+
+			n := apns.NewNotification()
+			n.ID = "opted_out"
+			c.Send(n)
+
+			Expect((<-s1.Results).Notification.ID).To(Equal("opted_out"))
+			Expect((<-s2.Results).Notification.ID).To(Equal("opted_out"))
+
+			close(mockDone)
+			close(d)
+		})
+	})
+
+	It("should count results dropped from a full buffer", func(d Done) {
+		mockDone := make(chan interface{})
+		withMockServerAsync(as, mockDone, func(s *mockTLSServer) {
+			c, _ := apns.NewClient(s.Address(), DummyCert, DummyKey)
+			c.Conn.Conf.InsecureSkipVerify = true
+			c.OnBeforeSend(func(n *apns.Notification) error {
+				return errors.New("user opted out")
+			})
+
+			sub := c.Subscribe(1, apns.DropOldest)
+
+			c.Send(apns.NewNotification())
+			c.Send(apns.NewNotification())
+
+			Eventually(sub.Dropped).Should(Equal(uint64(1)))
+			Expect(sub.Results).To(HaveLen(1))
+
+			close(mockDone)
+			close(d)
+		})
+	})
+
+	Describe("#Unsubscribe", func() {
+		It("should close the channels and be safe to repeat", func() {
+			c, _ := apns.NewClient("127.0.0.1:1", DummyCert, DummyKey)
+			sub := c.Subscribe(1, apns.DropNewest)
+
+			sub.Unsubscribe()
+			sub.Unsubscribe()
+
+			_, ok := <-sub.Results
+			Expect(ok).To(BeFalse())
+			_, ok = <-sub.Events
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
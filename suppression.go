@@ -0,0 +1,142 @@
+package apns
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// UserIDMetadataKey is the Notification.Metadata key holding the
+// application's user ID, used to match user-level suppressions.
+const UserIDMetadataKey = "user-id"
+
+// SuppressionStore persists suppressed keys. Implementations must be safe
+// for concurrent use.
+type SuppressionStore interface {
+	Add(key string) error
+	Remove(key string) error
+	Contains(key string) (bool, error)
+}
+
+// MemorySuppressionStore is an in-process SuppressionStore.
+type MemorySuppressionStore struct {
+	mu   sync.RWMutex
+	keys map[string]struct{}
+}
+
+func NewMemorySuppressionStore() *MemorySuppressionStore {
+	return &MemorySuppressionStore{keys: map[string]struct{}{}}
+}
+
+func (m *MemorySuppressionStore) Add(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys[key] = struct{}{}
+	return nil
+}
+
+func (m *MemorySuppressionStore) Remove(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.keys, key)
+	return nil
+}
+
+func (m *MemorySuppressionStore) Contains(key string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.keys[key]
+	return ok, nil
+}
+
+// SuppressionList rejects notifications addressed to suppressed device
+// tokens or user IDs before they are sent.
+type SuppressionList struct {
+	Store SuppressionStore
+}
+
+// NewSuppressionList creates a list backed by store, or by a
+// MemorySuppressionStore if store is nil.
+func NewSuppressionList(store SuppressionStore) *SuppressionList {
+	if store == nil {
+		store = NewMemorySuppressionStore()
+	}
+	return &SuppressionList{Store: store}
+}
+
+func tokenKey(token string) string {
+	return "token:" + strings.ToLower(token)
+}
+
+func userKey(id string) string {
+	return "user:" + id
+}
+
+func (l *SuppressionList) SuppressToken(token string) error {
+	return l.Store.Add(tokenKey(token))
+}
+
+func (l *SuppressionList) UnsuppressToken(token string) error {
+	return l.Store.Remove(tokenKey(token))
+}
+
+func (l *SuppressionList) SuppressUser(id string) error {
+	return l.Store.Add(userKey(id))
+}
+
+func (l *SuppressionList) UnsuppressUser(id string) error {
+	return l.Store.Remove(userKey(id))
+}
+
+// ImportCSV reads rows of the form "token,<device token>" or
+// "user,<user id>" and suppresses each one. It returns the number of rows
+// imported before any error.
+func (l *SuppressionList) ImportCSV(r io.Reader) (int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+
+	i := 0
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return i, nil
+		}
+		if err != nil {
+			return i, err
+		}
+
+		switch rec[0] {
+		case "token":
+			err = l.SuppressToken(rec[1])
+		case "user":
+			err = l.SuppressUser(rec[1])
+		default:
+			err = fmt.Errorf("unknown suppression kind %q", rec[0])
+		}
+		if err != nil {
+			return i, err
+		}
+
+		i++
+	}
+}
+
+// Suppressed reports whether n's device token or user ID is suppressed.
+func (l *SuppressionList) Suppressed(n Notification) (bool, error) {
+	ok, err := l.Store.Contains(tokenKey(n.DeviceToken))
+	if err != nil || ok {
+		return ok, err
+	}
+
+	if id, ok := n.Metadata[UserIDMetadataKey]; ok {
+		return l.Store.Contains(userKey(id))
+	}
+
+	return false, nil
+}
@@ -0,0 +1,80 @@
+package apns_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("SuppressionList", func() {
+	var l *apns.SuppressionList
+
+	BeforeEach(func() {
+		l = apns.NewSuppressionList(nil)
+	})
+
+	Describe("#Suppressed", func() {
+		Context("suppressed token", func() {
+			It("should match regardless of case", func() {
+				l.SuppressToken("ABCD")
+
+				n := apns.NewNotification()
+				n.DeviceToken = "abcd"
+
+				ok, err := l.Suppressed(n)
+				Expect(err).To(BeNil())
+				Expect(ok).To(BeTrue())
+			})
+		})
+
+		Context("suppressed user", func() {
+			It("should match the user id in metadata", func() {
+				l.SuppressUser("42")
+
+				n := apns.NewNotification()
+				n.Metadata = map[string]string{apns.UserIDMetadataKey: "42"}
+
+				ok, _ := l.Suppressed(n)
+				Expect(ok).To(BeTrue())
+			})
+		})
+
+		Context("after unsuppressing", func() {
+			It("should not match", func() {
+				l.SuppressToken("abcd")
+				l.UnsuppressToken("abcd")
+
+				n := apns.NewNotification()
+				n.DeviceToken = "abcd"
+
+				ok, _ := l.Suppressed(n)
+				Expect(ok).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("#ImportCSV", func() {
+		Context("valid rows", func() {
+			It("should suppress each row", func() {
+				i, err := l.ImportCSV(strings.NewReader("token,abcd\nuser, 42\n"))
+				Expect(err).To(BeNil())
+				Expect(i).To(Equal(2))
+
+				n := apns.NewNotification()
+				n.Metadata = map[string]string{apns.UserIDMetadataKey: "42"}
+				ok, _ := l.Suppressed(n)
+				Expect(ok).To(BeTrue())
+			})
+		})
+
+		Context("unknown kind", func() {
+			It("should stop with an error", func() {
+				i, err := l.ImportCSV(strings.NewReader("token,abcd\nemail,a@b.c\n"))
+				Expect(err).NotTo(BeNil())
+				Expect(i).To(Equal(1))
+			})
+		})
+	})
+})
@@ -0,0 +1,236 @@
+package apns
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// MinTokenRefreshInterval is how often Apple allows a provider token to
+	// be refreshed. Refreshing more often gets the provider throttled with
+	// TooManyProviderTokenUpdates.
+	MinTokenRefreshInterval = 20 * time.Minute
+
+	// MaxTokenAge is how long Apple accepts a provider token for.
+	MaxTokenAge = time.Hour
+
+	// DefaultTokenRefreshAfter is the default TokenGuard.RefreshAfter,
+	// leaving ample margin before MaxTokenAge.
+	DefaultTokenRefreshAfter = 40 * time.Minute
+
+	// ClockSkewTolerance is how far the local clock may drift from Apple's
+	// before TokenGuard compensates for it. HTTP dates only have second
+	// resolution, so smaller differences can't be measured reliably.
+	ClockSkewTolerance = 5 * time.Second
+
+	// tokenRetryInterval spaces out attempts after a failed generation.
+	tokenRetryInterval = 10 * time.Second
+)
+
+// TokenWarning describes a request that TokenGuard declined because it
+// would break Apple's token-auth guidance.
+type TokenWarning struct {
+	Time   time.Time
+	Reason string
+}
+
+// TokenGuard caches a provider authentication token and enforces Apple's
+// guidance for it: one token is reused across requests and connections,
+// and it's never regenerated more often than MinTokenRefreshInterval.
+// Requests that would break the rules are reported to OnWarning instead of
+// being passed on to Apple.
+type TokenGuard struct {
+	// Generate signs a new token issued at the given time.
+	Generate func(issuedAt time.Time) (string, error)
+
+	// RefreshAfter is the age at which the token is regenerated. Values
+	// below MinTokenRefreshInterval are raised to it.
+	RefreshAfter time.Duration
+
+	// OnWarning, if set, is called for every declined request.
+	OnWarning func(TokenWarning)
+
+	// OnEvent, if set, is called with EventClockSkewDetected when a clock
+	// skew is measured.
+	OnEvent func(Event)
+
+	mu        sync.Mutex
+	token     string
+	issuedAt  time.Time
+	failedAt  time.Time
+	lastErr   error
+	invalid   bool
+	warnedFor time.Duration
+	skew      time.Duration
+}
+
+func NewTokenGuard(generate func(issuedAt time.Time) (string, error)) *TokenGuard {
+	return &TokenGuard{Generate: generate, RefreshAfter: DefaultTokenRefreshAfter}
+}
+
+// warn reports reason to OnWarning, if set and reason isn't empty. It's
+// called without g.mu held, so OnWarning may use the guard.
+func (g *TokenGuard) warn(now time.Time, reason string) {
+	if g.OnWarning != nil && reason != "" {
+		g.OnWarning(TokenWarning{Time: now, Reason: reason})
+	}
+}
+
+// refreshAfter returns RefreshAfter, clamped to Apple's limits, and the
+// warning to report if it had to be raised.
+func (g *TokenGuard) refreshAfter() (time.Duration, string) {
+	d := g.RefreshAfter
+	switch {
+	case d == 0:
+		return DefaultTokenRefreshAfter, ""
+	case d < MinTokenRefreshInterval:
+		warning := ""
+		if g.warnedFor != d {
+			g.warnedFor = d
+			warning = "RefreshAfter " + d.String() + " is below the " + MinTokenRefreshInterval.String() + " minimum; using the minimum"
+		}
+		return MinTokenRefreshInterval, warning
+	case d >= MaxTokenAge:
+		return MaxTokenAge - time.Minute, ""
+	}
+	return d, ""
+}
+
+// Token returns the cached token, generating a new one if there is none
+// yet, it has reached RefreshAfter, or it was invalidated at least
+// MinTokenRefreshInterval after being issued.
+func (g *TokenGuard) Token() (string, error) {
+	now := time.Now()
+
+	g.mu.Lock()
+	token, warning, err := g.current(now)
+	g.mu.Unlock()
+
+	g.warn(now, warning)
+	return token, err
+}
+
+// current does the work of Token with g.mu held, returning any warning
+// for the caller to report once it's released.
+func (g *TokenGuard) current(now time.Time) (token, warning string, err error) {
+	age := now.Sub(g.issuedAt)
+
+	refreshAfter, warning := g.refreshAfter()
+	stale := g.token == "" || age >= refreshAfter || (g.invalid && age >= MinTokenRefreshInterval)
+	if !stale {
+		return g.token, warning, nil
+	}
+
+	if g.lastErr != nil && now.Sub(g.failedAt) < tokenRetryInterval {
+		if g.token != "" && age < MaxTokenAge {
+			return g.token, warning, nil
+		}
+		return "", warning, g.lastErr
+	}
+
+	token, err = g.Generate(now.Add(g.skew))
+	if err != nil {
+		g.lastErr, g.failedAt = err, now
+		if g.token != "" && age < MaxTokenAge {
+			return g.token, warning, nil
+		}
+		return "", warning, err
+	}
+
+	g.token, g.issuedAt, g.invalid, g.lastErr = token, now, false, nil
+	return token, warning, nil
+}
+
+// Invalidate asks for the token to be replaced, for example after Apple
+// reports it as expired. If the token is younger than
+// MinTokenRefreshInterval the request is deferred until it isn't, and a
+// warning is reported.
+func (g *TokenGuard) Invalidate() {
+	g.mu.Lock()
+	token := g.token
+	g.mu.Unlock()
+
+	g.invalidate(token)
+}
+
+// invalidate invalidates token if it's still the cached one. A rejection
+// arriving after the token was replaced says nothing about its successor.
+func (g *TokenGuard) invalidate(token string) {
+	now := time.Now()
+
+	g.mu.Lock()
+	if token != g.token {
+		g.mu.Unlock()
+		return
+	}
+	g.invalid = true
+	warning := ""
+	if g.token != "" && now.Sub(g.issuedAt) < MinTokenRefreshInterval {
+		warning = "token refresh requested " + now.Sub(g.issuedAt).String() + " after issue; deferring to avoid throttling"
+	}
+	g.mu.Unlock()
+
+	g.warn(now, warning)
+}
+
+// discard drops the cached token so the next call to Token signs a new one
+// straight away, for when the old one can't be used any more.
+func (g *TokenGuard) discard() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.token = ""
+	g.invalid = false
+	g.lastErr = nil
+}
+
+// InvalidProviderToken handles Apple rejecting token as invalid.
+// serverTime is the Date of Apple's response. Nothing is done if token
+// has been replaced since it was sent. If serverTime is more than
+// ClockSkewTolerance away from the local clock, the difference is added
+// to the issue time of every later token, the rejected token is dropped
+// right away (it can't succeed, so replacing it doesn't count against the
+// refresh limit) and EventClockSkewDetected is reported. Otherwise the
+// token is invalidated as with Invalidate.
+func (g *TokenGuard) InvalidProviderToken(token string, serverTime time.Time) {
+	skew := serverTime.Sub(time.Now())
+	if skew > -ClockSkewTolerance && skew < ClockSkewTolerance {
+		g.invalidate(token)
+		return
+	}
+
+	g.mu.Lock()
+	if token != g.token {
+		g.mu.Unlock()
+		return
+	}
+	g.skew = skew
+	g.token = ""
+	g.invalid = false
+	onEvent := g.OnEvent
+	g.mu.Unlock()
+
+	if onEvent != nil {
+		onEvent(Event{
+			Type:    EventClockSkewDetected,
+			Time:    time.Now(),
+			Message: "local clock is " + (-skew).String() + " off from APNS; adjusting token issue times",
+		})
+	}
+}
+
+// Skew returns the clock skew currently applied to token issue times.
+func (g *TokenGuard) Skew() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.skew
+}
+
+// IssuedAt returns when the cached token was issued.
+func (g *TokenGuard) IssuedAt() time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.issuedAt
+}
@@ -0,0 +1,136 @@
+package apns_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("TokenGuard", func() {
+	var generated int
+	var warnings []apns.TokenWarning
+	var g *apns.TokenGuard
+
+	BeforeEach(func() {
+		generated = 0
+		warnings = nil
+		g = apns.NewTokenGuard(func(issuedAt time.Time) (string, error) {
+			generated++
+			return "token", nil
+		})
+		g.OnWarning = func(w apns.TokenWarning) { warnings = append(warnings, w) }
+	})
+
+	Describe("#Token", func() {
+		It("should reuse the token", func() {
+			for i := 0; i < 3; i++ {
+				t, err := g.Token()
+				Expect(err).To(BeNil())
+				Expect(t).To(Equal("token"))
+			}
+			Expect(generated).To(Equal(1))
+		})
+
+		It("should not refresh more often than the minimum interval", func() {
+			g.RefreshAfter = time.Nanosecond
+
+			g.Token()
+			time.Sleep(time.Millisecond)
+			g.Token()
+
+			Expect(generated).To(Equal(1))
+			Expect(warnings).To(HaveLen(1))
+		})
+
+		It("should return generation errors when there is no token", func() {
+			g.Generate = func(time.Time) (string, error) { return "", errors.New("bad key") }
+
+			_, err := g.Token()
+			Expect(err).To(MatchError("bad key"))
+		})
+	})
+
+	Describe("#Invalidate", func() {
+		It("should let OnWarning use the guard", func() {
+			g.OnWarning = func(w apns.TokenWarning) {
+				warnings = append(warnings, w)
+				g.IssuedAt()
+			}
+
+			g.Token()
+			g.Invalidate()
+			Expect(warnings).To(HaveLen(1))
+		})
+
+		It("should defer refreshing a fresh token and warn", func() {
+			g.Token()
+			g.Invalidate()
+
+			t, _ := g.Token()
+			Expect(t).To(Equal("token"))
+			Expect(generated).To(Equal(1))
+			Expect(warnings).To(HaveLen(1))
+		})
+	})
+
+	Describe("#InvalidProviderToken", func() {
+		It("should offset issue times by the measured skew", func() {
+			var issued time.Time
+			g.Generate = func(issuedAt time.Time) (string, error) {
+				generated++
+				issued = issuedAt
+				return "token", nil
+			}
+
+			var events []apns.Event
+			g.OnEvent = func(e apns.Event) { events = append(events, e) }
+
+			t, _ := g.Token()
+			g.InvalidProviderToken(t, time.Now().Add(-2*time.Minute))
+
+			Expect(g.Skew()).To(BeNumerically("~", -2*time.Minute, time.Second))
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal(apns.EventClockSkewDetected))
+
+			g.Token()
+			Expect(generated).To(Equal(2))
+			Expect(time.Until(issued)).To(BeNumerically("~", -2*time.Minute, time.Second))
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("should ignore rejections of a token that was since replaced", func() {
+			g.Generate = func(time.Time) (string, error) {
+				generated++
+				return fmt.Sprint("token", generated), nil
+			}
+
+			old, _ := g.Token()
+			g.InvalidProviderToken(old, time.Now().Add(-2*time.Minute))
+			current, _ := g.Token()
+			Expect(current).NotTo(Equal(old))
+
+			// A request sent with the old token is rejected late.
+			g.InvalidProviderToken(old, time.Now().Add(-2*time.Minute))
+			g.InvalidProviderToken(old, time.Now())
+
+			t, _ := g.Token()
+			Expect(t).To(Equal(current))
+			Expect(generated).To(Equal(2))
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("should treat small differences as a plain invalidation", func() {
+			t, _ := g.Token()
+			g.InvalidProviderToken(t, time.Now())
+
+			Expect(g.Skew()).To(BeZero())
+			g.Token()
+			Expect(generated).To(Equal(1))
+			Expect(warnings).To(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package apns_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestApns(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Apns v2 Suite")
+}
@@ -0,0 +1,157 @@
+// Package apns is the v2 API of github.com/timehop/apns. It replaces
+// configuration by struct fields with options, and fire-and-forget sends
+// with Send, which takes a context and returns the notification's Result.
+//
+// The v1 package at github.com/timehop/apns keeps working unchanged; v2
+// clients drive a v1 client underneath, reachable with V1, so both can be
+// used while call sites move over:
+//
+//	c, err := apns.NewClient(v1.ProductionGateway, cert,
+//		apns.WithQueueSize(10000),
+//		apns.WithDefaultTopic("com.example.app"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer c.Close()
+//
+//	r, err := c.Send(ctx, n)
+package apns
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"time"
+
+	v1 "github.com/timehop/apns"
+)
+
+// Notification and Result are shared with v1, so values can be passed
+// between the two APIs.
+type (
+	Notification = v1.Notification
+	Result       = v1.Result
+)
+
+// Option configures a Client. Options are applied in order, before the
+// client's configuration is validated and before it starts.
+type Option func(*v1.Client)
+
+// WithQueueSize sets how many notifications Send accepts ahead of the
+// connection before it blocks.
+func WithQueueSize(n int) Option {
+	return func(c *v1.Client) { c.QueueSize = n }
+}
+
+// WithBufferSize sets how many written notifications are kept for
+// resending after an error.
+func WithBufferSize(n int) Option {
+	return func(c *v1.Client) { c.BufferSize = n }
+}
+
+// WithInFlight caps how many notifications may be written within window
+// before dispatch pauses. It also sets how long Send waits for APNS to
+// report an error before taking a notification as delivered.
+func WithInFlight(max int, window time.Duration) Option {
+	return func(c *v1.Client) {
+		c.MaxInFlight = max
+		c.InFlightWindow = window
+	}
+}
+
+// WithDefaultTopic sets the topic of notifications sent without one.
+func WithDefaultTopic(topic string) Option {
+	return func(c *v1.Client) { c.DefaultTopic = topic }
+}
+
+// WithRecoveryFile sets where Close writes the notifications it discards.
+func WithRecoveryFile(path string) Option {
+	return func(c *v1.Client) { c.RecoveryFile = path }
+}
+
+// WithLocalAddr binds connections to the given local addresses, one per
+// address family, for hosts that must egress through specific addresses.
+func WithLocalAddr(ips ...net.IP) Option {
+	return func(c *v1.Client) { c.Conn.LocalAddrs = ips }
+}
+
+// WithVerbose turns on logging.
+func WithVerbose() Option {
+	return func(c *v1.Client) { c.Verbose = true }
+}
+
+// WithSoftFail, for development and CI, keeps a client without working
+// credentials or a connection from holding up the application: Send
+// returns a Skipped Result instead of waiting, and NewClientWithFiles
+// accepts certificate files it can't load. See v1.Client.SoftFail.
+func WithSoftFail(on bool) Option {
+	return func(c *v1.Client) { c.SoftFail = on }
+}
+
+// Client sends notifications to APNS over one long-lived connection.
+type Client struct {
+	c *v1.Client
+}
+
+// NewClient creates a Client for the gateway gw that authenticates with
+// cert. It returns a *v1.ConfigError if the options don't add up.
+func NewClient(gw string, cert tls.Certificate, opts ...Option) (*Client, error) {
+	c, err := v1.NewClientWithConfig(gw, cert, func(c *v1.Client) error {
+		for _, opt := range opts {
+			opt(c)
+		}
+		return c.Validate()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Client{c: c}, nil
+}
+
+// NewClientWithFiles creates a Client from the certificate and key in the
+// specified files.
+func NewClientWithFiles(gw, certFile, keyFile string, opts ...Option) (*Client, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	c, cerr := NewClient(gw, cert, opts...)
+	switch {
+	case err != nil && (cerr != nil || !c.c.SoftFail):
+		if c != nil {
+			c.Close()
+		}
+		return nil, err
+	case cerr != nil:
+		return nil, cerr
+	case err != nil:
+		log.Printf("apns: can't load certificate, skipping every notification: %v", err)
+	}
+	return c, nil
+}
+
+// Send sends n and waits for its Result: a failure, or delivery once the
+// in-flight window has passed without one. The error is only set if ctx
+// is done first or the client is closed. If ctx is done while the queue is
+// full, n isn't sent; once queued, it may still be sent after ctx is done.
+func (c *Client) Send(ctx context.Context, n Notification) (Result, error) {
+	results := make(chan Result, 1)
+	if err := c.c.SendContext(ctx, n, v1.OnResult(func(r Result) { results <- r })); err != nil {
+		return Result{}, err
+	}
+
+	select {
+	case r := <-results:
+		return r, nil
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+// Close stops the client; see v1.Client.Close.
+func (c *Client) Close() error {
+	return c.c.Close()
+}
+
+// V1 returns the v1 client underneath, for features v2 doesn't cover yet.
+func (c *Client) V1() *v1.Client {
+	return c.c
+}
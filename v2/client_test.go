@@ -0,0 +1,157 @@
+package apns_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "github.com/timehop/apns"
+	"github.com/timehop/apns/v2"
+)
+
+func newCert() tls.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Apple Push Services: com.example.app"},
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+var _ = Describe("Client", func() {
+	Describe(".NewClient", func() {
+		It("should apply the options", func() {
+			c, err := apns.NewClient("127.0.0.1:1", newCert(),
+				apns.WithQueueSize(7),
+				apns.WithBufferSize(20),
+				apns.WithInFlight(10, time.Second),
+				apns.WithDefaultTopic("com.example.app"),
+				apns.WithRecoveryFile("/tmp/recovery"),
+				apns.WithLocalAddr(net.ParseIP("127.0.0.1")))
+			Expect(err).To(BeNil())
+			defer c.Close()
+
+			v := c.V1()
+			Expect(v.QueueSize).To(Equal(7))
+			Expect(v.BufferSize).To(Equal(20))
+			Expect(v.MaxInFlight).To(Equal(10))
+			Expect(v.InFlightWindow).To(Equal(time.Second))
+			Expect(v.DefaultTopic).To(Equal("com.example.app"))
+			Expect(v.RecoveryFile).To(Equal("/tmp/recovery"))
+			Expect(v.Conn.LocalAddrs).To(Equal([]net.IP{net.ParseIP("127.0.0.1")}))
+		})
+
+		It("should reject options that don't add up", func() {
+			c, err := apns.NewClient("127.0.0.1:1", newCert(),
+				apns.WithBufferSize(5),
+				apns.WithInFlight(10, time.Second))
+			Expect(c).To(BeNil())
+
+			var ce *v1.ConfigError
+			Expect(errors.As(err, &ce)).To(BeTrue())
+			Expect(ce.Problems[0].Field).To(Equal("MaxInFlight"))
+		})
+	})
+
+	Describe("WithQueueSize", func() {
+		It("should hold Send back once the queue is full", func() {
+			c, err := apns.NewClient("127.0.0.1:1", newCert(), apns.WithQueueSize(1))
+			Expect(err).To(BeNil())
+
+			// The gateway is unreachable, so the first notification stays
+			// queued and fills the queue.
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err = c.Send(ctx, v1.NewNotification())
+			Expect(err).To(Equal(context.DeadlineExceeded))
+
+			errs := make(chan error, 1)
+			go func() {
+				_, err := c.Send(context.Background(), v1.NewNotification())
+				errs <- err
+			}()
+			Consistently(errs, 100*time.Millisecond).ShouldNot(Receive())
+
+			c.Close()
+			Eventually(errs).Should(Receive(Equal(v1.ErrClosed)))
+		})
+
+		It("should stop waiting for room once the context is done", func() {
+			c, err := apns.NewClient("127.0.0.1:1", newCert(), apns.WithQueueSize(1))
+			Expect(err).To(BeNil())
+			defer c.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			c.Send(ctx, v1.NewNotification())
+
+			errs := make(chan error, 1)
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+				_, err := c.Send(ctx, v1.NewNotification())
+				errs <- err
+			}()
+			Eventually(errs).Should(Receive(Equal(context.DeadlineExceeded)))
+		})
+	})
+
+	Describe(".NewClientWithFiles", func() {
+		It("should fail on missing files unless soft-failing", func() {
+			_, err := apns.NewClientWithFiles("127.0.0.1:1", "/nonexistent.crt", "/nonexistent.key")
+			Expect(err).NotTo(BeNil())
+
+			c, err := apns.NewClientWithFiles("127.0.0.1:1", "/nonexistent.crt", "/nonexistent.key", apns.WithSoftFail(true))
+			Expect(err).To(BeNil())
+			c.Close()
+		})
+	})
+
+	Describe("#Send", func() {
+		It("should give up when the context is done", func() {
+			c, err := apns.NewClient("127.0.0.1:1", newCert())
+			Expect(err).To(BeNil())
+			defer c.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			_, err = c.Send(ctx, v1.NewNotification())
+			Expect(err).To(Equal(context.DeadlineExceeded))
+		})
+
+		It("should skip notifications it can't connect for in soft-fail mode", func() {
+			c, err := apns.NewClient("127.0.0.1:1", newCert(), apns.WithSoftFail(true))
+			Expect(err).To(BeNil())
+			defer c.Close()
+
+			n := v1.NewNotification()
+			n.DeviceToken = "9999999999999999999999999999999999999999999999999999999999999999"
+			r, err := c.Send(context.Background(), n)
+			Expect(err).To(BeNil())
+			Expect(r.Disposition).To(Equal(v1.Skipped))
+			Expect(c.V1().Skipped).To(Equal(1))
+		})
+
+		It("should return ErrClosed after Close", func() {
+			c, err := apns.NewClient("127.0.0.1:1", newCert())
+			Expect(err).To(BeNil())
+			c.Close()
+
+			_, err = c.Send(context.Background(), v1.NewNotification())
+			Expect(err).To(Equal(v1.ErrClosed))
+		})
+	})
+})
@@ -0,0 +1,15 @@
+package apns
+
+// NewVoIPNotification returns a VoIP push for the app with the given
+// bundle ID: topic with the .voip suffix, the voip push type and
+// PriorityImmediate. VoIP payloads may be up to MaxVoIPPayloadSize, which
+// ValidateLimits checks for; ValidatePushType checks the topic and
+// priority, should they be changed.
+func NewVoIPNotification(token, bundleID string) Notification {
+	n := NewNotification()
+	n.DeviceToken = token
+	n.PushType = PushTypeVoIP
+	n.Topic = bundleID + TopicSuffix(PushTypeVoIP)
+	n.Priority = PriorityImmediate
+	return n
+}
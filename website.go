@@ -0,0 +1,62 @@
+package apns
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrWebsitePushID is returned for website push IDs without the "web."
+// prefix Apple gives them.
+var ErrWebsitePushID = errors.New(`apns: website push IDs start with "web."`)
+
+// isWebsitePushID reports whether topic is a Safari website push ID.
+func isWebsitePushID(topic string) bool {
+	return strings.HasPrefix(topic, "web.")
+}
+
+// NewWebsiteNotification returns a legacy Safari website push for the
+// website with the given push ID, such as "web.com.example". Safari shows
+// title and body, and labels the button action, "View" if empty. urlArgs
+// fill in the placeholders of the urlFormatString in the website's push
+// package, so there must be as many as it has; url-args is sent even
+// when there are none, as Safari requires.
+func NewWebsiteNotification(token, websitePushID, title, body, action string, urlArgs ...string) Notification {
+	n := NewNotification()
+	n.DeviceToken = token
+	n.PushType = PushTypeAlert
+	n.Topic = websitePushID
+	n.Payload.APS.Alert = Alert{Title: title, Body: body, Action: action}
+	n.Payload.APS.URLArgs = urlArgs
+	if urlArgs == nil {
+		n.Payload.APS.URLArgs = []string{}
+	}
+	return n
+}
+
+// NewWebsiteClient2 creates a Client2 for Safari website pushes,
+// authenticating with the website push certificate cert and sending to
+// websitePushID by default.
+func NewWebsiteClient2(host string, cert tls.Certificate, websitePushID string) (*Client2, error) {
+	if !isWebsitePushID(websitePushID) {
+		return nil, ErrWebsitePushID
+	}
+
+	c := NewClient2(host, cert)
+	c.DefaultTopic = websitePushID
+	return c, nil
+}
+
+// validateWebsite checks the payload of a website push: Safari needs an
+// alert with a title and body, and url-args.
+func (n Notification) validateWebsite(aps apsDict) error {
+	var alert apsDict
+	if json.Unmarshal(aps["alert"], &alert) != nil || !alert.has("title") || !alert.has("body") {
+		return &PushTypeError{PushType: n.PushType, Reason: "website pushes need an alert title and body"}
+	}
+	if !aps.has("url-args") {
+		return &PushTypeError{PushType: n.PushType, Reason: "website pushes need url-args"}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package apns_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/timehop/apns"
+)
+
+var _ = Describe("Website push", func() {
+	const pushID = "web.com.example"
+
+	Describe(".NewWebsiteNotification", func() {
+		It("should build the payload Safari expects", func() {
+			n := apns.NewWebsiteNotification("abcd", pushID, "Sale", "50% off", "Shop", "sale", "42")
+
+			Expect(n.Topic).To(Equal(pushID))
+			Expect(n.ValidatePushType()).To(BeNil())
+			Expect(n.Lint()).To(BeNil())
+
+			b, err := json.Marshal(n.Payload)
+			Expect(err).To(BeNil())
+			Expect(b).To(MatchJSON(`{"aps":{"alert":{"title":"Sale","body":"50% off","action":"Shop"},"url-args":["sale","42"]}}`))
+		})
+
+		It("should send empty url-args", func() {
+			n := apns.NewWebsiteNotification("abcd", pushID, "Sale", "50% off", "")
+
+			b, err := json.Marshal(n.Payload)
+			Expect(err).To(BeNil())
+			Expect(b).To(MatchJSON(`{"aps":{"alert":{"title":"Sale","body":"50% off"},"url-args":[]}}`))
+		})
+
+		It("should need a title and url-args", func() {
+			n := apns.NewWebsiteNotification("abcd", pushID, "", "50% off", "")
+			Expect(n.ValidatePushType()).To(MatchError("alert push: website pushes need an alert title and body"))
+
+			n = apns.NewWebsiteNotification("abcd", pushID, "Sale", "50% off", "")
+			n.Payload.APS.URLArgs = nil
+			Expect(n.ValidatePushType()).To(MatchError("alert push: website pushes need url-args"))
+		})
+	})
+
+	Describe(".NewWebsiteClient2", func() {
+		It("should reject topics that aren't website push IDs", func() {
+			_, err := apns.NewWebsiteClient2("https://localhost", tls.Certificate{}, "com.example")
+			Expect(err).To(Equal(apns.ErrWebsitePushID))
+		})
+
+		It("should push to the website push ID", func() {
+			s := newMockHTTP2Server()
+			defer s.Close()
+
+			c, err := apns.NewWebsiteClient2(s.URL, tls.Certificate{}, pushID)
+			Expect(err).To(BeNil())
+
+			c.HTTPClient = newTestClient2(s).HTTPClient
+			n := apns.NewWebsiteNotification("abcd", "", "Sale", "50% off", "")
+			r, err := c.SendSync(context.Background(), n)
+			Expect(err).To(BeNil())
+			Expect(r.OK()).To(BeTrue())
+
+			Expect(s.requests[0].Header.Get("apns-topic")).To(Equal(pushID))
+		})
+	})
+})